@@ -12,9 +12,16 @@ import (
 	"time"
 
 	"github.com/devrimsoft/bug-notifications-api/internal/api"
+	"github.com/devrimsoft/bug-notifications-api/internal/apikey"
+	"github.com/devrimsoft/bug-notifications-api/internal/captcha"
 	"github.com/devrimsoft/bug-notifications-api/internal/config"
+	"github.com/devrimsoft/bug-notifications-api/internal/db"
 	"github.com/devrimsoft/bug-notifications-api/internal/middleware"
 	"github.com/devrimsoft/bug-notifications-api/internal/queue"
+	"github.com/devrimsoft/bug-notifications-api/internal/sites"
+	"github.com/devrimsoft/bug-notifications-api/internal/storage"
+	"github.com/devrimsoft/bug-notifications-api/internal/triage"
+	"github.com/devrimsoft/bug-notifications-api/internal/webhook"
 	"github.com/go-chi/chi/v5"
 	"github.com/redis/go-redis/v9"
 )
@@ -31,6 +38,12 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Sites can change while running: reload on SIGHUP (see config.Live)
+	live := config.NewLive(cfg)
+	reloadCtx, stopReload := context.WithCancel(context.Background())
+	defer stopReload()
+	go live.Run(reloadCtx)
+
 	// Redis
 	opts, err := redis.ParseURL(cfg.RedisURL)
 	if err != nil {
@@ -48,8 +61,46 @@ func main() {
 	}
 	cancel()
 
+	// PostgreSQL (status lookups and read/triage access for the admin API)
+	pool, err := db.Connect(context.Background(), cfg.DatabaseURL)
+	if err != nil {
+		slog.Error("database connection failed", "error", err)
+		os.Exit(1)
+	}
+	defer pool.Close()
+
+	// Run migrations: the sites sync below needs the schema, and the worker
+	// may not have started yet
+	if err := db.Migrate(context.Background(), pool); err != nil {
+		slog.Error("migration failed", "error", err)
+		os.Exit(1)
+	}
+
 	producer := queue.NewProducer(rdb)
-	handler := api.NewHandler(producer, cfg)
+	repo := db.NewRepository(pool)
+	images, err := storage.New(cfg)
+	if err != nil {
+		slog.Error("image storage init failed", "error", err)
+		os.Exit(1)
+	}
+	handler := api.NewHandler(producer, repo, captcha.New(live, rdb),
+		api.SiteLimiter(middleware.SiteRateLimiter(rdb, func(siteID string) int {
+			return live.Get().RateLimitFor(siteID)
+		}, cfg.TrustedProxies)), live)
+	keys := apikey.NewService(repo)
+
+	// Sites managed through the admin API, kept in sync across instances
+	siteSvc := sites.NewService(repo, rdb, live)
+	syncCtx, syncCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	err = siteSvc.Sync(syncCtx)
+	syncCancel()
+	if err != nil {
+		slog.Error("sites sync failed", "error", err)
+		os.Exit(1)
+	}
+	go siteSvc.Run(reloadCtx)
+
+	adminHandler := api.NewAdminHandler(repo, triage.NewService(repo, webhook.NewPublisher(repo, cfg)), siteSvc, live)
 
 	// Router
 	r := chi.NewRouter()
@@ -57,28 +108,72 @@ func main() {
 	// Global middleware
 	r.Use(middleware.SecureHeaders())
 	r.Use(middleware.RequireHTTPS())
-	r.Use(middleware.CORSMiddleware(cfg))
-	r.Use(middleware.BodyLimit(26 * 1024 * 1024)) // 26MB (5 images * 5MB + 1MB form data)
-	r.Use(middleware.RateLimit(rdb, cfg.RateLimitRPS, cfg.TrustedProxies))
+	r.Use(middleware.CORSMiddleware(live))
+	r.Use(middleware.BodyLimit(api.MaxRequestSize)) // all attachments + 1MB form data
+
+	// Server-to-server routes — per-site API key, rate limited per key, not
+	// browser-only. Sites with a signing secret must also sign each request.
+	// The per-IP limit runs first so unauthenticated floods never reach the
+	// key lookup. Only authenticated requests get the fixed upload deadline;
+	// browser reports get one that moves only while their body keeps arriving.
+	r.Route("/v1/server", func(r chi.Router) {
+		r.Use(middleware.RateLimit(rdb, cfg.RateLimitRPS, cfg.TrustedProxies))
+		r.Use(middleware.ServerAuth(keys.Authenticate))
+		r.Use(middleware.KeyRateLimit(rdb, cfg.ServerRateLimitRPS))
+		r.Use(middleware.UploadDeadline(api.UploadTimeout))
+		r.Use(middleware.SignedRequest(rdb, cfg.SigningSecretFor, cfg.SignatureMaxSkew))
+		r.Post("/reports", handler.CreateServerReport)
+	})
+
+	r.Group(func(r chi.Router) {
+		r.Use(middleware.RateLimit(rdb, cfg.RateLimitRPS, cfg.TrustedProxies))
 
-	// Health check (no auth required)
-	r.Get("/health", handler.HealthCheck)
+		// Health check (no auth required)
+		r.Get("/health", handler.HealthCheck)
 
-	// Frontend SPA — embedded dist/
-	handler.MountFrontend(r, frontendFS)
+		// Locally stored images — signed paths, no auth (linked from
+		// notifications and the admin API)
+		if local, ok := images.(*storage.Local); ok {
+			r.Handle(storage.LocalPathPrefix+"*", local)
+		}
 
-	// Protected routes
-	r.Route("/v1", func(r chi.Router) {
-		r.Use(middleware.BrowserOnly())
-		r.Get("/sites", handler.ListSites)
-		r.Post("/reports", handler.CreateReport)
+		// Frontend SPA — embedded dist/
+		handler.MountFrontend(r, frontendFS)
+
+		// Protected routes
+		r.Route("/v1", func(r chi.Router) {
+			r.Use(middleware.BrowserOnly())
+			r.Get("/sites", handler.ListSites)
+			r.With(middleware.UploadProgress(api.UploadIdleTimeout, api.UploadMinRate)).Post("/reports", handler.CreateReport)
+			r.Get("/reports/{event_id}/status", handler.ReportStatus)
+			r.Post("/captcha/challenge", handler.CaptchaChallenge)
+		})
+
+		// Admin routes — bearer token auth, not browser-only
+		if cfg.AdminEnabled() {
+			r.Route("/admin/v1", func(r chi.Router) {
+				r.Use(middleware.AdminAuth(cfg.AdminAPIKeys))
+				r.Get("/reports", adminHandler.ListReports)
+				r.Get("/reports/{id}", adminHandler.GetReport)
+				r.Patch("/reports/{id}/status", adminHandler.UpdateStatus)
+				r.Get("/reports/{id}/history", adminHandler.StatusHistory)
+				r.Get("/webhooks/deliveries", adminHandler.ListWebhookDeliveries)
+				r.Post("/webhooks/deliveries/{id}/replay", adminHandler.ReplayWebhookDelivery)
+				r.Get("/sites", adminHandler.ListSites)
+				r.Post("/sites", adminHandler.CreateSite)
+				r.Patch("/sites/{domain}", adminHandler.UpdateSite)
+				r.Delete("/sites/{domain}", adminHandler.DeactivateSite)
+				r.Get("/taxonomy", adminHandler.GetTaxonomy)
+				r.Put("/taxonomy/{kind}/{key}", adminHandler.SetTaxonomyEntry)
+			})
+		}
 	})
 
 	addr := fmt.Sprintf(":%d", cfg.Port)
 	srv := &http.Server{
 		Addr:         addr,
 		Handler:      r,
-		ReadTimeout:  10 * time.Second,
+		ReadTimeout:  10 * time.Second, // report routes extend it while uploading
 		WriteTimeout: 10 * time.Second,
 		IdleTimeout:  60 * time.Second,
 	}
@@ -89,13 +184,13 @@ func main() {
 
 	go func() {
 		if cfg.TLSEnabled() {
-			slog.Info("api server starting with TLS", "addr", addr, "sites", len(cfg.Sites))
+			slog.Info("api server starting with TLS", "addr", addr, "sites", len(live.Get().Sites))
 			if err := srv.ListenAndServeTLS(cfg.TLSCertFile, cfg.TLSKeyFile); err != nil && err != http.ErrServerClosed {
 				slog.Error("server error", "error", err)
 				os.Exit(1)
 			}
 		} else {
-			slog.Info("api server starting (TLS via reverse proxy expected)", "addr", addr, "sites", len(cfg.Sites))
+			slog.Info("api server starting (TLS via reverse proxy expected)", "addr", addr, "sites", len(live.Get().Sites))
 			if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 				slog.Error("server error", "error", err)
 				os.Exit(1)
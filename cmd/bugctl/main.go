@@ -0,0 +1,491 @@
+// Command bugctl is the operator CLI for the bug notifications service.
+//
+// Usage:
+//
+//	bugctl dlq list
+//	bugctl dlq show <event_id>
+//	bugctl dlq replay (--all | <event_id>)
+//	bugctl dlq purge --older-than <duration>
+//	bugctl quarantine list
+//	bugctl quarantine count
+//	bugctl migrate (up | down [--steps N] | status)
+//	bugctl keys list [--site <site_id>]
+//	bugctl keys create --site <site_id> [--name <name>] [--expires-in <age>]
+//	bugctl keys rotate --site <site_id> [--name <name>] [--overlap <age>]
+//	bugctl keys revoke <key_id>
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/devrimsoft/bug-notifications-api/internal/apikey"
+	"github.com/devrimsoft/bug-notifications-api/internal/db"
+	"github.com/devrimsoft/bug-notifications-api/internal/model"
+	"github.com/devrimsoft/bug-notifications-api/internal/queue"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/redis/go-redis/v9"
+)
+
+const usage = `usage: bugctl <command> [arguments]
+
+commands:
+  dlq list                          list dead-lettered messages
+  dlq show <event_id>               print a dead-lettered message as JSON
+  dlq replay (--all | <event_id>)   reset retries and push back to the queue
+  dlq purge --older-than <age>      delete messages that failed before now-age (e.g. 72h, 7d)
+  quarantine list                   list undecodable payloads with their decode errors
+  quarantine count                  print the number of quarantined payloads
+  migrate up                        apply all pending database migrations
+  migrate down [--steps N]          revert the last N applied migrations (default 1)
+  migrate status                    list migrations and when they were applied
+  keys list [--site S]              list server API keys
+  keys create --site S [--name N] [--expires-in AGE]
+                                    issue a server API key for site S (printed once)
+  keys rotate --site S [--name N] [--overlap AGE]
+                                    issue a new key for site S; its other keys expire
+                                    after the overlap (default 24h)
+  keys revoke <key_id>              disable a key immediately
+
+environment:
+  REDIS_URL      Redis connection string (default redis://localhost:6379)
+  DATABASE_URL   PostgreSQL connection string (required for migrate and keys)
+`
+
+// errUsage signals a command-line mistake; main prints usage and exits 2.
+var errUsage = errors.New("invalid usage")
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := run(ctx, os.Args[1:]); err != nil {
+		if errors.Is(err, errUsage) {
+			fmt.Fprint(os.Stderr, usage)
+			os.Exit(2)
+		}
+		fmt.Fprintln(os.Stderr, "bugctl:", err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, args []string) error {
+	if len(args) < 1 {
+		return errUsage
+	}
+
+	switch args[0] {
+	case "dlq":
+		return runDLQ(ctx, args[1:])
+	case "quarantine":
+		return runQuarantine(ctx, args[1:])
+	case "migrate":
+		return runMigrate(ctx, args[1:])
+	case "keys":
+		return runKeys(ctx, args[1:])
+	case "help", "-h", "--help":
+		fmt.Print(usage)
+		return nil
+	default:
+		return errUsage
+	}
+}
+
+func runDLQ(ctx context.Context, args []string) error {
+	if len(args) < 1 {
+		return errUsage
+	}
+
+	rdb, err := connectRedis(ctx)
+	if err != nil {
+		return err
+	}
+	defer rdb.Close()
+	dlq := queue.NewDLQ(rdb)
+
+	switch args[0] {
+	case "list":
+		return dlqList(ctx, dlq)
+	case "show":
+		if len(args) != 2 {
+			return errUsage
+		}
+		return dlqShow(ctx, dlq, args[1])
+	case "replay":
+		fs := flag.NewFlagSet("dlq replay", flag.ContinueOnError)
+		all := fs.Bool("all", false, "replay every message in the DLQ")
+		if err := fs.Parse(args[1:]); err != nil {
+			return errUsage
+		}
+		if *all == (fs.NArg() == 1) || fs.NArg() > 1 {
+			return errUsage
+		}
+		return dlqReplay(ctx, dlq, *all, fs.Arg(0))
+	case "purge":
+		fs := flag.NewFlagSet("dlq purge", flag.ContinueOnError)
+		olderThan := fs.String("older-than", "", "age, e.g. 72h or 7d")
+		if err := fs.Parse(args[1:]); err != nil {
+			return errUsage
+		}
+		if *olderThan == "" || fs.NArg() != 0 {
+			return errUsage
+		}
+		age, err := parseAge(*olderThan)
+		if err != nil {
+			return err
+		}
+		return dlqPurge(ctx, dlq, age)
+	default:
+		return errUsage
+	}
+}
+
+func dlqList(ctx context.Context, dlq *queue.DLQ) error {
+	entries, err := dlq.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "EVENT_ID\tSITE_ID\tRETRIES\tFAILED_AT\tLAST_ERROR")
+	for _, e := range entries {
+		if e.Message == nil {
+			fmt.Fprintf(tw, "(undecodable)\t-\t-\t-\t%s\n", truncate(e.Raw, 60))
+			continue
+		}
+		m := e.Message
+		failedAt := "-"
+		if t := e.FailedAt(); !t.IsZero() {
+			failedAt = t.Format(time.RFC3339)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%s\n", m.EventID, m.SiteID, m.RetryCount, failedAt, truncate(m.LastError, 60))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	fmt.Printf("%d message(s)\n", len(entries))
+	return nil
+}
+
+func dlqShow(ctx context.Context, dlq *queue.DLQ, eventID string) error {
+	entry, err := dlq.Find(ctx, eventID)
+	if err != nil {
+		return err
+	}
+	if entry == nil {
+		return fmt.Errorf("event %s not found in DLQ", eventID)
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(entry.Message)
+}
+
+func dlqReplay(ctx context.Context, dlq *queue.DLQ, all bool, eventID string) error {
+	if all {
+		n, err := dlq.ReplayAll(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("replayed %d message(s)\n", n)
+		return nil
+	}
+
+	ok, err := dlq.Replay(ctx, eventID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("event %s not found in DLQ", eventID)
+	}
+	fmt.Printf("replayed %s\n", eventID)
+	return nil
+}
+
+func dlqPurge(ctx context.Context, dlq *queue.DLQ, age time.Duration) error {
+	n, err := dlq.Purge(ctx, time.Now().Add(-age))
+	if err != nil {
+		return err
+	}
+	fmt.Printf("purged %d message(s)\n", n)
+	return nil
+}
+
+func runQuarantine(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+
+	rdb, err := connectRedis(ctx)
+	if err != nil {
+		return err
+	}
+	defer rdb.Close()
+	q := queue.NewQuarantine(rdb)
+
+	switch args[0] {
+	case "list":
+		entries, err := q.List(ctx)
+		if err != nil {
+			return err
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "STREAM_ID\tQUARANTINED_AT\tERROR\tRAW")
+		for _, e := range entries {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", e.StreamID, e.QuarantinedAt, truncate(e.Error, 60), truncate(e.Raw, 60))
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+		fmt.Printf("%d payload(s)\n", len(entries))
+		return nil
+	case "count":
+		n, err := q.Length(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Println(n)
+		return nil
+	default:
+		return errUsage
+	}
+}
+
+func runMigrate(ctx context.Context, args []string) error {
+	if len(args) < 1 {
+		return errUsage
+	}
+
+	pool, err := connectDB(ctx)
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+
+	switch args[0] {
+	case "up":
+		if len(args) != 1 {
+			return errUsage
+		}
+		return db.Migrate(ctx, pool)
+	case "down":
+		fs := flag.NewFlagSet("migrate down", flag.ContinueOnError)
+		steps := fs.Int("steps", 1, "number of migrations to revert")
+		if err := fs.Parse(args[1:]); err != nil || fs.NArg() != 0 || *steps < 1 {
+			return errUsage
+		}
+		return db.MigrateDown(ctx, pool, *steps)
+	case "status":
+		if len(args) != 1 {
+			return errUsage
+		}
+		states, initialized, err := db.MigrationStatus(ctx, pool)
+		if err != nil {
+			return err
+		}
+		if !initialized {
+			fmt.Println("database not initialised (no schema_migrations table); run bugctl migrate up")
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "VERSION\tNAME\tAPPLIED_AT")
+		for _, st := range states {
+			appliedAt := "pending"
+			if st.AppliedAt != nil {
+				appliedAt = st.AppliedAt.UTC().Format(time.RFC3339)
+			}
+			fmt.Fprintf(tw, "%03d\t%s\t%s\n", st.Version, st.Name, appliedAt)
+		}
+		return tw.Flush()
+	default:
+		return errUsage
+	}
+}
+
+func runKeys(ctx context.Context, args []string) error {
+	if len(args) < 1 {
+		return errUsage
+	}
+
+	fs := flag.NewFlagSet("keys "+args[0], flag.ContinueOnError)
+	site := fs.String("site", "", "site_id the key is bound to")
+	name := fs.String("name", "", "label to tell keys apart")
+	expiresIn := fs.String("expires-in", "", "key lifetime, e.g. 90d (default: no expiry)")
+	overlap := fs.String("overlap", "24h", "how long old keys stay valid after a rotation")
+	if err := fs.Parse(args[1:]); err != nil {
+		return errUsage
+	}
+
+	switch args[0] {
+	case "list":
+		if fs.NArg() != 0 {
+			return errUsage
+		}
+	case "create", "rotate":
+		if *site == "" || fs.NArg() != 0 {
+			return errUsage
+		}
+	case "revoke":
+		if fs.NArg() != 1 {
+			return errUsage
+		}
+	default:
+		return errUsage
+	}
+
+	pool, err := connectDB(ctx)
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+	repo := db.NewRepository(pool)
+	keys := apikey.NewService(repo)
+
+	switch args[0] {
+	case "list":
+		list, err := repo.ListSiteAPIKeys(ctx, strings.ToLower(*site))
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "ID\tSITE_ID\tNAME\tPREFIX\tSTATE\tEXPIRES_AT\tLAST_USED_AT")
+		for _, k := range list {
+			state := "active"
+			switch {
+			case k.RevokedAt != nil:
+				state = "revoked"
+			case !k.Active(now):
+				state = "expired"
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", k.ID, k.SiteID, orDash(k.Name), k.Prefix, state, formatTime(k.ExpiresAt), formatTime(k.LastUsedAt))
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+		fmt.Printf("%d key(s)\n", len(list))
+		return nil
+	case "create":
+		var expiresAt *time.Time
+		if *expiresIn != "" {
+			age, err := parseAge(*expiresIn)
+			if err != nil {
+				return err
+			}
+			t := time.Now().UTC().Add(age)
+			expiresAt = &t
+		}
+		k, key, err := keys.Create(ctx, *site, *name, expiresAt)
+		if err != nil {
+			return err
+		}
+		printNewKey(k, key)
+		return nil
+	case "rotate":
+		age, err := parseAge(*overlap)
+		if err != nil {
+			return err
+		}
+		k, key, n, err := keys.Rotate(ctx, *site, *name, age)
+		if k != nil {
+			printNewKey(k, key)
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%d older key(s) of %s expire at %s\n", n, k.SiteID, time.Now().UTC().Add(age).Format(time.RFC3339))
+		return nil
+	default: // revoke
+		ok, err := repo.RevokeSiteAPIKey(ctx, fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("key %s not found or already revoked", fs.Arg(0))
+		}
+		fmt.Printf("revoked %s\n", fs.Arg(0))
+		return nil
+	}
+}
+
+func printNewKey(k *model.SiteAPIKey, key string) {
+	fmt.Printf("id:      %s\nsite_id: %s\nkey:     %s\n", k.ID, k.SiteID, key)
+	fmt.Println("store the key now; it cannot be shown again")
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func connectDB(ctx context.Context) (*pgxpool.Pool, error) {
+	databaseURL := os.Getenv("DATABASE_URL")
+	if databaseURL == "" {
+		return nil, errors.New("DATABASE_URL is required")
+	}
+	return db.Connect(ctx, databaseURL)
+}
+
+func connectRedis(ctx context.Context) (*redis.Client, error) {
+	redisURL := os.Getenv("REDIS_URL")
+	if redisURL == "" {
+		redisURL = "redis://localhost:6379"
+	}
+	opts, err := redis.ParseURL(redisURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid REDIS_URL: %w", err)
+	}
+	rdb := redis.NewClient(opts)
+
+	pingCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if err := rdb.Ping(pingCtx).Err(); err != nil {
+		rdb.Close()
+		return nil, fmt.Errorf("redis ping failed: %w", err)
+	}
+	return rdb, nil
+}
+
+// parseAge accepts Go durations plus a whole-day suffix ("7d").
+func parseAge(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid age %q", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid age %q", s)
+	}
+	return d, nil
+}
+
+// truncate shortens s to at most n runes on one line, so multi-byte
+// characters are never cut in half.
+func truncate(s string, n int) string {
+	r := []rune(strings.ReplaceAll(s, "\n", " "))
+	if len(r) <= n {
+		return string(r)
+	}
+	return string(r[:n-3]) + "..."
+}
@@ -11,7 +11,12 @@ import (
 
 	"github.com/devrimsoft/bug-notifications-api/internal/config"
 	"github.com/devrimsoft/bug-notifications-api/internal/db"
+	"github.com/devrimsoft/bug-notifications-api/internal/email"
+	"github.com/devrimsoft/bug-notifications-api/internal/notify"
 	"github.com/devrimsoft/bug-notifications-api/internal/queue"
+	"github.com/devrimsoft/bug-notifications-api/internal/sites"
+	"github.com/devrimsoft/bug-notifications-api/internal/storage"
+	"github.com/devrimsoft/bug-notifications-api/internal/webhook"
 	"github.com/devrimsoft/bug-notifications-api/internal/worker"
 	"github.com/redis/go-redis/v9"
 )
@@ -28,6 +33,10 @@ func main() {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	// Sites can change while running: reload on SIGHUP (see config.Live)
+	live := config.NewLive(cfg)
+	go live.Run(ctx)
+
 	// Redis
 	opts, err := redis.ParseURL(cfg.RedisURL)
 	if err != nil {
@@ -60,7 +69,31 @@ func main() {
 	}
 
 	repo := db.NewRepository(pool)
-	consumer := queue.NewConsumer(rdb)
+
+	// Sites managed through the admin API, kept in sync with the API
+	siteSvc := sites.NewService(repo, rdb, live)
+	if err := siteSvc.Sync(ctx); err != nil {
+		slog.Error("sites sync failed", "error", err)
+		os.Exit(1)
+	}
+	images, err := storage.New(cfg)
+	if err != nil {
+		slog.Error("image storage init failed", "error", err)
+		os.Exit(1)
+	}
+	hooks := webhook.NewPublisher(repo, cfg)
+	mail := email.NewPublisher(repo, cfg)
+	notifier := notify.New(live, rdb)
+	consumer := queue.NewConsumer(rdb, queue.RetryPolicy{
+		MaxRetry:  cfg.RetryMaxAttempts,
+		BaseDelay: cfg.RetryBaseDelay,
+		MaxDelay:  cfg.RetryMaxDelay,
+		Jitter:    cfg.RetryJitter,
+	})
+	if err := consumer.Setup(ctx); err != nil {
+		slog.Error("queue setup failed", "error", err)
+		os.Exit(1)
+	}
 
 	// Start workers
 	var wg sync.WaitGroup
@@ -71,12 +104,52 @@ func main() {
 		go func(id int) {
 			defer wg.Done()
 			slog.Info("worker started", "worker_id", id)
-			w := worker.New(consumer, repo)
+			w := worker.New(consumer, repo, images, hooks, mail, notifier)
 			w.Run(ctx)
 			slog.Info("worker stopped", "worker_id", id)
 		}(i)
 	}
 
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		siteSvc.Run(ctx)
+	}()
+
+	// Move delayed retries back onto the queue when due
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		worker.PromoteRetries(ctx, consumer)
+	}()
+
+	// Outbound webhook delivery
+	if len(cfg.WebhookEndpoints) > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			webhook.NewDispatcher(repo, cfg).Run(ctx)
+		}()
+	}
+
+	// Email to site owners (instant and digest)
+	if mail.Enabled() {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			email.NewScheduler(repo, cfg).Run(ctx)
+		}()
+	}
+
+	// Delete stored images no report references
+	if cleaner, ok := images.(storage.Cleaner); ok {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			worker.NewReconciler(rdb, repo, cleaner, live).Run(ctx)
+		}()
+	}
+
 	// Wait for shutdown signal
 	done := make(chan os.Signal, 1)
 	signal.Notify(done, os.Interrupt, syscall.SIGTERM)
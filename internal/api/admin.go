@@ -0,0 +1,560 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/devrimsoft/bug-notifications-api/internal/config"
+	"github.com/devrimsoft/bug-notifications-api/internal/db"
+	"github.com/devrimsoft/bug-notifications-api/internal/middleware"
+	"github.com/devrimsoft/bug-notifications-api/internal/model"
+	"github.com/devrimsoft/bug-notifications-api/internal/sites"
+	"github.com/devrimsoft/bug-notifications-api/internal/triage"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+const (
+	DefaultPageSize = 50
+	MaxPageSize     = 200
+	// MaxPage keeps the offset of the last page from overflowing.
+	MaxPage = math.MaxInt / MaxPageSize
+
+	MaxNoteLength = 2000
+)
+
+// AdminHandler serves the authenticated /admin/v1 API for reading and
+// triaging stored reports and managing sites and the report taxonomy.
+type AdminHandler struct {
+	repo   *db.Repository
+	triage *triage.Service
+	sites  *sites.Service
+	live   *config.Live
+}
+
+func NewAdminHandler(repo *db.Repository, svc *triage.Service, siteSvc *sites.Service, live *config.Live) *AdminHandler {
+	return &AdminHandler{repo: repo, triage: svc, sites: siteSvc, live: live}
+}
+
+// ListReports handles GET /admin/v1/reports
+// Query params: site_id, report_type, category, status, from, to, page, per_page.
+// from/to accept RFC 3339 timestamps or YYYY-MM-DD dates. A to timestamp is
+// exclusive; a to date includes the whole day.
+func (h *AdminHandler) ListReports(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	filter := db.ReportFilter{
+		SiteID:     q.Get("site_id"),
+		ReportType: q.Get("report_type"),
+		Category:   q.Get("category"),
+		Status:     q.Get("status"),
+	}
+
+	// Inactive report types and categories still match stored reports
+	tax := h.live.Get().Taxonomy
+	if filter.ReportType != "" && !tax.ReportTypeExists(filter.ReportType) {
+		writeJSON(w, http.StatusBadRequest, model.ErrorResponse{
+			Error: fmt.Sprintf("invalid report_type %q", filter.ReportType),
+			Code:  "INVALID_FILTER",
+		})
+		return
+	}
+	if filter.Category != "" && !tax.CategoryExists(filter.Category) {
+		writeJSON(w, http.StatusBadRequest, model.ErrorResponse{
+			Error: fmt.Sprintf("invalid category %q", filter.Category),
+			Code:  "INVALID_FILTER",
+		})
+		return
+	}
+	if filter.Status != "" && !model.ValidStatuses[model.ReportStatus(filter.Status)] {
+		writeJSON(w, http.StatusBadRequest, model.ErrorResponse{
+			Error: fmt.Sprintf("invalid status %q", filter.Status),
+			Code:  "INVALID_FILTER",
+		})
+		return
+	}
+
+	var err error
+	if filter.From, err = parseTimeParam(q.Get("from"), false); err != nil {
+		writeJSON(w, http.StatusBadRequest, model.ErrorResponse{
+			Error: "from must be an RFC 3339 timestamp or YYYY-MM-DD date",
+			Code:  "INVALID_FILTER",
+		})
+		return
+	}
+	if filter.To, err = parseTimeParam(q.Get("to"), true); err != nil {
+		writeJSON(w, http.StatusBadRequest, model.ErrorResponse{
+			Error: "to must be an RFC 3339 timestamp or YYYY-MM-DD date",
+			Code:  "INVALID_FILTER",
+		})
+		return
+	}
+	if !filter.From.IsZero() && !filter.To.IsZero() && !filter.From.Before(filter.To) {
+		writeJSON(w, http.StatusBadRequest, model.ErrorResponse{
+			Error: "from must be before to",
+			Code:  "INVALID_FILTER",
+		})
+		return
+	}
+
+	page, perPage, ok := pageParams(w, r)
+	if !ok {
+		return
+	}
+	filter.Limit = perPage
+	filter.Offset = (page - 1) * perPage
+
+	reports, total, err := h.repo.ListReports(r.Context(), filter)
+	if err != nil {
+		slog.Error("list reports failed", "error", err)
+		writeJSON(w, http.StatusInternalServerError, model.ErrorResponse{
+			Error: "failed to list reports",
+			Code:  "DB_ERROR",
+		})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, model.ReportListResponse{
+		Reports: reports,
+		Total:   total,
+		Page:    page,
+		PerPage: perPage,
+	})
+}
+
+// GetReport handles GET /admin/v1/reports/{id}
+func (h *AdminHandler) GetReport(w http.ResponseWriter, r *http.Request) {
+	id, ok := idParam(w, r)
+	if !ok {
+		return
+	}
+
+	report, err := h.repo.GetReport(r.Context(), id)
+	if err != nil {
+		slog.Error("get report failed", "error", err, "id", id)
+		writeJSON(w, http.StatusInternalServerError, model.ErrorResponse{
+			Error: "failed to load report",
+			Code:  "DB_ERROR",
+		})
+		return
+	}
+	if report == nil {
+		writeJSON(w, http.StatusNotFound, model.ErrorResponse{
+			Error: "report not found",
+			Code:  "NOT_FOUND",
+		})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, report)
+}
+
+// UpdateStatus handles PATCH /admin/v1/reports/{id}/status
+// Body: {"status": "...", "note": "..."}; only status is required. The change
+// is recorded as made by the authenticated admin.
+// Changes outside the lifecycle in the triage package are rejected with 409 INVALID_TRANSITION.
+func (h *AdminHandler) UpdateStatus(w http.ResponseWriter, r *http.Request) {
+	id, ok := idParam(w, r)
+	if !ok {
+		return
+	}
+
+	var req model.StatusUpdateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, model.ErrorResponse{
+			Error: "invalid JSON body",
+			Code:  "INVALID_JSON",
+		})
+		return
+	}
+	if !model.ValidStatuses[req.Status] {
+		writeJSON(w, http.StatusUnprocessableEntity, model.ErrorResponse{
+			Error: fmt.Sprintf("invalid status %q", req.Status),
+			Code:  "INVALID_STATUS",
+		})
+		return
+	}
+	if req.Note != nil && utf8.RuneCountInString(*req.Note) > MaxNoteLength {
+		writeJSON(w, http.StatusUnprocessableEntity, model.ErrorResponse{
+			Error: fmt.Sprintf("note must be at most %d characters", MaxNoteLength),
+			Code:  "VALIDATION_ERROR",
+		})
+		return
+	}
+
+	report, err := h.triage.ChangeStatus(r.Context(), id, triage.Change{
+		Status:    req.Status,
+		ChangedBy: middleware.AdminFromContext(r.Context()),
+		Note:      req.Note,
+	})
+	var transitionErr *triage.TransitionError
+	switch {
+	case err == nil:
+		writeJSON(w, http.StatusOK, report)
+	case errors.Is(err, triage.ErrNotFound):
+		writeJSON(w, http.StatusNotFound, model.ErrorResponse{
+			Error: "report not found",
+			Code:  "NOT_FOUND",
+		})
+	case errors.As(err, &transitionErr):
+		writeJSON(w, http.StatusConflict, model.ErrorResponse{
+			Error: transitionErr.Error(),
+			Code:  "INVALID_TRANSITION",
+		})
+	default:
+		slog.Error("update report status failed", "error", err, "id", id)
+		writeJSON(w, http.StatusInternalServerError, model.ErrorResponse{
+			Error: "failed to update report",
+			Code:  "DB_ERROR",
+		})
+	}
+}
+
+// StatusHistory handles GET /admin/v1/reports/{id}/history
+func (h *AdminHandler) StatusHistory(w http.ResponseWriter, r *http.Request) {
+	id, ok := idParam(w, r)
+	if !ok {
+		return
+	}
+
+	history, err := h.triage.History(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, triage.ErrNotFound) {
+			writeJSON(w, http.StatusNotFound, model.ErrorResponse{
+				Error: "report not found",
+				Code:  "NOT_FOUND",
+			})
+			return
+		}
+		slog.Error("list status history failed", "error", err, "id", id)
+		writeJSON(w, http.StatusInternalServerError, model.ErrorResponse{
+			Error: "failed to load status history",
+			Code:  "DB_ERROR",
+		})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, model.StatusHistoryResponse{History: history})
+}
+
+// ListWebhookDeliveries handles GET /admin/v1/webhooks/deliveries
+// Query params: status, site_id, endpoint_id, report_id, page, per_page.
+func (h *AdminHandler) ListWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	filter := db.DeliveryFilter{
+		Status:     q.Get("status"),
+		SiteID:     q.Get("site_id"),
+		EndpointID: q.Get("endpoint_id"),
+		ReportID:   q.Get("report_id"),
+	}
+	if filter.Status != "" && !model.ValidDeliveryStatuses[model.DeliveryStatus(filter.Status)] {
+		writeJSON(w, http.StatusBadRequest, model.ErrorResponse{
+			Error: fmt.Sprintf("invalid status %q", filter.Status),
+			Code:  "INVALID_FILTER",
+		})
+		return
+	}
+	if filter.ReportID != "" {
+		if _, err := uuid.Parse(filter.ReportID); err != nil {
+			writeJSON(w, http.StatusBadRequest, model.ErrorResponse{
+				Error: "invalid report_id",
+				Code:  "INVALID_FILTER",
+			})
+			return
+		}
+	}
+
+	page, perPage, ok := pageParams(w, r)
+	if !ok {
+		return
+	}
+	filter.Limit = perPage
+	filter.Offset = (page - 1) * perPage
+
+	deliveries, total, err := h.repo.ListWebhookDeliveries(r.Context(), filter)
+	if err != nil {
+		slog.Error("list webhook deliveries failed", "error", err)
+		writeJSON(w, http.StatusInternalServerError, model.ErrorResponse{
+			Error: "failed to list webhook deliveries",
+			Code:  "DB_ERROR",
+		})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, model.DeliveryListResponse{
+		Deliveries: deliveries,
+		Total:      total,
+		Page:       page,
+		PerPage:    perPage,
+	})
+}
+
+// ReplayWebhookDelivery handles POST /admin/v1/webhooks/deliveries/{id}/replay
+// Resets the delivery to pending with a fresh attempt budget.
+func (h *AdminHandler) ReplayWebhookDelivery(w http.ResponseWriter, r *http.Request) {
+	id, ok := idParam(w, r)
+	if !ok {
+		return
+	}
+
+	found, err := h.repo.ReplayWebhookDelivery(r.Context(), id)
+	if err != nil {
+		slog.Error("replay webhook delivery failed", "error", err, "id", id)
+		writeJSON(w, http.StatusInternalServerError, model.ErrorResponse{
+			Error: "failed to replay webhook delivery",
+			Code:  "DB_ERROR",
+		})
+		return
+	}
+	if !found {
+		writeJSON(w, http.StatusNotFound, model.ErrorResponse{
+			Error: "webhook delivery not found",
+			Code:  "NOT_FOUND",
+		})
+		return
+	}
+
+	delivery, err := h.repo.GetWebhookDelivery(r.Context(), id)
+	if err != nil || delivery == nil {
+		slog.Error("reload webhook delivery failed", "error", err, "id", id)
+		writeJSON(w, http.StatusInternalServerError, model.ErrorResponse{
+			Error: "failed to load webhook delivery",
+			Code:  "DB_ERROR",
+		})
+		return
+	}
+
+	slog.Info("webhook delivery replayed", "id", id)
+	writeJSON(w, http.StatusAccepted, delivery)
+}
+
+// ListSites handles GET /admin/v1/sites
+// Returns the rows of the sites table, including inactive sites.
+func (h *AdminHandler) ListSites(w http.ResponseWriter, r *http.Request) {
+	list, err := h.sites.List(r.Context())
+	if err != nil {
+		slog.Error("list sites failed", "error", err)
+		writeJSON(w, http.StatusInternalServerError, model.ErrorResponse{
+			Error: "failed to list sites",
+			Code:  "DB_ERROR",
+		})
+		return
+	}
+	writeJSON(w, http.StatusOK, model.SiteListResponse{Sites: list})
+}
+
+// CreateSite handles POST /admin/v1/sites
+// Body: {"domain": "...", "name": "...", "active": true, "settings": {...}};
+// only domain is required. settings takes the fields of a SITES_FILE entry.
+func (h *AdminHandler) CreateSite(w http.ResponseWriter, r *http.Request) {
+	var req model.SiteCreateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, model.ErrorResponse{
+			Error: "invalid JSON body",
+			Code:  "INVALID_JSON",
+		})
+		return
+	}
+
+	site, err := h.sites.Create(r.Context(), req)
+	if err != nil {
+		writeSiteError(w, err, req.Domain)
+		return
+	}
+	slog.Info("site created", "site_id", site.Domain)
+	writeJSON(w, http.StatusCreated, site)
+}
+
+// UpdateSite handles PATCH /admin/v1/sites/{domain}
+// Body: {"name": "...", "active": false, "settings": {...}}; omitted fields
+// are left alone. "settings": null falls back to SITES_FILE or the environment.
+func (h *AdminHandler) UpdateSite(w http.ResponseWriter, r *http.Request) {
+	domain := strings.ToLower(chi.URLParam(r, "domain"))
+
+	var req model.SiteUpdateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, model.ErrorResponse{
+			Error: "invalid JSON body",
+			Code:  "INVALID_JSON",
+		})
+		return
+	}
+
+	site, err := h.sites.Update(r.Context(), domain, req)
+	if err != nil {
+		writeSiteError(w, err, domain)
+		return
+	}
+	slog.Info("site updated", "site_id", site.Domain, "active", site.Active)
+	writeJSON(w, http.StatusOK, site)
+}
+
+// DeactivateSite handles DELETE /admin/v1/sites/{domain}
+// The site stops accepting reports; its row and reports are kept.
+func (h *AdminHandler) DeactivateSite(w http.ResponseWriter, r *http.Request) {
+	domain := strings.ToLower(chi.URLParam(r, "domain"))
+
+	site, err := h.sites.Deactivate(r.Context(), domain)
+	if err != nil {
+		writeSiteError(w, err, domain)
+		return
+	}
+	slog.Info("site deactivated", "site_id", site.Domain)
+	writeJSON(w, http.StatusOK, site)
+}
+
+// GetTaxonomy handles GET /admin/v1/taxonomy
+// Returns all report types and categories, including inactive ones.
+func (h *AdminHandler) GetTaxonomy(w http.ResponseWriter, r *http.Request) {
+	tax, err := h.sites.Taxonomy(r.Context())
+	if err != nil {
+		slog.Error("load taxonomy failed", "error", err)
+		writeJSON(w, http.StatusInternalServerError, model.ErrorResponse{
+			Error: "failed to load taxonomy",
+			Code:  "DB_ERROR",
+		})
+		return
+	}
+	writeJSON(w, http.StatusOK, tax)
+}
+
+// SetTaxonomyEntry handles PUT /admin/v1/taxonomy/{kind}/{key}
+// kind is report_types or categories.
+// Body: {"labels": {"en": "...", "tr": "..."}, "position": 1, "active": true}
+func (h *AdminHandler) SetTaxonomyEntry(w http.ResponseWriter, r *http.Request) {
+	kind, key := chi.URLParam(r, "kind"), chi.URLParam(r, "key")
+
+	var req model.TaxonomyEntryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, model.ErrorResponse{
+			Error: "invalid JSON body",
+			Code:  "INVALID_JSON",
+		})
+		return
+	}
+
+	entry, err := h.sites.SetTaxonomyEntry(r.Context(), kind, key, req)
+	if err != nil {
+		var invalid *sites.InvalidError
+		switch {
+		case errors.Is(err, sites.ErrUnknownKind):
+			writeJSON(w, http.StatusNotFound, model.ErrorResponse{
+				Error: "unknown taxonomy kind, must be report_types or categories",
+				Code:  "NOT_FOUND",
+			})
+		case errors.As(err, &invalid):
+			writeJSON(w, http.StatusUnprocessableEntity, model.ErrorResponse{
+				Error: invalid.Error(),
+				Code:  "VALIDATION_ERROR",
+			})
+		default:
+			slog.Error("taxonomy change failed", "error", err, "kind", kind, "key", key)
+			writeJSON(w, http.StatusInternalServerError, model.ErrorResponse{
+				Error: "failed to save taxonomy entry",
+				Code:  "DB_ERROR",
+			})
+		}
+		return
+	}
+	slog.Info("taxonomy entry saved", "kind", kind, "key", entry.Key, "active", entry.Active)
+	writeJSON(w, http.StatusOK, entry)
+}
+
+// writeSiteError maps an error of the sites service to a response.
+func writeSiteError(w http.ResponseWriter, err error, domain string) {
+	var invalid *sites.InvalidError
+	switch {
+	case errors.Is(err, sites.ErrNotFound):
+		writeJSON(w, http.StatusNotFound, model.ErrorResponse{
+			Error: "site not found",
+			Code:  "NOT_FOUND",
+		})
+	case errors.Is(err, sites.ErrExists):
+		writeJSON(w, http.StatusConflict, model.ErrorResponse{
+			Error: "site already exists",
+			Code:  "SITE_EXISTS",
+		})
+	case errors.As(err, &invalid):
+		writeJSON(w, http.StatusUnprocessableEntity, model.ErrorResponse{
+			Error: invalid.Error(),
+			Code:  "VALIDATION_ERROR",
+		})
+	default:
+		slog.Error("site change failed", "error", err, "site_id", domain)
+		writeJSON(w, http.StatusInternalServerError, model.ErrorResponse{
+			Error: "failed to save site",
+			Code:  "DB_ERROR",
+		})
+	}
+}
+
+// pageParams reads page and per_page query params.
+// Writes a 400 response and returns false if they are invalid.
+func pageParams(w http.ResponseWriter, r *http.Request) (page, perPage int, ok bool) {
+	q := r.URL.Query()
+
+	page, err := parseIntParam(q.Get("page"), 1)
+	if err != nil || page < 1 || page > MaxPage {
+		writeJSON(w, http.StatusBadRequest, model.ErrorResponse{
+			Error: fmt.Sprintf("page must be between 1 and %d", MaxPage),
+			Code:  "INVALID_PAGINATION",
+		})
+		return 0, 0, false
+	}
+	perPage, err = parseIntParam(q.Get("per_page"), DefaultPageSize)
+	if err != nil || perPage < 1 || perPage > MaxPageSize {
+		writeJSON(w, http.StatusBadRequest, model.ErrorResponse{
+			Error: fmt.Sprintf("per_page must be between 1 and %d", MaxPageSize),
+			Code:  "INVALID_PAGINATION",
+		})
+		return 0, 0, false
+	}
+	return page, perPage, true
+}
+
+// idParam extracts and validates the {id} URL parameter.
+// Writes a 400 response and returns false if it is not a UUID.
+func idParam(w http.ResponseWriter, r *http.Request) (string, bool) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, model.ErrorResponse{
+			Error: "invalid id",
+			Code:  "INVALID_ID",
+		})
+		return "", false
+	}
+	return id.String(), true
+}
+
+// parseTimeParam parses an RFC 3339 timestamp or a YYYY-MM-DD date. With
+// end set, a date stands for the end of that day (the start of the next), so
+// it can be used as an exclusive upper bound.
+func parseTimeParam(s string, end bool) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.DateOnly, s)
+	if err != nil || !end {
+		return t, err
+	}
+	return t.AddDate(0, 0, 1), nil
+}
+
+func parseIntParam(s string, def int) (int, error) {
+	if s == "" {
+		return def, nil
+	}
+	return strconv.Atoi(s)
+}
@@ -0,0 +1,76 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/devrimsoft/bug-notifications-api/internal/config"
+	"github.com/devrimsoft/bug-notifications-api/internal/model"
+)
+
+func TestListReportsRejectsInvalidParams(t *testing.T) {
+	live := config.NewLive(&config.Config{
+		Sites:    []string{"example.com"},
+		Taxonomy: model.DefaultTaxonomy(),
+	})
+	// Every request is rejected before the repository is used
+	h := NewAdminHandler(nil, nil, nil, live)
+
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{"status", "status=done", "INVALID_FILTER"},
+		{"report type", "report_type=praise", "INVALID_FILTER"},
+		{"category", "category=billing", "INVALID_FILTER"},
+		{"from", "from=16.10.2026", "INVALID_FILTER"},
+		{"to", "to=2026-10-16T12:00", "INVALID_FILTER"},
+		{"from after to", "from=2026-10-17&to=2026-10-16", "INVALID_FILTER"},
+		{"from after to timestamp", "from=2026-10-16T12:00:00Z&to=2026-10-16T11:00:00Z", "INVALID_FILTER"},
+		{"page zero", "page=0", "INVALID_PAGINATION"},
+		{"page not a number", "page=two", "INVALID_PAGINATION"},
+		{"page overflow", "page=9223372036854775807&per_page=100", "INVALID_PAGINATION"},
+		{"page above the limit", "page=" + strconv.Itoa(MaxPage+1), "INVALID_PAGINATION"},
+		{"per_page zero", "per_page=0", "INVALID_PAGINATION"},
+		{"per_page above the limit", "per_page=" + strconv.Itoa(MaxPageSize+1), "INVALID_PAGINATION"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/admin/v1/reports?"+tt.query, nil)
+			w := httptest.NewRecorder()
+			h.ListReports(w, r)
+			if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), tt.want) {
+				t.Errorf("?%s: got %d %s, want 400 %s", tt.query, w.Code, w.Body.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTimeParam(t *testing.T) {
+	day := time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC)
+	noon := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		s    string
+		end  bool
+		want time.Time
+	}{
+		{"", false, time.Time{}},
+		{"", true, time.Time{}},
+		{"2026-10-16", false, day},
+		{"2026-10-16", true, day.AddDate(0, 0, 1)}, // the whole day is included
+		{"2026-10-16T12:00:00Z", false, noon},
+		{"2026-10-16T12:00:00Z", true, noon},
+	}
+	for _, tt := range tests {
+		got, err := parseTimeParam(tt.s, tt.end)
+		if err != nil || !got.Equal(tt.want) {
+			t.Errorf("parseTimeParam(%q, %v) = %v, %v; want %v", tt.s, tt.end, got, err, tt.want)
+		}
+	}
+}
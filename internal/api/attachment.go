@@ -0,0 +1,363 @@
+package api
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"path"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/devrimsoft/bug-notifications-api/internal/config"
+	"github.com/devrimsoft/bug-notifications-api/internal/model"
+)
+
+const (
+	MaxLogSize   = 2 * 1024 * 1024  // 2MB
+	MaxHARSize   = 10 * 1024 * 1024 // 10MB
+	MaxVideoSize = 20 * 1024 * 1024 // 20MB
+
+	// MaxAttachments caps the files of one report, of all kinds together.
+	MaxAttachments = config.MaxAttachments
+	// MaxUploadSize caps the total size of the files of one report.
+	MaxUploadSize = 50 * 1024 * 1024
+	// MaxRequestSize is the request body limit: all files plus 1MB form data.
+	MaxRequestSize = MaxUploadSize + 1024*1024
+	// UploadTimeout is how long a server report request may take to arrive,
+	// enough for MaxRequestSize at about 4 Mbit/s.
+	UploadTimeout = 2 * time.Minute
+	// UploadIdleTimeout and UploadMinRate bound browser report uploads: the
+	// body may take as long as it needs while it averages UploadMinRate bytes
+	// per second, and is cut off UploadIdleTimeout after it falls behind.
+	UploadIdleTimeout = 10 * time.Second
+	UploadMinRate     = 64 * 1024
+
+	// maxFormMemory is how much of a multipart form is held in memory; larger
+	// files are spilled to temporary files.
+	maxFormMemory = 10 * 1024 * 1024
+)
+
+// attachmentClass holds the limits of one kind of attachment.
+type attachmentClass struct {
+	maxSize    int
+	maxCount   int
+	extensions []string // accepted file name extensions, lowercase
+}
+
+var attachmentClasses = map[model.AttachmentKind]attachmentClass{
+	model.AttachmentImage: {MaxImageSize, MaxImages, []string{".jpg", ".jpeg", ".png", ".webp", ".gif"}},
+	model.AttachmentLog:   {MaxLogSize, 3, []string{".txt", ".log"}},
+	model.AttachmentHAR:   {MaxHARSize, 2, []string{".har"}},
+	model.AttachmentVideo: {MaxVideoSize, 1, []string{".mp4", ".webm"}},
+}
+
+// attachmentExtensions maps the MIME types of non-image attachments to the
+// extension stored files get.
+var attachmentExtensions = map[string]string{
+	"text/plain; charset=utf-8": ".txt",
+	"application/json":          ".har",
+	"video/mp4":                 ".mp4",
+	"video/webm":                ".webm",
+}
+
+// upload is a file of a multipart form, classified but not read yet.
+type upload struct {
+	label string // for error messages, e.g. "image[0]" or "attachment[2]"
+	kind  model.AttachmentKind
+	fh    *multipart.FileHeader
+}
+
+// classifyAttachment picks the attachment kind of a file by its extension.
+func classifyAttachment(filename string) (model.AttachmentKind, bool) {
+	ext := strings.ToLower(path.Ext(filename))
+	for kind, class := range attachmentClasses {
+		for _, e := range class.extensions {
+			if e == ext {
+				return kind, true
+			}
+		}
+	}
+	return "", false
+}
+
+// checkUploadCounts enforces the per-kind and overall limits on a report's
+// files. Returns an error message and code, or empty strings.
+func checkUploadCounts(uploads []upload) (string, string) {
+	if len(uploads) > MaxAttachments {
+		return fmt.Sprintf("maximum %d attachments allowed", MaxAttachments), "TOO_MANY_ATTACHMENTS"
+	}
+	counts := make(map[model.AttachmentKind]int)
+	var total int64
+	for _, u := range uploads {
+		counts[u.kind]++
+		total += u.fh.Size
+	}
+	if counts[model.AttachmentImage] > MaxImages {
+		return fmt.Sprintf("maximum %d images allowed", MaxImages), "TOO_MANY_IMAGES"
+	}
+	for kind, n := range counts {
+		if limit := attachmentClasses[kind].maxCount; n > limit {
+			return fmt.Sprintf("maximum %d %s attachments allowed", limit, kind), "TOO_MANY_ATTACHMENTS"
+		}
+	}
+	if total > MaxUploadSize {
+		return fmt.Sprintf("attachments must be at most %dMB in total", MaxUploadSize/(1024*1024)), "TOO_MANY_ATTACHMENTS"
+	}
+	return "", ""
+}
+
+// readUpload validates a file for its kind and returns the data to store
+// and its MIME type. Images have their metadata stripped and HAR files their
+// cookies and credentials.
+func (h *Handler) readUpload(u upload) ([]byte, string, error) {
+	if u.kind == model.AttachmentImage {
+		cfg := h.live.Get()
+		data, mime, err := validateImage(u.fh)
+		if err != nil {
+			return nil, "", err
+		}
+		// Strip metadata before the image is staged anywhere
+		return sanitizeImage(data, mime, imageLimits{
+			MaxPixels: cfg.ImageMaxPixels,
+			MaxSide:   cfg.ImageMaxSide,
+		})
+	}
+
+	class := attachmentClasses[u.kind]
+	data, err := readFile(u.fh, u.kind, class.maxSize)
+	if err != nil {
+		return nil, "", err
+	}
+	switch u.kind {
+	case model.AttachmentLog:
+		if !utf8.Valid(data) || bytes.IndexByte(data, 0) >= 0 {
+			return nil, "", fmt.Errorf("log must be UTF-8 text")
+		}
+		return data, "text/plain; charset=utf-8", nil
+	case model.AttachmentHAR:
+		data, err = scrubHAR(data)
+		if err != nil {
+			return nil, "", err
+		}
+		return data, "application/json", nil
+	case model.AttachmentVideo:
+		mime := detectVideo(data)
+		if mime == "" {
+			return nil, "", fmt.Errorf("file content is not a valid video (mp4, webm)")
+		}
+		return data, mime, nil
+	}
+	return nil, "", fmt.Errorf("unsupported attachment type")
+}
+
+// readFile reads an upload of at most maxSize bytes.
+func readFile(fh *multipart.FileHeader, kind model.AttachmentKind, maxSize int) ([]byte, error) {
+	tooLarge := fmt.Errorf("%s must be at most %dMB", kind, maxSize/(1024*1024))
+	if fh.Size > int64(maxSize) {
+		return nil, tooLarge
+	}
+	if fh.Size == 0 {
+		return nil, fmt.Errorf("%s file is empty", kind)
+	}
+
+	f, err := fh.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s file", kind)
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(io.LimitReader(f, int64(maxSize)+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s file", kind)
+	}
+	if len(data) > maxSize {
+		return nil, tooLarge
+	}
+	return data, nil
+}
+
+// harRedacted replaces the values of scrubbed headers and cookies.
+const harRedacted = "[redacted]"
+
+// harSecretHeaders are the headers whose values are scrubbed from HAR files.
+var harSecretHeaders = map[string]bool{
+	"cookie":              true,
+	"set-cookie":          true,
+	"authorization":       true,
+	"proxy-authorization": true,
+}
+
+// scrubHAR checks that data is an HTTP Archive and redacts the cookies and
+// credential headers of every request and response. Names are kept, so it
+// is still visible that a cookie or header was sent.
+func scrubHAR(data []byte) ([]byte, error) {
+	invalid := fmt.Errorf("file content is not a valid HAR file")
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber() // keep numbers as written
+	var har map[string]any
+	if err := dec.Decode(&har); err != nil {
+		return nil, invalid
+	}
+	log, ok := har["log"].(map[string]any)
+	if !ok {
+		return nil, invalid
+	}
+	entries, ok := log["entries"].([]any)
+	if !ok {
+		return nil, invalid
+	}
+
+	for _, e := range entries {
+		entry, ok := e.(map[string]any)
+		if !ok {
+			return nil, invalid
+		}
+		for _, side := range []string{"request", "response"} {
+			msg, ok := entry[side].(map[string]any)
+			if !ok {
+				continue
+			}
+			if headers, ok := msg["headers"].([]any); ok {
+				for _, h := range headers {
+					if h, ok := h.(map[string]any); ok {
+						if name, _ := h["name"].(string); harSecretHeaders[strings.ToLower(name)] {
+							h["value"] = harRedacted
+						}
+					}
+				}
+			}
+			if cookies, ok := msg["cookies"].([]any); ok {
+				for _, c := range cookies {
+					if c, ok := c.(map[string]any); ok {
+						c["value"] = harRedacted
+					}
+				}
+			}
+		}
+	}
+
+	out, err := json.Marshal(har)
+	if err != nil {
+		return nil, fmt.Errorf("failed to process HAR file")
+	}
+	return out, nil
+}
+
+// detectVideo checks the container structure of an MP4 or WebM file and
+// returns its MIME type, or "" if it is neither.
+func detectVideo(data []byte) string {
+	switch {
+	case len(data) >= 8 && string(data[4:8]) == "ftyp":
+		if isMP4(data) {
+			return "video/mp4"
+		}
+	case bytes.HasPrefix(data, []byte{0x1A, 0x45, 0xDF, 0xA3}):
+		if webmDocType(data) == "webm" {
+			return "video/webm"
+		}
+	}
+	return ""
+}
+
+// isMP4 walks the top-level boxes of an ISO media file and reports whether
+// it is well formed and has a movie box. HEIF and AVIF images share the
+// container but have no moov box.
+func isMP4(data []byte) bool {
+	hasMoov := false
+	for p := 0; p < len(data); {
+		if p+8 > len(data) {
+			return false
+		}
+		size := uint64(binary.BigEndian.Uint32(data[p:]))
+		typ := string(data[p+4 : p+8])
+		header := uint64(8)
+		switch size {
+		case 0: // box extends to the end of the file
+			size = uint64(len(data) - p)
+		case 1: // 64-bit size follows the type
+			if p+16 > len(data) {
+				return false
+			}
+			size = binary.BigEndian.Uint64(data[p+8:])
+			header = 16
+		}
+		if size < header || size > uint64(len(data)-p) {
+			return false
+		}
+		if p == 0 && typ != "ftyp" {
+			return false
+		}
+		if typ == "moov" {
+			hasMoov = true
+		}
+		p += int(size)
+	}
+	return hasMoov
+}
+
+// webmDocType returns the DocType of the EBML header at the start of data,
+// "webm" for WebM files.
+func webmDocType(data []byte) string {
+	p := 4 // after the EBML element ID
+	size, n := ebmlVint(data[p:])
+	if n == 0 {
+		return ""
+	}
+	p += n
+	end := p + int(size)
+	if size > uint64(len(data)) || end > len(data) {
+		return ""
+	}
+	for p < end {
+		// Element IDs keep their length marker bits
+		idLen := ebmlVintLen(data[p])
+		if idLen == 0 || p+idLen > end {
+			return ""
+		}
+		id := data[p : p+idLen]
+		p += idLen
+		size, n := ebmlVint(data[p:end])
+		if n == 0 || size > uint64(end-p-n) {
+			return ""
+		}
+		p += n
+		if bytes.Equal(id, []byte{0x42, 0x82}) { // DocType
+			return string(bytes.TrimRight(data[p:p+int(size)], "\x00"))
+		}
+		p += int(size)
+	}
+	return ""
+}
+
+// ebmlVintLen returns the length of an EBML variable-size integer from its
+// first byte, or 0 if it is invalid.
+func ebmlVintLen(b byte) int {
+	for i := 0; i < 8; i++ {
+		if b&(0x80>>i) != 0 {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// ebmlVint reads an EBML variable-size integer without its length marker.
+// Returns the value and the bytes read, or 0 bytes if it is invalid.
+func ebmlVint(data []byte) (uint64, int) {
+	if len(data) == 0 {
+		return 0, 0
+	}
+	n := ebmlVintLen(data[0])
+	if n == 0 || n > len(data) {
+		return 0, 0
+	}
+	v := uint64(data[0] & (0xFF >> n))
+	for _, b := range data[1:n] {
+		v = v<<8 | uint64(b)
+	}
+	return v, n
+}
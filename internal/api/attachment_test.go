@@ -0,0 +1,176 @@
+package api
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/devrimsoft/bug-notifications-api/internal/model"
+)
+
+func TestScrubHAR(t *testing.T) {
+	in := `{"log": {"version": "1.2", "entries": [{
+		"time": 12.5,
+		"request": {
+			"url": "https://example.com/api",
+			"headers": [
+				{"name": "Cookie", "value": "session=abc"},
+				{"name": "AUTHORIZATION", "value": "Bearer xyz"},
+				{"name": "Proxy-Authorization", "value": "Basic Zm9v"},
+				{"name": "Accept", "value": "application/json"}
+			],
+			"cookies": [{"name": "session", "value": "abc"}]
+		},
+		"response": {
+			"status": 200,
+			"headers": [{"name": "Set-Cookie", "value": "session=def; HttpOnly"}],
+			"cookies": [{"name": "session", "value": "def", "httpOnly": true}]
+		}
+	}]}}`
+
+	out, err := scrubHAR([]byte(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"abc", "xyz", "Zm9v", "def"} {
+		if strings.Contains(string(out), secret) {
+			t.Errorf("scrubbed HAR still contains %q: %s", secret, out)
+		}
+	}
+
+	var har struct {
+		Log struct {
+			Entries []struct {
+				Time    json.Number `json:"time"`
+				Request struct {
+					Headers []struct{ Name, Value string } `json:"headers"`
+					Cookies []struct{ Name, Value string } `json:"cookies"`
+				} `json:"request"`
+				Response struct {
+					Cookies []map[string]any `json:"cookies"`
+				} `json:"response"`
+			} `json:"entries"`
+		} `json:"log"`
+	}
+	if err := json.Unmarshal(out, &har); err != nil {
+		t.Fatal(err)
+	}
+	e := har.Log.Entries[0]
+	if e.Time != "12.5" {
+		t.Errorf("time = %s, want the number kept as written", e.Time)
+	}
+	wantHeaders := []string{"Cookie=" + harRedacted, "AUTHORIZATION=" + harRedacted, "Proxy-Authorization=" + harRedacted, "Accept=application/json"}
+	for i, h := range e.Request.Headers {
+		if got := h.Name + "=" + h.Value; got != wantHeaders[i] {
+			t.Errorf("request header %d = %s, want %s", i, got, wantHeaders[i])
+		}
+	}
+	if c := e.Request.Cookies[0]; c.Name != "session" || c.Value != harRedacted {
+		t.Errorf("request cookie = %+v", c)
+	}
+	if c := e.Response.Cookies[0]; c["name"] != "session" || c["value"] != harRedacted || c["httpOnly"] != true {
+		t.Errorf("response cookie = %v", c)
+	}
+}
+
+func TestScrubHARInvalid(t *testing.T) {
+	tests := []string{
+		``,
+		`not json`,
+		`[]`,
+		`{"entries": []}`,
+		`{"log": {}}`,
+		`{"log": {"entries": {}}}`,
+		`{"log": {"entries": ["x"]}}`,
+	}
+	for _, in := range tests {
+		if _, err := scrubHAR([]byte(in)); err == nil {
+			t.Errorf("scrubHAR(%q) accepted an invalid HAR", in)
+		}
+	}
+	// Entries without request or response are kept as they are
+	if _, err := scrubHAR([]byte(`{"log": {"entries": [{}]}}`)); err != nil {
+		t.Errorf("scrubHAR(empty entry) = %v", err)
+	}
+}
+
+// box returns an ISO media box with a 32-bit size.
+func box(typ string, payload ...byte) []byte {
+	b := make([]byte, 8, 8+len(payload))
+	binary.BigEndian.PutUint32(b, uint32(8+len(payload)))
+	copy(b[4:], typ)
+	return append(b, payload...)
+}
+
+func concat(parts ...[]byte) []byte {
+	var out []byte
+	for _, p := range parts {
+		out = append(out, p...)
+	}
+	return out
+}
+
+// ebmlHeader returns an EBML header with the given DocType.
+func ebmlHeader(docType string) []byte {
+	body := concat(
+		[]byte{0x42, 0x86, 0x81, 0x01}, // EBMLVersion 1
+		[]byte{0x42, 0x82, 0x80 | byte(len(docType))}, []byte(docType),
+	)
+	return concat([]byte{0x1A, 0x45, 0xDF, 0xA3, 0x80 | byte(len(body))}, body)
+}
+
+func TestDetectVideo(t *testing.T) {
+	ftyp := box("ftyp", []byte("isom\x00\x00\x02\x00isomiso2mp41")...)
+	largeMdat := concat([]byte{0, 0, 0, 1}, []byte("mdat"), []byte{0, 0, 0, 0, 0, 0, 0, 20}, []byte("data"))
+
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"mp4", concat(ftyp, box("moov", 1, 2, 3), box("mdat", 4, 5)), "video/mp4"},
+		{"mp4 with 64-bit box", concat(ftyp, largeMdat, box("moov")), "video/mp4"},
+		{"mp4 moov to end of file", concat(ftyp, []byte{0, 0, 0, 0}, []byte("moov"), []byte{1, 2}), "video/mp4"},
+		{"heif without moov", concat(box("ftyp", []byte("heic")...), box("meta")), ""},
+		{"truncated box", concat(ftyp, box("moov", 1, 2, 3)[:9]), ""},
+		{"box larger than file", concat(ftyp, []byte{0, 0, 0x10, 0}, []byte("moov")), ""},
+		{"box smaller than header", concat(ftyp, []byte{0, 0, 0, 4}, []byte("moov")), ""},
+		{"webm", concat(ebmlHeader("webm"), []byte{0x18, 0x53, 0x80, 0x67}), "video/webm"},
+		{"matroska", ebmlHeader("matroska"), ""},
+		{"ebml header past the end", []byte{0x1A, 0x45, 0xDF, 0xA3, 0x9F, 0x42}, ""},
+		{"ebml bare magic", []byte{0x1A, 0x45, 0xDF, 0xA3}, ""},
+		{"png", []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\x0dIHDR"), ""},
+		{"empty", nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := detectVideo(tt.data); got != tt.want {
+				t.Errorf("detectVideo = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClassifyAttachment(t *testing.T) {
+	tests := []struct {
+		name string
+		want model.AttachmentKind
+		ok   bool
+	}{
+		{"shot.PNG", model.AttachmentImage, true},
+		{"photo.jpeg", model.AttachmentImage, true},
+		{"console.log", model.AttachmentLog, true},
+		{"notes.txt", model.AttachmentLog, true},
+		{"network.har", model.AttachmentHAR, true},
+		{"screen.webm", model.AttachmentVideo, true},
+		{"screen.mov", "", false},
+		{"archive.har.zip", "", false},
+		{"noext", "", false},
+	}
+	for _, tt := range tests {
+		if got, ok := classifyAttachment(tt.name); got != tt.want || ok != tt.ok {
+			t.Errorf("classifyAttachment(%q) = %q, %v; want %q, %v", tt.name, got, ok, tt.want, tt.ok)
+		}
+	}
+}
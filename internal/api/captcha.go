@@ -0,0 +1,61 @@
+package api
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/devrimsoft/bug-notifications-api/internal/model"
+)
+
+// captchaTokenFields are the form fields and headers a captcha token is read
+// from, in order. The provider-specific names are what their widgets submit
+// by default; X-Turnstile-Token is kept for existing clients.
+var (
+	captchaTokenFields  = []string{"captcha_token", "cf-turnstile-response", "h-captcha-response", "g-recaptcha-response"}
+	captchaTokenHeaders = []string{"X-Captcha-Token", "X-Turnstile-Token"}
+)
+
+// captchaToken returns the captcha token of a report request. Multipart
+// requests carry it in a form field, JSON requests in a header.
+func captchaToken(r *http.Request, multipart bool) string {
+	if multipart {
+		for _, f := range captchaTokenFields {
+			if v := r.FormValue(f); v != "" {
+				return v
+			}
+		}
+		return ""
+	}
+	for _, h := range captchaTokenHeaders {
+		if v := r.Header.Get(h); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// CaptchaChallenge handles POST /v1/captcha/challenge
+// Issues a proof-of-work challenge for sites using the "pow" provider.
+func (h *Handler) CaptchaChallenge(w http.ResponseWriter, r *http.Request) {
+	pow := h.captchas.PoW()
+	if pow == nil {
+		writeJSON(w, http.StatusNotFound, model.ErrorResponse{
+			Error: "proof-of-work captcha is not enabled",
+			Code:  "CAPTCHA_NOT_ENABLED",
+		})
+		return
+	}
+
+	c, err := pow.Issue(r.Context())
+	if err != nil {
+		slog.Error("issue captcha challenge failed", "error", err)
+		writeJSON(w, http.StatusServiceUnavailable, model.ErrorResponse{
+			Error: "service temporarily unavailable",
+			Code:  "CAPTCHA_ERROR",
+		})
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, c)
+}
@@ -9,11 +9,16 @@ import (
 	"net/http"
 	"path"
 	"strings"
+	"sync/atomic"
 	"time"
 
+	"github.com/devrimsoft/bug-notifications-api/internal/captcha"
 	"github.com/devrimsoft/bug-notifications-api/internal/config"
+	"github.com/devrimsoft/bug-notifications-api/internal/db"
+	"github.com/devrimsoft/bug-notifications-api/internal/middleware"
 	"github.com/devrimsoft/bug-notifications-api/internal/model"
 	"github.com/devrimsoft/bug-notifications-api/internal/queue"
+	"github.com/devrimsoft/bug-notifications-api/internal/storage"
 	"github.com/devrimsoft/bug-notifications-api/internal/validate"
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
@@ -21,32 +26,62 @@ import (
 
 const MaxImages = 5
 
+// SiteLimiter applies a site's own rate limit to a browser report. It writes
+// the error response and returns false when the report must be rejected.
+type SiteLimiter func(w http.ResponseWriter, r *http.Request, siteID string) bool
+
 type Handler struct {
-	producer *queue.Producer
-	cfg      *config.Config
+	producer  *queue.Producer
+	repo      *db.Repository
+	captchas  *captcha.Service
+	siteLimit SiteLimiter
+	live      *config.Live
+
+	index atomic.Pointer[renderedIndex] // index.html for the current config
 }
 
-func NewHandler(producer *queue.Producer, cfg *config.Config) *Handler {
-	return &Handler{producer: producer, cfg: cfg}
+func NewHandler(producer *queue.Producer, repo *db.Repository, captchas *captcha.Service, siteLimit SiteLimiter, live *config.Live) *Handler {
+	return &Handler{producer: producer, repo: repo, captchas: captchas, siteLimit: siteLimit, live: live}
 }
 
 // CreateReport handles POST /v1/reports
-// Accepts application/json (no images) or multipart/form-data (with optional images).
+// Accepts application/json (no files) or multipart/form-data (with optional
+// images and other attachments).
 func (h *Handler) CreateReport(w http.ResponseWriter, r *http.Request) {
+	h.createReport(w, r, "")
+}
+
+// CreateServerReport handles POST /v1/server/reports
+// Same body as CreateReport, sent by a site's backend with its API key.
+// site_id may be omitted; if given it must match the key's site.
+// The captcha is not checked.
+func (h *Handler) CreateServerReport(w http.ResponseWriter, r *http.Request) {
+	key := middleware.APIKeyFromContext(r.Context())
+	if key == nil {
+		writeJSON(w, http.StatusUnauthorized, model.ErrorResponse{
+			Error: "api key required",
+			Code:  "MISSING_API_KEY",
+		})
+		return
+	}
+	h.createReport(w, r, key.SiteID)
+}
+
+// createReport validates a report, stages its files and enqueues it.
+// Files are uploaded to storage by the worker, off the request path.
+// boundSite is the site of the authenticating API key for server requests,
+// or empty for browser requests.
+func (h *Handler) createReport(w http.ResponseWriter, r *http.Request, boundSite string) {
+	cfg := h.live.Get() // one config for the whole request, even if it is reloaded meanwhile
 	var req model.ReportRequest
 
 	ct := r.Header.Get("Content-Type")
 
-	// Validated images ready for upload: [{data, filename}, ...]
-	type pendingImage struct {
-		data     []byte
-		filename string
-	}
-	var pendingImages []pendingImage
+	// Uploaded files, read once the site is known
+	var uploads []upload
 
 	if strings.HasPrefix(ct, "multipart/form-data") {
-		// 5 images * 5MB + 1MB form overhead
-		if err := r.ParseMultipartForm(MaxImages*MaxImageSize + 1024*1024); err != nil {
+		if err := r.ParseMultipartForm(maxFormMemory); err != nil {
 			writeJSON(w, http.StatusBadRequest, model.ErrorResponse{
 				Error: "invalid multipart form",
 				Code:  "INVALID_FORM",
@@ -76,32 +111,48 @@ func (h *Handler) CreateReport(w http.ResponseWriter, r *http.Request) {
 			req.LastName = &v
 		}
 
-		// Multiple images: field name "images" (multiple files)
-		if r.MultipartForm != nil && r.MultipartForm.File != nil {
-			files := r.MultipartForm.File["images"]
-			if len(files) > MaxImages {
-				writeJSON(w, http.StatusBadRequest, model.ErrorResponse{
-					Error: fmt.Sprintf("maximum %d images allowed", MaxImages),
-					Code:  "TOO_MANY_IMAGES",
-				})
-				return
+		// Custom fields: custom_fields[name]
+		for key, vals := range r.MultipartForm.Value {
+			name, ok := strings.CutPrefix(key, "custom_fields[")
+			if !ok || !strings.HasSuffix(name, "]") || len(vals) == 0 {
+				continue
+			}
+			if req.CustomFields == nil {
+				req.CustomFields = make(map[string]any)
 			}
+			req.CustomFields[strings.TrimSuffix(name, "]")] = vals[0]
+		}
 
-			for i, fh := range files {
-				data, _, err := validateImage(fh)
-				if err != nil {
+		// Files: field "images" takes images only, field "attachments" any
+		// attachment kind, picked by file extension
+		if r.MultipartForm != nil && r.MultipartForm.File != nil {
+			for i, fh := range r.MultipartForm.File["images"] {
+				uploads = append(uploads, upload{label: fmt.Sprintf("image[%d]", i), kind: model.AttachmentImage, fh: fh})
+			}
+			for i, fh := range r.MultipartForm.File["attachments"] {
+				kind, ok := classifyAttachment(fh.Filename)
+				if !ok {
 					writeJSON(w, http.StatusBadRequest, model.ErrorResponse{
-						Error: fmt.Sprintf("image[%d]: %s", i, err.Error()),
-						Code:  "INVALID_IMAGE",
+						Error: fmt.Sprintf("attachment[%d]: unsupported file type, allowed: jpg, png, webp, gif, txt, log, har, mp4, webm", i),
+						Code:  "INVALID_ATTACHMENT",
 					})
 					return
 				}
-				pendingImages = append(pendingImages, pendingImage{data: data, filename: fh.Filename})
+				uploads = append(uploads, upload{label: fmt.Sprintf("attachment[%d]", i), kind: kind, fh: fh})
+			}
+			if msg, code := checkUploadCounts(uploads); msg != "" {
+				writeJSON(w, http.StatusBadRequest, model.ErrorResponse{
+					Error: msg,
+					Code:  code,
+				})
+				return
 			}
 		}
 	} else {
-		// JSON body (no images)
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		// JSON body (no files). Custom field numbers are kept as written.
+		dec := json.NewDecoder(r.Body)
+		dec.UseNumber()
+		if err := dec.Decode(&req); err != nil {
 			writeJSON(w, http.StatusBadRequest, model.ErrorResponse{
 				Error: "invalid JSON body",
 				Code:  "INVALID_JSON",
@@ -110,6 +161,19 @@ func (h *Handler) CreateReport(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// Server requests are bound to the API key's site
+	if boundSite != "" {
+		if req.SiteID == "" {
+			req.SiteID = boundSite
+		} else if cfg.FindSiteByDomain(req.SiteID) != boundSite {
+			writeJSON(w, http.StatusForbidden, model.ErrorResponse{
+				Error: "site_id does not match the api key",
+				Code:  "SITE_MISMATCH",
+			})
+			return
+		}
+	}
+
 	// Validate site_id against allowed sites
 	if req.SiteID == "" {
 		writeJSON(w, http.StatusBadRequest, model.ErrorResponse{
@@ -118,16 +182,53 @@ func (h *Handler) CreateReport(w http.ResponseWriter, r *http.Request) {
 		})
 		return
 	}
-	if h.cfg.FindSiteByDomain(req.SiteID) == "" {
+	site := cfg.FindSiteByDomain(req.SiteID)
+	if site == "" {
 		writeJSON(w, http.StatusBadRequest, model.ErrorResponse{
 			Error: "invalid site_id",
 			Code:  "INVALID_SITE_ID",
 		})
 		return
 	}
+	req.SiteID = site // aliases are stored under the site's domain
+
+	// Browser reports also count against the site's own rate limit, if it has one
+	if boundSite == "" && !h.siteLimit(w, r, req.SiteID) {
+		return
+	}
+
+	// Attachment kinds and the file limit are set per site
+	if limit := cfg.MaxAttachmentsFor(req.SiteID); limit > 0 && len(uploads) > limit {
+		writeJSON(w, http.StatusBadRequest, model.ErrorResponse{
+			Error: fmt.Sprintf("maximum %d attachments allowed", limit),
+			Code:  "TOO_MANY_ATTACHMENTS",
+		})
+		return
+	}
+	for _, u := range uploads {
+		if !cfg.AttachmentAllowed(req.SiteID, u.kind) {
+			writeJSON(w, http.StatusBadRequest, model.ErrorResponse{
+				Error: fmt.Sprintf("%s: %s attachments are not enabled for this site", u.label, u.kind),
+				Code:  "ATTACHMENT_TYPE_NOT_ALLOWED",
+			})
+			return
+		}
+	}
 
 	// Validate
-	if errs := validate.ReportRequest(&req); len(errs) > 0 {
+	errs := validate.ReportRequest(&req, cfg.Taxonomy)
+	if len(errs) == 0 {
+		// Sites may accept only some report types and categories
+		if !cfg.ReportTypeAllowed(req.SiteID, string(req.ReportType)) {
+			errs = append(errs, fmt.Sprintf("report_type %q is not enabled for this site", req.ReportType))
+		}
+		if !cfg.CategoryAllowed(req.SiteID, string(req.Category)) {
+			errs = append(errs, fmt.Sprintf("category %q is not enabled for this site", req.Category))
+		}
+	}
+	customFields, fieldErrs := validate.CustomFields(&req, cfg.CustomFieldsFor(req.SiteID))
+	errs = append(errs, fieldErrs...)
+	if len(errs) > 0 {
 		writeJSON(w, http.StatusUnprocessableEntity, map[string]any{
 			"error":  "validation failed",
 			"code":   "VALIDATION_ERROR",
@@ -136,46 +237,61 @@ func (h *Handler) CreateReport(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Turnstile verification — skip when not configured, enforce when configured
-	if h.cfg.TurnstileSecretKey != "" {
-		var turnstileToken string
-		if strings.HasPrefix(ct, "multipart/form-data") {
-			turnstileToken = r.FormValue("cf-turnstile-response")
-		} else {
-			turnstileToken = r.Header.Get("X-Turnstile-Token")
-		}
-		if err := verifyTurnstile(h.cfg.TurnstileSecretKey, turnstileToken, r.RemoteAddr); err != nil {
-			slog.Warn("turnstile verification failed", "error", err, "remote_addr", r.RemoteAddr)
+	// Captcha verification — skip for sites without one or for server requests, enforce otherwise.
+	// Runs before any file is read: decoding and re-encoding images is the
+	// expensive part of a request, so it is only done for verified clients.
+	if provider, verifier := h.captchas.For(req.SiteID); verifier != nil && boundSite == "" {
+		token := captchaToken(r, strings.HasPrefix(ct, "multipart/form-data"))
+		if err := verifier.Verify(r.Context(), token, r.RemoteAddr); err != nil {
+			slog.Warn("captcha verification failed", "provider", provider, "site_id", req.SiteID, "error", err, "remote_addr", r.RemoteAddr)
+			code := "CAPTCHA_FAILED"
+			if provider == "turnstile" {
+				code = "TURNSTILE_FAILED" // kept for existing clients
+			}
 			writeJSON(w, http.StatusForbidden, model.ErrorResponse{
 				Error: "bot verification failed",
-				Code:  "TURNSTILE_FAILED",
+				Code:  code,
+			})
+			return
+		}
+	}
+
+	// Validated files, staged for the worker to upload
+	var stagedFiles []model.StagedFile
+	var fileData [][]byte
+	for _, u := range uploads {
+		data, mime, err := h.readUpload(u)
+		if err != nil {
+			code := "INVALID_ATTACHMENT"
+			if u.kind == model.AttachmentImage {
+				code = "INVALID_IMAGE"
+			}
+			writeJSON(w, http.StatusBadRequest, model.ErrorResponse{
+				Error: fmt.Sprintf("%s: %s", u.label, err.Error()),
+				Code:  code,
 			})
 			return
 		}
+		stagedFiles = append(stagedFiles, model.StagedFile{ContentType: mime, Kind: u.kind})
+		fileData = append(fileData, data)
 	}
 
-	// Upload images to R2
-	if len(pendingImages) > 0 {
-		if h.cfg.ImageAPIURL == "" || h.cfg.ImageAPIKey == "" {
-			slog.Error("image upload attempted but IMAGE_API_URL/IMAGE_API_KEY not configured")
+	// Pick storage keys now; the worker uploads under them
+	if len(stagedFiles) > 0 {
+		if cfg.ImageStorage == "" {
+			slog.Error("file upload attempted but IMAGE_STORAGE is not configured")
 			writeJSON(w, http.StatusServiceUnavailable, model.ErrorResponse{
 				Error: "image upload is not configured",
 				Code:  "IMAGE_NOT_CONFIGURED",
 			})
 			return
 		}
-
-		for i, img := range pendingImages {
-			imageURL, err := uploadToR2(h.cfg.ImageAPIURL, h.cfg.ImageAPIKey, img.data, img.filename)
-			if err != nil {
-				slog.Error("r2 image upload failed", "error", err, "index", i, "filename", img.filename)
-				writeJSON(w, http.StatusBadGateway, model.ErrorResponse{
-					Error: fmt.Sprintf("image[%d] upload failed", i),
-					Code:  "IMAGE_UPLOAD_FAILED",
-				})
-				return
+		for i, f := range stagedFiles {
+			ext := imageExtensions[f.ContentType]
+			if f.Kind != model.AttachmentImage {
+				ext = attachmentExtensions[f.ContentType]
 			}
-			req.ImageURLs = append(req.ImageURLs, imageURL)
+			stagedFiles[i].ObjectKey = storage.NewKey(req.SiteID, ext)
 		}
 	}
 
@@ -194,12 +310,14 @@ func (h *Handler) CreateReport(w http.ResponseWriter, r *http.Request) {
 		FirstName:    req.FirstName,
 		LastName:     req.LastName,
 		ImageURLs:    req.ImageURLs,
+		CustomFields: customFields,
+		StagedFiles:  stagedFiles,
 		ReceivedAt:   time.Now().UTC().Format(time.RFC3339),
 		RetryCount:   0,
 	}
 
 	// Enqueue
-	if err := h.producer.Enqueue(r.Context(), msg); err != nil {
+	if err := h.producer.Enqueue(r.Context(), msg, fileData); err != nil {
 		slog.Error("enqueue failed", "error", err)
 		writeJSON(w, http.StatusServiceUnavailable, model.ErrorResponse{
 			Error: "service temporarily unavailable",
@@ -208,7 +326,7 @@ func (h *Handler) CreateReport(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	slog.Info("report queued", "event_id", eventID, "site_id", req.SiteID, "images", len(req.ImageURLs))
+	slog.Info("report queued", "event_id", eventID, "site_id", req.SiteID, "attachments", len(stagedFiles), "server", boundSite != "")
 
 	writeJSON(w, http.StatusAccepted, model.ReportResponse{
 		EventID: eventID,
@@ -216,17 +334,59 @@ func (h *Handler) CreateReport(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// ListSites handles GET /v1/sites — returns reportable domains.
+// siteInfo is the public description of a site in GET /v1/sites.
+type siteInfo struct {
+	Domain       string                `json:"domain"`
+	Name         string                `json:"name"`
+	ReportTypes  []model.TaxonomyEntry `json:"report_types"` // active and enabled for the site
+	Categories   []model.TaxonomyEntry `json:"categories"`   // active and enabled for the site
+	CustomFields []model.CustomField   `json:"custom_fields,omitempty"`
+}
+
+// ListSites handles GET /v1/sites — returns reportable domains, and their
+// display names, accepted report types and categories with their labels and
+// custom fields under "details".
+// Served from the current config, which holds the active rows of the sites
+// table layered over SITES_FILE or ALLOWED_SITES.
 func (h *Handler) ListSites(w http.ResponseWriter, r *http.Request) {
-	domains := h.cfg.ReportableDomains()
+	cfg := h.live.Get()
+	domains := cfg.ReportableDomains()
 	if domains == nil {
 		domains = []string{}
 	}
+	details := make([]siteInfo, 0, len(domains))
+	for _, d := range domains {
+		info := siteInfo{
+			Domain:       d,
+			Name:         cfg.SiteName(d),
+			ReportTypes:  cfg.ReportTypesFor(d),
+			Categories:   cfg.CategoriesFor(d),
+			CustomFields: cfg.CustomFieldsFor(d),
+		}
+		details = append(details, info)
+	}
 	writeJSON(w, http.StatusOK, map[string]any{
-		"sites": domains,
+		"sites":   domains,
+		"details": details,
 	})
 }
 
+// indexCSP is the Content-Security-Policy of the SPA. Besides Cloudflare Web
+// Analytics it allows the scripts, frames, styles and API calls of the
+// captcha widgets: Turnstile, hCaptcha and reCAPTCHA. The proof-of-work
+// solver runs in a worker served from 'self'.
+var indexCSP = strings.Join([]string{
+	"default-src 'self'",
+	"script-src 'self' 'unsafe-inline' https://challenges.cloudflare.com https://static.cloudflareinsights.com https://hcaptcha.com https://*.hcaptcha.com https://www.google.com/recaptcha/ https://www.gstatic.com/recaptcha/",
+	"style-src 'self' 'unsafe-inline' https://hcaptcha.com https://*.hcaptcha.com",
+	"img-src 'self' data: blob:",
+	"connect-src 'self' https://cloudflareinsights.com https://hcaptcha.com https://*.hcaptcha.com https://www.google.com/recaptcha/",
+	"frame-src https://challenges.cloudflare.com https://hcaptcha.com https://*.hcaptcha.com https://www.google.com/recaptcha/ https://recaptcha.google.com/recaptcha/",
+	"worker-src 'self'",
+	"frame-ancestors 'none'",
+	"font-src 'self' data:",
+}, "; ")
+
 // MountFrontend sets up SPA serving from the embedded dist/ directory.
 // Static assets are served with cache headers; all other paths get index.html with config injected.
 func (h *Handler) MountFrontend(r chi.Router, embeddedFS embed.FS) {
@@ -237,29 +397,19 @@ func (h *Handler) MountFrontend(r chi.Router, embeddedFS embed.FS) {
 		return
 	}
 
-	// Pre-read index.html and inject config once at startup
+	// Pre-read index.html; the config is injected per config snapshot
 	indexBytes, err := fs.ReadFile(distFS, "index.html")
 	if err != nil {
 		slog.Error("failed to read embedded index.html", "error", err)
 		return
 	}
 
-	reportableDomains := h.cfg.ReportableDomains()
-
-	configJSON, _ := json.Marshal(map[string]any{
-		"turnstileSiteKey": h.cfg.TurnstileSiteKey,
-		"sites":            reportableDomains,
-		"portalDomain":     h.cfg.PortalDomain,
-	})
-
-	injectedHTML := strings.Replace(string(indexBytes), "__APP_CONFIG_JSON__", string(configJSON), 1)
-
 	serveIndex := func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "text/html; charset=utf-8")
 		w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
-		w.Header().Set("Content-Security-Policy", "default-src 'self'; script-src 'self' 'unsafe-inline' https://challenges.cloudflare.com https://static.cloudflareinsights.com; style-src 'self' 'unsafe-inline'; img-src 'self' data: blob:; connect-src 'self' https://cloudflareinsights.com; frame-src https://challenges.cloudflare.com; frame-ancestors 'none'; font-src 'self' data:")
+		w.Header().Set("Content-Security-Policy", indexCSP)
 		w.WriteHeader(http.StatusOK)
-		w.Write([]byte(injectedHTML))
+		w.Write(h.indexHTML(indexBytes))
 	}
 
 	fileServer := http.FileServer(http.FS(distFS))
@@ -292,6 +442,42 @@ func (h *Handler) MountFrontend(r chi.Router, embeddedFS embed.FS) {
 	})
 }
 
+// renderedIndex is index.html with the config of one snapshot injected.
+type renderedIndex struct {
+	cfg  *config.Config
+	html []byte
+}
+
+// indexHTML returns index.html with the current config injected. It is
+// rendered again only after the config is reloaded.
+func (h *Handler) indexHTML(index []byte) []byte {
+	cfg := h.live.Get()
+	if cur := h.index.Load(); cur != nil && cur.cfg == cfg {
+		return cur.html
+	}
+
+	reportableDomains := cfg.ReportableDomains()
+
+	// Captcha provider and widget key per site; the SPA renders the widget
+	// of the selected site. Sites without an entry need no captcha.
+	captchas := make(map[string]map[string]string)
+	for _, site := range reportableDomains {
+		if p := cfg.CaptchaProviderFor(site); p != "" {
+			captchas[site] = map[string]string{"provider": p, "siteKey": cfg.CaptchaSiteKeyFor(site)}
+		}
+	}
+
+	configJSON, _ := json.Marshal(map[string]any{
+		"captcha":      captchas,
+		"sites":        reportableDomains,
+		"portalDomain": cfg.PortalDomain,
+	})
+
+	html := []byte(strings.Replace(string(index), "__APP_CONFIG_JSON__", string(configJSON), 1))
+	h.index.Store(&renderedIndex{cfg: cfg, html: html})
+	return html
+}
+
 // HealthCheck handles GET /health
 func (h *Handler) HealthCheck(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, map[string]string{
@@ -0,0 +1,144 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/devrimsoft/bug-notifications-api/internal/captcha"
+	"github.com/devrimsoft/bug-notifications-api/internal/config"
+	"github.com/devrimsoft/bug-notifications-api/internal/model"
+)
+
+func TestCreateReportVerifiesCaptchaBeforeReadingFiles(t *testing.T) {
+	var pass atomic.Bool
+	verify := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if pass.Load() {
+			w.Write([]byte(`{"success": true}`))
+			return
+		}
+		w.Write([]byte(`{"success": false, "error-codes": ["invalid-input-response"]}`))
+	}))
+	t.Cleanup(verify.Close)
+
+	live := config.NewLive(&config.Config{
+		Sites:              []string{"example.com"},
+		Taxonomy:           model.DefaultTaxonomy(),
+		CaptchaProviders:   map[string]string{"*": "turnstile"},
+		TurnstileSecretKey: "secret",
+		TurnstileVerifyURL: verify.URL,
+		ImageMaxPixels:     16_000_000,
+	})
+	h := NewHandler(nil, nil, captcha.New(live, nil), func(http.ResponseWriter, *http.Request, string) bool { return true }, live)
+
+	post := func() *httptest.ResponseRecorder {
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		for k, v := range map[string]string{
+			"site_id":               "example.com",
+			"title":                 "Broken button",
+			"description":           "Nothing happens on click",
+			"category":              "functionality",
+			"cf-turnstile-response": "token",
+		} {
+			mw.WriteField(k, v)
+		}
+		fw, _ := mw.CreateFormFile("images", "shot.png")
+		fw.Write([]byte("not really a png"))
+		mw.Close()
+
+		r := httptest.NewRequest(http.MethodPost, "/v1/reports", &body)
+		r.Header.Set("Content-Type", mw.FormDataContentType())
+		w := httptest.NewRecorder()
+		h.CreateReport(w, r)
+		return w
+	}
+
+	if w := post(); w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), "TURNSTILE_FAILED") {
+		t.Errorf("failed captcha: got %d %s, want 403 TURNSTILE_FAILED before the image is read", w.Code, w.Body.String())
+	}
+
+	pass.Store(true)
+	if w := post(); w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "INVALID_IMAGE") {
+		t.Errorf("passed captcha: got %d %s, want 400 INVALID_IMAGE", w.Code, w.Body.String())
+	}
+}
+
+func TestIndexHTMLInjectsCaptchaConfig(t *testing.T) {
+	live := config.NewLive(&config.Config{
+		Sites: []string{"a.com", "b.com", "c.com", "d.com"},
+		CaptchaProviders: map[string]string{
+			"*":     "turnstile",
+			"b.com": "hcaptcha",
+			"c.com": "pow",
+			"d.com": "none",
+		},
+		TurnstileSiteKey: "ts-key",
+		HCaptchaSiteKey:  "hc-key",
+		PortalDomain:     "feedback.example.com",
+	})
+	h := NewHandler(nil, nil, nil, nil, live)
+
+	html := string(h.indexHTML([]byte(`<script>window.__APP_CONFIG__ = __APP_CONFIG_JSON__;</script>`)))
+	raw, ok := strings.CutPrefix(html, "<script>window.__APP_CONFIG__ = ")
+	if !ok {
+		t.Fatalf("unexpected index: %s", html)
+	}
+	raw = strings.TrimSuffix(raw, ";</script>")
+
+	var got struct {
+		Captcha      map[string]map[string]string `json:"captcha"`
+		Sites        []string                     `json:"sites"`
+		PortalDomain string                       `json:"portalDomain"`
+	}
+	if err := json.Unmarshal([]byte(raw), &got); err != nil {
+		t.Fatalf("injected config %s: %v", raw, err)
+	}
+	want := map[string]map[string]string{
+		"a.com": {"provider": "turnstile", "siteKey": "ts-key"},
+		"b.com": {"provider": "hcaptcha", "siteKey": "hc-key"},
+		"c.com": {"provider": "pow", "siteKey": ""},
+	}
+	if len(got.Captcha) != len(want) {
+		t.Errorf("captcha = %v, want %v", got.Captcha, want)
+	}
+	for site, w := range want {
+		if g := got.Captcha[site]; g["provider"] != w["provider"] || g["siteKey"] != w["siteKey"] {
+			t.Errorf("captcha[%s] = %v, want %v", site, g, w)
+		}
+	}
+	if len(got.Sites) != 4 || got.PortalDomain != "feedback.example.com" {
+		t.Errorf("sites = %v, portalDomain = %q", got.Sites, got.PortalDomain)
+	}
+}
+
+func TestIndexCSPAllowsCaptchaWidgets(t *testing.T) {
+	directives := make(map[string]string)
+	for _, d := range strings.Split(indexCSP, "; ") {
+		name, value, _ := strings.Cut(d, " ")
+		directives[name] = value
+	}
+	tests := []struct {
+		directive, source string
+	}{
+		{"script-src", "https://challenges.cloudflare.com"},
+		{"script-src", "https://*.hcaptcha.com"},
+		{"script-src", "https://www.google.com/recaptcha/"},
+		{"script-src", "https://www.gstatic.com/recaptcha/"},
+		{"frame-src", "https://challenges.cloudflare.com"},
+		{"frame-src", "https://*.hcaptcha.com"},
+		{"frame-src", "https://www.google.com/recaptcha/"},
+		{"worker-src", "'self'"},
+		{"frame-ancestors", "'none'"},
+	}
+	for _, tt := range tests {
+		if !strings.Contains(" "+directives[tt.directive]+" ", " "+tt.source+" ") {
+			t.Errorf("%s %q does not allow %s", tt.directive, directives[tt.directive], tt.source)
+		}
+	}
+}
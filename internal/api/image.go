@@ -2,13 +2,10 @@ package api
 
 import (
 	"bytes"
-	"encoding/json"
 	"fmt"
 	"io"
 	"mime/multipart"
-	"net/http"
 	"strings"
-	"time"
 )
 
 const (
@@ -32,6 +29,14 @@ var allowedExtensions = map[string]bool{
 	".gif":  true,
 }
 
+// imageExtensions maps detected MIME types to the extension stored images get.
+var imageExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/webp": ".webp",
+	"image/gif":  ".gif",
+}
+
 // magicBytes maps file signatures to MIME types for content-based detection.
 var magicBytes = []struct {
 	offset int
@@ -45,12 +50,6 @@ var magicBytes = []struct {
 	{0, []byte("GIF89a"), "image/gif"},
 }
 
-// imageUploadResponse is the R2 API response.
-type imageUploadResponse struct {
-	Success bool   `json:"success"`
-	URL     string `json:"url"`
-}
-
 // validateImage checks file size, extension, and magic bytes.
 // Returns the validated file data and detected MIME type, or an error.
 func validateImage(fh *multipart.FileHeader) ([]byte, string, error) {
@@ -111,47 +110,3 @@ func detectMimeFromBytes(data []byte) string {
 	}
 	return ""
 }
-
-// uploadToR2 sends the image to the R2 Image Processor API and returns the public URL.
-func uploadToR2(apiURL, apiKey string, fileData []byte, filename string) (string, error) {
-	var buf bytes.Buffer
-	writer := multipart.NewWriter(&buf)
-
-	part, err := writer.CreateFormFile("file", filename)
-	if err != nil {
-		return "", fmt.Errorf("create form file: %w", err)
-	}
-	if _, err := part.Write(fileData); err != nil {
-		return "", fmt.Errorf("write file data: %w", err)
-	}
-	writer.Close()
-
-	req, err := http.NewRequest(http.MethodPost, apiURL+"/upload", &buf)
-	if err != nil {
-		return "", fmt.Errorf("create request: %w", err)
-	}
-	req.Header.Set("Content-Type", writer.FormDataContentType())
-	req.Header.Set("X-API-Key", apiKey)
-
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		return "", fmt.Errorf("r2 upload request failed: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
-		return "", fmt.Errorf("r2 upload failed with status %d: %s", resp.StatusCode, string(body))
-	}
-
-	var result imageUploadResponse
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return "", fmt.Errorf("decode r2 response: %w", err)
-	}
-	if !result.Success || result.URL == "" {
-		return "", fmt.Errorf("r2 upload returned no url")
-	}
-
-	return result.URL, nil
-}
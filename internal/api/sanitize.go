@@ -0,0 +1,374 @@
+package api
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+
+	"golang.org/x/image/draw"
+	"golang.org/x/image/webp"
+)
+
+const jpegQuality = 90
+
+var errNotImage = errors.New("file content is not a valid image (jpg, png, webp, gif)")
+
+// imageLimits bounds the images sanitizeImage accepts and produces.
+type imageLimits struct {
+	MaxPixels int // reject images with more pixels (all GIF frames together)
+	MaxSide   int // downscale so neither side is longer; 0 keeps the size
+}
+
+// sanitizeImage rewrites a validated image so that no metadata (EXIF and GPS,
+// XMP, comments, ICC profiles) reaches storage. JPEG, PNG and GIF are decoded
+// and re-encoded. WebP can't be encoded in Go, so its container is rebuilt
+// without the metadata chunks; a WebP that has to be rotated or downscaled is
+// stored as JPEG, or PNG if it has transparency.
+//
+// EXIF orientation is applied before it is dropped, so photos keep their
+// rotation. Animated GIF and WebP are never downscaled.
+//
+// Returns the new data and its MIME type.
+func sanitizeImage(data []byte, mime string, lim imageLimits) ([]byte, string, error) {
+	switch mime {
+	case "image/jpeg":
+		return sanitizeJPEG(data, lim)
+	case "image/png":
+		return sanitizePNG(data, lim)
+	case "image/gif":
+		return sanitizeGIF(data, lim)
+	case "image/webp":
+		return sanitizeWebP(data, lim)
+	}
+	return nil, "", errNotImage
+}
+
+func sanitizeJPEG(data []byte, lim imageLimits) ([]byte, string, error) {
+	cfg, err := jpeg.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", errNotImage
+	}
+	if err := checkPixels(int64(cfg.Width)*int64(cfg.Height), lim); err != nil {
+		return nil, "", err
+	}
+	img, err := jpeg.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", errNotImage
+	}
+
+	img = downscale(orient(img, jpegOrientation(data)), lim.MaxSide)
+	return encodeJPEG(img)
+}
+
+func sanitizePNG(data []byte, lim imageLimits) ([]byte, string, error) {
+	cfg, err := png.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", errNotImage
+	}
+	if err := checkPixels(int64(cfg.Width)*int64(cfg.Height), lim); err != nil {
+		return nil, "", err
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", errNotImage
+	}
+
+	return encodePNG(downscale(img, lim.MaxSide))
+}
+
+func sanitizeGIF(data []byte, lim imageLimits) ([]byte, string, error) {
+	// Frames are counted before decoding; gif.DecodeAll would allocate them all
+	pixels, err := gifPixels(data)
+	if err != nil {
+		return nil, "", errNotImage
+	}
+	if err := checkPixels(pixels, lim); err != nil {
+		return nil, "", err
+	}
+	g, err := gif.DecodeAll(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", errNotImage
+	}
+
+	// Comments and application extensions are not decoded, so they are gone
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, g); err != nil {
+		return nil, "", fmt.Errorf("failed to process image")
+	}
+	return buf.Bytes(), "image/gif", nil
+}
+
+// WebP chunks that carry image data; everything else is dropped.
+var webpImageChunks = map[string]bool{
+	"VP8X": true,
+	"VP8 ": true,
+	"VP8L": true,
+	"ALPH": true,
+	"ANIM": true,
+	"ANMF": true,
+}
+
+func sanitizeWebP(data []byte, lim imageLimits) ([]byte, string, error) {
+	cfg, err := webp.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", errNotImage
+	}
+	if err := checkPixels(int64(cfg.Width)*int64(cfg.Height), lim); err != nil {
+		return nil, "", err
+	}
+
+	const (
+		animationBit = 1 << 1
+		metadataBits = 1<<2 | 1<<3 | 1<<5 // XMP, EXIF, ICC profile
+	)
+	// The RIFF header is fixed up once the size is known
+	out := append([]byte(nil), data[:12]...)
+	orientation := 1
+	animated := false
+	for p := 12; p+8 <= len(data); {
+		id := string(data[p : p+4])
+		size := int(binary.LittleEndian.Uint32(data[p+4:]))
+		end := p + 8 + size
+		if size < 0 || end > len(data) {
+			return nil, "", errNotImage
+		}
+		chunk := data[p:end]
+		switch {
+		case id == "EXIF":
+			orientation = exifOrientation(chunk[8:])
+		case id == "VP8X" && size >= 1:
+			animated = chunk[8]&animationBit != 0
+			chunk = append([]byte(nil), chunk...)
+			chunk[8] &^= metadataBits
+		}
+		if webpImageChunks[id] {
+			out = append(out, chunk...)
+			if size%2 == 1 {
+				out = append(out, 0)
+			}
+		}
+		p = end + size%2
+	}
+	binary.LittleEndian.PutUint32(out[4:], uint32(len(out)-8))
+
+	if animated {
+		return out, "image/webp", nil
+	}
+
+	// Still images are decoded in full, so broken files are rejected
+	img, err := webp.Decode(bytes.NewReader(out))
+	if err != nil {
+		return nil, "", errNotImage
+	}
+	if orientation == 1 && !needsDownscale(img.Bounds(), lim.MaxSide) {
+		return out, "image/webp", nil
+	}
+	rgba := downscale(orient(img, orientation), lim.MaxSide)
+	if opaque, ok := rgba.(interface{ Opaque() bool }); ok && opaque.Opaque() {
+		return encodeJPEG(rgba)
+	}
+	return encodePNG(rgba)
+}
+
+func checkPixels(pixels int64, lim imageLimits) error {
+	if pixels <= 0 {
+		return errNotImage
+	}
+	if pixels > int64(lim.MaxPixels) {
+		return fmt.Errorf("image is too large, at most %g megapixels allowed", float64(lim.MaxPixels)/1e6)
+	}
+	return nil
+}
+
+func encodeJPEG(img image.Image) ([]byte, string, error) {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: jpegQuality}); err != nil {
+		return nil, "", fmt.Errorf("failed to process image")
+	}
+	return buf.Bytes(), "image/jpeg", nil
+}
+
+func encodePNG(img image.Image) ([]byte, string, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, "", fmt.Errorf("failed to process image")
+	}
+	return buf.Bytes(), "image/png", nil
+}
+
+func needsDownscale(b image.Rectangle, maxSide int) bool {
+	return maxSide > 0 && (b.Dx() > maxSide || b.Dy() > maxSide)
+}
+
+// downscale shrinks img so neither side is longer than maxSide, keeping the
+// aspect ratio.
+func downscale(img image.Image, maxSide int) image.Image {
+	b := img.Bounds()
+	if !needsDownscale(b, maxSide) {
+		return img
+	}
+	w, h := maxSide, maxSide
+	if b.Dx() > b.Dy() {
+		h = max(1, b.Dy()*maxSide/b.Dx())
+	} else {
+		w = max(1, b.Dx()*maxSide/b.Dy())
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, b, draw.Src, nil)
+	return dst
+}
+
+// orient applies an EXIF orientation (1-8) so the image displays upright
+// without it.
+func orient(img image.Image, orientation int) image.Image {
+	if orientation < 2 || orientation > 8 {
+		return img
+	}
+	b := img.Bounds()
+	src := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(src, src.Bounds(), img, b.Min, draw.Src)
+
+	w, h := b.Dx(), b.Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w // rotated a quarter turn
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			var sx, sy int
+			switch orientation {
+			case 2: // flip horizontally
+				sx, sy = w-1-x, y
+			case 3: // rotate 180
+				sx, sy = w-1-x, h-1-y
+			case 4: // flip vertically
+				sx, sy = x, h-1-y
+			case 5: // transpose
+				sx, sy = y, x
+			case 6: // rotate 90 clockwise
+				sx, sy = y, h-1-x
+			case 7: // transverse
+				sx, sy = w-1-y, h-1-x
+			case 8: // rotate 90 counterclockwise
+				sx, sy = w-1-y, x
+			}
+			copy(dst.Pix[dst.PixOffset(x, y):][:4], src.Pix[src.PixOffset(sx, sy):][:4])
+		}
+	}
+	return dst
+}
+
+// jpegOrientation returns the EXIF orientation of a JPEG, or 1 if it has none.
+func jpegOrientation(data []byte) int {
+	p := 2 // after SOI
+	for p+4 <= len(data) && data[p] == 0xFF {
+		marker := data[p+1]
+		if marker == 0xDA { // start of scan, no metadata after this
+			break
+		}
+		size := int(binary.BigEndian.Uint16(data[p+2:]))
+		end := p + 2 + size
+		if size < 2 || end > len(data) {
+			break
+		}
+		if marker == 0xE1 && bytes.HasPrefix(data[p+4:end], []byte("Exif\x00\x00")) {
+			return exifOrientation(data[p+4 : end])
+		}
+		p = end
+	}
+	return 1
+}
+
+// exifOrientation reads the orientation tag from EXIF data (a TIFF header
+// and IFD0, optionally after an "Exif\0\0" prefix). Returns 1 if it is
+// missing or invalid.
+func exifOrientation(exif []byte) int {
+	tiff := bytes.TrimPrefix(exif, []byte("Exif\x00\x00"))
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd < 8 || ifd+2 > len(tiff) {
+		return 1
+	}
+	n := int(order.Uint16(tiff[ifd:]))
+	for i := 0; i < n; i++ {
+		e := ifd + 2 + i*12
+		if e+12 > len(tiff) {
+			break
+		}
+		if order.Uint16(tiff[e:]) == 0x0112 { // Orientation, a SHORT
+			if o := int(order.Uint16(tiff[e+8:])); o >= 1 && o <= 8 {
+				return o
+			}
+			break
+		}
+	}
+	return 1
+}
+
+// gifPixels adds up the frame sizes of a GIF by walking its blocks, without
+// decoding any image data.
+func gifPixels(data []byte) (int64, error) {
+	if len(data) < 13 {
+		return 0, errNotImage
+	}
+	p := 13 // header and logical screen descriptor
+	if data[10]&0x80 != 0 {
+		p += 3 << (data[10]&0x07 + 1) // global color table
+	}
+
+	var total int64
+	for p < len(data) {
+		switch data[p] {
+		case 0x21: // extension: label, then sub-blocks
+			p = skipGIFSubBlocks(data, p+2)
+		case 0x2C: // image descriptor
+			if p+10 > len(data) {
+				return 0, errNotImage
+			}
+			w := binary.LittleEndian.Uint16(data[p+5:])
+			h := binary.LittleEndian.Uint16(data[p+7:])
+			total += int64(w) * int64(h)
+			flags := data[p+9]
+			p += 10
+			if flags&0x80 != 0 {
+				p += 3 << (flags&0x07 + 1) // local color table
+			}
+			p = skipGIFSubBlocks(data, p+1) // after the LZW minimum code size
+		case 0x3B: // trailer
+			return total, nil
+		default:
+			return 0, errNotImage
+		}
+	}
+	return total, nil
+}
+
+func skipGIFSubBlocks(data []byte, p int) int {
+	for p < len(data) {
+		n := int(data[p])
+		p++
+		if n == 0 {
+			break
+		}
+		p += n
+	}
+	return p
+}
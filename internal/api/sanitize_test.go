@@ -0,0 +1,158 @@
+package api
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+func testImage(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for i := range img.Pix {
+		img.Pix[i] = 0xff
+	}
+	return img
+}
+
+func encodeTestPNG(t *testing.T, w, h int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, testImage(w, h)); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func encodeTestJPEG(t *testing.T, w, h int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, testImage(w, h), nil); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func encodeTestGIF(t *testing.T, w, h, frames int) []byte {
+	t.Helper()
+	g := &gif.GIF{}
+	for range frames {
+		g.Image = append(g.Image, image.NewPaletted(image.Rect(0, 0, w, h), color.Palette{color.Black, color.White}))
+		g.Delay = append(g.Delay, 10)
+	}
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, g); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// pngClaiming returns a small PNG whose header claims w x h pixels.
+func pngClaiming(t *testing.T, w, h uint32) []byte {
+	t.Helper()
+	data := encodeTestPNG(t, 1, 1)
+	// IHDR: length(4) type(4) width(4) height(4) ... crc(4), after the 8-byte signature
+	binary.BigEndian.PutUint32(data[16:], w)
+	binary.BigEndian.PutUint32(data[20:], h)
+	binary.BigEndian.PutUint32(data[29:], crc32.ChecksumIEEE(data[12:29]))
+	return data
+}
+
+// jpegClaiming returns a small JPEG whose frame header claims w x h pixels.
+func jpegClaiming(t *testing.T, w, h uint16) []byte {
+	t.Helper()
+	data := encodeTestJPEG(t, 8, 8)
+	sof := bytes.Index(data, []byte{0xFF, 0xC0})
+	if sof < 0 {
+		t.Fatal("no SOF0 marker")
+	}
+	binary.BigEndian.PutUint16(data[sof+5:], h)
+	binary.BigEndian.PutUint16(data[sof+7:], w)
+	return data
+}
+
+// webpClaiming returns an extended-format WebP header for a w x h canvas,
+// with no image data.
+func webpClaiming(w, h uint32) []byte {
+	vp8x := make([]byte, 10)
+	vp8x[4], vp8x[5], vp8x[6] = byte(w-1), byte((w-1)>>8), byte((w-1)>>16)
+	vp8x[7], vp8x[8], vp8x[9] = byte(h-1), byte((h-1)>>8), byte((h-1)>>16)
+	chunk := append([]byte("VP8X\x0a\x00\x00\x00"), vp8x...)
+	data := append([]byte("RIFF\x00\x00\x00\x00WEBP"), chunk...)
+	binary.LittleEndian.PutUint32(data[4:], uint32(len(data)-8))
+	return data
+}
+
+func TestSanitizeImagePixelLimit(t *testing.T) {
+	lim := imageLimits{MaxPixels: 16_000_000}
+	tests := []struct {
+		name    string
+		data    []byte
+		mime    string
+		wantErr string // "" means accepted
+	}{
+		{"png within limit", encodeTestPNG(t, 64, 48), "image/png", ""},
+		{"png bomb", pngClaiming(t, 20000, 20000), "image/png", "too large"},
+		{"png just over", pngClaiming(t, 4001, 4000), "image/png", "too large"},
+		{"png zero width", pngClaiming(t, 0, 10), "image/png", "not a valid image"},
+		{"jpeg within limit", encodeTestJPEG(t, 64, 48), "image/jpeg", ""},
+		{"jpeg bomb", jpegClaiming(t, 65000, 65000), "image/jpeg", "too large"},
+		{"webp bomb", webpClaiming(16000, 16000), "image/webp", "too large"},
+		{"gif frames within limit", encodeTestGIF(t, 2000, 2000, 4), "image/gif", ""},
+		{"gif frames over limit", encodeTestGIF(t, 2000, 2000, 5), "image/gif", "too large"},
+		{"truncated gif", []byte("GIF89a"), "image/gif", "not a valid image"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := sanitizeImage(tt.data, tt.mime, lim)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("sanitizeImage: %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("sanitizeImage error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckPixels(t *testing.T) {
+	lim := imageLimits{MaxPixels: 1000}
+	tests := []struct {
+		pixels  int64
+		wantErr bool
+	}{
+		{-1, true},
+		{0, true},
+		{1, false},
+		{1000, false},
+		{1001, true},
+	}
+	for _, tt := range tests {
+		if err := checkPixels(tt.pixels, lim); (err != nil) != tt.wantErr {
+			t.Errorf("checkPixels(%d) = %v, wantErr %v", tt.pixels, err, tt.wantErr)
+		}
+	}
+	if err := checkPixels(2_000_000, imageLimits{MaxPixels: 1_500_000}); err == nil || err.Error() != "image is too large, at most 1.5 megapixels allowed" {
+		t.Errorf("error message = %v", err)
+	}
+}
+
+func TestSanitizeImageDownscale(t *testing.T) {
+	out, mime, err := sanitizeImage(encodeTestPNG(t, 400, 100), "image/png", imageLimits{MaxPixels: 16_000_000, MaxSide: 200})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := png.DecodeConfig(bytes.NewReader(out))
+	if err != nil || mime != "image/png" {
+		t.Fatalf("output %s: %v", mime, err)
+	}
+	if cfg.Width != 200 || cfg.Height != 50 {
+		t.Errorf("downscaled to %dx%d, want 200x50", cfg.Width, cfg.Height)
+	}
+}
@@ -0,0 +1,75 @@
+package api
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/devrimsoft/bug-notifications-api/internal/model"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// ReportStatus handles GET /v1/reports/{event_id}/status
+// Tells the reporter whether their report is still queued, stored (with its
+// triage status, and whether its images are still uploading), dead-lettered
+// or quarantined. Only the state is returned, never the report contents or
+// contact details. Both lookups are by key.
+func (h *Handler) ReportStatus(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "event_id"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, model.ErrorResponse{
+			Error: "invalid event_id",
+			Code:  "INVALID_EVENT_ID",
+		})
+		return
+	}
+	eventID := id.String()
+
+	w.Header().Set("Cache-Control", "no-store")
+
+	report, err := h.repo.GetReport(r.Context(), eventID)
+	if err != nil {
+		slog.Error("report status lookup failed", "error", err, "event_id", eventID)
+		writeJSON(w, http.StatusServiceUnavailable, model.ErrorResponse{
+			Error: "service temporarily unavailable",
+			Code:  "DB_ERROR",
+		})
+		return
+	}
+	if report != nil {
+		state := model.StateStored
+		if report.AttachmentsPending {
+			state = model.StateAttachmentsPending
+		}
+		writeJSON(w, http.StatusOK, model.ReportStatusResponse{
+			EventID: eventID,
+			State:   state,
+			Status:  model.ReportStatus(report.Status),
+		})
+		return
+	}
+
+	// Not stored yet: the event must have been accepted recently to be in the
+	// queue, the retry schedule, the DLQ or quarantine.
+	state, err := h.producer.State(r.Context(), eventID)
+	if err != nil {
+		slog.Error("report status lookup failed", "error", err, "event_id", eventID)
+		writeJSON(w, http.StatusServiceUnavailable, model.ErrorResponse{
+			Error: "service temporarily unavailable",
+			Code:  "QUEUE_ERROR",
+		})
+		return
+	}
+	if state == "" {
+		writeJSON(w, http.StatusNotFound, model.ErrorResponse{
+			Error: "report not found",
+			Code:  "NOT_FOUND",
+		})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, model.ReportStatusResponse{
+		EventID: eventID,
+		State:   state,
+	})
+}
@@ -0,0 +1,152 @@
+// Package apikey issues and checks the per-site API keys used for
+// server-to-server report submission.
+//
+// Keys are 256-bit random tokens; only their SHA-256 hash is stored. A site
+// may hold several valid keys at once, so a key can be rotated by issuing a
+// new one and letting the old one expire after an overlap window.
+package apikey
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/devrimsoft/bug-notifications-api/internal/db"
+	"github.com/devrimsoft/bug-notifications-api/internal/model"
+	"github.com/google/uuid"
+)
+
+const (
+	// keyPrefix marks a string as one of our keys (helps secret scanners).
+	keyPrefix = "bnk_"
+
+	// displayPrefixLen is how much of a key is kept in clear to identify it.
+	displayPrefixLen = len(keyPrefix) + 8
+
+	// touchInterval is how often a key's last_used_at is updated at most.
+	touchInterval = time.Minute
+)
+
+// ErrUnknownSite is returned when a key is issued for a site that is not in
+// the sites table.
+var ErrUnknownSite = errors.New("unknown site")
+
+// Generate returns a new random key.
+func Generate() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate api key: %w", err)
+	}
+	return keyPrefix + hex.EncodeToString(b), nil
+}
+
+// Hash returns the stored form of a key.
+func Hash(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// Service manages site API keys.
+type Service struct {
+	repo *db.Repository
+
+	mu      sync.Mutex
+	touched map[string]time.Time // key ID -> last recorded use
+}
+
+func NewService(repo *db.Repository) *Service {
+	return &Service{repo: repo, touched: make(map[string]time.Time)}
+}
+
+// Create issues a new key for a site, valid from now until expiresAt (nil
+// means no expiry). The plaintext key is returned once and never stored.
+// The site must be registered in the sites table.
+func (s *Service) Create(ctx context.Context, siteID, name string, expiresAt *time.Time) (*model.SiteAPIKey, string, error) {
+	siteID = strings.ToLower(strings.TrimSpace(siteID))
+	site, err := s.repo.GetSite(ctx, siteID)
+	if err != nil {
+		return nil, "", err
+	}
+	if site == nil {
+		return nil, "", fmt.Errorf("%w %q: configure it and reload the API, or add it through the admin API", ErrUnknownSite, siteID)
+	}
+
+	key, err := Generate()
+	if err != nil {
+		return nil, "", err
+	}
+	k := &model.SiteAPIKey{
+		ID:        uuid.New().String(),
+		SiteID:    siteID,
+		Name:      name,
+		Prefix:    key[:displayPrefixLen],
+		ValidFrom: time.Now().UTC(),
+		ExpiresAt: expiresAt,
+	}
+	if err := s.repo.CreateSiteAPIKey(ctx, k, Hash(key)); err != nil {
+		return nil, "", err
+	}
+	return k, key, nil
+}
+
+// Rotate issues a new key for a site and makes the site's other keys expire
+// once overlap has passed, so clients can switch over without downtime.
+// Returns the new key and how many old keys were scheduled to expire.
+func (s *Service) Rotate(ctx context.Context, siteID, name string, overlap time.Duration) (*model.SiteAPIKey, string, int64, error) {
+	k, key, err := s.Create(ctx, siteID, name, nil)
+	if err != nil {
+		return nil, "", 0, err
+	}
+	n, err := s.repo.ExpireSiteAPIKeys(ctx, k.SiteID, k.ID, time.Now().UTC().Add(overlap))
+	if err != nil {
+		return k, key, 0, fmt.Errorf("new key %s created, but old keys were not expired: %w", k.Prefix, err)
+	}
+	return k, key, n, nil
+}
+
+// Authenticate returns the active key matching a plaintext key, or nil if
+// the key is unknown, revoked, expired or not yet valid.
+func (s *Service) Authenticate(ctx context.Context, key string) (*model.SiteAPIKey, error) {
+	if !strings.HasPrefix(key, keyPrefix) {
+		return nil, nil
+	}
+	k, err := s.repo.FindSiteAPIKeyByHash(ctx, Hash(key))
+	if err != nil || k == nil {
+		return nil, err
+	}
+	now := time.Now()
+	if !k.Active(now) {
+		return nil, nil
+	}
+	if s.shouldTouch(k.ID, now) {
+		if err := s.repo.TouchSiteAPIKey(ctx, k.ID); err != nil {
+			slog.Warn("record api key use failed", "key", k.Prefix, "error", err)
+		}
+	}
+	return k, nil
+}
+
+// shouldTouch reports whether a key's use should be written to the database,
+// so a busy key costs one UPDATE per touchInterval per process rather than
+// one per request.
+func (s *Service) shouldTouch(id string, now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if last, ok := s.touched[id]; ok && now.Sub(last) < touchInterval {
+		return false
+	}
+	for kid, last := range s.touched {
+		if now.Sub(last) >= touchInterval {
+			delete(s.touched, kid)
+		}
+	}
+	s.touched[id] = now
+	return true
+}
@@ -0,0 +1,51 @@
+package apikey
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGenerate(t *testing.T) {
+	a, err := Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := Generate()
+	if !strings.HasPrefix(a, keyPrefix) || len(a) != len(keyPrefix)+64 {
+		t.Errorf("Generate = %q, want %s + 64 hex characters", a, keyPrefix)
+	}
+	if a == b {
+		t.Error("Generate returned the same key twice")
+	}
+	if Hash(a) == Hash(b) || Hash(a) != Hash(a) {
+		t.Error("Hash is not a stable per-key digest")
+	}
+}
+
+func TestShouldTouch(t *testing.T) {
+	s := NewService(nil)
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	steps := []struct {
+		id    string
+		after time.Duration
+		want  bool
+	}{
+		{"a", 0, true},
+		{"a", time.Second, false},
+		{"b", time.Second, true},
+		{"a", touchInterval - time.Second, false},
+		{"a", touchInterval, true},
+		{"a", touchInterval + time.Second, false},
+		{"b", 2 * touchInterval, true},
+	}
+	for i, st := range steps {
+		if got := s.shouldTouch(st.id, start.Add(st.after)); got != st.want {
+			t.Errorf("step %d: shouldTouch(%s, +%v) = %v, want %v", i, st.id, st.after, got, st.want)
+		}
+	}
+	if len(s.touched) != 1 {
+		t.Errorf("touched holds %d keys, want stale entries dropped", len(s.touched))
+	}
+}
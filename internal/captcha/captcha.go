@@ -0,0 +1,96 @@
+// Package captcha verifies the bot-protection token sent with browser
+// reports. Each site uses one provider, chosen with CAPTCHA_PROVIDERS:
+//
+//	turnstile  Cloudflare Turnstile
+//	hcaptcha   hCaptcha
+//	recaptcha  Google reCAPTCHA v3, with a minimum score
+//	pow        a proof-of-work challenge issued by this API, no third party
+package captcha
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/devrimsoft/bug-notifications-api/internal/config"
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	ErrMissingToken = errors.New("captcha token is required")
+	ErrFailed       = errors.New("captcha verification failed")
+)
+
+// Verifier checks a token solved in the browser.
+type Verifier interface {
+	Verify(ctx context.Context, token, remoteIP string) error
+}
+
+// Service picks the verifier of a site. The providers are set up again
+// when the configuration is reloaded.
+type Service struct {
+	live *config.Live
+	rdb  *redis.Client
+
+	mu        sync.Mutex
+	cfg       *config.Config // the config verifiers and pow were set up for
+	verifiers map[string]Verifier
+	pow       *PoW
+}
+
+func New(live *config.Live, rdb *redis.Client) *Service {
+	return &Service{live: live, rdb: rdb}
+}
+
+// providers returns the config and the providers set up for it.
+func (s *Service) providers() (*config.Config, map[string]Verifier, *PoW) {
+	cfg := s.live.Get()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cfg != cfg {
+		s.cfg = cfg
+		s.verifiers, s.pow = setup(cfg, s.rdb)
+	}
+	return s.cfg, s.verifiers, s.pow
+}
+
+// setup creates the providers that have credentials; CAPTCHA_PROVIDERS is
+// checked against them when the config is loaded.
+func setup(cfg *config.Config, rdb *redis.Client) (map[string]Verifier, *PoW) {
+	verifiers := make(map[string]Verifier)
+	if cfg.TurnstileSecretKey != "" {
+		verifiers["turnstile"] = NewSiteverify(cfg.TurnstileVerifyURL, cfg.TurnstileSecretKey, 0)
+	}
+	if cfg.HCaptchaSecretKey != "" {
+		verifiers["hcaptcha"] = NewSiteverify(cfg.HCaptchaVerifyURL, cfg.HCaptchaSecretKey, 0)
+	}
+	if cfg.ReCAPTCHASecretKey != "" {
+		verifiers["recaptcha"] = NewSiteverify(cfg.ReCAPTCHAVerifyURL, cfg.ReCAPTCHASecretKey, cfg.ReCAPTCHAMinScore)
+	}
+	var pow *PoW
+	for _, p := range cfg.CaptchaProviders {
+		if p == "pow" {
+			pow = NewPoW(rdb, cfg.PoWDifficulty)
+			verifiers["pow"] = pow
+			break
+		}
+	}
+	return verifiers, pow
+}
+
+// For returns the provider name and verifier of a site, or a nil verifier
+// if the site's reports are not checked.
+func (s *Service) For(siteID string) (string, Verifier) {
+	cfg, verifiers, _ := s.providers()
+	p := cfg.CaptchaProviderFor(siteID)
+	if p == "" {
+		return "", nil
+	}
+	return p, verifiers[p]
+}
+
+// PoW returns the proof-of-work provider, or nil if no site uses it.
+func (s *Service) PoW() *PoW {
+	_, _, pow := s.providers()
+	return pow
+}
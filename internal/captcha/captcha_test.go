@@ -0,0 +1,90 @@
+package captcha
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/devrimsoft/bug-notifications-api/internal/config"
+)
+
+func TestServiceFor(t *testing.T) {
+	ctx := context.Background()
+	turnstile := newSiteverifyStub(t, "ts-secret")
+	hcaptcha := newSiteverifyStub(t, "hc-secret")
+	recaptcha := newSiteverifyStub(t, "rc-secret")
+	_, rdb := newTestRedis(t)
+
+	t.Setenv("DATABASE_URL", "postgres://localhost/bugs")
+	t.Setenv("ALLOWED_SITES", "a.com,b.com,c.com,d.com,e.com")
+	t.Setenv("TURNSTILE_SITE_KEY", "ts-site")
+	t.Setenv("TURNSTILE_SECRET_KEY", "ts-secret")
+	t.Setenv("TURNSTILE_VERIFY_URL", turnstile.URL)
+	t.Setenv("HCAPTCHA_SITE_KEY", "hc-site")
+	t.Setenv("HCAPTCHA_SECRET_KEY", "hc-secret")
+	t.Setenv("HCAPTCHA_VERIFY_URL", hcaptcha.URL)
+	t.Setenv("RECAPTCHA_SITE_KEY", "rc-site")
+	t.Setenv("RECAPTCHA_SECRET_KEY", "rc-secret")
+	t.Setenv("RECAPTCHA_VERIFY_URL", recaptcha.URL)
+	t.Setenv("RECAPTCHA_MIN_SCORE", "0.7")
+	t.Setenv("CAPTCHA_PROVIDERS", "*:turnstile,b.com:hcaptcha,c.com:recaptcha,d.com:pow,e.com:none")
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	live := config.NewLive(cfg)
+	s := New(live, rdb)
+
+	// Each site's token goes to its own provider
+	sites := []struct {
+		site     string
+		provider string
+		stub     *siteverifyStub
+	}{
+		{site: "a.com", provider: "turnstile", stub: turnstile},
+		{site: "unknown.com", provider: "turnstile", stub: turnstile},
+		{site: "b.com", provider: "hcaptcha", stub: hcaptcha},
+		{site: "c.com", provider: "recaptcha", stub: recaptcha},
+	}
+	for _, tt := range sites {
+		name, v := s.For(tt.site)
+		if name != tt.provider || v == nil {
+			t.Fatalf("For(%s) = %q, %v; want %s", tt.site, name, v, tt.provider)
+		}
+		token := "ok"
+		if tt.provider == "recaptcha" {
+			token = "score-0.8"
+		}
+		if err := v.Verify(ctx, token, ""); err != nil {
+			t.Errorf("%s: Verify = %v", tt.site, err)
+		}
+		if f := tt.stub.lastForm(); f["response"] != token {
+			t.Errorf("%s: %s stub got %v", tt.site, tt.provider, f)
+		}
+	}
+
+	// RECAPTCHA_MIN_SCORE applies to reCAPTCHA
+	_, v := s.For("c.com")
+	if err := v.Verify(ctx, "score-0.6", ""); !errors.Is(err, ErrFailed) {
+		t.Errorf("reCAPTCHA score 0.6: Verify = %v, want ErrFailed", err)
+	}
+
+	if name, v := s.For("d.com"); name != "pow" || v != s.PoW() || s.PoW() == nil {
+		t.Errorf("For(d.com) = %q, %v; want the PoW provider", name, v)
+	}
+	if name, v := s.For("e.com"); name != "" || v != nil {
+		t.Errorf("For(e.com) = %q, %v; want no captcha", name, v)
+	}
+
+	// The providers follow a reload
+	t.Setenv("CAPTCHA_PROVIDERS", "*:hcaptcha")
+	if err := live.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if name, _ := s.For("a.com"); name != "hcaptcha" {
+		t.Errorf("For(a.com) after reload = %q, want hcaptcha", name)
+	}
+	if s.PoW() != nil {
+		t.Error("PoW() after reload is set, but no site uses it")
+	}
+}
@@ -0,0 +1,100 @@
+package captcha
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math/bits"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/devrimsoft/bug-notifications-api/internal/model"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// challengeKeyPrefix + challenge ID holds the difficulty of an issued
+	// challenge until it is solved or expires.
+	challengeKeyPrefix = "captcha:pow:"
+
+	// ChallengeTTL is how long the browser has to solve a challenge.
+	ChallengeTTL = 5 * time.Minute
+
+	maxSolutionLen = 32
+)
+
+// PoW is a proof-of-work captcha. The API issues a random challenge; the
+// browser searches for a solution such that SHA-256("{challenge}:{solution}")
+// starts with the required number of zero bits, and sends
+// "{challenge}:{solution}" as the token. Challenges are kept in Redis and
+// can be used once.
+type PoW struct {
+	rdb        *redis.Client
+	difficulty int
+}
+
+func NewPoW(rdb *redis.Client, difficulty int) *PoW {
+	return &PoW{rdb: rdb, difficulty: difficulty}
+}
+
+// Issue creates a new challenge.
+func (p *PoW) Issue(ctx context.Context) (*model.CaptchaChallenge, error) {
+	c := &model.CaptchaChallenge{
+		Challenge:  uuid.New().String(),
+		Algorithm:  "sha256",
+		Difficulty: p.difficulty,
+		ExpiresAt:  time.Now().UTC().Add(ChallengeTTL),
+	}
+	if err := p.rdb.Set(ctx, challengeKeyPrefix+c.Challenge, p.difficulty, ChallengeTTL).Err(); err != nil {
+		return nil, fmt.Errorf("store challenge: %w", err)
+	}
+	return c, nil
+}
+
+// Verify checks a solved challenge and uses it up, even if the solution is
+// wrong.
+func (p *PoW) Verify(ctx context.Context, token, remoteIP string) error {
+	if token == "" {
+		return ErrMissingToken
+	}
+	id, solution, ok := strings.Cut(token, ":")
+	if !ok || solution == "" || len(solution) > maxSolutionLen {
+		return ErrFailed
+	}
+	if _, err := uuid.Parse(id); err != nil {
+		return ErrFailed
+	}
+
+	v, err := p.rdb.GetDel(ctx, challengeKeyPrefix+id).Result()
+	if errors.Is(err, redis.Nil) {
+		return fmt.Errorf("%w: unknown or expired challenge", ErrFailed)
+	}
+	if err != nil {
+		return fmt.Errorf("get challenge: %w", err)
+	}
+	difficulty, err := strconv.Atoi(v)
+	if err != nil {
+		return fmt.Errorf("invalid challenge difficulty %q", v)
+	}
+
+	sum := sha256.Sum256([]byte(id + ":" + solution))
+	if leadingZeroBits(sum[:]) < difficulty {
+		return ErrFailed
+	}
+	return nil
+}
+
+// leadingZeroBits counts the zero bits at the start of b.
+func leadingZeroBits(b []byte) int {
+	n := 0
+	for _, x := range b {
+		if x != 0 {
+			return n + bits.LeadingZeros8(x)
+		}
+		n += 8
+	}
+	return n
+}
@@ -0,0 +1,167 @@
+package captcha
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func newTestRedis(t *testing.T) (*miniredis.Miniredis, *redis.Client) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+	return mr, rdb
+}
+
+// solve searches for a solution the way the browser does. With wrong set,
+// it returns one that misses the difficulty instead.
+func solve(challenge string, difficulty int, wrong bool) string {
+	for i := 0; ; i++ {
+		s := strconv.Itoa(i)
+		sum := sha256.Sum256([]byte(challenge + ":" + s))
+		if (leadingZeroBits(sum[:]) >= difficulty) != wrong {
+			return challenge + ":" + s
+		}
+	}
+}
+
+func TestPoW(t *testing.T) {
+	ctx := context.Background()
+	mr, rdb := newTestRedis(t)
+	p := NewPoW(rdb, 8)
+
+	c, err := p.Issue(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Algorithm != "sha256" || c.Difficulty != 8 {
+		t.Errorf("challenge = %+v", c)
+	}
+	if until := time.Until(c.ExpiresAt); until <= 0 || until > ChallengeTTL {
+		t.Errorf("challenge expires in %v", until)
+	}
+	if ttl := mr.TTL(challengeKeyPrefix + c.Challenge); ttl != ChallengeTTL {
+		t.Errorf("stored challenge TTL = %v, want %v", ttl, ChallengeTTL)
+	}
+
+	token := solve(c.Challenge, c.Difficulty, false)
+	if err := p.Verify(ctx, token, ""); err != nil {
+		t.Fatalf("Verify = %v", err)
+	}
+	// A solution works once
+	if err := p.Verify(ctx, token, ""); !errors.Is(err, ErrFailed) || !strings.Contains(err.Error(), "unknown or expired") {
+		t.Errorf("replayed Verify = %v, want an unknown challenge", err)
+	}
+}
+
+func TestPoWWrongSolution(t *testing.T) {
+	ctx := context.Background()
+	_, rdb := newTestRedis(t)
+	p := NewPoW(rdb, 8)
+
+	c, err := p.Issue(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Verify(ctx, solve(c.Challenge, 8, true), ""); !errors.Is(err, ErrFailed) {
+		t.Fatalf("wrong solution: Verify = %v, want ErrFailed", err)
+	}
+	// A wrong guess uses the challenge up, so it can't be brute-forced online
+	if err := p.Verify(ctx, solve(c.Challenge, 8, false), ""); !errors.Is(err, ErrFailed) {
+		t.Errorf("right solution after a wrong one: Verify = %v, want ErrFailed", err)
+	}
+}
+
+func TestPoWExpiry(t *testing.T) {
+	ctx := context.Background()
+	mr, rdb := newTestRedis(t)
+	p := NewPoW(rdb, 8)
+
+	c, err := p.Issue(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := solve(c.Challenge, 8, false)
+	mr.FastForward(ChallengeTTL + time.Second)
+	if err := p.Verify(ctx, token, ""); !errors.Is(err, ErrFailed) || !strings.Contains(err.Error(), "unknown or expired") {
+		t.Errorf("expired challenge: Verify = %v", err)
+	}
+}
+
+func TestPoWKeepsIssuedDifficulty(t *testing.T) {
+	ctx := context.Background()
+	_, rdb := newTestRedis(t)
+
+	c, err := NewPoW(rdb, 4).Issue(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The difficulty was raised after the challenge went out; the browser
+	// solved the one it was given
+	var token string
+	for i := 0; ; i++ {
+		token = c.Challenge + ":" + strconv.Itoa(i)
+		sum := sha256.Sum256([]byte(token))
+		if n := leadingZeroBits(sum[:]); n >= 4 && n < 12 {
+			break
+		}
+	}
+	if err := NewPoW(rdb, 12).Verify(ctx, token, ""); err != nil {
+		t.Errorf("Verify = %v, want the issued difficulty to apply", err)
+	}
+}
+
+func TestPoWMalformedTokens(t *testing.T) {
+	ctx := context.Background()
+	_, rdb := newTestRedis(t)
+	p := NewPoW(rdb, 1)
+
+	if err := p.Verify(ctx, "", ""); !errors.Is(err, ErrMissingToken) {
+		t.Errorf("Verify(\"\") = %v, want ErrMissingToken", err)
+	}
+	c, err := p.Issue(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, token := range []string{
+		c.Challenge,
+		c.Challenge + ":",
+		c.Challenge + ":" + strings.Repeat("1", maxSolutionLen+1),
+		"not-a-uuid:1",
+		"captcha:pow:" + c.Challenge + ":1",
+	} {
+		if err := p.Verify(ctx, token, ""); !errors.Is(err, ErrFailed) {
+			t.Errorf("Verify(%q) = %v, want ErrFailed", token, err)
+		}
+	}
+	// None of them used the challenge up
+	if err := p.Verify(ctx, solve(c.Challenge, 1, false), ""); err != nil {
+		t.Errorf("Verify after malformed tokens = %v", err)
+	}
+}
+
+func TestLeadingZeroBits(t *testing.T) {
+	tests := []struct {
+		b    []byte
+		want int
+	}{
+		{[]byte{0x80}, 0},
+		{[]byte{0x01}, 7},
+		{[]byte{0x00, 0x10}, 11},
+		{[]byte{0x00, 0x00}, 16},
+		{nil, 0},
+	}
+	for _, tt := range tests {
+		if got := leadingZeroBits(tt.b); got != tt.want {
+			t.Errorf("leadingZeroBits(%x) = %d, want %d", tt.b, got, tt.want)
+		}
+	}
+}
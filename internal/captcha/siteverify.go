@@ -0,0 +1,76 @@
+package captcha
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Siteverify checks tokens with a siteverify endpoint, the API Turnstile,
+// hCaptcha and reCAPTCHA share: a form POST of secret, response and
+// remoteip, answered with JSON.
+type Siteverify struct {
+	url      string
+	secret   string
+	minScore float64 // reCAPTCHA v3 only; 0 skips the score check
+	client   *http.Client
+}
+
+func NewSiteverify(verifyURL, secret string, minScore float64) *Siteverify {
+	return &Siteverify{
+		url:      verifyURL,
+		secret:   secret,
+		minScore: minScore,
+		client:   &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+type siteverifyResponse struct {
+	Success    bool     `json:"success"`
+	Score      *float64 `json:"score"` // reCAPTCHA v3
+	ErrorCodes []string `json:"error-codes"`
+}
+
+// Verify sends the token to the provider.
+func (s *Siteverify) Verify(ctx context.Context, token, remoteIP string) error {
+	if token == "" {
+		return ErrMissingToken
+	}
+
+	form := url.Values{
+		"secret":   {s.secret},
+		"response": {token},
+		"remoteip": {remoteIP},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("captcha verify request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var result siteverifyResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("captcha verify decode failed: %w", err)
+	}
+
+	if !result.Success {
+		if len(result.ErrorCodes) > 0 {
+			return fmt.Errorf("%w: %s", ErrFailed, strings.Join(result.ErrorCodes, ", "))
+		}
+		return ErrFailed
+	}
+	if s.minScore > 0 && (result.Score == nil || *result.Score < s.minScore) {
+		return fmt.Errorf("%w: score below %g", ErrFailed, s.minScore)
+	}
+	return nil
+}
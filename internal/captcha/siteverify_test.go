@@ -0,0 +1,137 @@
+package captcha
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// siteverifyStub answers like Turnstile, hCaptcha and reCAPTCHA do. The
+// token picks the answer: "ok", "score-0.9" or anything else to fail.
+type siteverifyStub struct {
+	*httptest.Server
+	mu    sync.Mutex
+	forms []map[string]string
+}
+
+func newSiteverifyStub(t *testing.T, secret string) *siteverifyStub {
+	t.Helper()
+	s := &siteverifyStub{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/x-www-form-urlencoded" {
+			t.Errorf("siteverify got %s with Content-Type %q", r.Method, r.Header.Get("Content-Type"))
+		}
+		if err := r.ParseForm(); err != nil {
+			t.Error(err)
+		}
+		s.mu.Lock()
+		s.forms = append(s.forms, map[string]string{
+			"secret":   r.PostForm.Get("secret"),
+			"response": r.PostForm.Get("response"),
+			"remoteip": r.PostForm.Get("remoteip"),
+		})
+		s.mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		token := r.PostForm.Get("response")
+		switch {
+		case r.PostForm.Get("secret") != secret:
+			w.Write([]byte(`{"success":false,"error-codes":["invalid-input-secret"]}`))
+		case token == "ok":
+			w.Write([]byte(`{"success":true,"hostname":"shop.example.com"}`))
+		case strings.HasPrefix(token, "score-"):
+			w.Write([]byte(`{"success":true,"score":` + strings.TrimPrefix(token, "score-") + `,"action":"report"}`))
+		case token == "garbage":
+			w.Write([]byte(`<html>`))
+		default:
+			w.Write([]byte(`{"success":false,"error-codes":["invalid-input-response","timeout-or-duplicate"]}`))
+		}
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *siteverifyStub) lastForm() map[string]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.forms) == 0 {
+		return nil
+	}
+	return s.forms[len(s.forms)-1]
+}
+
+func TestSiteverify(t *testing.T) {
+	ctx := context.Background()
+	stub := newSiteverifyStub(t, "secret")
+	v := NewSiteverify(stub.URL, "secret", 0)
+
+	if err := v.Verify(ctx, "ok", "203.0.113.7"); err != nil {
+		t.Fatalf("Verify(ok) = %v", err)
+	}
+	if f := stub.lastForm(); f["secret"] != "secret" || f["response"] != "ok" || f["remoteip"] != "203.0.113.7" {
+		t.Errorf("form = %v", f)
+	}
+
+	err := v.Verify(ctx, "expired", "203.0.113.7")
+	if !errors.Is(err, ErrFailed) || !strings.Contains(err.Error(), "invalid-input-response, timeout-or-duplicate") {
+		t.Errorf("Verify(expired) = %v, want ErrFailed with the error codes", err)
+	}
+
+	// Without a minimum score, a score is not looked at
+	if err := v.Verify(ctx, "score-0.1", ""); err != nil {
+		t.Errorf("Verify(score-0.1) without a minimum = %v", err)
+	}
+
+	if err := NewSiteverify(stub.URL, "wrong", 0).Verify(ctx, "ok", ""); !errors.Is(err, ErrFailed) {
+		t.Errorf("wrong secret: Verify = %v, want ErrFailed", err)
+	}
+
+	// A broken provider answer is an error, but not a failed captcha
+	if err := v.Verify(ctx, "garbage", ""); err == nil || errors.Is(err, ErrFailed) {
+		t.Errorf("Verify(garbage) = %v, want a decode error", err)
+	}
+	stub.Close()
+	if err := v.Verify(ctx, "ok", ""); err == nil || errors.Is(err, ErrFailed) {
+		t.Errorf("provider down: Verify = %v, want a request error", err)
+	}
+}
+
+func TestSiteverifyMissingToken(t *testing.T) {
+	stub := newSiteverifyStub(t, "secret")
+	if err := NewSiteverify(stub.URL, "secret", 0).Verify(context.Background(), "", ""); !errors.Is(err, ErrMissingToken) {
+		t.Errorf("Verify(\"\") = %v, want ErrMissingToken", err)
+	}
+	if f := stub.lastForm(); f != nil {
+		t.Errorf("the provider was called without a token: %v", f)
+	}
+}
+
+func TestSiteverifyMinScore(t *testing.T) {
+	ctx := context.Background()
+	stub := newSiteverifyStub(t, "secret")
+	v := NewSiteverify(stub.URL, "secret", 0.5)
+
+	tests := []struct {
+		token string
+		ok    bool
+	}{
+		{token: "score-0.9", ok: true},
+		{token: "score-0.5", ok: true},
+		{token: "score-0.49", ok: false},
+		{token: "score-0", ok: false},
+		{token: "ok", ok: false}, // a reCAPTCHA v2 answer has no score
+	}
+	for _, tt := range tests {
+		err := v.Verify(ctx, tt.token, "")
+		if tt.ok && err != nil {
+			t.Errorf("Verify(%s) = %v", tt.token, err)
+		}
+		if !tt.ok && (!errors.Is(err, ErrFailed) || !strings.Contains(err.Error(), "score below 0.5")) {
+			t.Errorf("Verify(%s) = %v, want a score failure", tt.token, err)
+		}
+	}
+}
@@ -1,36 +1,150 @@
 package config
 
 import (
+	"encoding/json"
 	"fmt"
 	"net"
+	"net/mail"
+	"net/url"
 	"os"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
+
+	"github.com/devrimsoft/bug-notifications-api/internal/model"
 )
 
 type Config struct {
-	Port              int
-	RedisURL          string
-	DatabaseURL       string
-	Sites             []string // allowed site domains
-	RateLimitRPS      int
-	WorkerConcurrency int
-	TLSCertFile       string
-	TLSKeyFile        string
-	TrustedProxies    []*net.IPNet
-	ImageAPIURL       string
-	ImageAPIKey       string
-	PortalDomain      string
-	TurnstileSiteKey  string
-	TurnstileSecretKey string
+	Port                  int
+	RedisURL              string
+	DatabaseURL           string
+	Sites                 []string         // allowed site domains
+	SiteConfigs           map[string]*Site // site -> settings from SITES_FILE; nil without it
+	SitesFile             string
+	SitesFilePollInterval time.Duration   // 0 reloads on SIGHUP only
+	sitesFileData         []byte          // SITES_FILE as loaded; see Live.SetStored
+	Taxonomy              *model.Taxonomy // report types and categories
+	RateLimitRPS          int
+	ServerRateLimitRPS    int
+	SigningSecrets        map[string]string // site -> request signing secret
+	SignatureMaxSkew      time.Duration
+	WorkerConcurrency     int
+	TLSCertFile           string
+	TLSKeyFile            string
+	TrustedProxies        []*net.IPNet
+	ImageStorage          string // "", r2, local or s3
+	ImageAPIURL           string
+	ImageAPIKey           string
+	ImageLocalDir         string
+	ImagePublicURL        string
+	ImageSigningKey       string
+	S3Endpoint            string
+	S3Region              string
+	S3Bucket              string
+	S3AccessKeyID         string
+	S3SecretAccessKey     string
+	S3PublicURL           string
+	ImageOrphanGrace      time.Duration
+	ImageMaxPixels        int                               // larger images are rejected
+	ImageMaxSide          int                               // longer images are downscaled; 0 keeps the original size
+	AttachmentKinds       map[string][]model.AttachmentKind // site (or "*") -> allowed attachment kinds
+	PortalDomain          string
+	TurnstileSiteKey      string
+	TurnstileSecretKey    string
+	TurnstileVerifyURL    string
+	HCaptchaSiteKey       string
+	HCaptchaSecretKey     string
+	HCaptchaVerifyURL     string
+	ReCAPTCHASiteKey      string
+	ReCAPTCHASecretKey    string
+	ReCAPTCHAVerifyURL    string
+	ReCAPTCHAMinScore     float64
+	PoWDifficulty         int               // leading zero bits of the solution hash
+	CaptchaProviders      map[string]string // site (or "*") -> captcha provider
+	AdminAPIKeys          map[string]string // admin name -> bearer token
+	RetryMaxAttempts      int
+	RetryBaseDelay        time.Duration
+	RetryMaxDelay         time.Duration
+	RetryJitter           float64
+	WebhookEndpoints      []WebhookEndpoint
+	WebhookMaxAttempts    int
+	NotifyRoutes          []NotifyRoute
+	TelegramAPIURL        string
+	SMTPHost              string
+	SMTPPort              int
+	SMTPUsername          string
+	SMTPPassword          string
+	SMTPFrom              string
+	SMTPTLS               string // starttls, tls or none
+	EmailRecipients       []EmailRecipient
+}
+
+// WebhookEndpoint is an outbound webhook target for one site (or "*" for all sites).
+type WebhookEndpoint struct {
+	ID     string   `json:"id"`
+	SiteID string   `json:"site_id"`
+	URL    string   `json:"url"`
+	Secret string   `json:"secret"`
+	Events []string `json:"events"` // empty means all events
 }
 
 // Load reads configuration from environment variables.
 func Load() (*Config, error) {
+	return LoadWithStored(Stored{})
+}
+
+// Stored is the configuration kept in the database.
+type Stored struct {
+	Sites    []model.Site    // rows of the sites table, see applyStoredSites
+	Taxonomy *model.Taxonomy // report types and categories; nil until loaded, see LoadWithStored
+}
+
+// loaded reports whether the database configuration was read. Load passes
+// none.
+func (s Stored) loaded() bool {
+	return s.Sites != nil || s.Taxonomy != nil
+}
+
+// LoadWithStored is Load with the configuration kept in the database: the
+// sites table layered over SITES_FILE or ALLOWED_SITES, and the taxonomy.
+//
+// Report types and categories may be added to the database, so the ones
+// named by SITES_FILE, NOTIFY_ROUTES and stored site settings are only
+// checked once the taxonomy is known. Without it, as in Load at startup,
+// they are accepted and model.DefaultTaxonomy is served until the database
+// configuration is applied with Live.SetStored.
+//
+// Sites may be added through the admin API too, so the sites named by
+// SIGNING_SECRETS, ATTACHMENT_TYPES, CAPTCHA_PROVIDERS, WEBHOOK_ENDPOINTS,
+// NOTIFY_ROUTES and EMAIL_RECIPIENTS are likewise only checked once the
+// database configuration is given.
+func LoadWithStored(stored Stored) (*Config, error) {
+	return load(stored, nil)
+}
+
+// load is LoadWithStored with the contents of SITES_FILE given as sitesData,
+// or read from disk when sitesData is nil.
+func load(stored Stored, sitesData []byte) (*Config, error) {
 	cfg := &Config{
-		Port:              8080,
-		RateLimitRPS:      10,
-		WorkerConcurrency: 10,
+		Port:               8080,
+		RateLimitRPS:       10,
+		ServerRateLimitRPS: 50,
+		SignatureMaxSkew:   5 * time.Minute,
+		WorkerConcurrency:  10,
+		RetryMaxAttempts:   5,
+		RetryBaseDelay:     5 * time.Second,
+		RetryMaxDelay:      5 * time.Minute,
+		RetryJitter:        0.2,
+		WebhookMaxAttempts: 8,
+		ImageOrphanGrace:   24 * time.Hour,
+		ImageMaxPixels:     16_000_000,
+		ReCAPTCHAMinScore:  0.5,
+		PoWDifficulty:      20,
+		Taxonomy:           stored.Taxonomy,
+	}
+	if cfg.Taxonomy == nil {
+		cfg.Taxonomy = model.DefaultTaxonomy()
 	}
 
 	if p := os.Getenv("PORT"); p != "" {
@@ -51,17 +165,44 @@ func Load() (*Config, error) {
 		return nil, fmt.Errorf("DATABASE_URL is required")
 	}
 
-	// ALLOWED_SITES format: "example.com,other.com,shop.example.com"
-	allowedSites := os.Getenv("ALLOWED_SITES")
-	if allowedSites == "" {
-		return nil, fmt.Errorf("ALLOWED_SITES is required")
-	}
-	for _, domain := range strings.Split(allowedSites, ",") {
-		domain = strings.ToLower(strings.TrimSpace(domain))
-		if domain == "" {
-			continue
+	// SITES_FILE: YAML or JSON file describing the sites and their settings.
+	// Without it, ALLOWED_SITES format: "example.com,other.com,shop.example.com"
+	// Both may be left out when all sites are managed through the admin API.
+	if path := os.Getenv("SITES_FILE"); path != "" {
+		var err error
+		if sitesData != nil {
+			err = parseSitesFile(cfg, path, sitesData, stored.Taxonomy)
+		} else {
+			err = loadSitesFile(cfg, path, stored.Taxonomy)
+		}
+		if err != nil {
+			return nil, err
 		}
-		cfg.Sites = append(cfg.Sites, domain)
+		cfg.SitesFile = path
+
+		// SITES_FILE_POLL_INTERVAL: how often to check SITES_FILE for changes
+		// and reload it, e.g. "30s"
+		if v := os.Getenv("SITES_FILE_POLL_INTERVAL"); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil || d <= 0 {
+				return nil, fmt.Errorf("invalid SITES_FILE_POLL_INTERVAL: must be a positive duration")
+			}
+			cfg.SitesFilePollInterval = d
+		}
+	} else {
+		for _, domain := range strings.Split(os.Getenv("ALLOWED_SITES"), ",") {
+			domain = strings.ToLower(strings.TrimSpace(domain))
+			if domain == "" {
+				continue
+			}
+			cfg.Sites = append(cfg.Sites, domain)
+		}
+	}
+	if err := applyStoredSites(cfg, stored.Sites, stored.Taxonomy); err != nil {
+		return nil, err
+	}
+	knownSite := func(site string) bool {
+		return !stored.loaded() || slices.Contains(cfg.Sites, site)
 	}
 
 	if r := os.Getenv("RATE_LIMIT_RPS"); r != "" {
@@ -72,6 +213,45 @@ func Load() (*Config, error) {
 		cfg.RateLimitRPS = rps
 	}
 
+	// SERVER_RATE_LIMIT_RPS applies per API key on /v1/server routes
+	if v := os.Getenv("SERVER_RATE_LIMIT_RPS"); v != "" {
+		rps, err := strconv.Atoi(v)
+		if err != nil || rps < 1 {
+			return nil, fmt.Errorf("invalid SERVER_RATE_LIMIT_RPS: must be a positive integer")
+		}
+		cfg.ServerRateLimitRPS = rps
+	}
+
+	// SIGNING_SECRETS format: "example.com:secret1,other.com:secret2"
+	// Sites listed here must sign their /v1/server requests with HMAC-SHA256.
+	if v := os.Getenv("SIGNING_SECRETS"); v != "" {
+		cfg.SigningSecrets = make(map[string]string)
+		for _, entry := range strings.Split(v, ",") {
+			entry = strings.TrimSpace(entry)
+			if entry == "" {
+				continue
+			}
+			site, secret, ok := strings.Cut(entry, ":")
+			site = strings.ToLower(strings.TrimSpace(site))
+			if !ok || secret == "" {
+				return nil, fmt.Errorf("invalid SIGNING_SECRETS entry for %q: expected site:secret", site)
+			}
+			if !knownSite(site) {
+				return nil, fmt.Errorf("invalid SIGNING_SECRETS: unknown site %q", site)
+			}
+			cfg.SigningSecrets[site] = secret
+		}
+	}
+
+	// SIGNATURE_MAX_SKEW: how far a signature timestamp may be from server time
+	if v := os.Getenv("SIGNATURE_MAX_SKEW"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid SIGNATURE_MAX_SKEW: must be a positive duration")
+		}
+		cfg.SignatureMaxSkew = d
+	}
+
 	if w := os.Getenv("WORKER_CONCURRENCY"); w != "" {
 		wc, err := strconv.Atoi(w)
 		if err != nil {
@@ -80,12 +260,190 @@ func Load() (*Config, error) {
 		cfg.WorkerConcurrency = wc
 	}
 
-	cfg.ImageAPIURL = os.Getenv("IMAGE_API_URL")
-	cfg.ImageAPIKey = os.Getenv("IMAGE_API_KEY")
+	if v := os.Getenv("RETRY_MAX_ATTEMPTS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid RETRY_MAX_ATTEMPTS: must be a positive integer")
+		}
+		cfg.RetryMaxAttempts = n
+	}
+
+	// RETRY_BASE_DELAY / RETRY_MAX_DELAY format: Go durations, e.g. "5s", "10m"
+	if v := os.Getenv("RETRY_BASE_DELAY"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid RETRY_BASE_DELAY: must be a positive duration")
+		}
+		cfg.RetryBaseDelay = d
+	}
+	if v := os.Getenv("RETRY_MAX_DELAY"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid RETRY_MAX_DELAY: must be a positive duration")
+		}
+		cfg.RetryMaxDelay = d
+	}
+	if cfg.RetryMaxDelay < cfg.RetryBaseDelay {
+		return nil, fmt.Errorf("RETRY_MAX_DELAY must not be less than RETRY_BASE_DELAY")
+	}
+
+	// RETRY_JITTER: fraction of each delay (0-1) randomly shaved off
+	if v := os.Getenv("RETRY_JITTER"); v != "" {
+		j, err := strconv.ParseFloat(v, 64)
+		if err != nil || j < 0 || j > 1 {
+			return nil, fmt.Errorf("invalid RETRY_JITTER: must be between 0 and 1")
+		}
+		cfg.RetryJitter = j
+	}
+
+	// WEBHOOK_ENDPOINTS format: JSON array, e.g.
+	// [{"id":"ops","site_id":"example.com","url":"https://hooks.example.com/bugs","secret":"...","events":["report.created"]}]
+	if v := os.Getenv("WEBHOOK_ENDPOINTS"); v != "" {
+		if err := json.Unmarshal([]byte(v), &cfg.WebhookEndpoints); err != nil {
+			return nil, fmt.Errorf("invalid WEBHOOK_ENDPOINTS: %w", err)
+		}
+		if err := validateWebhookEndpoints(cfg.WebhookEndpoints, knownSite); err != nil {
+			return nil, fmt.Errorf("invalid WEBHOOK_ENDPOINTS: %w", err)
+		}
+	}
+
+	if v := os.Getenv("WEBHOOK_MAX_ATTEMPTS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid WEBHOOK_MAX_ATTEMPTS: must be a positive integer")
+		}
+		cfg.WebhookMaxAttempts = n
+	}
+
+	// NOTIFY_ROUTES format: JSON array, e.g.
+	// [{"id":"team","type":"slack","site_id":"*","exclude_categories":["security"],"url":"https://hooks.slack.com/services/..."},
+	//  {"id":"sec","type":"telegram","site_id":"*","categories":["security"],"bot_token":"...","chat_id":"-100123"}]
+	if v := os.Getenv("NOTIFY_ROUTES"); v != "" {
+		if err := json.Unmarshal([]byte(v), &cfg.NotifyRoutes); err != nil {
+			return nil, fmt.Errorf("invalid NOTIFY_ROUTES: %w", err)
+		}
+		if err := validateNotifyRoutes(cfg.NotifyRoutes, knownSite, stored.Taxonomy); err != nil {
+			return nil, fmt.Errorf("invalid NOTIFY_ROUTES: %w", err)
+		}
+	}
+
+	// TELEGRAM_API_URL overrides the Bot API base URL (e.g. a local stand-in for tests)
+	cfg.TelegramAPIURL = strings.TrimRight(os.Getenv("TELEGRAM_API_URL"), "/")
+	if cfg.TelegramAPIURL == "" {
+		cfg.TelegramAPIURL = "https://api.telegram.org"
+	}
+
+	// SMTP_TLS: "starttls" (default, port 587), "tls" (implicit TLS, port 465)
+	// or "none" (plain, port 25 - only for a local SMTP sink)
+	cfg.SMTPHost = os.Getenv("SMTP_HOST")
+	cfg.SMTPUsername = os.Getenv("SMTP_USERNAME")
+	cfg.SMTPPassword = os.Getenv("SMTP_PASSWORD")
+	cfg.SMTPFrom = os.Getenv("SMTP_FROM")
+	cfg.SMTPTLS = strings.ToLower(os.Getenv("SMTP_TLS"))
+	switch cfg.SMTPTLS {
+	case "", "starttls":
+		cfg.SMTPTLS = "starttls"
+		cfg.SMTPPort = 587
+	case "tls":
+		cfg.SMTPPort = 465
+	case "none":
+		cfg.SMTPPort = 25
+	default:
+		return nil, fmt.Errorf("invalid SMTP_TLS: must be starttls, tls or none")
+	}
+	if v := os.Getenv("SMTP_PORT"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 65535 {
+			return nil, fmt.Errorf("invalid SMTP_PORT: must be a port number")
+		}
+		cfg.SMTPPort = n
+	}
+
+	// EMAIL_RECIPIENTS format: JSON array, mode is instant, hourly or daily, e.g.
+	// [{"site_id":"example.com","to":"owner@example.com","mode":"daily"}]
+	if v := os.Getenv("EMAIL_RECIPIENTS"); v != "" {
+		if err := json.Unmarshal([]byte(v), &cfg.EmailRecipients); err != nil {
+			return nil, fmt.Errorf("invalid EMAIL_RECIPIENTS: %w", err)
+		}
+		if err := validateEmailRecipients(cfg.EmailRecipients, knownSite); err != nil {
+			return nil, fmt.Errorf("invalid EMAIL_RECIPIENTS: %w", err)
+		}
+		if cfg.SMTPHost == "" || cfg.SMTPFrom == "" {
+			return nil, fmt.Errorf("SMTP_HOST and SMTP_FROM are required when EMAIL_RECIPIENTS is set")
+		}
+		if _, err := mail.ParseAddress(cfg.SMTPFrom); err != nil {
+			return nil, fmt.Errorf("invalid SMTP_FROM: %w", err)
+		}
+	}
+
+	if err := loadImageStorage(cfg); err != nil {
+		return nil, err
+	}
+
+	// IMAGE_MAX_PIXELS: width x height limit for uploaded images (all frames
+	// of a GIF together), guards against decompression bombs
+	if v := os.Getenv("IMAGE_MAX_PIXELS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid IMAGE_MAX_PIXELS: must be a positive integer")
+		}
+		cfg.ImageMaxPixels = n
+	}
+	// IMAGE_MAX_SIDE: downscale uploaded images so neither side is longer, e.g. 2560
+	if v := os.Getenv("IMAGE_MAX_SIDE"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid IMAGE_MAX_SIDE: must be a non-negative integer")
+		}
+		cfg.ImageMaxSide = n
+	}
+
+	// ATTACHMENT_TYPES format: "example.com:image|log|har|video,*:image"
+	// Sites not listed (and without a "*" entry) accept images only.
+	if v := os.Getenv("ATTACHMENT_TYPES"); v != "" {
+		cfg.AttachmentKinds = make(map[string][]model.AttachmentKind)
+		for _, entry := range strings.Split(v, ",") {
+			entry = strings.TrimSpace(entry)
+			if entry == "" {
+				continue
+			}
+			site, kinds, ok := strings.Cut(entry, ":")
+			site = strings.ToLower(strings.TrimSpace(site))
+			if !ok || kinds == "" {
+				return nil, fmt.Errorf("invalid ATTACHMENT_TYPES entry for %q: expected site:kind|kind", site)
+			}
+			if site != "*" && !knownSite(site) {
+				return nil, fmt.Errorf("invalid ATTACHMENT_TYPES: unknown site %q", site)
+			}
+			for _, k := range strings.Split(kinds, "|") {
+				kind := model.AttachmentKind(strings.ToLower(strings.TrimSpace(k)))
+				if !model.ValidAttachmentKinds[kind] {
+					return nil, fmt.Errorf("invalid ATTACHMENT_TYPES: unknown kind %q, must be image, log, har or video", k)
+				}
+				if err := checkAttachmentStorage(cfg, kind); err != nil {
+					return nil, fmt.Errorf("invalid ATTACHMENT_TYPES: %w", err)
+				}
+				cfg.AttachmentKinds[site] = append(cfg.AttachmentKinds[site], kind)
+			}
+		}
+	}
 
 	cfg.PortalDomain = strings.ToLower(strings.TrimSpace(os.Getenv("PORTAL_DOMAIN")))
-	cfg.TurnstileSiteKey = os.Getenv("TURNSTILE_SITE_KEY")
-	cfg.TurnstileSecretKey = os.Getenv("TURNSTILE_SECRET_KEY")
+	if err := loadCaptcha(cfg, knownSite); err != nil {
+		return nil, err
+	}
+
+	if err := applySiteConfigs(cfg, knownSite, stored.Taxonomy); err != nil {
+		return nil, err
+	}
+
+	// ADMIN_API_KEY and ADMIN_API_KEYS enable the /admin/v1 API. Leave both
+	// empty to disable it. ADMIN_API_KEY authenticates as "admin";
+	// ADMIN_API_KEYS format: "alice:token1,bob:token2". The name is recorded
+	// as the actor of admin changes such as report status updates.
+	if err := loadAdminKeys(cfg); err != nil {
+		return nil, err
+	}
 
 	cfg.TLSCertFile = os.Getenv("TLS_CERT_FILE")
 	cfg.TLSKeyFile = os.Getenv("TLS_KEY_FILE")
@@ -119,23 +477,441 @@ func Load() (*Config, error) {
 	return cfg, nil
 }
 
-// AllowedDomains returns a list of all registered site domains.
+// validateWebhookEndpoints checks IDs are unique, sites and events exist, URLs
+// are absolute http(s) URLs and every endpoint has a signing secret.
+func validateWebhookEndpoints(endpoints []WebhookEndpoint, knownSite func(string) bool) error {
+	seen := make(map[string]bool)
+	for i := range endpoints {
+		ep := &endpoints[i]
+		ep.SiteID = strings.ToLower(strings.TrimSpace(ep.SiteID))
+		if ep.ID == "" {
+			return fmt.Errorf("endpoint %d: id is required", i)
+		}
+		if seen[ep.ID] {
+			return fmt.Errorf("endpoint %q: duplicate id", ep.ID)
+		}
+		seen[ep.ID] = true
+		if ep.SiteID != "*" && !knownSite(ep.SiteID) {
+			return fmt.Errorf("endpoint %q: unknown site_id %q", ep.ID, ep.SiteID)
+		}
+		u, err := url.Parse(ep.URL)
+		if err != nil || u.Host == "" || (u.Scheme != "https" && u.Scheme != "http") {
+			return fmt.Errorf("endpoint %q: url must be an absolute http or https URL", ep.ID)
+		}
+		if ep.Secret == "" {
+			return fmt.Errorf("endpoint %q: secret is required", ep.ID)
+		}
+		for _, ev := range ep.Events {
+			if !model.ValidWebhookEvents[model.WebhookEventType(ev)] {
+				return fmt.Errorf("endpoint %q: unknown event %q", ep.ID, ev)
+			}
+		}
+	}
+	return nil
+}
+
+// loadImageStorage reads the image storage backend and its settings.
+// IMAGE_STORAGE is r2, local or s3; when unset it defaults to r2 if
+// IMAGE_API_URL is set, otherwise image uploads are disabled.
+func loadImageStorage(cfg *Config) error {
+	cfg.ImageStorage = strings.ToLower(strings.TrimSpace(os.Getenv("IMAGE_STORAGE")))
+	cfg.ImageAPIURL = strings.TrimRight(os.Getenv("IMAGE_API_URL"), "/")
+	cfg.ImageAPIKey = os.Getenv("IMAGE_API_KEY")
+	if cfg.ImageStorage == "" && cfg.ImageAPIURL != "" {
+		cfg.ImageStorage = "r2"
+	}
+
+	switch cfg.ImageStorage {
+	case "":
+	case "r2":
+		if cfg.ImageAPIURL == "" || cfg.ImageAPIKey == "" {
+			return fmt.Errorf("IMAGE_API_URL and IMAGE_API_KEY are required for IMAGE_STORAGE=r2")
+		}
+	case "local":
+		// IMAGE_PUBLIC_URL: base URL of this API as seen by report readers,
+		// e.g. https://bug.example.com (images are served under /images/)
+		cfg.ImageLocalDir = os.Getenv("IMAGE_LOCAL_DIR")
+		cfg.ImagePublicURL = strings.TrimRight(os.Getenv("IMAGE_PUBLIC_URL"), "/")
+		cfg.ImageSigningKey = os.Getenv("IMAGE_SIGNING_KEY")
+		if cfg.ImageLocalDir == "" || cfg.ImagePublicURL == "" || cfg.ImageSigningKey == "" {
+			return fmt.Errorf("IMAGE_LOCAL_DIR, IMAGE_PUBLIC_URL and IMAGE_SIGNING_KEY are required for IMAGE_STORAGE=local")
+		}
+		if len(cfg.ImageSigningKey) < 32 {
+			return fmt.Errorf("invalid IMAGE_SIGNING_KEY: must be at least 32 characters")
+		}
+		if !isHTTPURL(cfg.ImagePublicURL) {
+			return fmt.Errorf("invalid IMAGE_PUBLIC_URL: must be an absolute http or https URL")
+		}
+	case "s3":
+		cfg.S3Endpoint = strings.TrimRight(os.Getenv("S3_ENDPOINT"), "/")
+		cfg.S3Region = os.Getenv("S3_REGION")
+		cfg.S3Bucket = os.Getenv("S3_BUCKET")
+		cfg.S3AccessKeyID = os.Getenv("S3_ACCESS_KEY_ID")
+		cfg.S3SecretAccessKey = os.Getenv("S3_SECRET_ACCESS_KEY")
+		cfg.S3PublicURL = strings.TrimRight(os.Getenv("S3_PUBLIC_URL"), "/")
+		if cfg.S3Region == "" {
+			cfg.S3Region = "us-east-1"
+		}
+		if cfg.S3Endpoint == "" || cfg.S3Bucket == "" || cfg.S3AccessKeyID == "" || cfg.S3SecretAccessKey == "" {
+			return fmt.Errorf("S3_ENDPOINT, S3_BUCKET, S3_ACCESS_KEY_ID and S3_SECRET_ACCESS_KEY are required for IMAGE_STORAGE=s3")
+		}
+		if !isHTTPURL(cfg.S3Endpoint) {
+			return fmt.Errorf("invalid S3_ENDPOINT: must be an absolute http or https URL")
+		}
+		if cfg.S3PublicURL != "" && !isHTTPURL(cfg.S3PublicURL) {
+			return fmt.Errorf("invalid S3_PUBLIC_URL: must be an absolute http or https URL")
+		}
+	default:
+		return fmt.Errorf("invalid IMAGE_STORAGE: must be r2, local or s3")
+	}
+
+	// IMAGE_ORPHAN_GRACE: how old an unreferenced stored image must be before
+	// the worker deletes it, e.g. "24h"
+	if v := os.Getenv("IMAGE_ORPHAN_GRACE"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("invalid IMAGE_ORPHAN_GRACE: must be a positive duration")
+		}
+		cfg.ImageOrphanGrace = d
+	}
+	return nil
+}
+
+// ValidCaptchaProviders lists the supported captcha providers. "none" turns
+// the captcha off for a site.
+var ValidCaptchaProviders = map[string]bool{
+	"turnstile": true,
+	"hcaptcha":  true,
+	"recaptcha": true,
+	"pow":       true,
+	"none":      true,
+}
+
+// loadCaptcha reads the captcha provider of each site and the provider
+// settings. Without CAPTCHA_PROVIDERS, every site uses Turnstile when
+// TURNSTILE_SECRET_KEY is set and no captcha otherwise.
+func loadCaptcha(cfg *Config, knownSite func(string) bool) error {
+	cfg.TurnstileSiteKey = os.Getenv("TURNSTILE_SITE_KEY")
+	cfg.TurnstileSecretKey = os.Getenv("TURNSTILE_SECRET_KEY")
+	cfg.HCaptchaSiteKey = os.Getenv("HCAPTCHA_SITE_KEY")
+	cfg.HCaptchaSecretKey = os.Getenv("HCAPTCHA_SECRET_KEY")
+	cfg.ReCAPTCHASiteKey = os.Getenv("RECAPTCHA_SITE_KEY")
+	cfg.ReCAPTCHASecretKey = os.Getenv("RECAPTCHA_SECRET_KEY")
+
+	// *_VERIFY_URL overrides a provider's siteverify URL (e.g. a local stub for tests)
+	verifyURLs := []struct {
+		env    string
+		dst    *string
+		defURL string
+	}{
+		{"TURNSTILE_VERIFY_URL", &cfg.TurnstileVerifyURL, "https://challenges.cloudflare.com/turnstile/v0/siteverify"},
+		{"HCAPTCHA_VERIFY_URL", &cfg.HCaptchaVerifyURL, "https://api.hcaptcha.com/siteverify"},
+		{"RECAPTCHA_VERIFY_URL", &cfg.ReCAPTCHAVerifyURL, "https://www.google.com/recaptcha/api/siteverify"},
+	}
+	for _, v := range verifyURLs {
+		*v.dst = os.Getenv(v.env)
+		if *v.dst == "" {
+			*v.dst = v.defURL
+		} else if !isHTTPURL(*v.dst) {
+			return fmt.Errorf("invalid %s: must be an absolute http or https URL", v.env)
+		}
+	}
+
+	// RECAPTCHA_MIN_SCORE: reCAPTCHA v3 scores below this are rejected (0-1)
+	if v := os.Getenv("RECAPTCHA_MIN_SCORE"); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || f < 0 || f > 1 {
+			return fmt.Errorf("invalid RECAPTCHA_MIN_SCORE: must be between 0 and 1")
+		}
+		cfg.ReCAPTCHAMinScore = f
+	}
+
+	// POW_DIFFICULTY: leading zero bits a proof-of-work solution needs; each
+	// extra bit doubles the expected work in the browser
+	if v := os.Getenv("POW_DIFFICULTY"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 32 {
+			return fmt.Errorf("invalid POW_DIFFICULTY: must be between 1 and 32")
+		}
+		cfg.PoWDifficulty = n
+	}
+
+	// CAPTCHA_PROVIDERS format: "*:turnstile,shop.example.com:pow,internal.example.com:none"
+	cfg.CaptchaProviders = make(map[string]string)
+	v := os.Getenv("CAPTCHA_PROVIDERS")
+	if v == "" && cfg.TurnstileSecretKey != "" {
+		v = "*:turnstile"
+	}
+	for _, entry := range strings.Split(v, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		site, provider, ok := strings.Cut(entry, ":")
+		site = strings.ToLower(strings.TrimSpace(site))
+		provider = strings.ToLower(strings.TrimSpace(provider))
+		if !ok || provider == "" {
+			return fmt.Errorf("invalid CAPTCHA_PROVIDERS entry for %q: expected site:provider", site)
+		}
+		if site != "*" && !knownSite(site) {
+			return fmt.Errorf("invalid CAPTCHA_PROVIDERS: unknown site %q", site)
+		}
+		if !ValidCaptchaProviders[provider] {
+			return fmt.Errorf("invalid CAPTCHA_PROVIDERS: provider for %q must be turnstile, hcaptcha, recaptcha, pow or none", site)
+		}
+		if err := checkCaptchaKeys(cfg, provider); err != nil {
+			return err
+		}
+		cfg.CaptchaProviders[site] = provider
+	}
+	return nil
+}
+
+// checkCaptchaKeys checks that a captcha provider has its secret key.
+func checkCaptchaKeys(cfg *Config, provider string) error {
+	switch {
+	case provider == "turnstile" && cfg.TurnstileSecretKey == "":
+		return fmt.Errorf("TURNSTILE_SECRET_KEY is required for the turnstile captcha provider")
+	case provider == "hcaptcha" && cfg.HCaptchaSecretKey == "":
+		return fmt.Errorf("HCAPTCHA_SECRET_KEY is required for the hcaptcha captcha provider")
+	case provider == "recaptcha" && cfg.ReCAPTCHASecretKey == "":
+		return fmt.Errorf("RECAPTCHA_SECRET_KEY is required for the recaptcha captcha provider")
+	}
+	return nil
+}
+
+// checkAttachmentStorage checks that the image storage can hold an
+// attachment kind. The R2 Image Processor only takes images.
+func checkAttachmentStorage(cfg *Config, kind model.AttachmentKind) error {
+	if kind != model.AttachmentImage && cfg.ImageStorage == "r2" {
+		return fmt.Errorf("%s attachments need IMAGE_STORAGE=local or s3", kind)
+	}
+	return nil
+}
+
+// isHTTPURL reports whether s is an absolute http or https URL.
+func isHTTPURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && u.Host != "" && (u.Scheme == "https" || u.Scheme == "http")
+}
+
+// SigningSecretFor returns the request signing secret for a site, or "" if
+// the site does not sign its requests.
+func (c *Config) SigningSecretFor(siteID string) string {
+	return c.SigningSecrets[siteID]
+}
+
+// WebhookEndpointsFor returns the endpoints subscribed to the given event for a site.
+func (c *Config) WebhookEndpointsFor(siteID, event string) []WebhookEndpoint {
+	var out []WebhookEndpoint
+	for _, ep := range c.WebhookEndpoints {
+		if ep.SiteID != "*" && ep.SiteID != siteID {
+			continue
+		}
+		if len(ep.Events) > 0 && !slices.Contains(ep.Events, event) {
+			continue
+		}
+		out = append(out, ep)
+	}
+	return out
+}
+
+// NotifyRoute sends new reports for one site (or "*") to a chat channel.
+// Categories limits the route to those categories (empty means all);
+// ExcludeCategories drops them, e.g. to keep "security" out of a public channel.
+type NotifyRoute struct {
+	ID                string   `json:"id" yaml:"id"`
+	Type              string   `json:"type" yaml:"type"` // slack, discord or telegram
+	SiteID            string   `json:"site_id" yaml:"site_id"`
+	Categories        []string `json:"categories" yaml:"categories"`
+	ExcludeCategories []string `json:"exclude_categories" yaml:"exclude_categories"`
+	URL               string   `json:"url" yaml:"url"`             // slack/discord webhook URL
+	BotToken          string   `json:"bot_token" yaml:"bot_token"` // telegram
+	ChatID            string   `json:"chat_id" yaml:"chat_id"`     // telegram
+}
+
+// ValidNotifyTypes lists the supported chat notification channel types.
+var ValidNotifyTypes = map[string]bool{
+	"slack":    true,
+	"discord":  true,
+	"telegram": true,
+}
+
+// validateNotifyRoutes checks IDs are unique, sites and categories exist and
+// each route has the credentials its channel type needs. Categories are not
+// checked without a taxonomy.
+func validateNotifyRoutes(routes []NotifyRoute, knownSite func(string) bool, tax *model.Taxonomy) error {
+	seen := make(map[string]bool)
+	for i := range routes {
+		rt := &routes[i]
+		rt.SiteID = strings.ToLower(strings.TrimSpace(rt.SiteID))
+		if rt.ID == "" {
+			return fmt.Errorf("route %d: id is required", i)
+		}
+		if seen[rt.ID] {
+			return fmt.Errorf("route %q: duplicate id", rt.ID)
+		}
+		seen[rt.ID] = true
+		if !ValidNotifyTypes[rt.Type] {
+			return fmt.Errorf("route %q: type must be slack, discord or telegram", rt.ID)
+		}
+		if rt.SiteID != "*" && !knownSite(rt.SiteID) {
+			return fmt.Errorf("route %q: unknown site_id %q", rt.ID, rt.SiteID)
+		}
+		for _, c := range append(slices.Clone(rt.Categories), rt.ExcludeCategories...) {
+			if tax != nil && !tax.HasCategory(c) {
+				return fmt.Errorf("route %q: unknown category %q", rt.ID, c)
+			}
+		}
+		switch rt.Type {
+		case "slack", "discord":
+			u, err := url.Parse(rt.URL)
+			if err != nil || u.Host == "" || (u.Scheme != "https" && u.Scheme != "http") {
+				return fmt.Errorf("route %q: url must be an absolute http or https URL", rt.ID)
+			}
+		case "telegram":
+			if rt.BotToken == "" || rt.ChatID == "" {
+				return fmt.Errorf("route %q: bot_token and chat_id are required", rt.ID)
+			}
+		}
+	}
+	return nil
+}
+
+// NotifyRoutesFor returns the chat routes matching a report's site and category.
+func (c *Config) NotifyRoutesFor(siteID, category string) []NotifyRoute {
+	var out []NotifyRoute
+	for _, rt := range c.NotifyRoutes {
+		if rt.SiteID != "*" && rt.SiteID != siteID {
+			continue
+		}
+		if len(rt.Categories) > 0 && !slices.Contains(rt.Categories, category) {
+			continue
+		}
+		if slices.Contains(rt.ExcludeCategories, category) {
+			continue
+		}
+		out = append(out, rt)
+	}
+	return out
+}
+
+// CaptchaProviderFor returns the captcha provider of a site, or "" if its
+// browser reports are not checked.
+func (c *Config) CaptchaProviderFor(siteID string) string {
+	p, ok := c.CaptchaProviders[siteID]
+	if !ok {
+		p = c.CaptchaProviders["*"]
+	}
+	if p == "none" {
+		return ""
+	}
+	return p
+}
+
+// CaptchaSiteKeyFor returns the public key the browser widget of a site
+// needs, or "" if its provider has none.
+func (c *Config) CaptchaSiteKeyFor(siteID string) string {
+	switch c.CaptchaProviderFor(siteID) {
+	case "turnstile":
+		return c.TurnstileSiteKey
+	case "hcaptcha":
+		return c.HCaptchaSiteKey
+	case "recaptcha":
+		return c.ReCAPTCHASiteKey
+	}
+	return ""
+}
+
+// AttachmentAllowed reports whether a site accepts attachments of a kind.
+// Images are allowed unless the site (or "*") has its own list without them.
+func (c *Config) AttachmentAllowed(siteID string, kind model.AttachmentKind) bool {
+	kinds, ok := c.AttachmentKinds[siteID]
+	if !ok {
+		kinds, ok = c.AttachmentKinds["*"]
+	}
+	if !ok {
+		return kind == model.AttachmentImage
+	}
+	return slices.Contains(kinds, kind)
+}
+
+// EmailRecipient receives report emails for one site (or "*" for all sites),
+// either one email per report or an hourly/daily digest.
+type EmailRecipient struct {
+	SiteID string          `json:"site_id"`
+	To     string          `json:"to"`
+	Mode   model.EmailMode `json:"mode"` // defaults to instant
+}
+
+// validateEmailRecipients checks sites exist, addresses parse and modes are known.
+func validateEmailRecipients(recipients []EmailRecipient, knownSite func(string) bool) error {
+	for i := range recipients {
+		rc := &recipients[i]
+		rc.SiteID = strings.ToLower(strings.TrimSpace(rc.SiteID))
+		if rc.Mode == "" {
+			rc.Mode = model.EmailInstant
+		}
+		addr, err := mail.ParseAddress(rc.To)
+		if err != nil {
+			return fmt.Errorf("recipient %d: invalid address %q", i, rc.To)
+		}
+		rc.To = strings.ToLower(addr.Address)
+		if rc.SiteID != "*" && !knownSite(rc.SiteID) {
+			return fmt.Errorf("recipient %q: unknown site_id %q", rc.To, rc.SiteID)
+		}
+		if !model.ValidEmailModes[rc.Mode] {
+			return fmt.Errorf("recipient %q: mode must be instant, hourly or daily", rc.To)
+		}
+	}
+	return nil
+}
+
+// EmailRecipientsFor returns the recipients for a site's reports.
+func (c *Config) EmailRecipientsFor(siteID string) []EmailRecipient {
+	var out []EmailRecipient
+	for _, rc := range c.EmailRecipients {
+		if rc.SiteID == "*" || rc.SiteID == siteID {
+			out = append(out, rc)
+		}
+	}
+	return out
+}
+
+// AllowedDomains returns all registered site domains and their aliases.
 func (c *Config) AllowedDomains() []string {
-	return c.Sites
+	domains := slices.Clone(c.Sites)
+	for _, d := range c.Sites {
+		if s := c.SiteConfigs[d]; s != nil {
+			domains = append(domains, s.Aliases...)
+		}
+	}
+	return domains
 }
 
-// AllowedOrigins returns full origin URLs (https://domain) for CORS.
-// Includes the portal domain if configured.
+// AllowedOrigins returns full origin URLs (https://domain) for CORS: site
+// domains and aliases, the extra origins of SITES_FILE and the portal domain
+// if configured.
 func (c *Config) AllowedOrigins() []string {
 	seen := make(map[string]bool)
 	var origins []string
-	for _, d := range c.Sites {
+	for _, d := range c.AllowedDomains() {
 		o := "https://" + d
 		if !seen[o] {
 			origins = append(origins, o)
 			seen[o] = true
 		}
 	}
+	for _, d := range c.Sites {
+		if s := c.SiteConfigs[d]; s != nil {
+			for _, o := range s.Origins {
+				if !seen[o] {
+					origins = append(origins, o)
+					seen[o] = true
+				}
+			}
+		}
+	}
 	if c.PortalDomain != "" {
 		o := "https://" + c.PortalDomain
 		if !seen[o] {
@@ -146,17 +922,66 @@ func (c *Config) AllowedOrigins() []string {
 	return origins
 }
 
-// FindSiteByDomain returns the domain if it's in the allowed sites list, or empty string.
+// FindSiteByDomain returns the site a domain or alias belongs to, or empty string.
 func (c *Config) FindSiteByDomain(domain string) string {
 	domain = strings.ToLower(domain)
 	for _, d := range c.Sites {
 		if d == domain {
 			return d
 		}
+		if s := c.SiteConfigs[d]; s != nil && slices.Contains(s.Aliases, domain) {
+			return d
+		}
 	}
 	return ""
 }
 
+// AdminEnabled returns true if at least one admin API key is configured.
+func (c *Config) AdminEnabled() bool {
+	return len(c.AdminAPIKeys) > 0
+}
+
+// maxAdminNameLength bounds admin names, which end up in status history.
+const maxAdminNameLength = 100
+
+func loadAdminKeys(cfg *Config) error {
+	keys := make(map[string]string)
+	if v := os.Getenv("ADMIN_API_KEY"); v != "" {
+		keys["admin"] = v
+	}
+	if v := os.Getenv("ADMIN_API_KEYS"); v != "" {
+		for _, entry := range strings.Split(v, ",") {
+			entry = strings.TrimSpace(entry)
+			if entry == "" {
+				continue
+			}
+			name, token, ok := strings.Cut(entry, ":")
+			name = strings.TrimSpace(name)
+			if !ok || name == "" || token == "" {
+				return fmt.Errorf("invalid ADMIN_API_KEYS entry: expected name:token")
+			}
+			if len(name) > maxAdminNameLength {
+				return fmt.Errorf("invalid ADMIN_API_KEYS: name %q is longer than %d characters", name, maxAdminNameLength)
+			}
+			if _, dup := keys[name]; dup {
+				return fmt.Errorf("invalid ADMIN_API_KEYS: duplicate name %q", name)
+			}
+			keys[name] = token
+		}
+	}
+	seen := make(map[string]bool, len(keys))
+	for _, token := range keys {
+		if seen[token] {
+			return fmt.Errorf("invalid ADMIN_API_KEYS: the same token is used by more than one admin")
+		}
+		seen[token] = true
+	}
+	if len(keys) > 0 {
+		cfg.AdminAPIKeys = keys
+	}
+	return nil
+}
+
 // TLSEnabled returns true if TLS certificate and key files are configured.
 func (c *Config) TLSEnabled() bool {
 	return c.TLSCertFile != "" && c.TLSKeyFile != ""
@@ -0,0 +1,43 @@
+package config
+
+import (
+	"maps"
+	"testing"
+)
+
+func TestLoadAdminKeys(t *testing.T) {
+	tests := []struct {
+		name    string
+		key     string
+		keys    string
+		want    map[string]string
+		wantErr bool
+	}{
+		{name: "disabled"},
+		{name: "single key", key: "root", want: map[string]string{"admin": "root"}},
+		{name: "named keys", keys: "alice:a1, bob:b:2", want: map[string]string{"alice": "a1", "bob": "b:2"}},
+		{name: "both", key: "root", keys: "alice:a1", want: map[string]string{"admin": "root", "alice": "a1"}},
+		{name: "missing token", keys: "alice:", wantErr: true},
+		{name: "missing name", keys: ":a1", wantErr: true},
+		{name: "no separator", keys: "alice", wantErr: true},
+		{name: "duplicate name", key: "root", keys: "admin:other", wantErr: true},
+		{name: "shared token", keys: "alice:same,bob:same", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("ADMIN_API_KEY", tt.key)
+			t.Setenv("ADMIN_API_KEYS", tt.keys)
+			cfg := &Config{}
+			err := loadAdminKeys(cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("loadAdminKeys error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !maps.Equal(cfg.AdminAPIKeys, tt.want) {
+				t.Errorf("AdminAPIKeys = %v, want %v", cfg.AdminAPIKeys, tt.want)
+			}
+			if cfg.AdminEnabled() != (len(tt.want) > 0) {
+				t.Errorf("AdminEnabled = %v", cfg.AdminEnabled())
+			}
+		})
+	}
+}
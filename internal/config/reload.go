@@ -0,0 +1,140 @@
+package config
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// Live holds the current configuration and swaps in a new one on reload.
+// Components that must follow site changes read it with Get on every use
+// instead of keeping a *Config.
+//
+// Environment variables are fixed for the life of a process, so a reload
+// picks up changes to SITES_FILE and the configuration kept in the
+// database; everything else needs a restart.
+type Live struct {
+	cur      atomic.Pointer[Config]
+	mu       sync.Mutex // serializes reloads
+	stored   Stored     // see SetStored
+	reloaded chan struct{}
+}
+
+// NewLive returns a Live starting at cfg.
+func NewLive(cfg *Config) *Live {
+	l := &Live{reloaded: make(chan struct{}, 1)}
+	l.cur.Store(cfg)
+	return l
+}
+
+// Get returns the current configuration. It must not be modified.
+func (l *Live) Get() *Config {
+	return l.cur.Load()
+}
+
+// Reload loads the configuration again and swaps it in. If it fails to
+// load or validate, the current configuration stays and the error is returned.
+func (l *Live) Reload() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	cfg, err := LoadWithStored(l.stored)
+	if err != nil {
+		return err
+	}
+	l.cur.Store(cfg)
+	select {
+	case l.reloaded <- struct{}{}:
+	default: // a reload is already pending
+	}
+	return nil
+}
+
+// Reloaded receives after Reload swaps in a new configuration, so sites
+// added to SITES_FILE can be registered in the database. Reloads that
+// happen before the last one was received are coalesced.
+func (l *Live) Reloaded() <-chan struct{} {
+	return l.reloaded
+}
+
+// SetStored swaps in a configuration with the given database configuration.
+// If it fails to validate, the current configuration stays. The stored
+// configuration is layered over SITES_FILE as of the last load or reload, so
+// edits to the file only take effect on a reload.
+func (l *Live) SetStored(stored Stored) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	cfg, err := load(stored, l.Get().sitesFileData)
+	if err != nil {
+		return err
+	}
+	l.stored = stored
+	l.cur.Store(cfg)
+	return nil
+}
+
+// CheckStored reports whether a configuration with the given database
+// configuration would be valid, without swapping it in.
+func (l *Live) CheckStored(stored Stored) error {
+	_, err := load(stored, l.Get().sitesFileData)
+	return err
+}
+
+// Run reloads on SIGHUP and, if SITES_FILE_POLL_INTERVAL is set, whenever
+// the modification time of SITES_FILE changes, until the context is cancelled.
+func (l *Live) Run(ctx context.Context) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	cfg := l.Get()
+	var poll <-chan time.Time
+	if cfg.SitesFile != "" && cfg.SitesFilePollInterval > 0 {
+		ticker := time.NewTicker(cfg.SitesFilePollInterval)
+		defer ticker.Stop()
+		poll = ticker.C
+	}
+	modTime := fileModTime(cfg.SitesFile)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			modTime = fileModTime(cfg.SitesFile)
+			l.reload("signal")
+		case <-poll:
+			if t := fileModTime(cfg.SitesFile); !t.Equal(modTime) {
+				modTime = t
+				l.reload("file change")
+			}
+		}
+	}
+}
+
+func (l *Live) reload(trigger string) {
+	if err := l.Reload(); err != nil {
+		slog.Error("config reload failed, keeping current config", "trigger", trigger, "error", err)
+		return
+	}
+	slog.Info("config reloaded", "trigger", trigger, "sites", len(l.Get().Sites))
+}
+
+// fileModTime returns the modification time of a file, or the zero time if
+// it can't be read.
+func fileModTime(path string) time.Time {
+	if path == "" {
+		return time.Time{}
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
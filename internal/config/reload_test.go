@@ -0,0 +1,102 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"slices"
+	"testing"
+
+	"github.com/devrimsoft/bug-notifications-api/internal/model"
+)
+
+func TestLiveReloaded(t *testing.T) {
+	t.Setenv("DATABASE_URL", "postgres://localhost/bugs")
+	t.Setenv("ALLOWED_SITES", "a.com")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := NewLive(cfg)
+
+	select {
+	case <-l.Reloaded():
+		t.Fatal("Reloaded received before a reload")
+	default:
+	}
+
+	t.Setenv("ALLOWED_SITES", "a.com,b.com")
+	for range 2 {
+		if err := l.Reload(); err != nil {
+			t.Fatalf("Reload: %v", err)
+		}
+	}
+	if !slices.Equal(l.Get().Sites, []string{"a.com", "b.com"}) {
+		t.Errorf("Sites = %v after reload", l.Get().Sites)
+	}
+	<-l.Reloaded()
+	select {
+	case <-l.Reloaded():
+		t.Error("pending reloads were not coalesced")
+	default:
+	}
+
+	// A failed reload keeps the current configuration and is not announced
+	t.Setenv("PORT", "not a port")
+	if err := l.Reload(); err == nil {
+		t.Fatal("Reload succeeded with an invalid PORT")
+	}
+	select {
+	case <-l.Reloaded():
+		t.Error("Reloaded received after a failed reload")
+	default:
+	}
+	if !slices.Equal(l.Get().Sites, []string{"a.com", "b.com"}) {
+		t.Errorf("Sites = %v after a failed reload", l.Get().Sites)
+	}
+}
+
+func TestLiveSetStoredKeepsSitesFile(t *testing.T) {
+	t.Setenv("DATABASE_URL", "postgres://localhost/bugs")
+	path := writeFile(t, "sites.json", `{"sites":[{"domain":"a.com"}]}`)
+	t.Setenv("SITES_FILE", path)
+	cfg, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := NewLive(cfg)
+	stored := Stored{
+		Sites:    []model.Site{{Domain: "db.com", Active: true, Settings: json.RawMessage(`{}`)}},
+		Taxonomy: model.DefaultTaxonomy(),
+	}
+
+	// An edit that was not signalled is not picked up by a stored change,
+	// and a half-written file does not make stored changes fail
+	for _, data := range []string{`{"sites":[{"domain":"a.com"},{"domain":"b.com"}]}`, `{"sites":[{"dom`} {
+		if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := l.CheckStored(stored); err != nil {
+			t.Fatalf("CheckStored: %v", err)
+		}
+		if err := l.SetStored(stored); err != nil {
+			t.Fatalf("SetStored: %v", err)
+		}
+		if want := []string{"a.com", "db.com"}; !slices.Equal(l.Get().Sites, want) {
+			t.Errorf("Sites = %v, want %v", l.Get().Sites, want)
+		}
+	}
+	if err := l.Reload(); err == nil {
+		t.Fatal("Reload succeeded with a half-written SITES_FILE")
+	}
+
+	// A reload reads the file and keeps the stored sites
+	if err := os.WriteFile(path, []byte(`{"sites":[{"domain":"a.com"},{"domain":"b.com"}]}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Reload(); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if want := []string{"a.com", "b.com", "db.com"}; !slices.Equal(l.Get().Sites, want) {
+		t.Errorf("Sites = %v after reload, want %v", l.Get().Sites, want)
+	}
+}
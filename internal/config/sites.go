@@ -0,0 +1,386 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/devrimsoft/bug-notifications-api/internal/model"
+	"github.com/devrimsoft/bug-notifications-api/internal/validate"
+	"gopkg.in/yaml.v3"
+)
+
+// MaxAttachments caps the files of one report, of all kinds together. A
+// site's attachments.max_files may only lower it.
+const MaxAttachments = 8
+
+// Site is a site as described in SITES_FILE. Settings that are left out
+// fall back to the environment (ATTACHMENT_TYPES, CAPTCHA_PROVIDERS,
+// NOTIFY_ROUTES, RATE_LIMIT_RPS) or the built-in defaults.
+type Site struct {
+	Domain       string              `json:"domain" yaml:"domain"`
+	Name         string              `json:"name" yaml:"name"`       // display name, defaults to the domain
+	Aliases      []string            `json:"aliases" yaml:"aliases"` // other domains reports may name as site_id
+	Origins      []string            `json:"origins" yaml:"origins"` // extra CORS origins, e.g. "https://app.example.net"
+	ReportTypes  []string            `json:"report_types" yaml:"report_types"`
+	Categories   []string            `json:"categories" yaml:"categories"`
+	CustomFields []model.CustomField `json:"custom_fields" yaml:"custom_fields"` // extra report fields
+	Attachments  *SiteAttachments    `json:"attachments" yaml:"attachments"`
+	RateLimitRPS int                 `json:"rate_limit_rps" yaml:"rate_limit_rps"` // per IP, on top of RATE_LIMIT_RPS
+	Captcha      string              `json:"captcha" yaml:"captcha"`
+	NotifyRoutes []NotifyRoute       `json:"notify_routes" yaml:"notify_routes"` // site_id is set to the site
+}
+
+// SiteAttachments limits the files of a site's reports.
+type SiteAttachments struct {
+	Kinds    []string `json:"kinds" yaml:"kinds"`         // allowed attachment kinds
+	MaxFiles int      `json:"max_files" yaml:"max_files"` // per report; 0 keeps the default
+}
+
+type sitesFile struct {
+	Sites []Site `json:"sites" yaml:"sites"`
+}
+
+// loadSitesFile reads the sites from a YAML (.yaml, .yml) or JSON (.json)
+// file. Unknown fields are rejected, so typos don't go unnoticed.
+func loadSitesFile(cfg *Config, path string, tax *model.Taxonomy) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read SITES_FILE: %w", err)
+	}
+	return parseSitesFile(cfg, path, data, tax)
+}
+
+// parseSitesFile is loadSitesFile with the file already read. The contents
+// are kept on cfg, so later changes to the stored sites can be layered over
+// the same file.
+func parseSitesFile(cfg *Config, path string, data []byte, tax *model.Taxonomy) error {
+	var f sitesFile
+	var err error
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		err = dec.Decode(&f)
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(&f)
+	default:
+		return fmt.Errorf("invalid SITES_FILE: must be a .yaml, .yml or .json file")
+	}
+	if err != nil {
+		return fmt.Errorf("invalid SITES_FILE: %w", err)
+	}
+	if len(f.Sites) == 0 {
+		return fmt.Errorf("invalid SITES_FILE: no sites")
+	}
+
+	cfg.SiteConfigs = make(map[string]*Site, len(f.Sites))
+	seen := make(map[string]string) // domain or alias -> site
+	for i := range f.Sites {
+		s := &f.Sites[i]
+		if err := validateSite(s, seen, tax); err != nil {
+			return fmt.Errorf("invalid SITES_FILE: %w", err)
+		}
+		cfg.Sites = append(cfg.Sites, s.Domain)
+		cfg.SiteConfigs[s.Domain] = s
+	}
+	cfg.sitesFileData = data
+	return nil
+}
+
+// validateSite normalizes a site and checks its settings. Domains and
+// aliases must be unique across all sites; report types and categories must
+// be active in the taxonomy, if there is one.
+func validateSite(s *Site, seen map[string]string, tax *model.Taxonomy) error {
+	s.Domain = strings.ToLower(strings.TrimSpace(s.Domain))
+	if s.Domain == "" {
+		return fmt.Errorf("site domain is required")
+	}
+	if s.Name == "" {
+		s.Name = s.Domain
+	}
+	for i, a := range s.Aliases {
+		s.Aliases[i] = strings.ToLower(strings.TrimSpace(a))
+	}
+	for _, d := range append([]string{s.Domain}, s.Aliases...) {
+		if d == "" {
+			return fmt.Errorf("site %q: empty alias", s.Domain)
+		}
+		if other, ok := seen[d]; ok {
+			return fmt.Errorf("site %q: domain %q is already used by site %q", s.Domain, d, other)
+		}
+		seen[d] = s.Domain
+	}
+
+	for i, o := range s.Origins {
+		u, err := url.Parse(strings.TrimRight(o, "/"))
+		if err != nil || u.Host == "" || (u.Scheme != "https" && u.Scheme != "http") || u.Path != "" {
+			return fmt.Errorf("site %q: origin %q must be a scheme and host, e.g. https://app.example.com", s.Domain, o)
+		}
+		s.Origins[i] = u.Scheme + "://" + strings.ToLower(u.Host)
+	}
+	for _, t := range s.ReportTypes {
+		if tax != nil && !tax.HasReportType(t) {
+			return fmt.Errorf("site %q: unknown report type %q", s.Domain, t)
+		}
+	}
+	for _, c := range s.Categories {
+		if tax != nil && !tax.HasCategory(c) {
+			return fmt.Errorf("site %q: unknown category %q", s.Domain, c)
+		}
+	}
+	if a := s.Attachments; a != nil {
+		for _, k := range a.Kinds {
+			if !model.ValidAttachmentKinds[model.AttachmentKind(k)] {
+				return fmt.Errorf("site %q: unknown attachment kind %q, must be image, log, har or video", s.Domain, k)
+			}
+		}
+		if a.MaxFiles < 0 || a.MaxFiles > MaxAttachments {
+			return fmt.Errorf("site %q: attachments.max_files must be between 0 and %d", s.Domain, MaxAttachments)
+		}
+	}
+	if err := validateCustomFields(s.CustomFields); err != nil {
+		return fmt.Errorf("site %q: %w", s.Domain, err)
+	}
+	if s.RateLimitRPS < 0 {
+		return fmt.Errorf("site %q: rate_limit_rps must not be negative", s.Domain)
+	}
+	s.Captcha = strings.ToLower(s.Captcha)
+	if s.Captcha != "" && !ValidCaptchaProviders[s.Captcha] {
+		return fmt.Errorf("site %q: captcha must be turnstile, hcaptcha, recaptcha, pow or none", s.Domain)
+	}
+	for i := range s.NotifyRoutes {
+		s.NotifyRoutes[i].SiteID = s.Domain
+	}
+	return nil
+}
+
+// MaxCustomFields caps the custom fields of a site.
+const MaxCustomFields = 20
+
+// customFieldNamePattern matches the names of custom fields.
+var customFieldNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,49}$`)
+
+func validateCustomFields(fields []model.CustomField) error {
+	if len(fields) > MaxCustomFields {
+		return fmt.Errorf("at most %d custom fields allowed", MaxCustomFields)
+	}
+	seen := make(map[string]bool)
+	for _, f := range fields {
+		if !customFieldNamePattern.MatchString(f.Name) {
+			return fmt.Errorf("custom field name %q must be lowercase letters, digits and underscores", f.Name)
+		}
+		if seen[f.Name] {
+			return fmt.Errorf("duplicate custom field %q", f.Name)
+		}
+		seen[f.Name] = true
+		if !model.ValidCustomFieldTypes[f.Type] {
+			return fmt.Errorf("custom field %q: type must be string, number, enum, bool or url", f.Name)
+		}
+		if f.Type == model.CustomFieldEnum && len(f.Options) == 0 {
+			return fmt.Errorf("custom field %q: enum needs options", f.Name)
+		}
+		if f.Type != model.CustomFieldEnum && len(f.Options) > 0 {
+			return fmt.Errorf("custom field %q: options are for enum fields only", f.Name)
+		}
+		maxLen := validate.MaxCustomFieldLen
+		if f.Type == model.CustomFieldURL {
+			maxLen = validate.MaxURLLen
+		}
+		if f.MaxLength < 0 || f.MaxLength > maxLen {
+			return fmt.Errorf("custom field %q: max_length must be between 0 and %d", f.Name, maxLen)
+		}
+		if f.MaxLength > 0 && f.Type != model.CustomFieldString && f.Type != model.CustomFieldURL {
+			return fmt.Errorf("custom field %q: max_length is for string and url fields only", f.Name)
+		}
+	}
+	return nil
+}
+
+// ParseSiteSettings reads the settings of a site in the sites table: the
+// fields of a SITES_FILE entry, as JSON. Unknown fields are rejected. A nil
+// taxonomy skips the report type and category checks.
+func ParseSiteSettings(domain string, data []byte, tax *model.Taxonomy) (*Site, error) {
+	var s Site
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&s); err != nil {
+		return nil, fmt.Errorf("invalid settings: %w", err)
+	}
+	if s.Domain != "" && !strings.EqualFold(s.Domain, domain) {
+		return nil, fmt.Errorf("settings domain %q does not match the site", s.Domain)
+	}
+	s.Domain = domain
+	if err := validateSite(&s, make(map[string]string), tax); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// applyStoredSites layers the rows of the sites table over the sites of
+// SITES_FILE or ALLOWED_SITES. A row with settings replaces the site's
+// SITES_FILE entry, or adds the site; a row without settings only renames
+// it. Inactive rows remove the site.
+func applyStoredSites(cfg *Config, stored []model.Site, tax *model.Taxonomy) error {
+	for _, row := range stored {
+		if !row.Active {
+			cfg.Sites = slices.DeleteFunc(cfg.Sites, func(d string) bool { return d == row.Domain })
+			delete(cfg.SiteConfigs, row.Domain)
+			continue
+		}
+		if row.Settings != nil {
+			s, err := ParseSiteSettings(row.Domain, row.Settings, tax)
+			if err != nil {
+				return fmt.Errorf("invalid stored site %q: %w", row.Domain, err)
+			}
+			if !slices.Contains(cfg.Sites, row.Domain) {
+				cfg.Sites = append(cfg.Sites, row.Domain)
+			}
+			if cfg.SiteConfigs == nil {
+				cfg.SiteConfigs = make(map[string]*Site)
+			}
+			cfg.SiteConfigs[row.Domain] = s
+		}
+		if row.Name != "" && slices.Contains(cfg.Sites, row.Domain) {
+			s := cfg.SiteConfigs[row.Domain]
+			if s == nil {
+				if cfg.SiteConfigs == nil {
+					cfg.SiteConfigs = make(map[string]*Site)
+				}
+				s = &Site{Domain: row.Domain}
+				cfg.SiteConfigs[row.Domain] = s
+			}
+			s.Name = row.Name
+		}
+	}
+
+	// Domains and aliases must stay unique across both sources
+	seen := make(map[string]string)
+	for _, d := range cfg.Sites {
+		names := []string{d}
+		if s := cfg.SiteConfigs[d]; s != nil {
+			names = append(names, s.Aliases...)
+		}
+		for _, n := range names {
+			if other, ok := seen[n]; ok {
+				return fmt.Errorf("invalid stored sites: domain %q is used by both %q and %q", n, other, d)
+			}
+			seen[n] = d
+		}
+	}
+	return nil
+}
+
+// applySiteConfigs layers the settings of SITES_FILE over the ones read
+// from the environment.
+func applySiteConfigs(cfg *Config, knownSite func(string) bool, tax *model.Taxonomy) error {
+	for _, domain := range cfg.Sites {
+		s := cfg.SiteConfigs[domain]
+		if s == nil {
+			continue
+		}
+		if s.Attachments != nil && len(s.Attachments.Kinds) > 0 {
+			if cfg.AttachmentKinds == nil {
+				cfg.AttachmentKinds = make(map[string][]model.AttachmentKind)
+			}
+			var kinds []model.AttachmentKind
+			for _, k := range s.Attachments.Kinds {
+				kind := model.AttachmentKind(k)
+				if err := checkAttachmentStorage(cfg, kind); err != nil {
+					return fmt.Errorf("invalid SITES_FILE: site %q: %w", domain, err)
+				}
+				kinds = append(kinds, kind)
+			}
+			cfg.AttachmentKinds[domain] = kinds
+		}
+		if s.Captcha != "" {
+			if err := checkCaptchaKeys(cfg, s.Captcha); err != nil {
+				return err
+			}
+			cfg.CaptchaProviders[domain] = s.Captcha
+		}
+		cfg.NotifyRoutes = append(cfg.NotifyRoutes, s.NotifyRoutes...)
+	}
+	if err := validateNotifyRoutes(cfg.NotifyRoutes, knownSite, tax); err != nil {
+		return fmt.Errorf("invalid notify routes: %w", err)
+	}
+	return nil
+}
+
+// SiteName returns the display name of a site.
+func (c *Config) SiteName(siteID string) string {
+	if s := c.SiteConfigs[siteID]; s != nil {
+		return s.Name
+	}
+	return siteID
+}
+
+// ReportTypeAllowed reports whether a site accepts reports of a type. Sites
+// without a list accept all types.
+func (c *Config) ReportTypeAllowed(siteID, reportType string) bool {
+	s := c.SiteConfigs[siteID]
+	return s == nil || len(s.ReportTypes) == 0 || slices.Contains(s.ReportTypes, reportType)
+}
+
+// CategoryAllowed reports whether a site accepts reports in a category.
+// Sites without a list accept all categories.
+func (c *Config) CategoryAllowed(siteID, category string) bool {
+	s := c.SiteConfigs[siteID]
+	return s == nil || len(s.Categories) == 0 || slices.Contains(s.Categories, category)
+}
+
+// ReportTypesFor returns the active report types a site accepts, in order.
+func (c *Config) ReportTypesFor(siteID string) []model.TaxonomyEntry {
+	entries := []model.TaxonomyEntry{}
+	for _, e := range c.Taxonomy.ReportTypes {
+		if e.Active && c.ReportTypeAllowed(siteID, e.Key) {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+// CategoriesFor returns the active categories a site accepts, in order.
+func (c *Config) CategoriesFor(siteID string) []model.TaxonomyEntry {
+	entries := []model.TaxonomyEntry{}
+	for _, e := range c.Taxonomy.Categories {
+		if e.Active && c.CategoryAllowed(siteID, e.Key) {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+// MaxAttachmentsFor returns the file limit of a site's reports, or 0 if the
+// site uses the default.
+func (c *Config) MaxAttachmentsFor(siteID string) int {
+	if s := c.SiteConfigs[siteID]; s != nil && s.Attachments != nil {
+		return s.Attachments.MaxFiles
+	}
+	return 0
+}
+
+// CustomFieldsFor returns the custom report fields of a site.
+func (c *Config) CustomFieldsFor(siteID string) []model.CustomField {
+	if s := c.SiteConfigs[siteID]; s != nil {
+		return s.CustomFields
+	}
+	return nil
+}
+
+// RateLimitFor returns the per-IP rate limit of a site's reports, or 0 if
+// only RATE_LIMIT_RPS applies.
+func (c *Config) RateLimitFor(siteID string) int {
+	if s := c.SiteConfigs[siteID]; s != nil {
+		return s.RateLimitRPS
+	}
+	return 0
+}
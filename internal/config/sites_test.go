@@ -0,0 +1,534 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/devrimsoft/bug-notifications-api/internal/model"
+)
+
+// writeFile writes data to a file named name in a temporary directory and
+// returns its path.
+func writeFile(t *testing.T, name, data string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+const sitesYAML = `
+sites:
+  - domain: Example.com
+    name: Example
+    aliases: [WWW.example.com]
+    origins: ["https://App.Example.net/"]
+    report_types: [bug]
+    categories: [design, security]
+    custom_fields:
+      - name: app_version
+        type: string
+        required: true
+      - name: plan
+        type: enum
+        options: [free, pro]
+    attachments:
+      kinds: [image, log]
+      max_files: 3
+    rate_limit_rps: 2
+    captcha: POW
+    notify_routes:
+      - id: sec
+        type: telegram
+        categories: [security]
+        bot_token: token
+        chat_id: "-100"
+  - domain: other.com
+`
+
+const sitesJSON = `{"sites": [
+  {
+    "domain": "Example.com",
+    "name": "Example",
+    "aliases": ["WWW.example.com"],
+    "origins": ["https://App.Example.net/"],
+    "report_types": ["bug"],
+    "categories": ["design", "security"],
+    "custom_fields": [
+      {"name": "app_version", "type": "string", "required": true},
+      {"name": "plan", "type": "enum", "options": ["free", "pro"]}
+    ],
+    "attachments": {"kinds": ["image", "log"], "max_files": 3},
+    "rate_limit_rps": 2,
+    "captcha": "POW",
+    "notify_routes": [
+      {"id": "sec", "type": "telegram", "categories": ["security"], "bot_token": "token", "chat_id": "-100"}
+    ]
+  },
+  {"domain": "other.com"}
+]}`
+
+func TestLoadSitesFile(t *testing.T) {
+	want := map[string]*Site{
+		"example.com": {
+			Domain:      "example.com",
+			Name:        "Example",
+			Aliases:     []string{"www.example.com"},
+			Origins:     []string{"https://app.example.net"},
+			ReportTypes: []string{"bug"},
+			Categories:  []string{"design", "security"},
+			CustomFields: []model.CustomField{
+				{Name: "app_version", Type: model.CustomFieldString, Required: true},
+				{Name: "plan", Type: model.CustomFieldEnum, Options: []string{"free", "pro"}},
+			},
+			Attachments:  &SiteAttachments{Kinds: []string{"image", "log"}, MaxFiles: 3},
+			RateLimitRPS: 2,
+			Captcha:      "pow",
+			NotifyRoutes: []NotifyRoute{
+				{ID: "sec", Type: "telegram", SiteID: "example.com", Categories: []string{"security"}, BotToken: "token", ChatID: "-100"},
+			},
+		},
+		"other.com": {Domain: "other.com", Name: "other.com"},
+	}
+
+	for name, data := range map[string]string{"sites.yaml": sitesYAML, "sites.json": sitesJSON} {
+		t.Run(name, func(t *testing.T) {
+			cfg := &Config{}
+			if err := loadSitesFile(cfg, writeFile(t, name, data), model.DefaultTaxonomy()); err != nil {
+				t.Fatalf("loadSitesFile: %v", err)
+			}
+			if want := []string{"example.com", "other.com"}; !slices.Equal(cfg.Sites, want) {
+				t.Errorf("Sites = %v, want %v", cfg.Sites, want)
+			}
+			if !reflect.DeepEqual(cfg.SiteConfigs, want) {
+				t.Errorf("SiteConfigs = %+v, want %+v", cfg.SiteConfigs, want)
+			}
+		})
+	}
+}
+
+func TestLoadSitesFileErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		data string
+		want string
+	}{
+		{name: "extension", file: "sites.toml", data: "", want: "must be a .yaml, .yml or .json file"},
+		{name: "no sites", file: "sites.yaml", data: "sites: []", want: "no sites"},
+		{name: "unknown yaml field", file: "sites.yml", data: "sites:\n  - domain: a.com\n    captha: pow\n", want: "captha"},
+		{name: "unknown json field", file: "sites.json", data: `{"sites":[{"domain":"a.com","captha":"pow"}]}`, want: "captha"},
+		{name: "missing domain", file: "sites.json", data: `{"sites":[{"name":"A"}]}`, want: "site domain is required"},
+		{name: "empty alias", file: "sites.json", data: `{"sites":[{"domain":"a.com","aliases":[" "]}]}`, want: "empty alias"},
+		{name: "duplicate domain", file: "sites.json", data: `{"sites":[{"domain":"a.com"},{"domain":"A.com"}]}`, want: `domain "a.com" is already used by site "a.com"`},
+		{name: "alias of another site", file: "sites.json", data: `{"sites":[{"domain":"a.com"},{"domain":"b.com","aliases":["a.com"]}]}`, want: `domain "a.com" is already used by site "a.com"`},
+		{name: "origin with path", file: "sites.json", data: `{"sites":[{"domain":"a.com","origins":["https://a.com/app"]}]}`, want: "must be a scheme and host"},
+		{name: "origin scheme", file: "sites.json", data: `{"sites":[{"domain":"a.com","origins":["ftp://a.com"]}]}`, want: "must be a scheme and host"},
+		{name: "report type", file: "sites.json", data: `{"sites":[{"domain":"a.com","report_types":["praise"]}]}`, want: `unknown report type "praise"`},
+		{name: "category", file: "sites.json", data: `{"sites":[{"domain":"a.com","categories":["billing"]}]}`, want: `unknown category "billing"`},
+		{name: "attachment kind", file: "sites.json", data: `{"sites":[{"domain":"a.com","attachments":{"kinds":["zip"]}}]}`, want: `unknown attachment kind "zip"`},
+		{name: "max files", file: "sites.json", data: `{"sites":[{"domain":"a.com","attachments":{"max_files":-1}}]}`, want: "max_files must be between 0 and 8"},
+		{name: "max files above the limit", file: "sites.json", data: `{"sites":[{"domain":"a.com","attachments":{"max_files":20}}]}`, want: "max_files must be between 0 and 8"},
+		{name: "rate limit", file: "sites.json", data: `{"sites":[{"domain":"a.com","rate_limit_rps":-1}]}`, want: "rate_limit_rps must not be negative"},
+		{name: "captcha", file: "sites.json", data: `{"sites":[{"domain":"a.com","captcha":"recaptcha2"}]}`, want: "captcha must be"},
+		{name: "custom field name", file: "sites.json", data: `{"sites":[{"domain":"a.com","custom_fields":[{"name":"App","type":"string"}]}]}`, want: "must be lowercase"},
+		{name: "custom field duplicate", file: "sites.json", data: `{"sites":[{"domain":"a.com","custom_fields":[{"name":"a","type":"string"},{"name":"a","type":"bool"}]}]}`, want: `duplicate custom field "a"`},
+		{name: "custom field type", file: "sites.json", data: `{"sites":[{"domain":"a.com","custom_fields":[{"name":"a","type":"date"}]}]}`, want: "type must be"},
+		{name: "enum without options", file: "sites.json", data: `{"sites":[{"domain":"a.com","custom_fields":[{"name":"a","type":"enum"}]}]}`, want: "enum needs options"},
+		{name: "options on string", file: "sites.json", data: `{"sites":[{"domain":"a.com","custom_fields":[{"name":"a","type":"string","options":["x"]}]}]}`, want: "options are for enum fields only"},
+		{name: "max length on bool", file: "sites.json", data: `{"sites":[{"domain":"a.com","custom_fields":[{"name":"a","type":"bool","max_length":5}]}]}`, want: "max_length is for string and url fields only"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{}
+			err := loadSitesFile(cfg, writeFile(t, tt.file, tt.data), model.DefaultTaxonomy())
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("loadSitesFile = %v, want an error containing %q", err, tt.want)
+			}
+			if !strings.HasPrefix(err.Error(), "invalid SITES_FILE: ") {
+				t.Errorf("error %q does not name SITES_FILE", err)
+			}
+		})
+	}
+
+	cfg := &Config{}
+	if err := loadSitesFile(cfg, filepath.Join(t.TempDir(), "missing.yaml"), model.DefaultTaxonomy()); err == nil ||
+		!strings.HasPrefix(err.Error(), "read SITES_FILE: ") {
+		t.Errorf("loadSitesFile(missing) = %v, want a read error", err)
+	}
+}
+
+func TestLoadWithSitesFile(t *testing.T) {
+	t.Setenv("DATABASE_URL", "postgres://localhost/bugs")
+	t.Setenv("SITES_FILE", writeFile(t, "sites.yaml", sitesYAML))
+	t.Setenv("SITES_FILE_POLL_INTERVAL", "30s")
+	t.Setenv("ALLOWED_SITES", "ignored.com")
+	t.Setenv("IMAGE_STORAGE", "local")
+	t.Setenv("IMAGE_LOCAL_DIR", t.TempDir())
+	t.Setenv("IMAGE_PUBLIC_URL", "https://files.example.com")
+	t.Setenv("IMAGE_SIGNING_KEY", strings.Repeat("k", 32))
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if want := []string{"example.com", "other.com"}; !slices.Equal(cfg.Sites, want) {
+		t.Errorf("Sites = %v, want %v (ALLOWED_SITES is ignored)", cfg.Sites, want)
+	}
+	if cfg.SitesFilePollInterval.String() != "30s" {
+		t.Errorf("SitesFilePollInterval = %v", cfg.SitesFilePollInterval)
+	}
+	if got := cfg.CaptchaProviderFor("example.com"); got != "pow" {
+		t.Errorf("CaptchaProviderFor(example.com) = %q, want pow", got)
+	}
+	if !cfg.AttachmentAllowed("example.com", model.AttachmentLog) || cfg.AttachmentAllowed("example.com", model.AttachmentVideo) {
+		t.Errorf("AttachmentKinds[example.com] = %v, want image and log", cfg.AttachmentKinds["example.com"])
+	}
+	if got := cfg.NotifyRoutesFor("example.com", "security"); len(got) != 1 || got[0].ID != "sec" {
+		t.Errorf("NotifyRoutesFor(example.com, security) = %+v", got)
+	}
+	if got := cfg.NotifyRoutesFor("other.com", "security"); len(got) != 0 {
+		t.Errorf("NotifyRoutesFor(other.com, security) = %+v, want none", got)
+	}
+	if cfg.SiteName("example.com") != "Example" || cfg.SiteName("other.com") != "other.com" {
+		t.Errorf("SiteName = %q, %q", cfg.SiteName("example.com"), cfg.SiteName("other.com"))
+	}
+	if cfg.FindSiteByDomain("www.example.com") != "example.com" {
+		t.Errorf("FindSiteByDomain(www.example.com) = %q", cfg.FindSiteByDomain("www.example.com"))
+	}
+	if !slices.Contains(cfg.AllowedOrigins(), "https://app.example.net") {
+		t.Errorf("AllowedOrigins = %v, want the site's extra origin", cfg.AllowedOrigins())
+	}
+	if !cfg.ReportTypeAllowed("example.com", "bug") || cfg.ReportTypeAllowed("example.com", "request") {
+		t.Error("example.com should only accept bug reports")
+	}
+	if !cfg.CategoryAllowed("other.com", "billing") {
+		t.Error("sites without a category list should accept every category")
+	}
+	if cfg.RateLimitFor("example.com") != 2 || cfg.MaxAttachmentsFor("example.com") != 3 {
+		t.Errorf("RateLimitFor = %d, MaxAttachmentsFor = %d", cfg.RateLimitFor("example.com"), cfg.MaxAttachmentsFor("example.com"))
+	}
+}
+
+func TestLoadWithSitesFileChecksStorage(t *testing.T) {
+	t.Setenv("DATABASE_URL", "postgres://localhost/bugs")
+	t.Setenv("SITES_FILE", writeFile(t, "sites.json", `{"sites":[{"domain":"a.com","attachments":{"kinds":["har"]}}]}`))
+	t.Setenv("IMAGE_STORAGE", "r2")
+	t.Setenv("IMAGE_API_URL", "https://images.example.com")
+	t.Setenv("IMAGE_API_KEY", "key")
+
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "har attachments need IMAGE_STORAGE=local or s3") {
+		t.Fatalf("Load = %v, want a storage error", err)
+	}
+}
+
+func TestParseSiteSettings(t *testing.T) {
+	tax := model.DefaultTaxonomy()
+
+	s, err := ParseSiteSettings("shop.com", []byte(`{"name":"Shop","aliases":["WWW.shop.com"],"captcha":"None","categories":["mobile"]}`), tax)
+	if err != nil {
+		t.Fatalf("ParseSiteSettings: %v", err)
+	}
+	want := &Site{Domain: "shop.com", Name: "Shop", Aliases: []string{"www.shop.com"}, Captcha: "none", Categories: []string{"mobile"}}
+	if !reflect.DeepEqual(s, want) {
+		t.Errorf("ParseSiteSettings = %+v, want %+v", s, want)
+	}
+
+	s, err = ParseSiteSettings("shop.com", []byte(`{"domain":"SHOP.com"}`), tax)
+	if err != nil || s.Domain != "shop.com" || s.Name != "shop.com" {
+		t.Errorf("ParseSiteSettings(matching domain) = %+v, %v", s, err)
+	}
+
+	errs := []struct {
+		name string
+		data string
+		want string
+	}{
+		{name: "not json", data: `[1]`, want: "invalid settings"},
+		{name: "unknown field", data: `{"captha":"pow"}`, want: "invalid settings"},
+		{name: "other domain", data: `{"domain":"other.com"}`, want: `settings domain "other.com" does not match the site`},
+		{name: "alias is the domain", data: `{"aliases":["shop.com"]}`, want: `domain "shop.com" is already used`},
+		{name: "report type", data: `{"report_types":["praise"]}`, want: `unknown report type "praise"`},
+		{name: "custom field", data: `{"custom_fields":[{"name":"a","type":"enum"}]}`, want: "enum needs options"},
+	}
+	for _, tt := range errs {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseSiteSettings("shop.com", []byte(tt.data), tax); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("ParseSiteSettings = %v, want an error containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyStoredSites(t *testing.T) {
+	base := func() *Config {
+		return &Config{
+			Sites: []string{"a.com", "b.com", "c.com"},
+			SiteConfigs: map[string]*Site{
+				"a.com": {Domain: "a.com", Name: "A", Aliases: []string{"www.a.com"}, Captcha: "pow"},
+			},
+		}
+	}
+
+	cfg := base()
+	err := applyStoredSites(cfg, []model.Site{
+		{Domain: "a.com", Name: "Renamed A", Active: true},                                      // renames, keeps the file settings
+		{Domain: "b.com", Active: false},                                                        // removed
+		{Domain: "c.com", Active: true, Settings: []byte(`{"name":"C","captcha":"none"}`)},      // replaces
+		{Domain: "d.com", Name: "D", Active: true, Settings: []byte(`{"aliases":["m.d.com"]}`)}, // added, named by the row
+		{Domain: "e.com", Name: "E", Active: true},                                              // unknown without settings: ignored
+	}, model.DefaultTaxonomy())
+	if err != nil {
+		t.Fatalf("applyStoredSites: %v", err)
+	}
+	if want := []string{"a.com", "c.com", "d.com"}; !slices.Equal(cfg.Sites, want) {
+		t.Errorf("Sites = %v, want %v", cfg.Sites, want)
+	}
+	want := map[string]*Site{
+		"a.com": {Domain: "a.com", Name: "Renamed A", Aliases: []string{"www.a.com"}, Captcha: "pow"},
+		"c.com": {Domain: "c.com", Name: "C", Captcha: "none"},
+		"d.com": {Domain: "d.com", Name: "D", Aliases: []string{"m.d.com"}},
+	}
+	if !reflect.DeepEqual(cfg.SiteConfigs, want) {
+		t.Errorf("SiteConfigs = %+v, want %+v", cfg.SiteConfigs, want)
+	}
+
+	// A name-only row for an ALLOWED_SITES site without settings
+	cfg = &Config{Sites: []string{"a.com"}}
+	if err := applyStoredSites(cfg, []model.Site{{Domain: "a.com", Name: "A", Active: true}}, model.DefaultTaxonomy()); err != nil {
+		t.Fatalf("applyStoredSites(name only): %v", err)
+	}
+	if cfg.SiteName("a.com") != "A" {
+		t.Errorf("SiteName(a.com) = %q, want A", cfg.SiteName("a.com"))
+	}
+
+	errs := []struct {
+		name string
+		row  model.Site
+		want string
+	}{
+		{
+			name: "invalid settings",
+			row:  model.Site{Domain: "d.com", Active: true, Settings: []byte(`{"captcha":"maybe"}`)},
+			want: `invalid stored site "d.com"`,
+		},
+		{
+			name: "alias of a file site",
+			row:  model.Site{Domain: "d.com", Active: true, Settings: []byte(`{"aliases":["www.a.com"]}`)},
+			want: `domain "www.a.com" is used by both "a.com" and "d.com"`,
+		},
+		{
+			name: "alias is a file domain",
+			row:  model.Site{Domain: "d.com", Active: true, Settings: []byte(`{"aliases":["b.com"]}`)},
+			want: `domain "b.com" is used by both "b.com" and "d.com"`,
+		},
+	}
+	for _, tt := range errs {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := applyStoredSites(base(), []model.Site{tt.row}, model.DefaultTaxonomy()); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("applyStoredSites = %v, want an error containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadWithStoredSites(t *testing.T) {
+	t.Setenv("DATABASE_URL", "postgres://localhost/bugs")
+	t.Setenv("ALLOWED_SITES", "a.com,b.com")
+	t.Setenv("CAPTCHA_PROVIDERS", "*:none")
+
+	cfg, err := LoadWithStored(Stored{Sites: []model.Site{
+		{Domain: "b.com", Active: false},
+		{Domain: "c.com", Active: true, Settings: []byte(`{"captcha":"pow"}`)},
+	}})
+	if err != nil {
+		t.Fatalf("LoadWithStored: %v", err)
+	}
+	if want := []string{"a.com", "c.com"}; !slices.Equal(cfg.Sites, want) {
+		t.Errorf("Sites = %v, want %v", cfg.Sites, want)
+	}
+	if cfg.CaptchaProviderFor("c.com") != "pow" || cfg.CaptchaProviderFor("a.com") != "" {
+		t.Errorf("captcha providers = %v", cfg.CaptchaProviders)
+	}
+
+	// Environment settings must name sites that remain after the stored rows
+	t.Setenv("SIGNING_SECRETS", "b.com:secret")
+	if _, err := LoadWithStored(Stored{Sites: []model.Site{{Domain: "b.com", Active: false}}}); err == nil ||
+		!strings.Contains(err.Error(), `unknown site "b.com"`) {
+		t.Errorf("LoadWithStored = %v, want an unknown site error", err)
+	}
+}
+
+func TestLoadChecksTaxonomyOnceKnown(t *testing.T) {
+	t.Setenv("DATABASE_URL", "postgres://localhost/bugs")
+	t.Setenv("SITES_FILE", writeFile(t, "sites.json", `{"sites":[{"domain":"a.com","report_types":["praise"],"categories":["billing"]}]}`))
+	t.Setenv("NOTIFY_ROUTES", `[{"id":"money","type":"telegram","site_id":"*","categories":["billing"],"bot_token":"t","chat_id":"1"}]`)
+
+	// At startup the taxonomy is not loaded yet; keys added to the database
+	// must not stop the process
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if !slices.Equal(cfg.Taxonomy.CategoryKeys(), model.DefaultTaxonomy().CategoryKeys()) {
+		t.Errorf("Taxonomy = %v, want the default until the database is loaded", cfg.Taxonomy.CategoryKeys())
+	}
+
+	tax := model.DefaultTaxonomy()
+	tax.ReportTypes = append(tax.ReportTypes, model.TaxonomyEntry{Key: "praise", Position: 3, Active: true})
+	tax.Categories = append(tax.Categories, model.TaxonomyEntry{Key: "billing", Position: 8, Active: true})
+	cfg, err = LoadWithStored(Stored{Taxonomy: tax})
+	if err != nil {
+		t.Fatalf("LoadWithStored: %v", err)
+	}
+	if got := cfg.ReportTypesFor("a.com"); len(got) != 1 || got[0].Key != "praise" {
+		t.Errorf("ReportTypesFor(a.com) = %+v, want praise", got)
+	}
+	if got := cfg.NotifyRoutesFor("a.com", "billing"); len(got) != 1 {
+		t.Errorf("NotifyRoutesFor(a.com, billing) = %+v", got)
+	}
+
+	// Keys missing from or inactive in the database are rejected
+	tests := []struct {
+		name string
+		tax  func() *model.Taxonomy
+		want string
+	}{
+		{
+			name: "default taxonomy",
+			tax:  model.DefaultTaxonomy,
+			want: `unknown report type "praise"`,
+		},
+		{
+			name: "inactive category",
+			tax: func() *model.Taxonomy {
+				tax := model.DefaultTaxonomy()
+				tax.ReportTypes = append(tax.ReportTypes, model.TaxonomyEntry{Key: "praise", Active: true})
+				tax.Categories = append(tax.Categories, model.TaxonomyEntry{Key: "billing", Active: false})
+				return tax
+			},
+			want: `unknown category "billing"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := LoadWithStored(Stored{Taxonomy: tt.tax()}); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("LoadWithStored = %v, want an error containing %q", err, tt.want)
+			}
+		})
+	}
+
+	// NOTIFY_ROUTES is checked on its own too
+	t.Setenv("SITES_FILE", writeFile(t, "sites.json", `{"sites":[{"domain":"a.com"}]}`))
+	if _, err := LoadWithStored(Stored{Taxonomy: model.DefaultTaxonomy()}); err == nil ||
+		!strings.Contains(err.Error(), `invalid NOTIFY_ROUTES: route "money": unknown category "billing"`) {
+		t.Errorf("LoadWithStored = %v, want a NOTIFY_ROUTES error", err)
+	}
+}
+
+func TestTaxonomyFor(t *testing.T) {
+	tax := &model.Taxonomy{
+		ReportTypes: []model.TaxonomyEntry{
+			{Key: "bug", Position: 1, Active: true},
+			{Key: "request", Position: 2, Active: true},
+			{Key: "legacy", Position: 3, Active: false},
+		},
+		Categories: []model.TaxonomyEntry{
+			{Key: "design", Position: 1, Active: true},
+			{Key: "billing", Position: 2, Active: true},
+			{Key: "old", Position: 3, Active: false},
+		},
+	}
+	cfg := &Config{
+		Taxonomy: tax,
+		Sites:    []string{"a.com", "b.com"},
+		SiteConfigs: map[string]*Site{
+			"a.com": {Domain: "a.com", ReportTypes: []string{"request"}, Categories: []string{"billing", "design"}},
+		},
+	}
+
+	keys := func(entries []model.TaxonomyEntry) []string {
+		var out []string
+		for _, e := range entries {
+			out = append(out, e.Key)
+		}
+		return out
+	}
+	tests := []struct {
+		site       string
+		types      []string
+		categories []string
+	}{
+		// The taxonomy's order, not the site's
+		{site: "a.com", types: []string{"request"}, categories: []string{"design", "billing"}},
+		// Sites without lists get every active entry
+		{site: "b.com", types: []string{"bug", "request"}, categories: []string{"design", "billing"}},
+	}
+	for _, tt := range tests {
+		if got := keys(cfg.ReportTypesFor(tt.site)); !slices.Equal(got, tt.types) {
+			t.Errorf("ReportTypesFor(%s) = %v, want %v", tt.site, got, tt.types)
+		}
+		if got := keys(cfg.CategoriesFor(tt.site)); !slices.Equal(got, tt.categories) {
+			t.Errorf("CategoriesFor(%s) = %v, want %v", tt.site, got, tt.categories)
+		}
+	}
+
+	cfg.Taxonomy = &model.Taxonomy{}
+	if got := cfg.CategoriesFor("a.com"); got == nil || len(got) != 0 {
+		t.Errorf("CategoriesFor with an empty taxonomy = %#v, want an empty slice", got)
+	}
+}
+
+func TestLoadChecksSitesOnceKnown(t *testing.T) {
+	t.Setenv("DATABASE_URL", "postgres://localhost/bugs")
+	t.Setenv("ALLOWED_SITES", "a.com")
+	t.Setenv("SMTP_HOST", "smtp.example.com")
+	t.Setenv("SMTP_FROM", "bugs@example.com")
+	t.Setenv("POW_DIFFICULTY", "8")
+
+	// db.com exists only in the sites table, which is not read yet at startup
+	env := map[string]string{
+		"SIGNING_SECRETS":   "db.com:secret",
+		"ATTACHMENT_TYPES":  "db.com:image|log",
+		"CAPTCHA_PROVIDERS": "*:none,db.com:pow",
+		"WEBHOOK_ENDPOINTS": `[{"id":"ops","site_id":"db.com","url":"https://hooks.example.com/bugs","secret":"s"}]`,
+		"NOTIFY_ROUTES":     `[{"id":"team","type":"slack","site_id":"db.com","url":"https://hooks.slack.com/services/x"}]`,
+		"EMAIL_RECIPIENTS":  `[{"site_id":"db.com","to":"owner@example.com"}]`,
+	}
+	for k, v := range env {
+		t.Setenv(k, v)
+	}
+	if _, err := Load(); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	cfg, err := LoadWithStored(Stored{
+		Sites:    []model.Site{{Domain: "db.com", Active: true, Settings: []byte(`{}`)}},
+		Taxonomy: model.DefaultTaxonomy(),
+	})
+	if err != nil {
+		t.Fatalf("LoadWithStored: %v", err)
+	}
+	if cfg.SigningSecrets["db.com"] != "secret" || cfg.CaptchaProviderFor("db.com") != "pow" {
+		t.Errorf("db.com settings were not applied: %v, %v", cfg.SigningSecrets, cfg.CaptchaProviders)
+	}
+
+	// Once the database is read, each setting must name a known site
+	for k := range env {
+		t.Run(k, func(t *testing.T) {
+			for other := range env {
+				if other != k {
+					t.Setenv(other, "")
+				}
+			}
+			_, err := LoadWithStored(Stored{Sites: []model.Site{}, Taxonomy: model.DefaultTaxonomy()})
+			if err == nil || !strings.Contains(err.Error(), "invalid "+k) || !strings.Contains(err.Error(), `"db.com"`) {
+				t.Errorf("LoadWithStored = %v, want an unknown site error for %s", err, k)
+			}
+		})
+	}
+}
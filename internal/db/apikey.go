@@ -0,0 +1,117 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/devrimsoft/bug-notifications-api/internal/model"
+	"github.com/jackc/pgx/v5"
+)
+
+// apiKeyColumns is the column list shared by all site_api_keys SELECT queries.
+// Keep in sync with scanAPIKey.
+const apiKeyColumns = `id, site_id, name, prefix, valid_from, expires_at, revoked_at, last_used_at, created_at`
+
+// CreateSiteAPIKey stores a new key. Only its hash is persisted.
+func (r *Repository) CreateSiteAPIKey(ctx context.Context, k *model.SiteAPIKey, keyHash string) error {
+	query := `
+		INSERT INTO site_api_keys (id, site_id, name, prefix, key_hash, valid_from, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+	_, err := r.pool.Exec(ctx, query, k.ID, k.SiteID, k.Name, k.Prefix, keyHash, k.ValidFrom, k.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("insert site api key: %w", err)
+	}
+	return nil
+}
+
+// FindSiteAPIKeyByHash returns the key with the given hash, or nil if there is none.
+// The key is returned whether or not it is currently active.
+func (r *Repository) FindSiteAPIKeyByHash(ctx context.Context, keyHash string) (*model.SiteAPIKey, error) {
+	k, err := scanAPIKey(r.pool.QueryRow(ctx, `SELECT `+apiKeyColumns+` FROM site_api_keys WHERE key_hash = $1`, keyHash))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("find site api key: %w", err)
+	}
+	return k, nil
+}
+
+// TouchSiteAPIKey records that a key was used. Writes at most once a minute per key.
+func (r *Repository) TouchSiteAPIKey(ctx context.Context, id string) error {
+	_, err := r.pool.Exec(ctx, `
+		UPDATE site_api_keys SET last_used_at = now()
+		WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < now() - interval '1 minute')
+	`, id)
+	if err != nil {
+		return fmt.Errorf("touch site api key: %w", err)
+	}
+	return nil
+}
+
+// ListSiteAPIKeys returns all keys, or a single site's keys, newest first.
+func (r *Repository) ListSiteAPIKeys(ctx context.Context, siteID string) ([]model.SiteAPIKey, error) {
+	query := `SELECT ` + apiKeyColumns + ` FROM site_api_keys`
+	var args []any
+	if siteID != "" {
+		query += ` WHERE site_id = $1`
+		args = append(args, siteID)
+	}
+	query += ` ORDER BY site_id, created_at DESC`
+
+	rows, err := r.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list site api keys: %w", err)
+	}
+	defer rows.Close()
+
+	keys := []model.SiteAPIKey{}
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan site api key: %w", err)
+		}
+		keys = append(keys, *k)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("list site api keys: %w", err)
+	}
+	return keys, nil
+}
+
+// ExpireSiteAPIKeys makes every usable key of a site except keepID expire at
+// the given time, unless it already expires sooner. Returns how many keys
+// were changed.
+func (r *Repository) ExpireSiteAPIKeys(ctx context.Context, siteID, keepID string, at time.Time) (int64, error) {
+	tag, err := r.pool.Exec(ctx, `
+		UPDATE site_api_keys SET expires_at = $3
+		WHERE site_id = $1 AND id <> $2 AND revoked_at IS NULL
+		  AND (expires_at IS NULL OR expires_at > $3)
+	`, siteID, keepID, at)
+	if err != nil {
+		return 0, fmt.Errorf("expire site api keys: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// RevokeSiteAPIKey disables a key immediately. Returns false if no
+// unrevoked key with the given ID exists.
+func (r *Repository) RevokeSiteAPIKey(ctx context.Context, id string) (bool, error) {
+	tag, err := r.pool.Exec(ctx, `UPDATE site_api_keys SET revoked_at = now() WHERE id = $1 AND revoked_at IS NULL`, id)
+	if err != nil {
+		return false, fmt.Errorf("revoke site api key: %w", err)
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// scanAPIKey reads a single site_api_keys row selected with apiKeyColumns.
+func scanAPIKey(row pgx.Row) (*model.SiteAPIKey, error) {
+	var k model.SiteAPIKey
+	err := row.Scan(&k.ID, &k.SiteID, &k.Name, &k.Prefix, &k.ValidFrom, &k.ExpiresAt, &k.RevokedAt, &k.LastUsedAt, &k.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &k, nil
+}
@@ -0,0 +1,142 @@
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/devrimsoft/bug-notifications-api/internal/model"
+	"github.com/jackc/pgx/v5"
+)
+
+// AttachFiles saves the files the worker uploaded for a report, after any
+// attachments it already has, records how many were lost and clears
+// attachments_pending. Reports that are no longer pending are left alone, so
+// a redelivered message can't add the files twice.
+func (r *Repository) AttachFiles(ctx context.Context, id string, attachments []model.Attachment, lost int) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin attach files: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx, `
+		UPDATE bug_reports SET attachments_pending = false, attachments_lost = $2
+		WHERE id = $1 AND attachments_pending
+	`, id, lost)
+	if err != nil {
+		return fmt.Errorf("attach files: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return nil
+	}
+
+	var existing int
+	if err := tx.QueryRow(ctx, `SELECT count(*) FROM attachments WHERE report_id = $1`, id).Scan(&existing); err != nil {
+		return fmt.Errorf("count attachments: %w", err)
+	}
+	for i := range attachments {
+		if err := insertAttachment(ctx, tx, id, existing+i, &attachments[i]); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit attach files: %w", err)
+	}
+	return nil
+}
+
+func insertAttachment(ctx context.Context, tx pgx.Tx, reportID string, position int, a *model.Attachment) error {
+	_, err := tx.Exec(ctx, `
+		INSERT INTO attachments (report_id, position, kind, url, object_key, thumbnail_url, thumbnail_key, content_type, size_bytes, width, height, sha256)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		ON CONFLICT (report_id, position) DO NOTHING
+	`, reportID, position, a.Kind, a.URL, nullable(a.ObjectKey), a.ThumbnailURL, nullable(a.ThumbnailKey),
+		nullable(a.ContentType), nullable(a.Size), nullable(a.Width), nullable(a.Height), nullable(a.SHA256))
+	if err != nil {
+		return fmt.Errorf("insert attachment: %w", err)
+	}
+	return nil
+}
+
+// loadAttachments fills in the attachments and image URLs of reports.
+func (r *Repository) loadAttachments(ctx context.Context, reports []model.BugReport) error {
+	if len(reports) == 0 {
+		return nil
+	}
+	ids := make([]string, len(reports))
+	byID := make(map[string]*model.BugReport, len(reports))
+	for i := range reports {
+		ids[i] = reports[i].ID
+		byID[reports[i].ID] = &reports[i]
+	}
+
+	rows, err := r.pool.Query(ctx, `
+		SELECT report_id, kind, url, thumbnail_url, COALESCE(content_type, ''), COALESCE(size_bytes, 0),
+		       COALESCE(width, 0), COALESCE(height, 0), COALESCE(sha256, ''),
+		       COALESCE(object_key, ''), COALESCE(thumbnail_key, '')
+		FROM attachments
+		WHERE report_id = ANY($1::uuid[])
+		ORDER BY report_id, position
+	`, ids)
+	if err != nil {
+		return fmt.Errorf("load attachments: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var reportID string
+		var a model.Attachment
+		err := rows.Scan(&reportID, &a.Kind, &a.URL, &a.ThumbnailURL, &a.ContentType, &a.Size,
+			&a.Width, &a.Height, &a.SHA256, &a.ObjectKey, &a.ThumbnailKey)
+		if err != nil {
+			return fmt.Errorf("scan attachment: %w", err)
+		}
+		if report := byID[reportID]; report != nil {
+			report.Attachments = append(report.Attachments, a)
+			if a.Kind == model.AttachmentImage {
+				report.ImageURLs = append(report.ImageURLs, a.URL)
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("load attachments: %w", err)
+	}
+	return nil
+}
+
+// ReferencedImageKeys returns which of the given storage keys belong to an
+// attachment, as the file itself or an image thumbnail.
+func (r *Repository) ReferencedImageKeys(ctx context.Context, keys []string) (map[string]bool, error) {
+	rows, err := r.pool.Query(ctx, `
+		SELECT object_key FROM attachments WHERE object_key = ANY($1::text[])
+		UNION
+		SELECT thumbnail_key FROM attachments WHERE thumbnail_key = ANY($1::text[])
+	`, keys)
+	if err != nil {
+		return nil, fmt.Errorf("referenced image keys: %w", err)
+	}
+	defer rows.Close()
+
+	refs := make(map[string]bool)
+	for rows.Next() {
+		var k string
+		if err := rows.Scan(&k); err != nil {
+			return nil, fmt.Errorf("scan image key: %w", err)
+		}
+		refs[k] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("referenced image keys: %w", err)
+	}
+	return refs, nil
+}
+
+// nullable maps a zero value to SQL NULL.
+func nullable[T comparable](v T) *T {
+	var zero T
+	if v == zero {
+		return nil
+	}
+	return &v
+}
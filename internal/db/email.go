@@ -0,0 +1,143 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/devrimsoft/bug-notifications-api/internal/model"
+)
+
+// CreateEmailNotification records a pending email for one recipient, due at
+// dueAt. A report is queued at most once per recipient.
+func (r *Repository) CreateEmailNotification(ctx context.Context, n *model.EmailNotification, dueAt time.Time) error {
+	query := `
+		INSERT INTO email_notifications (id, recipient, site_id, report_id, mode, next_attempt_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (recipient, report_id) DO NOTHING
+	`
+	_, err := r.pool.Exec(ctx, query, n.ID, n.Recipient, n.SiteID, n.ReportID, string(n.Mode), dueAt)
+	if err != nil {
+		return fmt.Errorf("insert email notification: %w", err)
+	}
+	return nil
+}
+
+// emailLockNamespace is the first key of the per-recipient advisory locks
+// taken by ClaimDueEmailNotifications; the second is a hash of the recipient.
+const emailLockNamespace int32 = 0x656d6c // "eml"
+
+// DueEmailRecipients returns up to limit recipients with pending emails that
+// are due, longest waiting first.
+func (r *Repository) DueEmailRecipients(ctx context.Context, limit int) ([]string, error) {
+	rows, err := r.pool.Query(ctx, `
+		SELECT recipient FROM email_notifications
+		WHERE status = 'pending' AND next_attempt_at <= now()
+		GROUP BY recipient
+		ORDER BY min(next_attempt_at)
+		LIMIT $1
+	`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("list email recipients: %w", err)
+	}
+	defer rows.Close()
+
+	var out []string
+	for rows.Next() {
+		var recipient string
+		if err := rows.Scan(&recipient); err != nil {
+			return nil, fmt.Errorf("scan email recipient: %w", err)
+		}
+		out = append(out, recipient)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("list email recipients: %w", err)
+	}
+	return out, nil
+}
+
+// ClaimDueEmailNotifications returns every pending email of a recipient that
+// is due and pushes their next attempt out by lease, like
+// ClaimDueWebhookDeliveries. A recipient is claimed by one scheduler at a
+// time under an advisory lock, so a digest is never split between them;
+// while another scheduler holds it, nothing is returned.
+func (r *Repository) ClaimDueEmailNotifications(ctx context.Context, recipient string, lease time.Duration) ([]model.EmailNotification, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var locked bool
+	if err := tx.QueryRow(ctx, `SELECT pg_try_advisory_xact_lock($1, hashtext($2))`, emailLockNamespace, recipient).Scan(&locked); err != nil {
+		return nil, fmt.Errorf("lock email recipient: %w", err)
+	}
+	if !locked {
+		return nil, nil
+	}
+
+	rows, err := tx.Query(ctx, `
+		UPDATE email_notifications SET next_attempt_at = now() + make_interval(secs => $2)
+		WHERE recipient = $1 AND status = 'pending' AND next_attempt_at <= now()
+		RETURNING id, recipient, site_id, report_id, mode, attempts
+	`, recipient, lease.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("claim email notifications: %w", err)
+	}
+	defer rows.Close()
+
+	var out []model.EmailNotification
+	for rows.Next() {
+		var n model.EmailNotification
+		if err := rows.Scan(&n.ID, &n.Recipient, &n.SiteID, &n.ReportID, &n.Mode, &n.Attempts); err != nil {
+			return nil, fmt.Errorf("scan email notification: %w", err)
+		}
+		out = append(out, n)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("claim email notifications: %w", err)
+	}
+	rows.Close()
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("commit tx: %w", err)
+	}
+	return out, nil
+}
+
+// MarkEmailsSent records that the given emails went out.
+func (r *Repository) MarkEmailsSent(ctx context.Context, ids []string) error {
+	query := `
+		UPDATE email_notifications
+		SET status = 'sent', attempts = attempts + 1, last_error = NULL, sent_at = now()
+		WHERE id = ANY($1::uuid[])
+	`
+	if _, err := r.pool.Exec(ctx, query, ids); err != nil {
+		return fmt.Errorf("mark emails sent: %w", err)
+	}
+	return nil
+}
+
+// MarkEmailAttemptFailed records a failed send for the given emails. With a
+// zero nextAttempt they are marked failed for good; otherwise they stay
+// pending until then.
+func (r *Repository) MarkEmailAttemptFailed(ctx context.Context, ids []string, errMsg string, nextAttempt time.Time) error {
+	var err error
+	if nextAttempt.IsZero() {
+		_, err = r.pool.Exec(ctx, `
+			UPDATE email_notifications
+			SET status = 'failed', attempts = attempts + 1, last_error = $2
+			WHERE id = ANY($1::uuid[])
+		`, ids, errMsg)
+	} else {
+		_, err = r.pool.Exec(ctx, `
+			UPDATE email_notifications
+			SET attempts = attempts + 1, last_error = $2, next_attempt_at = $3
+			WHERE id = ANY($1::uuid[])
+		`, ids, errMsg, nextAttempt)
+	}
+	if err != nil {
+		return fmt.Errorf("mark email attempt failed: %w", err)
+	}
+	return nil
+}
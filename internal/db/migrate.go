@@ -0,0 +1,274 @@
+package db
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+//go:embed migrations/*.sql
+var migrationFS embed.FS
+
+// migrationLockID is the Postgres advisory lock key held while migrating,
+// so several workers starting at once apply each migration exactly once.
+const migrationLockID int64 = 0x6275675f6d6967 // "bug_mig"
+
+// migrationFilePattern matches "NNN_name.up.sql" and "NNN_name.down.sql".
+var migrationFilePattern = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+const createSchemaMigrationsSQL = `
+CREATE TABLE IF NOT EXISTS schema_migrations (
+    version    INTEGER PRIMARY KEY,
+    name       TEXT NOT NULL,
+    applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+)`
+
+// Migration is one numbered schema change with its up and down SQL.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationState reports whether a known migration has been applied.
+type MigrationState struct {
+	Version   int
+	Name      string
+	AppliedAt *time.Time
+}
+
+// loadMigrations reads the embedded migrations, sorted by version.
+func loadMigrations() ([]Migration, error) {
+	entries, err := fs.ReadDir(migrationFS, "migrations")
+	if err != nil {
+		return nil, fmt.Errorf("read migrations: %w", err)
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, e := range entries {
+		m := migrationFilePattern.FindStringSubmatch(e.Name())
+		if m == nil {
+			return nil, fmt.Errorf("migration file %q does not match NNN_name.(up|down).sql", e.Name())
+		}
+		version, _ := strconv.Atoi(m[1])
+
+		data, err := migrationFS.ReadFile(path.Join("migrations", e.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("read migration %s: %w", e.Name(), err)
+		}
+
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = mig
+		} else if mig.Name != m[2] {
+			return nil, fmt.Errorf("migration version %d used by both %q and %q", version, mig.Name, m[2])
+		}
+		if m[3] == "up" {
+			mig.Up = string(data)
+		} else {
+			mig.Down = string(data)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if mig.Up == "" {
+			return nil, fmt.Errorf("migration %03d_%s has no up file", mig.Version, mig.Name)
+		}
+		migrations = append(migrations, *mig)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Migrate applies all pending embedded migrations in version order.
+func Migrate(ctx context.Context, pool *pgxpool.Pool) error {
+	slog.Info("running database migrations...")
+
+	migrations, err := loadMigrations()
+	if err != nil {
+		return err
+	}
+
+	applied := 0
+	err = withMigrationLock(ctx, pool, func(conn *pgx.Conn) error {
+		done, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, mig := range migrations {
+			if _, ok := done[mig.Version]; ok {
+				continue
+			}
+			slog.Info("applying migration", "version", mig.Version, "name", mig.Name)
+			if err := runMigration(ctx, conn, mig.Up,
+				`INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`, mig.Version, mig.Name,
+			); err != nil {
+				return fmt.Errorf("migration %03d_%s: %w", mig.Version, mig.Name, err)
+			}
+			applied++
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	slog.Info("database migrations completed", "applied", applied)
+	return nil
+}
+
+// MigrateDown rolls back the given number of most recently applied migrations.
+func MigrateDown(ctx context.Context, pool *pgxpool.Pool, steps int) error {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return err
+	}
+	known := make(map[int]Migration, len(migrations))
+	for _, mig := range migrations {
+		known[mig.Version] = mig
+	}
+
+	return withMigrationLock(ctx, pool, func(conn *pgx.Conn) error {
+		done, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		versions := make([]int, 0, len(done))
+		for v := range done {
+			versions = append(versions, v)
+		}
+		sort.Sort(sort.Reverse(sort.IntSlice(versions)))
+
+		for i := 0; i < steps && i < len(versions); i++ {
+			mig, ok := known[versions[i]]
+			if !ok {
+				return fmt.Errorf("applied migration %d is not known to this build", versions[i])
+			}
+			if mig.Down == "" {
+				return fmt.Errorf("migration %03d_%s has no down file", mig.Version, mig.Name)
+			}
+			slog.Info("reverting migration", "version", mig.Version, "name", mig.Name)
+			if err := runMigration(ctx, conn, mig.Down,
+				`DELETE FROM schema_migrations WHERE version = $1`, mig.Version,
+			); err != nil {
+				return fmt.Errorf("revert %03d_%s: %w", mig.Version, mig.Name, err)
+			}
+		}
+		return nil
+	})
+}
+
+// MigrationStatus lists every embedded migration and when it was applied.
+// It only reads: initialized is false, and every migration pending, if
+// schema_migrations does not exist yet.
+func MigrationStatus(ctx context.Context, pool *pgxpool.Pool) (states []MigrationState, initialized bool, err error) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return nil, false, err
+	}
+
+	if err := pool.QueryRow(ctx, `SELECT to_regclass('schema_migrations') IS NOT NULL`).Scan(&initialized); err != nil {
+		return nil, false, fmt.Errorf("check schema_migrations: %w", err)
+	}
+	done := make(map[int]time.Time)
+	if initialized {
+		if done, err = appliedVersions(ctx, pool); err != nil {
+			return nil, false, err
+		}
+	}
+
+	for _, mig := range migrations {
+		state := MigrationState{Version: mig.Version, Name: mig.Name}
+		if t, ok := done[mig.Version]; ok {
+			state.AppliedAt = &t
+		}
+		states = append(states, state)
+	}
+	return states, initialized, nil
+}
+
+// withMigrationLock runs fn on a dedicated connection holding the migration
+// advisory lock, creating schema_migrations first if needed.
+func withMigrationLock(ctx context.Context, pool *pgxpool.Pool, fn func(conn *pgx.Conn) error) error {
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("acquire connection: %w", err)
+	}
+	defer conn.Release()
+
+	if _, err := conn.Exec(ctx, `SELECT pg_advisory_lock($1)`, migrationLockID); err != nil {
+		return fmt.Errorf("acquire migration lock: %w", err)
+	}
+	defer func() {
+		// Use a fresh context so the lock is released even if ctx was cancelled.
+		unlockCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if _, err := conn.Exec(unlockCtx, `SELECT pg_advisory_unlock($1)`, migrationLockID); err != nil {
+			slog.Error("release migration lock failed", "error", err)
+		}
+	}()
+
+	if _, err := conn.Exec(ctx, createSchemaMigrationsSQL); err != nil {
+		return fmt.Errorf("create schema_migrations: %w", err)
+	}
+
+	return fn(conn.Conn())
+}
+
+// querier is satisfied by *pgx.Conn, *pgxpool.Pool and pgx.Tx.
+type querier interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// appliedVersions returns applied migration versions and their apply times.
+func appliedVersions(ctx context.Context, q querier) (map[int]time.Time, error) {
+	rows, err := q.Query(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("read schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	done := make(map[int]time.Time)
+	for rows.Next() {
+		var v int
+		var t time.Time
+		if err := rows.Scan(&v, &t); err != nil {
+			return nil, fmt.Errorf("scan schema_migrations: %w", err)
+		}
+		done[v] = t
+	}
+	return done, rows.Err()
+}
+
+// runMigration executes a migration script and its bookkeeping statement in one transaction.
+func runMigration(ctx context.Context, conn *pgx.Conn, script, bookkeeping string, args ...any) error {
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, script); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, bookkeeping, args...); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
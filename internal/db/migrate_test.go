@@ -0,0 +1,42 @@
+package db
+
+import (
+	"io/fs"
+	"strings"
+	"testing"
+)
+
+func TestEmbeddedMigrations(t *testing.T) {
+	entries, err := fs.ReadDir(migrationFS, "migrations")
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := make(map[string]bool, len(entries))
+	for _, e := range entries {
+		if !migrationFilePattern.MatchString(e.Name()) {
+			t.Errorf("migration file %q does not match NNN_name.(up|down).sql", e.Name())
+		}
+		files[e.Name()] = true
+	}
+	for name := range files {
+		if base, ok := strings.CutSuffix(name, ".up.sql"); ok && !files[base+".down.sql"] {
+			t.Errorf("%s has no %s.down.sql", name, base)
+		}
+	}
+
+	migrations, err := loadMigrations()
+	if err != nil {
+		t.Fatalf("loadMigrations: %v", err)
+	}
+	if len(migrations) == 0 {
+		t.Fatal("no migrations embedded")
+	}
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			t.Fatalf("migration %03d_%s: versions must be unique and contiguous from 1, want %03d", m.Version, m.Name, i+1)
+		}
+		if strings.TrimSpace(m.Up) == "" || strings.TrimSpace(m.Down) == "" {
+			t.Errorf("migration %03d_%s: up and down scripts must not be empty", m.Version, m.Name)
+		}
+	}
+}
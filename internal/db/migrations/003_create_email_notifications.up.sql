@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS email_notifications (
+    id              UUID PRIMARY KEY,
+    recipient       TEXT NOT NULL,
+    site_id         TEXT NOT NULL,
+    report_id       UUID NOT NULL,
+    mode            TEXT NOT NULL CHECK (mode IN ('instant', 'hourly', 'daily')),
+    status          TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'sent', 'failed')),
+    attempts        INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL,
+    last_error      TEXT,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    sent_at         TIMESTAMPTZ,
+    UNIQUE (recipient, report_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_email_notifications_due ON email_notifications (next_attempt_at) WHERE status = 'pending';
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS report_status_history;
+
+ALTER TABLE bug_reports DROP CONSTRAINT IF EXISTS bug_reports_status_check;
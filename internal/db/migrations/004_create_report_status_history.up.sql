@@ -0,0 +1,14 @@
+ALTER TABLE bug_reports ADD CONSTRAINT bug_reports_status_check
+    CHECK (status IN ('new', 'triaged', 'in_progress', 'resolved', 'wont_fix', 'duplicate'));
+
+CREATE TABLE IF NOT EXISTS report_status_history (
+    id          BIGSERIAL PRIMARY KEY,
+    report_id   UUID NOT NULL REFERENCES bug_reports (id) ON DELETE CASCADE,
+    from_status TEXT NOT NULL,
+    to_status   TEXT NOT NULL,
+    changed_by  TEXT NOT NULL,
+    note        TEXT,
+    changed_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_report_status_history_report ON report_status_history (report_id, changed_at);
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS site_api_keys (
+    id           UUID PRIMARY KEY,
+    site_id      TEXT NOT NULL,
+    name         TEXT NOT NULL DEFAULT '',
+    prefix       TEXT NOT NULL,
+    key_hash     TEXT NOT NULL UNIQUE,
+    valid_from   TIMESTAMPTZ NOT NULL DEFAULT now(),
+    expires_at   TIMESTAMPTZ,
+    revoked_at   TIMESTAMPTZ,
+    last_used_at TIMESTAMPTZ,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_site_api_keys_site ON site_api_keys (site_id, created_at DESC);
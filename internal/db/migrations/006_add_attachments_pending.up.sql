@@ -0,0 +1,2 @@
+-- Set while the worker is still uploading a report's images
+ALTER TABLE bug_reports ADD COLUMN IF NOT EXISTS attachments_pending BOOLEAN NOT NULL DEFAULT false;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_bug_reports_image_keys;
+ALTER TABLE bug_reports DROP COLUMN IF EXISTS image_keys;
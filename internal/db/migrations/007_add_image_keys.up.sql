@@ -0,0 +1,18 @@
+-- Storage keys of the images the worker uploaded for a report, so the orphan
+-- reconciler can tell which stored objects are still referenced
+ALTER TABLE bug_reports ADD COLUMN IF NOT EXISTS image_keys JSONB;
+
+CREATE INDEX IF NOT EXISTS idx_bug_reports_image_keys ON bug_reports USING GIN (image_keys);
+
+-- Backfill reports whose images were uploaded by the API directly; their URLs
+-- end in the "{site_id}/{yyyy}/{mm}/{uuid}{ext}" key
+UPDATE bug_reports b SET image_keys = k.keys
+FROM (
+    SELECT r.id, jsonb_agg(m[1]) AS keys
+    FROM bug_reports r,
+         jsonb_array_elements_text(r.image_urls) AS u,
+         regexp_match(u, '([^/]+/[0-9]{4}/[0-9]{2}/[0-9a-f-]{36}\.[a-z]+)$') AS m
+    WHERE m IS NOT NULL AND split_part(m[1], '/', 1) = r.site_id
+    GROUP BY r.id
+) k
+WHERE b.id = k.id AND b.image_keys IS NULL;
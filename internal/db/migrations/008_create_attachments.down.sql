@@ -0,0 +1,17 @@
+ALTER TABLE bug_reports ADD COLUMN IF NOT EXISTS image_urls JSONB DEFAULT '[]';
+ALTER TABLE bug_reports ADD COLUMN IF NOT EXISTS image_keys JSONB;
+CREATE INDEX IF NOT EXISTS idx_bug_reports_image_keys ON bug_reports USING GIN (image_keys);
+
+UPDATE bug_reports r SET
+    image_urls = a.urls,
+    image_keys = a.keys
+FROM (
+    SELECT report_id,
+           jsonb_agg(url ORDER BY position) AS urls,
+           jsonb_agg(object_key ORDER BY position) FILTER (WHERE object_key IS NOT NULL) AS keys
+    FROM attachments
+    GROUP BY report_id
+) a
+WHERE r.id = a.report_id;
+
+DROP TABLE IF EXISTS attachments;
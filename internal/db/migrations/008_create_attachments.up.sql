@@ -0,0 +1,33 @@
+CREATE TABLE IF NOT EXISTS attachments (
+    id            BIGSERIAL PRIMARY KEY,
+    report_id     UUID NOT NULL REFERENCES bug_reports (id) ON DELETE CASCADE,
+    position      INT NOT NULL,
+    url           TEXT NOT NULL,
+    object_key    TEXT,
+    thumbnail_url TEXT,
+    thumbnail_key TEXT,
+    content_type  TEXT,
+    size_bytes    BIGINT,
+    width         INT,
+    height        INT,
+    sha256        TEXT,
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (report_id, position)
+);
+
+CREATE INDEX IF NOT EXISTS idx_attachments_object_key ON attachments (object_key) WHERE object_key IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_attachments_thumbnail_key ON attachments (thumbnail_key) WHERE thumbnail_key IS NOT NULL;
+
+-- Move existing image URLs over; stored images get their key back from image_keys
+INSERT INTO attachments (report_id, position, url, object_key, created_at)
+SELECT r.id, u.ord - 1, u.url,
+       (SELECT k FROM jsonb_array_elements_text(COALESCE(r.image_keys, '[]')) AS k
+        WHERE u.url LIKE '%/' || k LIMIT 1),
+       r.created_at
+FROM bug_reports r,
+     jsonb_array_elements_text(r.image_urls) WITH ORDINALITY AS u(url, ord)
+WHERE jsonb_typeof(r.image_urls) = 'array'
+ON CONFLICT (report_id, position) DO NOTHING;
+
+ALTER TABLE bug_reports DROP COLUMN IF EXISTS image_keys;
+ALTER TABLE bug_reports DROP COLUMN IF EXISTS image_urls;
@@ -0,0 +1,2 @@
+DELETE FROM attachments WHERE kind <> 'image';
+ALTER TABLE attachments DROP COLUMN IF EXISTS kind;
@@ -0,0 +1,2 @@
+ALTER TABLE attachments ADD COLUMN IF NOT EXISTS kind TEXT NOT NULL DEFAULT 'image'
+    CHECK (kind IN ('image', 'log', 'har', 'video'));
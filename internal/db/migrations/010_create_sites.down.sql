@@ -0,0 +1,2 @@
+ALTER TABLE bug_reports DROP CONSTRAINT IF EXISTS bug_reports_site_id_fkey;
+DROP TABLE IF EXISTS sites;
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS sites (
+    domain     TEXT PRIMARY KEY,
+    name       TEXT NOT NULL DEFAULT '',
+    active     BOOLEAN NOT NULL DEFAULT true,
+    settings   JSONB CHECK (settings IS NULL OR jsonb_typeof(settings) = 'object'),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- Register the sites of existing reports, so the foreign key holds
+INSERT INTO sites (domain)
+SELECT DISTINCT site_id FROM bug_reports
+ON CONFLICT (domain) DO NOTHING;
+
+ALTER TABLE bug_reports ADD CONSTRAINT bug_reports_site_id_fkey
+    FOREIGN KEY (site_id) REFERENCES sites (domain);
@@ -0,0 +1,2 @@
+ALTER TABLE bug_reports ADD COLUMN IF NOT EXISTS custom_fields JSONB
+    CHECK (custom_fields IS NULL OR jsonb_typeof(custom_fields) = 'object');
@@ -0,0 +1,9 @@
+ALTER TABLE bug_reports DROP CONSTRAINT IF EXISTS bug_reports_category_fkey;
+ALTER TABLE bug_reports DROP CONSTRAINT IF EXISTS bug_reports_report_type_fkey;
+DROP TABLE IF EXISTS report_categories;
+DROP TABLE IF EXISTS report_types;
+
+ALTER TABLE bug_reports ADD CONSTRAINT bug_reports_report_type_check
+    CHECK (report_type IN ('bug', 'request'));
+ALTER TABLE bug_reports ADD CONSTRAINT bug_reports_category_check
+    CHECK (category IN ('design', 'functionality', 'performance', 'content', 'mobile', 'security', 'other'));
@@ -0,0 +1,39 @@
+CREATE TABLE IF NOT EXISTS report_types (
+    key        TEXT PRIMARY KEY,
+    labels     JSONB NOT NULL DEFAULT '{}' CHECK (jsonb_typeof(labels) = 'object'),
+    position   INTEGER NOT NULL DEFAULT 0,
+    active     BOOLEAN NOT NULL DEFAULT true,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS report_categories (
+    key        TEXT PRIMARY KEY,
+    labels     JSONB NOT NULL DEFAULT '{}' CHECK (jsonb_typeof(labels) = 'object'),
+    position   INTEGER NOT NULL DEFAULT 0,
+    active     BOOLEAN NOT NULL DEFAULT true,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+INSERT INTO report_types (key, labels, position) VALUES
+    ('bug',     '{"tr": "Hata Bildirimi", "en": "Bug Report", "de": "Fehlerbericht", "ru": "Ошибка", "uk": "Помилка", "es": "Informe de error"}', 1),
+    ('request', '{"tr": "Öneriler", "en": "Suggestions", "de": "Vorschläge", "ru": "Предложения", "uk": "Пропозиції", "es": "Sugerencias"}', 2)
+ON CONFLICT (key) DO NOTHING;
+
+INSERT INTO report_categories (key, labels, position) VALUES
+    ('design',        '{"tr": "Tasarım", "en": "Design", "de": "Design", "ru": "Дизайн", "uk": "Дизайн", "es": "Diseño"}', 1),
+    ('functionality', '{"tr": "İşlevsellik", "en": "Functionality", "de": "Funktionalität", "ru": "Функциональность", "uk": "Функціональність", "es": "Funcionalidad"}', 2),
+    ('performance',   '{"tr": "Performans", "en": "Performance", "de": "Leistung", "ru": "Производительность", "uk": "Продуктивність", "es": "Rendimiento"}', 3),
+    ('content',       '{"tr": "İçerik", "en": "Content", "de": "Inhalt", "ru": "Контент", "uk": "Контент", "es": "Contenido"}', 4),
+    ('mobile',        '{"tr": "Mobil", "en": "Mobile", "de": "Mobil", "ru": "Мобильный", "uk": "Мобільний", "es": "Móvil"}', 5),
+    ('security',      '{"tr": "Güvenlik", "en": "Security", "de": "Sicherheit", "ru": "Безопасность", "uk": "Безпека", "es": "Seguridad"}', 6),
+    ('other',         '{"tr": "Diğer", "en": "Other", "de": "Sonstiges", "ru": "Другое", "uk": "Інше", "es": "Otro"}', 7)
+ON CONFLICT (key) DO NOTHING;
+
+-- The tables replace the fixed lists
+ALTER TABLE bug_reports DROP CONSTRAINT IF EXISTS bug_reports_report_type_check;
+ALTER TABLE bug_reports DROP CONSTRAINT IF EXISTS bug_reports_category_check;
+
+ALTER TABLE bug_reports ADD CONSTRAINT bug_reports_report_type_fkey
+    FOREIGN KEY (report_type) REFERENCES report_types (key);
+ALTER TABLE bug_reports ADD CONSTRAINT bug_reports_category_fkey
+    FOREIGN KEY (category) REFERENCES report_categories (key);
@@ -0,0 +1 @@
+ALTER TABLE bug_reports DROP COLUMN IF EXISTS attachments_lost;
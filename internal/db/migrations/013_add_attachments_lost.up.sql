@@ -0,0 +1,2 @@
+-- Uploads the worker could not store because their staged copy was gone
+ALTER TABLE bug_reports ADD COLUMN IF NOT EXISTS attachments_lost INTEGER NOT NULL DEFAULT 0;
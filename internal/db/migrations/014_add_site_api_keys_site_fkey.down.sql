@@ -0,0 +1 @@
+ALTER TABLE site_api_keys DROP CONSTRAINT IF EXISTS site_api_keys_site_id_fkey;
@@ -0,0 +1,7 @@
+-- Register the sites of existing keys, so the foreign key holds
+INSERT INTO sites (domain)
+SELECT DISTINCT site_id FROM site_api_keys
+ON CONFLICT (domain) DO NOTHING;
+
+ALTER TABLE site_api_keys ADD CONSTRAINT site_api_keys_site_id_fkey
+    FOREIGN KEY (site_id) REFERENCES sites (domain);
@@ -2,8 +2,9 @@ package db
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/devrimsoft/bug-notifications-api/internal/model"
 	"github.com/jackc/pgx/v5"
@@ -20,23 +21,22 @@ func NewRepository(pool *pgxpool.Pool) *Repository {
 
 // InsertReport inserts a bug report into the database.
 // Uses ON CONFLICT to ensure idempotency via event_id.
+// Image URLs given in the request are saved as attachments along with it.
+// The site must have a row in the sites table; configured sites get one
+// from RegisterSites.
 func (r *Repository) InsertReport(ctx context.Context, msg *model.QueueMessage) error {
-	// Encode image_urls as JSON for JSONB column
-	var imageURLsJSON []byte
-	if len(msg.ImageURLs) > 0 {
-		var err error
-		imageURLsJSON, err = json.Marshal(msg.ImageURLs)
-		if err != nil {
-			return fmt.Errorf("marshal image_urls: %w", err)
-		}
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin insert report: %w", err)
 	}
+	defer tx.Rollback(ctx)
 
 	query := `
-		INSERT INTO bug_reports (id, site_id, report_type, title, description, category, page_url, contact_type, contact_value, first_name, last_name, image_urls, status, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, 'new', $13::timestamptz)
+		INSERT INTO bug_reports (id, site_id, report_type, title, description, category, page_url, contact_type, contact_value, first_name, last_name, status, created_at, attachments_pending, custom_fields)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, 'new', $12::timestamptz, $13, $14)
 		ON CONFLICT (id) DO NOTHING
 	`
-	_, err := r.pool.Exec(ctx, query,
+	tag, err := tx.Exec(ctx, query,
 		msg.EventID,
 		msg.SiteID,
 		string(msg.ReportType),
@@ -48,38 +48,173 @@ func (r *Repository) InsertReport(ctx context.Context, msg *model.QueueMessage)
 		msg.ContactValue,
 		msg.FirstName,
 		msg.LastName,
-		imageURLsJSON,
 		msg.ReceivedAt,
+		len(msg.StagedFiles) > 0,
+		nullableJSON(msg.CustomFields),
 	)
 	if err != nil {
 		return fmt.Errorf("insert report: %w", err)
 	}
+	if tag.RowsAffected() == 0 {
+		return nil // already inserted
+	}
+
+	for i, url := range msg.ImageURLs {
+		if err := insertAttachment(ctx, tx, msg.EventID, i, &model.Attachment{Kind: model.AttachmentImage, URL: url}); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit insert report: %w", err)
+	}
 	return nil
 }
 
-// GetReport retrieves a single bug report by ID.
+// reportColumns is the column list shared by all bug_reports SELECT queries.
+// Keep in sync with scanReport.
+const reportColumns = `id, site_id, report_type, title, description, category, page_url, contact_type, contact_value, first_name, last_name, status, created_at, attachments_pending, attachments_lost, custom_fields`
+
+// ReportFilter narrows down a ListReports query. Zero values are ignored.
+type ReportFilter struct {
+	SiteID     string
+	ReportType string
+	Category   string
+	Status     string
+	From       time.Time // inclusive
+	To         time.Time // exclusive
+	Limit      int
+	Offset     int
+}
+
+// GetReport retrieves a single bug report by ID, with its attachments.
 func (r *Repository) GetReport(ctx context.Context, id string) (*model.BugReport, error) {
-	query := `
-		SELECT id, site_id, report_type, title, description, category, page_url, contact_type, contact_value, first_name, last_name, image_urls, status, created_at
-		FROM bug_reports WHERE id = $1
-	`
-	row := r.pool.QueryRow(ctx, query, id)
+	query := `SELECT ` + reportColumns + ` FROM bug_reports WHERE id = $1`
 
+	report, err := scanReport(r.pool.QueryRow(ctx, query, id))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("get report: %w", err)
+	}
+
+	reports := []model.BugReport{*report}
+	if err := r.loadAttachments(ctx, reports); err != nil {
+		return nil, err
+	}
+	return &reports[0], nil
+}
+
+// GetReportsByID retrieves the given reports, oldest first. Unknown IDs are skipped.
+func (r *Repository) GetReportsByID(ctx context.Context, ids []string) ([]model.BugReport, error) {
+	query := `SELECT ` + reportColumns + ` FROM bug_reports WHERE id = ANY($1::uuid[]) ORDER BY created_at, id`
+	rows, err := r.pool.Query(ctx, query, ids)
+	if err != nil {
+		return nil, fmt.Errorf("get reports: %w", err)
+	}
+	defer rows.Close()
+
+	reports := []model.BugReport{}
+	for rows.Next() {
+		report, err := scanReport(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan report: %w", err)
+		}
+		reports = append(reports, *report)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("get reports: %w", err)
+	}
+	rows.Close()
+
+	if err := r.loadAttachments(ctx, reports); err != nil {
+		return nil, err
+	}
+	return reports, nil
+}
+
+// ListReports returns a page of bug reports matching the filter, newest first,
+// along with the total number of matching rows.
+func (r *Repository) ListReports(ctx context.Context, f ReportFilter) ([]model.BugReport, int, error) {
+	var conds []string
+	var args []any
+
+	add := func(cond string, v any) {
+		args = append(args, v)
+		conds = append(conds, fmt.Sprintf(cond, len(args)))
+	}
+	if f.SiteID != "" {
+		add("site_id = $%d", f.SiteID)
+	}
+	if f.ReportType != "" {
+		add("report_type = $%d", f.ReportType)
+	}
+	if f.Category != "" {
+		add("category = $%d", f.Category)
+	}
+	if f.Status != "" {
+		add("status = $%d", f.Status)
+	}
+	if !f.From.IsZero() {
+		add("created_at >= $%d", f.From)
+	}
+	if !f.To.IsZero() {
+		add("created_at < $%d", f.To)
+	}
+
+	where := ""
+	if len(conds) > 0 {
+		where = " WHERE " + strings.Join(conds, " AND ")
+	}
+
+	var total int
+	if err := r.pool.QueryRow(ctx, `SELECT count(*) FROM bug_reports`+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("count reports: %w", err)
+	}
+
+	query := `SELECT ` + reportColumns + ` FROM bug_reports` + where +
+		fmt.Sprintf(` ORDER BY created_at DESC, id LIMIT $%d OFFSET $%d`, len(args)+1, len(args)+2)
+	rows, err := r.pool.Query(ctx, query, append(args, f.Limit, f.Offset)...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("list reports: %w", err)
+	}
+	defer rows.Close()
+
+	reports := []model.BugReport{}
+	for rows.Next() {
+		report, err := scanReport(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("scan report: %w", err)
+		}
+		reports = append(reports, *report)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("list reports: %w", err)
+	}
+	rows.Close()
+
+	if err := r.loadAttachments(ctx, reports); err != nil {
+		return nil, 0, err
+	}
+	return reports, total, nil
+}
+
+// scanReport reads a single bug_reports row selected with reportColumns.
+func scanReport(row pgx.Row) (*model.BugReport, error) {
 	var report model.BugReport
-	var imageURLsJSON []byte
+	var customFields []byte
 	err := row.Scan(
 		&report.ID, &report.SiteID, &report.ReportType, &report.Title, &report.Description,
 		&report.Category, &report.PageURL, &report.ContactType, &report.ContactValue,
-		&report.FirstName, &report.LastName, &imageURLsJSON, &report.Status, &report.CreatedAt,
+		&report.FirstName, &report.LastName, &report.Status, &report.CreatedAt,
+		&report.AttachmentsPending, &report.AttachmentsLost, &customFields,
 	)
-	if imageURLsJSON != nil {
-		json.Unmarshal(imageURLsJSON, &report.ImageURLs)
-	}
 	if err != nil {
-		if err == pgx.ErrNoRows {
-			return nil, nil
-		}
-		return nil, fmt.Errorf("get report: %w", err)
+		return nil, err
+	}
+	if customFields != nil {
+		report.CustomFields = customFields
 	}
 	return &report, nil
 }
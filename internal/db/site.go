@@ -0,0 +1,153 @@
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/devrimsoft/bug-notifications-api/internal/model"
+	"github.com/jackc/pgx/v5"
+)
+
+// siteColumns is the column list shared by all sites SELECT queries.
+// Keep in sync with scanSite.
+const siteColumns = `domain, name, active, settings, created_at`
+
+// siteLockID is the Postgres advisory lock key held by WithSiteLock.
+const siteLockID int64 = 0x6275675f73697465 // "bug_site"
+
+// SiteTx is a transaction holding the site lock, see WithSiteLock.
+type SiteTx struct {
+	tx pgx.Tx
+}
+
+// WithSiteLock runs fn in a transaction holding the site lock and commits
+// it if fn returns nil. Changes to the sites table and the taxonomy are
+// checked against the rows they read, so they are made one at a time: a
+// change validated by one instance can't be invalidated by a concurrent one
+// it never saw.
+func (r *Repository) WithSiteLock(ctx context.Context, fn func(tx *SiteTx) error) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock($1)`, siteLockID); err != nil {
+		return fmt.Errorf("acquire site lock: %w", err)
+	}
+	if err := fn(&SiteTx{tx: tx}); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit tx: %w", err)
+	}
+	return nil
+}
+
+// ListSites returns all rows of the sites table, by domain.
+func (r *Repository) ListSites(ctx context.Context) ([]model.Site, error) {
+	return listSites(ctx, r.pool)
+}
+
+// ListSites is Repository.ListSites within the transaction.
+func (t *SiteTx) ListSites(ctx context.Context) ([]model.Site, error) {
+	return listSites(ctx, t.tx)
+}
+
+func listSites(ctx context.Context, q querier) ([]model.Site, error) {
+	rows, err := q.Query(ctx, `SELECT `+siteColumns+` FROM sites ORDER BY domain`)
+	if err != nil {
+		return nil, fmt.Errorf("list sites: %w", err)
+	}
+	defer rows.Close()
+
+	sites := []model.Site{}
+	for rows.Next() {
+		s, err := scanSite(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan site: %w", err)
+		}
+		sites = append(sites, *s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("list sites: %w", err)
+	}
+	return sites, nil
+}
+
+// GetSite returns a site, or nil if there is none.
+func (r *Repository) GetSite(ctx context.Context, domain string) (*model.Site, error) {
+	s, err := scanSite(r.pool.QueryRow(ctx, `SELECT `+siteColumns+` FROM sites WHERE domain = $1`, domain))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("get site: %w", err)
+	}
+	return s, nil
+}
+
+// CreateSite inserts a site. Returns false if the domain is already taken.
+func (t *SiteTx) CreateSite(ctx context.Context, s *model.Site) (bool, error) {
+	err := t.tx.QueryRow(ctx, `
+		INSERT INTO sites (domain, name, active, settings)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (domain) DO NOTHING
+		RETURNING created_at
+	`, s.Domain, s.Name, s.Active, nullableJSON(s.Settings)).Scan(&s.CreatedAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return false, nil
+		}
+		return false, fmt.Errorf("insert site: %w", err)
+	}
+	return true, nil
+}
+
+// UpdateSite saves the name, active flag and settings of a site. Returns
+// false if it does not exist.
+func (t *SiteTx) UpdateSite(ctx context.Context, s *model.Site) (bool, error) {
+	tag, err := t.tx.Exec(ctx, `
+		UPDATE sites SET name = $2, active = $3, settings = $4 WHERE domain = $1
+	`, s.Domain, s.Name, s.Active, nullableJSON(s.Settings))
+	if err != nil {
+		return false, fmt.Errorf("update site: %w", err)
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// RegisterSites adds rows without settings for the given domains, so reports
+// of sites configured with SITES_FILE or ALLOWED_SITES satisfy the foreign
+// key. Existing rows are left alone.
+func (r *Repository) RegisterSites(ctx context.Context, domains []string) error {
+	_, err := r.pool.Exec(ctx, `
+		INSERT INTO sites (domain)
+		SELECT unnest($1::text[])
+		ON CONFLICT (domain) DO NOTHING
+	`, domains)
+	if err != nil {
+		return fmt.Errorf("register sites: %w", err)
+	}
+	return nil
+}
+
+// scanSite reads a single sites row selected with siteColumns.
+func scanSite(row pgx.Row) (*model.Site, error) {
+	var s model.Site
+	var settings []byte
+	if err := row.Scan(&s.Domain, &s.Name, &s.Active, &settings, &s.CreatedAt); err != nil {
+		return nil, err
+	}
+	if settings != nil {
+		s.Settings = settings
+	}
+	return &s, nil
+}
+
+// nullableJSON maps empty JSON to SQL NULL.
+func nullableJSON(data []byte) any {
+	if len(data) == 0 {
+		return nil
+	}
+	return string(data)
+}
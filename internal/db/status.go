@@ -0,0 +1,82 @@
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/devrimsoft/bug-notifications-api/internal/model"
+	"github.com/jackc/pgx/v5"
+)
+
+// ChangeReportStatus moves a report to status to and records the change in
+// report_status_history, in one transaction. check is called with the
+// current status while the row is locked; if it returns an error nothing is
+// changed and that error is returned as is. Setting the status a report
+// already has is a no-op and is not recorded.
+//
+// Returns the status the report had before; found is false if no report
+// with the given ID exists.
+func (r *Repository) ChangeReportStatus(ctx context.Context, id, to, changedBy string, note *string, check func(from string) error) (previous string, found bool, err error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return "", false, fmt.Errorf("begin status change: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	err = tx.QueryRow(ctx, `SELECT status FROM bug_reports WHERE id = $1 FOR UPDATE`, id).Scan(&previous)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return "", false, nil
+		}
+		return "", false, fmt.Errorf("lock report: %w", err)
+	}
+	if previous == to {
+		return previous, true, nil
+	}
+	if err := check(previous); err != nil {
+		return previous, true, err
+	}
+
+	if _, err := tx.Exec(ctx, `UPDATE bug_reports SET status = $2 WHERE id = $1`, id, to); err != nil {
+		return "", false, fmt.Errorf("update report status: %w", err)
+	}
+	_, err = tx.Exec(ctx, `
+		INSERT INTO report_status_history (report_id, from_status, to_status, changed_by, note)
+		VALUES ($1, $2, $3, $4, $5)
+	`, id, previous, to, changedBy, note)
+	if err != nil {
+		return "", false, fmt.Errorf("insert status history: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return "", false, fmt.Errorf("commit status change: %w", err)
+	}
+	return previous, true, nil
+}
+
+// ListStatusHistory returns a report's status changes, oldest first.
+func (r *Repository) ListStatusHistory(ctx context.Context, reportID string) ([]model.StatusChange, error) {
+	rows, err := r.pool.Query(ctx, `
+		SELECT id, report_id, from_status, to_status, changed_by, note, changed_at
+		FROM report_status_history
+		WHERE report_id = $1
+		ORDER BY changed_at, id
+	`, reportID)
+	if err != nil {
+		return nil, fmt.Errorf("list status history: %w", err)
+	}
+	defer rows.Close()
+
+	history := []model.StatusChange{}
+	for rows.Next() {
+		var c model.StatusChange
+		if err := rows.Scan(&c.ID, &c.ReportID, &c.FromStatus, &c.ToStatus, &c.ChangedBy, &c.Note, &c.ChangedAt); err != nil {
+			return nil, fmt.Errorf("scan status change: %w", err)
+		}
+		history = append(history, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("list status history: %w", err)
+	}
+	return history, nil
+}
@@ -0,0 +1,86 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/devrimsoft/bug-notifications-api/internal/model"
+)
+
+// Taxonomy tables, by kind.
+const (
+	TaxonomyReportTypes = "report_types"
+	TaxonomyCategories  = "report_categories"
+)
+
+// LoadTaxonomy returns all report types and categories, by position.
+func (r *Repository) LoadTaxonomy(ctx context.Context) (*model.Taxonomy, error) {
+	return loadTaxonomy(ctx, r.pool)
+}
+
+// LoadTaxonomy is Repository.LoadTaxonomy within the transaction.
+func (t *SiteTx) LoadTaxonomy(ctx context.Context) (*model.Taxonomy, error) {
+	return loadTaxonomy(ctx, t.tx)
+}
+
+func loadTaxonomy(ctx context.Context, q querier) (*model.Taxonomy, error) {
+	var tax model.Taxonomy
+	var err error
+	if tax.ReportTypes, err = listTaxonomy(ctx, q, TaxonomyReportTypes); err != nil {
+		return nil, err
+	}
+	if tax.Categories, err = listTaxonomy(ctx, q, TaxonomyCategories); err != nil {
+		return nil, err
+	}
+	return &tax, nil
+}
+
+// listTaxonomy returns the rows of one taxonomy table. table must be one of
+// the Taxonomy constants.
+func listTaxonomy(ctx context.Context, q querier, table string) ([]model.TaxonomyEntry, error) {
+	rows, err := q.Query(ctx, `SELECT key, labels, position, active FROM `+table+` ORDER BY position, key`)
+	if err != nil {
+		return nil, fmt.Errorf("list %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	entries := []model.TaxonomyEntry{}
+	for rows.Next() {
+		var e model.TaxonomyEntry
+		var labels []byte
+		if err := rows.Scan(&e.Key, &labels, &e.Position, &e.Active); err != nil {
+			return nil, fmt.Errorf("scan %s: %w", table, err)
+		}
+		if err := json.Unmarshal(labels, &e.Labels); err != nil {
+			return nil, fmt.Errorf("scan %s: labels: %w", table, err)
+		}
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("list %s: %w", table, err)
+	}
+	return entries, nil
+}
+
+// UpsertTaxonomyEntry adds or replaces a report type or category. table must
+// be one of the Taxonomy constants.
+func (t *SiteTx) UpsertTaxonomyEntry(ctx context.Context, table string, e *model.TaxonomyEntry) error {
+	labels, err := json.Marshal(e.Labels)
+	if err != nil {
+		return fmt.Errorf("marshal labels: %w", err)
+	}
+	if e.Labels == nil {
+		labels = []byte(`{}`)
+	}
+	_, err = t.tx.Exec(ctx, `
+		INSERT INTO `+table+` (key, labels, position, active)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (key) DO UPDATE
+		SET labels = EXCLUDED.labels, position = EXCLUDED.position, active = EXCLUDED.active
+	`, e.Key, string(labels), e.Position, e.Active)
+	if err != nil {
+		return fmt.Errorf("upsert %s: %w", table, err)
+	}
+	return nil
+}
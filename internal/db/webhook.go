@@ -0,0 +1,210 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/devrimsoft/bug-notifications-api/internal/model"
+	"github.com/jackc/pgx/v5"
+)
+
+// deliveryColumns is the column list shared by all webhook_deliveries SELECT queries.
+// Keep in sync with scanDelivery.
+const deliveryColumns = `id, endpoint_id, site_id, event_type, report_id, url, payload, status, attempts, next_attempt_at, last_status_code, last_error, created_at, delivered_at`
+
+// DeliveryFilter narrows down a ListWebhookDeliveries query. Zero values are ignored.
+type DeliveryFilter struct {
+	Status     string
+	SiteID     string
+	EndpointID string
+	ReportID   string
+	Limit      int
+	Offset     int
+}
+
+// CreateWebhookDelivery records a pending delivery. eventKey identifies the
+// event per endpoint, so re-publishing the same event is a no-op.
+func (r *Repository) CreateWebhookDelivery(ctx context.Context, d *model.WebhookDelivery, eventKey string) error {
+	query := `
+		INSERT INTO webhook_deliveries (id, endpoint_id, site_id, event_type, event_key, report_id, url, payload)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (endpoint_id, event_key) DO NOTHING
+	`
+	_, err := r.pool.Exec(ctx, query,
+		d.ID, d.EndpointID, d.SiteID, string(d.EventType), eventKey, d.ReportID, d.URL, []byte(d.Payload),
+	)
+	if err != nil {
+		return fmt.Errorf("insert webhook delivery: %w", err)
+	}
+	return nil
+}
+
+// ClaimDueWebhookDeliveries returns up to limit pending deliveries that are due
+// and pushes their next attempt out by lease, so concurrent dispatchers
+// (and a dispatcher that dies mid-send) don't double-send before the lease expires.
+func (r *Repository) ClaimDueWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]model.WebhookDelivery, error) {
+	query := `
+		UPDATE webhook_deliveries SET next_attempt_at = now() + make_interval(secs => $2)
+		WHERE id IN (
+			SELECT id FROM webhook_deliveries
+			WHERE status = 'pending' AND next_attempt_at <= now()
+			ORDER BY next_attempt_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + deliveryColumns
+	rows, err := r.pool.Query(ctx, query, limit, lease.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("claim webhook deliveries: %w", err)
+	}
+	return collectDeliveries(rows)
+}
+
+// MarkWebhookDelivered records a successful delivery attempt.
+func (r *Repository) MarkWebhookDelivered(ctx context.Context, id string, statusCode int) error {
+	query := `
+		UPDATE webhook_deliveries
+		SET status = 'delivered', attempts = attempts + 1, last_status_code = $2, last_error = NULL, delivered_at = now()
+		WHERE id = $1
+	`
+	if _, err := r.pool.Exec(ctx, query, id, statusCode); err != nil {
+		return fmt.Errorf("mark webhook delivered: %w", err)
+	}
+	return nil
+}
+
+// MarkWebhookAttemptFailed records a failed delivery attempt. With a zero
+// nextAttempt the delivery is marked failed for good; otherwise it stays
+// pending until then. statusCode is 0 when no HTTP response was received.
+func (r *Repository) MarkWebhookAttemptFailed(ctx context.Context, id string, statusCode int, errMsg string, nextAttempt time.Time) error {
+	var code *int
+	if statusCode != 0 {
+		code = &statusCode
+	}
+
+	var err error
+	if nextAttempt.IsZero() {
+		_, err = r.pool.Exec(ctx, `
+			UPDATE webhook_deliveries
+			SET status = 'failed', attempts = attempts + 1, last_status_code = $2, last_error = $3
+			WHERE id = $1
+		`, id, code, errMsg)
+	} else {
+		_, err = r.pool.Exec(ctx, `
+			UPDATE webhook_deliveries
+			SET attempts = attempts + 1, last_status_code = $2, last_error = $3, next_attempt_at = $4
+			WHERE id = $1
+		`, id, code, errMsg, nextAttempt)
+	}
+	if err != nil {
+		return fmt.Errorf("mark webhook attempt failed: %w", err)
+	}
+	return nil
+}
+
+// ReplayWebhookDelivery resets a delivery to pending with a fresh attempt budget.
+// Returns false if no delivery with the given ID exists.
+func (r *Repository) ReplayWebhookDelivery(ctx context.Context, id string) (bool, error) {
+	tag, err := r.pool.Exec(ctx, `
+		UPDATE webhook_deliveries
+		SET status = 'pending', attempts = 0, next_attempt_at = now(), delivered_at = NULL
+		WHERE id = $1
+	`, id)
+	if err != nil {
+		return false, fmt.Errorf("replay webhook delivery: %w", err)
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// GetWebhookDelivery retrieves a single delivery by ID.
+func (r *Repository) GetWebhookDelivery(ctx context.Context, id string) (*model.WebhookDelivery, error) {
+	d, err := scanDelivery(r.pool.QueryRow(ctx, `SELECT `+deliveryColumns+` FROM webhook_deliveries WHERE id = $1`, id))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("get webhook delivery: %w", err)
+	}
+	return d, nil
+}
+
+// ListWebhookDeliveries returns a page of deliveries matching the filter,
+// newest first, along with the total number of matching rows.
+func (r *Repository) ListWebhookDeliveries(ctx context.Context, f DeliveryFilter) ([]model.WebhookDelivery, int, error) {
+	var conds []string
+	var args []any
+
+	add := func(cond string, v any) {
+		args = append(args, v)
+		conds = append(conds, fmt.Sprintf(cond, len(args)))
+	}
+	if f.Status != "" {
+		add("status = $%d", f.Status)
+	}
+	if f.SiteID != "" {
+		add("site_id = $%d", f.SiteID)
+	}
+	if f.EndpointID != "" {
+		add("endpoint_id = $%d", f.EndpointID)
+	}
+	if f.ReportID != "" {
+		add("report_id = $%d", f.ReportID)
+	}
+
+	where := ""
+	if len(conds) > 0 {
+		where = " WHERE " + strings.Join(conds, " AND ")
+	}
+
+	var total int
+	if err := r.pool.QueryRow(ctx, `SELECT count(*) FROM webhook_deliveries`+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("count webhook deliveries: %w", err)
+	}
+
+	query := `SELECT ` + deliveryColumns + ` FROM webhook_deliveries` + where +
+		fmt.Sprintf(` ORDER BY created_at DESC, id LIMIT $%d OFFSET $%d`, len(args)+1, len(args)+2)
+	rows, err := r.pool.Query(ctx, query, append(args, f.Limit, f.Offset)...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("list webhook deliveries: %w", err)
+	}
+	deliveries, err := collectDeliveries(rows)
+	if err != nil {
+		return nil, 0, err
+	}
+	return deliveries, total, nil
+}
+
+func collectDeliveries(rows pgx.Rows) ([]model.WebhookDelivery, error) {
+	defer rows.Close()
+
+	deliveries := []model.WebhookDelivery{}
+	for rows.Next() {
+		d, err := scanDelivery(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan webhook delivery: %w", err)
+		}
+		deliveries = append(deliveries, *d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("read webhook deliveries: %w", err)
+	}
+	return deliveries, nil
+}
+
+// scanDelivery reads a single webhook_deliveries row selected with deliveryColumns.
+func scanDelivery(row pgx.Row) (*model.WebhookDelivery, error) {
+	var d model.WebhookDelivery
+	var payload []byte
+	err := row.Scan(
+		&d.ID, &d.EndpointID, &d.SiteID, &d.EventType, &d.ReportID, &d.URL, &payload,
+		&d.Status, &d.Attempts, &d.NextAttemptAt, &d.LastStatusCode, &d.LastError,
+		&d.CreatedAt, &d.DeliveredAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	d.Payload = payload
+	return &d, nil
+}
@@ -0,0 +1,66 @@
+// Package email mails new reports to site owners over SMTP.
+//
+// Reports are written to the email_notifications table by a Publisher when the
+// worker stores them, and sent by a Scheduler running in the worker: instant
+// recipients get one email per report, digest recipients one email per site
+// at the top of each hour or day (UTC) listing everything that arrived since.
+package email
+
+import (
+	"context"
+	"time"
+
+	"github.com/devrimsoft/bug-notifications-api/internal/config"
+	"github.com/devrimsoft/bug-notifications-api/internal/db"
+	"github.com/devrimsoft/bug-notifications-api/internal/model"
+	"github.com/google/uuid"
+)
+
+// Publisher records pending emails for new reports.
+type Publisher struct {
+	repo *db.Repository
+	cfg  *config.Config
+}
+
+func NewPublisher(repo *db.Repository, cfg *config.Config) *Publisher {
+	return &Publisher{repo: repo, cfg: cfg}
+}
+
+// Enabled reports whether any email recipients are configured.
+func (p *Publisher) Enabled() bool {
+	return len(p.cfg.EmailRecipients) > 0
+}
+
+// ReportCreated queues an email for every recipient of the report's site.
+// Publishing the same report twice is a no-op.
+func (p *Publisher) ReportCreated(ctx context.Context, report *model.BugReport) error {
+	now := time.Now().UTC()
+	for _, rc := range p.cfg.EmailRecipientsFor(report.SiteID) {
+		err := p.repo.CreateEmailNotification(ctx, &model.EmailNotification{
+			ID:        uuid.New().String(),
+			Recipient: rc.To,
+			SiteID:    report.SiteID,
+			ReportID:  report.ID,
+			Mode:      rc.Mode,
+		}, dueAt(rc.Mode, now))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dueAt returns when an email queued at now should be sent: right away for
+// instant mode, otherwise at the end of the current hour or UTC day, so all
+// reports of one period share a due time and go out in one digest.
+func dueAt(mode model.EmailMode, now time.Time) time.Time {
+	switch mode {
+	case model.EmailHourly:
+		return now.Truncate(time.Hour).Add(time.Hour)
+	case model.EmailDaily:
+		y, m, d := now.UTC().Date()
+		return time.Date(y, m, d+1, 0, 0, 0, 0, time.UTC)
+	default:
+		return now
+	}
+}
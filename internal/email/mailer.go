@@ -0,0 +1,154 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"time"
+
+	"github.com/devrimsoft/bug-notifications-api/internal/config"
+	"github.com/google/uuid"
+)
+
+const (
+	dialTimeout = 10 * time.Second
+
+	// sendTimeout bounds a whole SMTP session, from dial to QUIT.
+	sendTimeout = time.Minute
+)
+
+// Message is a rendered email with plain-text and HTML bodies.
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Mailer sends messages through the configured SMTP server.
+type Mailer struct {
+	cfg *config.Config
+}
+
+func NewMailer(cfg *config.Config) *Mailer {
+	return &Mailer{cfg: cfg}
+}
+
+// Send delivers msg in a single SMTP session. Depending on SMTP_TLS the
+// connection uses implicit TLS, is upgraded with STARTTLS (which the server
+// must offer) or stays in plain text. Authentication is skipped when no
+// username is configured.
+func (m *Mailer) Send(ctx context.Context, msg *Message) error {
+	from, err := mail.ParseAddress(m.cfg.SMTPFrom)
+	if err != nil {
+		return fmt.Errorf("parse from address: %w", err)
+	}
+	body, err := buildMessage(m.cfg.SMTPFrom, msg)
+	if err != nil {
+		return err
+	}
+
+	host := m.cfg.SMTPHost
+	addr := net.JoinHostPort(host, strconv.Itoa(m.cfg.SMTPPort))
+	tlsConfig := &tls.Config{ServerName: host}
+
+	dialer := &net.Dialer{Timeout: dialTimeout}
+	var conn net.Conn
+	if m.cfg.SMTPTLS == "tls" {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: tlsConfig}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return fmt.Errorf("connect to %s: %w", addr, err)
+	}
+	conn.SetDeadline(time.Now().Add(sendTimeout))
+
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("smtp handshake: %w", err)
+	}
+	defer c.Close()
+
+	if m.cfg.SMTPTLS == "starttls" {
+		if ok, _ := c.Extension("STARTTLS"); !ok {
+			return fmt.Errorf("server %s does not support STARTTLS", addr)
+		}
+		if err := c.StartTLS(tlsConfig); err != nil {
+			return fmt.Errorf("starttls: %w", err)
+		}
+	}
+	if m.cfg.SMTPUsername != "" {
+		if err := c.Auth(smtp.PlainAuth("", m.cfg.SMTPUsername, m.cfg.SMTPPassword, host)); err != nil {
+			return fmt.Errorf("smtp auth: %w", err)
+		}
+	}
+
+	if err := c.Mail(from.Address); err != nil {
+		return fmt.Errorf("mail from: %w", err)
+	}
+	if err := c.Rcpt(msg.To); err != nil {
+		return fmt.Errorf("rcpt to: %w", err)
+	}
+	w, err := c.Data()
+	if err != nil {
+		return fmt.Errorf("data: %w", err)
+	}
+	if _, err := w.Write(body); err != nil {
+		return fmt.Errorf("write message: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("send message: %w", err)
+	}
+	return c.Quit()
+}
+
+// buildMessage renders msg as a multipart/alternative MIME message.
+func buildMessage(from string, msg *Message) ([]byte, error) {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+
+	header := func(k, v string) { fmt.Fprintf(&buf, "%s: %s\r\n", k, v) }
+	header("From", from)
+	header("To", msg.To)
+	header("Subject", mime.QEncoding.Encode("utf-8", msg.Subject))
+	header("Date", time.Now().Format(time.RFC1123Z))
+	header("Message-ID", fmt.Sprintf("<%s@bug-notifications>", uuid.New().String()))
+	header("MIME-Version", "1.0")
+	header("Content-Type", "multipart/alternative; boundary="+mw.Boundary())
+	buf.WriteString("\r\n")
+
+	for _, part := range []struct{ contentType, body string }{
+		{"text/plain; charset=utf-8", msg.Text},
+		{"text/html; charset=utf-8", msg.HTML},
+	} {
+		pw, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("create mime part: %w", err)
+		}
+		qw := quotedprintable.NewWriter(pw)
+		if _, err := qw.Write([]byte(part.body)); err != nil {
+			return nil, fmt.Errorf("encode mime part: %w", err)
+		}
+		if err := qw.Close(); err != nil {
+			return nil, fmt.Errorf("encode mime part: %w", err)
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return nil, fmt.Errorf("close mime message: %w", err)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,183 @@
+package email
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/devrimsoft/bug-notifications-api/internal/config"
+)
+
+// smtpSink is a minimal SMTP server that accepts any message and records it.
+type smtpSink struct {
+	ln       net.Listener
+	from     string
+	rcpt     []string
+	data     string
+	received chan struct{}
+}
+
+func newSMTPSink(t *testing.T) *smtpSink {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &smtpSink{ln: ln, received: make(chan struct{})}
+	t.Cleanup(func() { ln.Close() })
+	go s.serve()
+	return s
+}
+
+func (s *smtpSink) port() int {
+	return s.ln.Addr().(*net.TCPAddr).Port
+}
+
+func (s *smtpSink) serve() {
+	conn, err := s.ln.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	reply := func(line string) { io.WriteString(conn, line+"\r\n") }
+	reply("220 sink ready")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.TrimRight(line, "\r\n")
+		switch verb := strings.ToUpper(strings.SplitN(cmd, " ", 2)[0]); verb {
+		case "EHLO", "HELO":
+			reply("250 sink")
+		case "MAIL":
+			s.from = cmd
+			reply("250 ok")
+		case "RCPT":
+			s.rcpt = append(s.rcpt, cmd)
+			reply("250 ok")
+		case "DATA":
+			reply("354 go ahead")
+			var b strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				b.WriteString(strings.TrimPrefix(l, "."))
+			}
+			s.data = b.String()
+			reply("250 queued")
+			close(s.received)
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 not implemented")
+		}
+	}
+}
+
+func TestMailerSendToSink(t *testing.T) {
+	sink := newSMTPSink(t)
+	m := NewMailer(&config.Config{
+		SMTPHost: "127.0.0.1",
+		SMTPPort: sink.port(),
+		SMTPFrom: "Bug Reports <bugs@example.com>",
+		SMTPTLS:  "none",
+	})
+
+	err := m.Send(context.Background(), &Message{
+		To:      "owner@example.com",
+		Subject: "[example.com] Bug report: Büyük hata",
+		Text:    "plain body",
+		HTML:    "<p>html body</p>",
+	})
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	<-sink.received
+
+	if sink.from != "MAIL FROM:<bugs@example.com>" {
+		t.Errorf("MAIL = %q", sink.from)
+	}
+	if len(sink.rcpt) != 1 || sink.rcpt[0] != "RCPT TO:<owner@example.com>" {
+		t.Errorf("RCPT = %q", sink.rcpt)
+	}
+
+	msg, err := mail.ReadMessage(strings.NewReader(sink.data))
+	if err != nil {
+		t.Fatalf("parse message: %v", err)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil || subject != "[example.com] Bug report: Büyük hata" {
+		t.Errorf("Subject = %q, %v", subject, err)
+	}
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("Content-Type = %q, %v", msg.Header.Get("Content-Type"), err)
+	}
+
+	var parts []string
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		p, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("read part: %v", err)
+		}
+		body, _ := io.ReadAll(p) // quoted-printable is decoded by NextPart
+		parts = append(parts, p.Header.Get("Content-Type")+": "+string(body))
+	}
+	want := []string{
+		"text/plain; charset=utf-8: plain body",
+		"text/html; charset=utf-8: <p>html body</p>",
+	}
+	if strings.Join(parts, "\n") != strings.Join(want, "\n") {
+		t.Errorf("parts = %q, want %q", parts, want)
+	}
+}
+
+func TestMailerSendRequiresStartTLS(t *testing.T) {
+	sink := newSMTPSink(t)
+	m := NewMailer(&config.Config{
+		SMTPHost: "127.0.0.1",
+		SMTPPort: sink.port(),
+		SMTPFrom: "bugs@example.com",
+		SMTPTLS:  "starttls",
+	})
+
+	err := m.Send(context.Background(), &Message{To: "owner@example.com", Subject: "s"})
+	if err == nil || !strings.Contains(err.Error(), "does not support STARTTLS") {
+		t.Fatalf("Send = %v, want a STARTTLS error", err)
+	}
+}
+
+func TestMailerSendBadPort(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+	ln.Close()
+
+	m := NewMailer(&config.Config{SMTPHost: "127.0.0.1", SMTPPort: port, SMTPFrom: "bugs@example.com", SMTPTLS: "none"})
+	if err := m.Send(context.Background(), &Message{To: "owner@example.com"}); err == nil ||
+		!strings.Contains(err.Error(), "connect to 127.0.0.1:"+strconv.Itoa(port)) {
+		t.Fatalf("Send = %v, want a connect error", err)
+	}
+}
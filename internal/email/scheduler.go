@@ -0,0 +1,159 @@
+package email
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/devrimsoft/bug-notifications-api/internal/config"
+	"github.com/devrimsoft/bug-notifications-api/internal/db"
+	"github.com/devrimsoft/bug-notifications-api/internal/model"
+	"github.com/devrimsoft/bug-notifications-api/internal/queue"
+)
+
+const (
+	// pollInterval is how often the outbox is checked for due emails.
+	pollInterval = 5 * time.Second
+
+	// recipientBatch caps how many recipients are handled per poll. All due
+	// emails of a recipient are claimed at once, so a digest is never split.
+	recipientBatch = 50
+
+	// claimLease must exceed the time to send one recipient's emails.
+	claimLease = 5 * time.Minute
+
+	maxAttempts = 5
+)
+
+// Scheduler sends due emails and records the outcome. Several schedulers
+// (one per worker process) may run concurrently.
+type Scheduler struct {
+	repo   *db.Repository
+	mailer *Mailer
+	retry  queue.RetryPolicy
+}
+
+func NewScheduler(repo *db.Repository, cfg *config.Config) *Scheduler {
+	return &Scheduler{
+		repo:   repo,
+		mailer: NewMailer(cfg),
+		retry: queue.RetryPolicy{
+			MaxRetry:  maxAttempts,
+			BaseDelay: time.Minute,
+			MaxDelay:  time.Hour,
+			Jitter:    0.2,
+		},
+	}
+}
+
+// Run polls for due emails until the context is cancelled.
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		recipients, err := s.repo.DueEmailRecipients(ctx, recipientBatch)
+		if err != nil {
+			if ctx.Err() == nil {
+				slog.Error("list email recipients failed", "error", err)
+			}
+			continue
+		}
+		for _, recipient := range recipients {
+			pending, err := s.repo.ClaimDueEmailNotifications(ctx, recipient, claimLease)
+			if err != nil {
+				if ctx.Err() == nil {
+					slog.Error("claim email notifications failed", "recipient", recipient, "error", err)
+				}
+				continue
+			}
+			for _, batch := range group(pending) {
+				s.send(ctx, batch)
+			}
+		}
+	}
+}
+
+// group splits claimed emails into one batch per message: a batch per
+// instant email, and one per recipient, site and mode for digests.
+func group(pending []model.EmailNotification) [][]model.EmailNotification {
+	type key struct {
+		recipient, siteID string
+		mode              model.EmailMode
+	}
+	var batches [][]model.EmailNotification
+	index := make(map[key]int)
+	for _, n := range pending {
+		if n.Mode == model.EmailInstant {
+			batches = append(batches, []model.EmailNotification{n})
+			continue
+		}
+		k := key{n.Recipient, n.SiteID, n.Mode}
+		if i, ok := index[k]; ok {
+			batches[i] = append(batches[i], n)
+			continue
+		}
+		index[k] = len(batches)
+		batches = append(batches, []model.EmailNotification{n})
+	}
+	return batches
+}
+
+// send renders and mails one batch, then records success, a scheduled retry,
+// or final failure for every email in it.
+func (s *Scheduler) send(ctx context.Context, batch []model.EmailNotification) {
+	first := batch[0]
+	ids := make([]string, len(batch))
+	reportIDs := make([]string, len(batch))
+	for i, n := range batch {
+		ids[i] = n.ID
+		reportIDs[i] = n.ReportID
+	}
+
+	err := s.deliver(ctx, first, reportIDs)
+	if err == nil {
+		if err := s.repo.MarkEmailsSent(ctx, ids); err != nil {
+			slog.Error("record email sent failed", "recipient", first.Recipient, "error", err)
+		}
+		slog.Info("email sent", "recipient", first.Recipient, "site_id", first.SiteID, "mode", first.Mode, "reports", len(batch))
+		return
+	}
+
+	attempt := first.Attempts + 1
+	var next time.Time
+	if attempt < s.retry.MaxRetry {
+		next = time.Now().Add(s.retry.Delay(attempt))
+	}
+	slog.Warn("email send failed",
+		"recipient", first.Recipient,
+		"site_id", first.SiteID,
+		"mode", first.Mode,
+		"attempt", attempt,
+		"error", err,
+		"final", next.IsZero(),
+	)
+	if err := s.repo.MarkEmailAttemptFailed(ctx, ids, err.Error(), next); err != nil {
+		slog.Error("record email failure failed", "recipient", first.Recipient, "error", err)
+	}
+}
+
+func (s *Scheduler) deliver(ctx context.Context, first model.EmailNotification, reportIDs []string) error {
+	reports, err := s.repo.GetReportsByID(ctx, reportIDs)
+	if err != nil {
+		return err
+	}
+	if len(reports) == 0 {
+		return nil // reports were deleted; nothing left to tell
+	}
+	msg, err := render(first.Recipient, first.SiteID, first.Mode, reports)
+	if err != nil {
+		return err
+	}
+	return s.mailer.Send(ctx, msg)
+}
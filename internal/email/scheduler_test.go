@@ -0,0 +1,60 @@
+package email
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/devrimsoft/bug-notifications-api/internal/model"
+)
+
+func TestGroup(t *testing.T) {
+	n := func(id, recipient, site string, mode model.EmailMode) model.EmailNotification {
+		return model.EmailNotification{ID: id, Recipient: recipient, SiteID: site, Mode: mode}
+	}
+	pending := []model.EmailNotification{
+		n("1", "a@x", "one.com", model.EmailHourly),
+		n("2", "a@x", "one.com", model.EmailInstant),
+		n("3", "a@x", "one.com", model.EmailHourly),
+		n("4", "a@x", "two.com", model.EmailHourly),
+		n("5", "a@x", "one.com", model.EmailDaily),
+		n("6", "a@x", "one.com", model.EmailInstant),
+		n("7", "b@x", "one.com", model.EmailHourly),
+	}
+
+	var got [][]string
+	for _, batch := range group(pending) {
+		var ids []string
+		for _, e := range batch {
+			ids = append(ids, e.ID)
+		}
+		got = append(got, ids)
+	}
+	want := [][]string{{"1", "3"}, {"2"}, {"4"}, {"5"}, {"6"}, {"7"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("group = %v, want %v", got, want)
+	}
+}
+
+func TestDueAt(t *testing.T) {
+	now := time.Date(2026, 3, 31, 23, 40, 12, 0, time.UTC)
+	tests := []struct {
+		mode model.EmailMode
+		want time.Time
+	}{
+		{model.EmailInstant, now},
+		{model.EmailHourly, time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)},
+		{model.EmailDaily, time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		if got := dueAt(tt.mode, now); !got.Equal(tt.want) {
+			t.Errorf("dueAt(%s) = %v, want %v", tt.mode, got, tt.want)
+		}
+	}
+
+	// Reports of the same period share a due time
+	later := now.Add(-30 * time.Minute)
+	if a, b := dueAt(model.EmailHourly, later), dueAt(model.EmailHourly, later.Add(5*time.Minute)); !a.Equal(b) {
+		t.Errorf("hourly due times differ: %v, %v", a, b)
+	}
+}
@@ -0,0 +1,94 @@
+package email
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"strings"
+	texttemplate "text/template"
+
+	"github.com/devrimsoft/bug-notifications-api/internal/model"
+)
+
+//go:embed templates/*.tmpl
+var templateFS embed.FS
+
+var templateFuncs = map[string]any{
+	"deref": func(s *string) string {
+		if s == nil {
+			return ""
+		}
+		return *s
+	},
+	"fullName": func(r model.BugReport) string {
+		var parts []string
+		for _, p := range []*string{r.FirstName, r.LastName} {
+			if p != nil && *p != "" {
+				parts = append(parts, *p)
+			}
+		}
+		return strings.Join(parts, " ")
+	},
+	"typeLabel": typeLabel,
+	"inc":       func(i int) int { return i + 1 },
+}
+
+var (
+	textTemplate = texttemplate.Must(texttemplate.New("report.txt.tmpl").Funcs(templateFuncs).ParseFS(templateFS, "templates/report.txt.tmpl"))
+	htmlTemplate = htmltemplate.Must(htmltemplate.New("report.html.tmpl").Funcs(templateFuncs).ParseFS(templateFS, "templates/report.html.tmpl"))
+)
+
+// templateData is what the report templates render. A single-report email
+// is rendered with Digest false and one entry in Reports.
+type templateData struct {
+	SiteID  string
+	Mode    model.EmailMode
+	Digest  bool
+	Reports []model.BugReport
+}
+
+// render builds the email for reports of one site sent to one recipient.
+func render(to, siteID string, mode model.EmailMode, reports []model.BugReport) (*Message, error) {
+	data := templateData{
+		SiteID:  siteID,
+		Mode:    mode,
+		Digest:  mode != model.EmailInstant,
+		Reports: reports,
+	}
+
+	var text, html bytes.Buffer
+	if err := textTemplate.Execute(&text, data); err != nil {
+		return nil, fmt.Errorf("render text template: %w", err)
+	}
+	if err := htmlTemplate.Execute(&html, data); err != nil {
+		return nil, fmt.Errorf("render html template: %w", err)
+	}
+
+	var subject string
+	if data.Digest {
+		subject = fmt.Sprintf("[%s] %d new report(s)", siteID, len(reports))
+	} else {
+		r := reports[0]
+		subject = fmt.Sprintf("[%s] %s: %s", siteID, typeLabel(r.ReportType), r.Title)
+	}
+
+	return &Message{
+		To:      to,
+		Subject: strings.Join(strings.Fields(subject), " "), // no header folding from user input
+		Text:    text.String(),
+		HTML:    html.String(),
+	}, nil
+}
+
+// typeLabel returns a short human label for a report type. Types
+// added to the taxonomy are shown by key.
+func typeLabel(t string) string {
+	switch t {
+	case string(model.ReportTypeBug):
+		return "Bug report"
+	case string(model.ReportTypeRequest):
+		return "Feature request"
+	}
+	return t
+}
@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	"github.com/devrimsoft/bug-notifications-api/internal/model"
+)
+
+type apiKeyContextKey struct{}
+
+// ServerAuth protects server-to-server routes with a per-site API key sent in
+// the X-API-Key header. authenticate returns the matching active key, or nil
+// if the key is unknown, revoked or expired. The key is stored in the request
+// context for the handler (see APIKeyFromContext).
+func ServerAuth(authenticate func(ctx context.Context, key string) (*model.SiteAPIKey, error)) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			raw := r.Header.Get("X-API-Key")
+			if raw == "" {
+				writeJSON(w, http.StatusUnauthorized, map[string]string{
+					"error": "api key required",
+					"code":  "MISSING_API_KEY",
+				})
+				return
+			}
+
+			key, err := authenticate(r.Context(), raw)
+			if err != nil {
+				slog.Error("api key lookup failed", "error", err)
+				writeJSON(w, http.StatusServiceUnavailable, map[string]string{
+					"error": "service temporarily unavailable",
+					"code":  "AUTH_UNAVAILABLE",
+				})
+				return
+			}
+			if key == nil {
+				writeJSON(w, http.StatusUnauthorized, map[string]string{
+					"error": "invalid api key",
+					"code":  "INVALID_API_KEY",
+				})
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), apiKeyContextKey{}, key)))
+		})
+	}
+}
+
+// APIKeyFromContext returns the site API key that authenticated the request,
+// or nil outside ServerAuth-protected routes.
+func APIKeyFromContext(ctx context.Context) *model.SiteAPIKey {
+	key, _ := ctx.Value(apiKeyContextKey{}).(*model.SiteAPIKey)
+	return key
+}
@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAdminAuth(t *testing.T) {
+	keys := map[string]string{"admin": "root-token", "alice": "alice-token"}
+	var gotAdmin string
+	h := AdminAuth(keys)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAdmin = AdminFromContext(r.Context())
+	}))
+
+	tests := []struct {
+		header    string
+		wantCode  int
+		wantAdmin string
+	}{
+		{"", http.StatusUnauthorized, ""},
+		{"Basic alice-token", http.StatusUnauthorized, ""},
+		{"Bearer ", http.StatusUnauthorized, ""},
+		{"Bearer wrong", http.StatusForbidden, ""},
+		{"Bearer alice", http.StatusForbidden, ""},
+		{"Bearer root-token", http.StatusOK, "admin"},
+		{"Bearer alice-token", http.StatusOK, "alice"},
+	}
+	for _, tt := range tests {
+		gotAdmin = ""
+		r := httptest.NewRequest(http.MethodGet, "/admin/v1/reports", nil)
+		if tt.header != "" {
+			r.Header.Set("Authorization", tt.header)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != tt.wantCode || gotAdmin != tt.wantAdmin {
+			t.Errorf("Authorization %q: code %d admin %q, want %d %q", tt.header, w.Code, gotAdmin, tt.wantCode, tt.wantAdmin)
+		}
+	}
+}
+
+func TestAdminFromContextOutsideAdminAuth(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	if got := AdminFromContext(r.Context()); got != "" {
+		t.Errorf("AdminFromContext = %q, want empty", got)
+	}
+}
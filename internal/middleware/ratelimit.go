@@ -12,7 +12,7 @@ import (
 
 // tokenBucketScript implements an atomic token bucket rate limiter in Redis.
 //
-// KEYS[1]: rate limit key ("rl:{ip}")
+// KEYS[1]: rate limit key ("rl:{ip}", "rl:key:{api key id}" or "rl:site:{site}:{ip}")
 // ARGV[1]: current time in milliseconds
 // ARGV[2]: refill rate (tokens per second)
 // ARGV[3]: burst size (max tokens)
@@ -53,44 +53,85 @@ return 1
 // RateLimit implements a distributed token bucket rate limiter backed by Redis.
 // All API instances share the same counters, preventing bypass via load balancing.
 func RateLimit(rdb *redis.Client, rps int, trustedProxies []*net.IPNet) func(http.Handler) http.Handler {
-	rate := float64(rps)
-	burst := float64(rps * 2)
-	ttl := 300 // key TTL in seconds (expire after 5 min of inactivity)
-
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			ip := realIP(r, trustedProxies)
-			key := "rl:" + ip
-			nowMs := time.Now().UnixMilli()
-
-			ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
-			defer cancel()
-
-			result, err := tokenBucketScript.Run(ctx, rdb, []string{key},
-				nowMs, rate, burst, ttl,
-			).Int()
-
-			if err != nil {
-				// Fail-open: allow request on Redis error but log it
-				slog.Error("rate limiter redis error", "error", err, "ip", ip)
-				next.ServeHTTP(w, r)
+			if !allow(w, r, rdb, "rl:"+ip, rps) {
 				return
 			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
 
-			if result == 0 {
-				w.Header().Set("Retry-After", "1")
-				writeJSON(w, http.StatusTooManyRequests, map[string]string{
-					"error": "rate limit exceeded",
-					"code":  "RATE_LIMITED",
+// KeyRateLimit is RateLimit keyed by the authenticated site API key instead
+// of the client IP, so each server integration gets its own budget.
+// Must run after ServerAuth.
+func KeyRateLimit(rdb *redis.Client, rps int) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := APIKeyFromContext(r.Context())
+			if key == nil {
+				writeJSON(w, http.StatusUnauthorized, map[string]string{
+					"error": "api key required",
+					"code":  "MISSING_API_KEY",
 				})
 				return
 			}
-
+			if !allow(w, r, rdb, "rl:key:"+key.ID, rps) {
+				return
+			}
 			next.ServeHTTP(w, r)
 		})
 	}
 }
 
+// SiteRateLimiter returns a check for the per-IP rate limit of sites that
+// set their own (RateLimitFor), on top of RateLimit. The site is only known
+// once the body is parsed, so the handler calls it; when the limit is hit it
+// writes a 429 response and returns false.
+func SiteRateLimiter(rdb *redis.Client, rpsFor func(siteID string) int, trustedProxies []*net.IPNet) func(w http.ResponseWriter, r *http.Request, siteID string) bool {
+	return func(w http.ResponseWriter, r *http.Request, siteID string) bool {
+		rps := rpsFor(siteID)
+		if rps <= 0 {
+			return true
+		}
+		return allow(w, r, rdb, "rl:site:"+siteID+":"+realIP(r, trustedProxies), rps)
+	}
+}
+
+// allow takes a token from the bucket at key. When the bucket is empty it
+// writes a 429 response and returns false.
+func allow(w http.ResponseWriter, r *http.Request, rdb *redis.Client, key string, rps int) bool {
+	rate := float64(rps)
+	burst := float64(rps * 2)
+	ttl := 300 // key TTL in seconds (expire after 5 min of inactivity)
+	nowMs := time.Now().UnixMilli()
+
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+	defer cancel()
+
+	result, err := tokenBucketScript.Run(ctx, rdb, []string{key},
+		nowMs, rate, burst, ttl,
+	).Int()
+
+	if err != nil {
+		// Fail-open: allow request on Redis error but log it
+		slog.Error("rate limiter redis error", "error", err, "key", key)
+		return true
+	}
+
+	if result == 0 {
+		w.Header().Set("Retry-After", "1")
+		writeJSON(w, http.StatusTooManyRequests, map[string]string{
+			"error": "rate limit exceeded",
+			"code":  "RATE_LIMITED",
+		})
+		return false
+	}
+	return true
+}
+
 // realIP extracts the client IP address from the request.
 // X-Forwarded-For and X-Real-IP headers are only trusted when the immediate
 // connection (RemoteAddr) comes from a configured trusted proxy. This prevents
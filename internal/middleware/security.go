@@ -1,11 +1,14 @@
 package middleware
 
 import (
+	"io"
 	"log/slog"
 	"net"
 	"net/http"
 	"net/url"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/devrimsoft/bug-notifications-api/internal/config"
 )
@@ -57,7 +60,7 @@ func RequireHTTPS() func(http.Handler) http.Handler {
 
 // BrowserOnly is a casual-abuse filter, NOT a security boundary.
 // A determined attacker can spoof all checked headers. Real security comes from
-// CORS enforcement and Turnstile bot verification.
+// CORS enforcement and captcha bot verification.
 // This middleware only raises the bar for lazy/accidental misuse.
 func BrowserOnly() func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
@@ -119,21 +122,25 @@ func BrowserOnly() func(http.Handler) http.Handler {
 }
 
 // CORSMiddleware handles CORS preflight and response headers.
-// Allows origins from the portal domain and its subdomains.
-func CORSMiddleware(cfg *config.Config) func(http.Handler) http.Handler {
-	// Build allowed domains list: portal domain + all site domains
-	var domains []string
-	if cfg.PortalDomain != "" {
-		domains = append(domains, cfg.PortalDomain)
-	}
-	domains = append(domains, cfg.Sites...)
-
+// Allows origins from the portal domain, site domains and aliases and their
+// subdomains, plus the extra origins listed in SITES_FILE. The lists follow
+// config reloads.
+func CORSMiddleware(live *config.Live) func(http.Handler) http.Handler {
 	isAllowed := func(origin string) bool {
+		cfg := live.Get()
+		if slices.Contains(cfg.AllowedOrigins(), strings.ToLower(origin)) {
+			return true
+		}
 		u, err := url.Parse(origin)
 		if err != nil || u.Scheme != "https" {
 			return false
 		}
+		// Allowed domains: portal domain + all site domains and aliases
 		host := strings.ToLower(u.Hostname())
+		domains := cfg.AllowedDomains()
+		if cfg.PortalDomain != "" {
+			domains = append(domains, cfg.PortalDomain)
+		}
 		for _, d := range domains {
 			if host == d || strings.HasSuffix(host, "."+d) {
 				return true
@@ -178,6 +185,76 @@ func BodyLimit(maxBytes int64) func(http.Handler) http.Handler {
 	}
 }
 
+// UploadDeadline gives a route d to read its request body and write its
+// response, instead of the server's read and write timeouts, so large
+// uploads on slow links are not cut off.
+func UploadDeadline(d time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rc := http.NewResponseController(w)
+			deadline := time.Now().Add(d)
+			if err := rc.SetReadDeadline(deadline); err != nil {
+				slog.Warn("set upload read deadline failed", "error", err)
+			}
+			if err := rc.SetWriteDeadline(deadline); err != nil {
+				slog.Warn("set upload write deadline failed", "error", err)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// UploadProgress lets a route read its request body past the server's read
+// timeout for as long as the body keeps arriving: the read and write
+// deadlines are pushed window ahead on every read, but only while the body
+// has averaged at least minRate bytes per second. A client that dribbles its
+// body is cut off window after it falls behind.
+func UploadProgress(window time.Duration, minRate int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p := &progressBody{
+				ReadCloser: r.Body,
+				rc:         http.NewResponseController(w),
+				start:      time.Now(),
+				window:     window,
+				minRate:    minRate,
+			}
+			p.extend()
+			r.Body = p
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// progressBody extends the connection deadlines as a request body is read.
+type progressBody struct {
+	io.ReadCloser
+	rc      *http.ResponseController
+	start   time.Time
+	read    int64
+	window  time.Duration
+	minRate int64
+}
+
+func (p *progressBody) Read(b []byte) (int, error) {
+	n, err := p.ReadCloser.Read(b)
+	p.read += int64(n)
+	if n > 0 && float64(p.read) >= float64(p.minRate)*time.Since(p.start).Seconds() {
+		p.extend()
+	}
+	return n, err
+}
+
+func (p *progressBody) extend() {
+	deadline := time.Now().Add(p.window)
+	if err := p.rc.SetReadDeadline(deadline); err != nil {
+		slog.Warn("set upload read deadline failed", "error", err)
+	}
+	if err := p.rc.SetWriteDeadline(deadline); err != nil {
+		slog.Warn("set upload write deadline failed", "error", err)
+	}
+}
+
 // --- helpers ---
 
 func isLoopback(remoteAddr string) bool {
@@ -0,0 +1,77 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// echo writes back the request body, or 408 if it could not be read.
+var echo = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusRequestTimeout)
+		return
+	}
+	w.Write(body)
+})
+
+// slowUpload posts chunks to h on a server with 200ms read and write
+// timeouts, pausing before each chunk, and reports whether h echoed the
+// whole body.
+func slowUpload(t *testing.T, h http.Handler, pause time.Duration, chunks ...string) bool {
+	t.Helper()
+	srv := httptest.NewUnstartedServer(h)
+	srv.Config.ReadTimeout = 200 * time.Millisecond
+	srv.Config.WriteTimeout = 200 * time.Millisecond
+	srv.Start()
+	defer srv.Close()
+
+	pr, pw := io.Pipe()
+	go func() {
+		for i, c := range chunks {
+			if i > 0 {
+				time.Sleep(pause)
+			}
+			pw.Write([]byte(c))
+		}
+		pw.Close()
+	}()
+	resp, err := http.Post(srv.URL, "text/plain", pr)
+	if err != nil {
+		return false
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode == http.StatusOK && string(body) == strings.Join(chunks, "")
+}
+
+func TestUploadDeadline(t *testing.T) {
+	// A body that arrives after the server's read timeout
+	if slowUpload(t, echo, 500*time.Millisecond, "first ", "second") {
+		t.Error("slow upload succeeded without a deadline")
+	}
+	if !slowUpload(t, UploadDeadline(5*time.Second)(echo), 500*time.Millisecond, "first ", "second") {
+		t.Error("slow upload failed within the upload deadline")
+	}
+}
+
+func TestUploadProgress(t *testing.T) {
+	chunks := strings.Split(strings.Repeat("x", 8), "")
+
+	// Steady progress keeps the upload alive past the server's read timeout
+	if !slowUpload(t, UploadProgress(300*time.Millisecond, 1)(echo), 100*time.Millisecond, chunks...) {
+		t.Error("steady upload failed")
+	}
+	// A stall longer than the window cuts it off
+	if slowUpload(t, UploadProgress(300*time.Millisecond, 1)(echo), 600*time.Millisecond, "first ", "second") {
+		t.Error("stalled upload succeeded")
+	}
+	// So does arriving below the minimum rate
+	if slowUpload(t, UploadProgress(300*time.Millisecond, 1024)(echo), 100*time.Millisecond, chunks...) {
+		t.Error("dribbled upload succeeded")
+	}
+}
@@ -0,0 +1,159 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Request signing headers for server-to-server submissions.
+const (
+	HeaderSignatureTimestamp = "X-Signature-Timestamp"
+	HeaderSignatureNonce     = "X-Signature-Nonce"
+	HeaderSignature          = "X-Signature"
+
+	// nonceKeyPrefix + site ID + ":" + nonce marks a nonce as used.
+	nonceKeyPrefix = "sig:nonce:"
+)
+
+var validNonce = regexp.MustCompile(`^[A-Za-z0-9_-]{16,128}$`)
+
+// SignRequest returns the X-Signature value for a request:
+// "sha256=" + hex(HMAC-SHA256(secret, canonical)), where canonical is
+//
+//	METHOD "\n" PATH "\n" TIMESTAMP "\n" NONCE "\n" hex(SHA256(body))
+//
+// PATH is the escaped URL path without the query string and TIMESTAMP is
+// Unix seconds, as sent in X-Signature-Timestamp.
+func SignRequest(secret, method, path, timestamp, nonce string, body []byte) string {
+	bodyHash := sha256.Sum256(body)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(method + "\n" + path + "\n" + timestamp + "\n" + nonce + "\n"))
+	mac.Write([]byte(hex.EncodeToString(bodyHash[:])))
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// SignedRequest verifies request signatures on server-to-server routes.
+// Signing is opt-in per site: when secretFor returns "" for the key's site the
+// request passes unchecked. Otherwise the timestamp must be within maxSkew of
+// the server clock, the signature must match (see SignRequest) and the nonce
+// must not have been seen before; nonces are remembered in Redis for twice
+// maxSkew, long enough to cover every timestamp that would still be accepted.
+// Must run after ServerAuth.
+func SignedRequest(rdb *redis.Client, secretFor func(siteID string) string, maxSkew time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := APIKeyFromContext(r.Context())
+			if key == nil {
+				writeJSON(w, http.StatusUnauthorized, map[string]string{
+					"error": "api key required",
+					"code":  "MISSING_API_KEY",
+				})
+				return
+			}
+			secret := secretFor(key.SiteID)
+			if secret == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ts := r.Header.Get(HeaderSignatureTimestamp)
+			nonce := r.Header.Get(HeaderSignatureNonce)
+			sig := r.Header.Get(HeaderSignature)
+			if ts == "" || nonce == "" || sig == "" {
+				writeJSON(w, http.StatusUnauthorized, map[string]string{
+					"error": "request signature required",
+					"code":  "MISSING_SIGNATURE",
+				})
+				return
+			}
+
+			unix, err := strconv.ParseInt(ts, 10, 64)
+			if err != nil {
+				writeJSON(w, http.StatusUnauthorized, map[string]string{
+					"error": "signature timestamp must be unix seconds",
+					"code":  "INVALID_SIGNATURE_TIMESTAMP",
+				})
+				return
+			}
+			if skew := time.Since(time.Unix(unix, 0)); skew > maxSkew || skew < -maxSkew {
+				writeJSON(w, http.StatusUnauthorized, map[string]string{
+					"error": "signature timestamp outside the allowed clock skew",
+					"code":  "SIGNATURE_EXPIRED",
+				})
+				return
+			}
+
+			if !validNonce.MatchString(nonce) {
+				writeJSON(w, http.StatusUnauthorized, map[string]string{
+					"error": "signature nonce must be 16-128 characters of [A-Za-z0-9_-]",
+					"code":  "INVALID_SIGNATURE_NONCE",
+				})
+				return
+			}
+
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				var maxErr *http.MaxBytesError
+				if errors.As(err, &maxErr) {
+					writeJSON(w, http.StatusRequestEntityTooLarge, map[string]string{
+						"error": "request body too large",
+						"code":  "BODY_TOO_LARGE",
+					})
+					return
+				}
+				writeJSON(w, http.StatusBadRequest, map[string]string{
+					"error": "could not read request body",
+					"code":  "INVALID_BODY",
+				})
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			want := SignRequest(secret, r.Method, r.URL.EscapedPath(), ts, nonce, body)
+			if !hmac.Equal([]byte(sig), []byte(want)) {
+				writeJSON(w, http.StatusUnauthorized, map[string]string{
+					"error": "invalid request signature",
+					"code":  "INVALID_SIGNATURE",
+				})
+				return
+			}
+
+			// Record the nonce only after the signature checks out, so
+			// unsigned garbage cannot fill up the nonce set.
+			ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+			fresh, err := rdb.SetNX(ctx, nonceKeyPrefix+key.SiteID+":"+nonce, ts, 2*maxSkew).Result()
+			cancel()
+			if err != nil {
+				// Fail closed: without the nonce set a replay can't be ruled out
+				slog.Error("signature nonce check failed", "error", err)
+				writeJSON(w, http.StatusServiceUnavailable, map[string]string{
+					"error": "service temporarily unavailable",
+					"code":  "AUTH_UNAVAILABLE",
+				})
+				return
+			}
+			if !fresh {
+				slog.Warn("replayed request signature", "site_id", key.SiteID, "key", key.Prefix)
+				writeJSON(w, http.StatusUnauthorized, map[string]string{
+					"error": "signature nonce already used",
+					"code":  "SIGNATURE_REPLAYED",
+				})
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package middleware
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/devrimsoft/bug-notifications-api/internal/model"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestSignRequest(t *testing.T) {
+	body := []byte(`{"title":"x"}`)
+	// Computed independently: HMAC-SHA256("secret", "POST\n/v1/server/reports\n1700000000\nabcdefghijklmnop\n" + hex(SHA256(body)))
+	const want = "sha256=10be44970005c0d2a8590ce2b4d58ad2d9f6c3dafc2d46ac4e5fb98994e73261"
+	if got := SignRequest("secret", "POST", "/v1/server/reports", "1700000000", "abcdefghijklmnop", body); got != want {
+		t.Errorf("SignRequest = %s, want %s", got, want)
+	}
+
+	// Every signed component changes the signature
+	variants := []string{
+		SignRequest("other", "POST", "/v1/server/reports", "1700000000", "abcdefghijklmnop", body),
+		SignRequest("secret", "PUT", "/v1/server/reports", "1700000000", "abcdefghijklmnop", body),
+		SignRequest("secret", "POST", "/v1/server/report", "1700000000", "abcdefghijklmnop", body),
+		SignRequest("secret", "POST", "/v1/server/reports", "1700000001", "abcdefghijklmnop", body),
+		SignRequest("secret", "POST", "/v1/server/reports", "1700000000", "abcdefghijklmnoq", body),
+		SignRequest("secret", "POST", "/v1/server/reports", "1700000000", "abcdefghijklmnop", []byte(`{"title":"y"}`)),
+	}
+	for i, v := range variants {
+		if v == want {
+			t.Errorf("variant %d produced the same signature", i)
+		}
+	}
+}
+
+func TestSignedRequest(t *testing.T) {
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+
+	const maxSkew = 5 * time.Minute
+	secrets := map[string]string{"signed.com": "s3cret"}
+	var reached string
+	h := SignedRequest(rdb, func(site string) string { return secrets[site] }, maxSkew)(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			b, _ := io.ReadAll(r.Body)
+			reached = string(b)
+		}))
+
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	stale := strconv.FormatInt(time.Now().Add(-2*maxSkew).Unix(), 10)
+	const body = `{"title":"bug"}`
+	sign := func(ts, nonce string) string {
+		return SignRequest("s3cret", http.MethodPost, "/v1/server/reports", ts, nonce, []byte(body))
+	}
+
+	tests := []struct {
+		name     string
+		site     string
+		ts       string
+		nonce    string
+		sig      string
+		wantCode string // "" means the request reached the handler
+	}{
+		{name: "unsigned site", site: "plain.com"},
+		{name: "missing headers", site: "signed.com", wantCode: "MISSING_SIGNATURE"},
+		{name: "bad timestamp", site: "signed.com", ts: "yesterday", nonce: "nonce-0000000001", sig: "x", wantCode: "INVALID_SIGNATURE_TIMESTAMP"},
+		{name: "stale timestamp", site: "signed.com", ts: stale, nonce: "nonce-0000000002", sig: sign(stale, "nonce-0000000002"), wantCode: "SIGNATURE_EXPIRED"},
+		{name: "short nonce", site: "signed.com", ts: now, nonce: "short", sig: sign(now, "short"), wantCode: "INVALID_SIGNATURE_NONCE"},
+		{name: "wrong signature", site: "signed.com", ts: now, nonce: "nonce-0000000003", sig: sign(now, "nonce-0000000004"), wantCode: "INVALID_SIGNATURE"},
+		{name: "valid", site: "signed.com", ts: now, nonce: "nonce-0000000005", sig: sign(now, "nonce-0000000005")},
+		{name: "replayed", site: "signed.com", ts: now, nonce: "nonce-0000000005", sig: sign(now, "nonce-0000000005"), wantCode: "SIGNATURE_REPLAYED"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reached = ""
+			r := httptest.NewRequest(http.MethodPost, "/v1/server/reports", strings.NewReader(body))
+			r = r.WithContext(context.WithValue(r.Context(), apiKeyContextKey{}, &model.SiteAPIKey{SiteID: tt.site, Prefix: "bnk_test"}))
+			if tt.ts != "" {
+				r.Header.Set(HeaderSignatureTimestamp, tt.ts)
+			}
+			if tt.nonce != "" {
+				r.Header.Set(HeaderSignatureNonce, tt.nonce)
+			}
+			if tt.sig != "" {
+				r.Header.Set(HeaderSignature, tt.sig)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+
+			if tt.wantCode == "" {
+				if w.Code != http.StatusOK || reached != body {
+					t.Fatalf("code %d, handler saw %q; want the request passed through with its body", w.Code, reached)
+				}
+				return
+			}
+			if w.Code != http.StatusUnauthorized || !strings.Contains(w.Body.String(), `"`+tt.wantCode+`"`) {
+				t.Errorf("got %d %s, want 401 %s", w.Code, w.Body.String(), tt.wantCode)
+			}
+			if reached != "" {
+				t.Error("rejected request reached the handler")
+			}
+		})
+	}
+
+	// Only verified nonces are recorded
+	if mr.Exists(nonceKeyPrefix + "signed.com:nonce-0000000003") {
+		t.Error("nonce of a request with a bad signature was recorded")
+	}
+	if !mr.Exists(nonceKeyPrefix + "signed.com:nonce-0000000005") {
+		t.Error("nonce of a verified request was not recorded")
+	}
+}
@@ -0,0 +1,26 @@
+package model
+
+import "time"
+
+// SiteAPIKey is a server-to-server API key bound to one site. Only a hash of
+// the key is stored; Prefix (the first characters of the key) identifies it
+// in listings and logs.
+type SiteAPIKey struct {
+	ID         string     `json:"id"`
+	SiteID     string     `json:"site_id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	ValidFrom  time.Time  `json:"valid_from"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// Active reports whether the key may be used at the given time.
+func (k *SiteAPIKey) Active(now time.Time) bool {
+	if k.RevokedAt != nil || now.Before(k.ValidFrom) {
+		return false
+	}
+	return k.ExpiresAt == nil || now.Before(*k.ExpiresAt)
+}
@@ -0,0 +1,13 @@
+package model
+
+import "time"
+
+// CaptchaChallenge is a proof-of-work challenge for the browser to solve.
+// A solution is a string S such that SHA-256(Challenge + ":" + S) starts
+// with Difficulty zero bits; the captcha token is Challenge + ":" + S.
+type CaptchaChallenge struct {
+	Challenge  string    `json:"challenge"`
+	Algorithm  string    `json:"algorithm"`
+	Difficulty int       `json:"difficulty"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
@@ -0,0 +1,26 @@
+package model
+
+type EmailMode string
+
+const (
+	EmailInstant EmailMode = "instant"
+	EmailHourly  EmailMode = "hourly"
+	EmailDaily   EmailMode = "daily"
+)
+
+var ValidEmailModes = map[EmailMode]bool{
+	EmailInstant: true,
+	EmailHourly:  true,
+	EmailDaily:   true,
+}
+
+// EmailNotification is a row of the email outbox: one report to be mailed to
+// one recipient, either on its own (instant) or as part of a digest.
+type EmailNotification struct {
+	ID        string
+	Recipient string
+	SiteID    string
+	ReportID  string
+	Mode      EmailMode
+	Attempts  int
+}
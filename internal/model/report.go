@@ -1,7 +1,12 @@
 package model
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
+// ReportType is the key of a report type. The valid types are data, see
+// Taxonomy; the built-in ones are named here.
 type ReportType string
 
 const (
@@ -9,11 +14,8 @@ const (
 	ReportTypeRequest ReportType = "request"
 )
 
-var ValidReportTypes = map[ReportType]bool{
-	ReportTypeBug:     true,
-	ReportTypeRequest: true,
-}
-
+// Category is the key of a report category. The valid categories are data,
+// see Taxonomy; the built-in ones are named here.
 type Category string
 
 const (
@@ -26,14 +28,41 @@ const (
 	CategoryOther         Category = "other"
 )
 
-var ValidCategories = map[Category]bool{
-	CategoryDesign:        true,
-	CategoryFunctionality: true,
-	CategoryPerformance:   true,
-	CategoryContent:       true,
-	CategoryMobile:        true,
-	CategorySecurity:      true,
-	CategoryOther:         true,
+type ReportStatus string
+
+const (
+	StatusNew        ReportStatus = "new"
+	StatusTriaged    ReportStatus = "triaged"
+	StatusInProgress ReportStatus = "in_progress"
+	StatusResolved   ReportStatus = "resolved"
+	StatusWontFix    ReportStatus = "wont_fix"
+	StatusDuplicate  ReportStatus = "duplicate"
+)
+
+var ValidStatuses = map[ReportStatus]bool{
+	StatusNew:        true,
+	StatusTriaged:    true,
+	StatusInProgress: true,
+	StatusResolved:   true,
+	StatusWontFix:    true,
+	StatusDuplicate:  true,
+}
+
+// AttachmentKind is the class of a file attached to a report.
+type AttachmentKind string
+
+const (
+	AttachmentImage AttachmentKind = "image"
+	AttachmentLog   AttachmentKind = "log"   // plain text, e.g. a console log
+	AttachmentHAR   AttachmentKind = "har"   // HTTP Archive (network capture)
+	AttachmentVideo AttachmentKind = "video" // MP4 or WebM screen recording
+)
+
+var ValidAttachmentKinds = map[AttachmentKind]bool{
+	AttachmentImage: true,
+	AttachmentLog:   true,
+	AttachmentHAR:   true,
+	AttachmentVideo: true,
 }
 
 type ContactType string
@@ -52,6 +81,36 @@ var ValidContactTypes = map[ContactType]bool{
 	ContactInstagram: true,
 }
 
+// CustomFieldType is the type of a custom report field.
+type CustomFieldType string
+
+const (
+	CustomFieldString CustomFieldType = "string"
+	CustomFieldNumber CustomFieldType = "number"
+	CustomFieldEnum   CustomFieldType = "enum" // one of Options
+	CustomFieldBool   CustomFieldType = "bool"
+	CustomFieldURL    CustomFieldType = "url" // http or https
+)
+
+var ValidCustomFieldTypes = map[CustomFieldType]bool{
+	CustomFieldString: true,
+	CustomFieldNumber: true,
+	CustomFieldEnum:   true,
+	CustomFieldBool:   true,
+	CustomFieldURL:    true,
+}
+
+// CustomField is an extra report field a site declares, e.g. an app
+// version or order number.
+type CustomField struct {
+	Name      string          `json:"name" yaml:"name"`
+	Label     string          `json:"label,omitempty" yaml:"label"` // shown in forms, defaults to the name
+	Type      CustomFieldType `json:"type" yaml:"type"`
+	Required  bool            `json:"required,omitempty" yaml:"required"`
+	MaxLength int             `json:"max_length,omitempty" yaml:"max_length"` // string and url only; 0 keeps the default
+	Options   []string        `json:"options,omitempty" yaml:"options"`       // enum only
+}
+
 // ReportRequest is the incoming API request body.
 type ReportRequest struct {
 	SiteID       string     `json:"site_id"`
@@ -59,30 +118,50 @@ type ReportRequest struct {
 	Title        string     `json:"title"`
 	Description  string     `json:"description"`
 	Category     Category   `json:"category"`
-	PageURL      *string  `json:"page_url,omitempty"`
-	ContactType  *string  `json:"contact_type,omitempty"`
-	ContactValue *string  `json:"contact_value,omitempty"`
-	FirstName    *string  `json:"first_name,omitempty"`
-	LastName     *string  `json:"last_name,omitempty"`
-	ImageURLs    []string `json:"image_urls,omitempty"`
+	PageURL      *string    `json:"page_url,omitempty"`
+	ContactType  *string    `json:"contact_type,omitempty"`
+	ContactValue *string    `json:"contact_value,omitempty"`
+	FirstName    *string    `json:"first_name,omitempty"`
+	LastName     *string    `json:"last_name,omitempty"`
+	ImageURLs    []string   `json:"image_urls,omitempty"`
+	// CustomFields holds the values of the site's custom fields by name.
+	// Multipart forms send them as custom_fields[name].
+	CustomFields map[string]any `json:"custom_fields,omitempty"`
 }
 
 // QueueMessage is what gets pushed to Redis.
 type QueueMessage struct {
-	EventID      string     `json:"event_id"`
-	SiteID       string     `json:"site_id"`
-	ReportType   ReportType `json:"report_type"`
-	Title        string     `json:"title"`
-	Description  string     `json:"description"`
-	Category     Category   `json:"category"`
-	PageURL      *string  `json:"page_url,omitempty"`
-	ContactType  *string  `json:"contact_type,omitempty"`
-	ContactValue *string  `json:"contact_value,omitempty"`
-	FirstName    *string  `json:"first_name,omitempty"`
-	LastName     *string  `json:"last_name,omitempty"`
-	ImageURLs    []string `json:"image_urls,omitempty"`
-	ReceivedAt   string   `json:"received_at"`
-	RetryCount   int      `json:"retry_count"`
+	EventID       string          `json:"event_id"`
+	SiteID        string          `json:"site_id"`
+	ReportType    ReportType      `json:"report_type"`
+	Title         string          `json:"title"`
+	Description   string          `json:"description"`
+	Category      Category        `json:"category"`
+	PageURL       *string         `json:"page_url,omitempty"`
+	ContactType   *string         `json:"contact_type,omitempty"`
+	ContactValue  *string         `json:"contact_value,omitempty"`
+	FirstName     *string         `json:"first_name,omitempty"`
+	LastName      *string         `json:"last_name,omitempty"`
+	ImageURLs     []string        `json:"image_urls,omitempty"`
+	CustomFields  json.RawMessage `json:"custom_fields,omitempty"` // validated JSON object
+	StagedFiles   []StagedFile    `json:"staged_files,omitempty"`  // uploads the worker still has to store
+	ReceivedAt    string          `json:"received_at"`
+	RetryCount    int             `json:"retry_count"`
+	NextAttemptAt string          `json:"next_attempt_at,omitempty"` // RFC 3339, set when scheduled for retry
+	LastError     string          `json:"last_error,omitempty"`
+	FailedAt      string          `json:"failed_at,omitempty"` // RFC 3339, time of the last failure
+
+	// StreamID is the Redis stream entry ID, set by the consumer on delivery.
+	// It is not part of the payload.
+	StreamID string `json:"-"`
+}
+
+// StagedFile is a validated upload held in Redis until the worker puts it
+// in storage.
+type StagedFile struct {
+	ObjectKey   string         `json:"object_key"` // storage key, chosen when the report is accepted
+	ContentType string         `json:"content_type"`
+	Kind        AttachmentKind `json:"kind"`
 }
 
 // BugReport is the database row.
@@ -98,9 +177,42 @@ type BugReport struct {
 	ContactValue *string   `json:"contact_value,omitempty"`
 	FirstName    *string   `json:"first_name,omitempty"`
 	LastName     *string   `json:"last_name,omitempty"`
-	ImageURLs    []string  `json:"image_urls,omitempty"`
 	Status       string    `json:"status"`
 	CreatedAt    time.Time `json:"created_at"`
+
+	// CustomFields is a JSON object with the values of the site's custom fields.
+	CustomFields json.RawMessage `json:"custom_fields,omitempty"`
+
+	// Attachments are the report's files, in upload order.
+	Attachments []Attachment `json:"attachments,omitempty"`
+	// ImageURLs lists the URLs of the image attachments, kept for existing
+	// consumers of the report JSON. It is filled in when the report is loaded.
+	ImageURLs []string `json:"image_urls,omitempty"`
+
+	// AttachmentsPending is set while the worker is still uploading files.
+	AttachmentsPending bool `json:"attachments_pending"`
+	// AttachmentsLost counts uploads that were accepted but never stored,
+	// because their staged copy expired or the message was dead-lettered.
+	AttachmentsLost int `json:"attachments_lost,omitempty"`
+}
+
+// Attachment is a file of a report. Images given as URLs in the request
+// only have a URL and kind; the other fields are set for files uploaded
+// through this service. Width, Height and ThumbnailURL are for images only.
+type Attachment struct {
+	Kind         AttachmentKind `json:"kind"`
+	URL          string         `json:"url"`
+	ThumbnailURL *string        `json:"thumbnail_url,omitempty"`
+	ContentType  string         `json:"content_type,omitempty"`
+	Size         int64          `json:"size,omitempty"` // bytes
+	Width        int            `json:"width,omitempty"`
+	Height       int            `json:"height,omitempty"`
+	SHA256       string         `json:"sha256,omitempty"` // hex
+
+	// Storage keys. ObjectKey is empty for external URLs, ThumbnailKey when
+	// the image is small enough to be its own thumbnail.
+	ObjectKey    string `json:"-"`
+	ThumbnailKey string `json:"-"`
 }
 
 // ReportResponse is the API response.
@@ -109,8 +221,57 @@ type ReportResponse struct {
 	Queued  bool   `json:"queued"`
 }
 
+// ReportState is where a submitted report is in the processing pipeline.
+type ReportState string
+
+const (
+	StateQueued             ReportState = "queued"              // accepted, waiting for (or retrying) storage
+	StateStored             ReportState = "stored"              // saved to the database
+	StateAttachmentsPending ReportState = "attachments_pending" // saved; images still uploading
+	StateDeadLettered       ReportState = "dead_lettered"       // storage failed for good; awaiting an operator
+	StateQuarantined        ReportState = "quarantined"         // payload unreadable; awaiting an operator
+)
+
+// ReportStatusResponse is the public status of a submitted report.
+// It deliberately carries no report contents or contact details.
+type ReportStatusResponse struct {
+	EventID string       `json:"event_id"`
+	State   ReportState  `json:"state"`
+	Status  ReportStatus `json:"status,omitempty"` // triage status, once stored
+}
+
+// ReportListResponse is the admin API response for a page of stored reports.
+type ReportListResponse struct {
+	Reports []BugReport `json:"reports"`
+	Total   int         `json:"total"`
+	Page    int         `json:"page"`
+	PerPage int         `json:"per_page"`
+}
+
+// StatusUpdateRequest is the admin API request body for changing a report's status.
+type StatusUpdateRequest struct {
+	Status ReportStatus `json:"status"`
+	Note   *string      `json:"note,omitempty"`
+}
+
+// StatusChange is one entry of a report's status history.
+type StatusChange struct {
+	ID         int64        `json:"id"`
+	ReportID   string       `json:"report_id"`
+	FromStatus ReportStatus `json:"from_status"`
+	ToStatus   ReportStatus `json:"to_status"`
+	ChangedBy  string       `json:"changed_by"`
+	Note       *string      `json:"note,omitempty"`
+	ChangedAt  time.Time    `json:"changed_at"`
+}
+
+// StatusHistoryResponse is the admin API response for a report's status history.
+type StatusHistoryResponse struct {
+	History []StatusChange `json:"history"`
+}
+
 // ErrorResponse is the standard error format.
 type ErrorResponse struct {
-	Error   string `json:"error"`
-	Code    string `json:"code,omitempty"`
+	Error string `json:"error"`
+	Code  string `json:"code,omitempty"`
 }
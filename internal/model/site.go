@@ -0,0 +1,38 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Site is a row of the sites table, managed through the admin API. Settings
+// has the fields of a SITES_FILE entry; without them the site keeps its
+// SITES_FILE or environment settings, and a site that is in neither is only
+// registered (reports referencing it can be stored) but does not accept reports.
+type Site struct {
+	Domain    string          `json:"domain"`
+	Name      string          `json:"name,omitempty"`
+	Active    bool            `json:"active"`
+	Settings  json.RawMessage `json:"settings,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// SiteCreateRequest is the body of POST /admin/v1/sites.
+type SiteCreateRequest struct {
+	Domain   string          `json:"domain"`
+	Name     string          `json:"name"`
+	Active   *bool           `json:"active"` // defaults to true
+	Settings json.RawMessage `json:"settings"`
+}
+
+// SiteUpdateRequest is the body of PATCH /admin/v1/sites/{domain}. Omitted
+// fields are left alone; "settings": null clears the settings.
+type SiteUpdateRequest struct {
+	Name     *string         `json:"name"`
+	Active   *bool           `json:"active"`
+	Settings json.RawMessage `json:"settings"`
+}
+
+type SiteListResponse struct {
+	Sites []Site `json:"sites"`
+}
@@ -0,0 +1,95 @@
+package model
+
+import "slices"
+
+// TaxonomyEntry is a report type or category. Labels are keyed by language
+// code, e.g. "en" or "tr".
+type TaxonomyEntry struct {
+	Key      string            `json:"key"`
+	Labels   map[string]string `json:"labels,omitempty"`
+	Position int               `json:"position"`
+	Active   bool              `json:"active"`
+}
+
+// Taxonomy lists the report types and categories reports may use. It is
+// stored in the report_types and report_categories tables.
+type Taxonomy struct {
+	ReportTypes []TaxonomyEntry `json:"report_types"`
+	Categories  []TaxonomyEntry `json:"categories"`
+}
+
+// TaxonomyEntryRequest is the body of PUT /admin/v1/taxonomy/{kind}/{key}.
+// Active defaults to true.
+type TaxonomyEntryRequest struct {
+	Labels   map[string]string `json:"labels"`
+	Position int               `json:"position"`
+	Active   *bool             `json:"active"`
+}
+
+// HasReportType reports whether an active report type has the key.
+func (t *Taxonomy) HasReportType(key string) bool {
+	return hasActive(t.ReportTypes, key)
+}
+
+// HasCategory reports whether an active category has the key.
+func (t *Taxonomy) HasCategory(key string) bool {
+	return hasActive(t.Categories, key)
+}
+
+// ReportTypeExists reports whether a report type has the key, active or
+// not. Stored reports may use inactive ones.
+func (t *Taxonomy) ReportTypeExists(key string) bool {
+	return slices.ContainsFunc(t.ReportTypes, func(e TaxonomyEntry) bool { return e.Key == key })
+}
+
+// CategoryExists reports whether a category has the key, active or not.
+func (t *Taxonomy) CategoryExists(key string) bool {
+	return slices.ContainsFunc(t.Categories, func(e TaxonomyEntry) bool { return e.Key == key })
+}
+
+// ReportTypeKeys returns the keys of the active report types.
+func (t *Taxonomy) ReportTypeKeys() []string {
+	return activeKeys(t.ReportTypes)
+}
+
+// CategoryKeys returns the keys of the active categories.
+func (t *Taxonomy) CategoryKeys() []string {
+	return activeKeys(t.Categories)
+}
+
+func hasActive(entries []TaxonomyEntry, key string) bool {
+	return slices.ContainsFunc(entries, func(e TaxonomyEntry) bool { return e.Active && e.Key == key })
+}
+
+func activeKeys(entries []TaxonomyEntry) []string {
+	var keys []string
+	for _, e := range entries {
+		if e.Active {
+			keys = append(keys, e.Key)
+		}
+	}
+	return keys
+}
+
+// DefaultTaxonomy returns the built-in report types and categories, used
+// until the tables are loaded. The tables are seeded with the same keys.
+func DefaultTaxonomy() *Taxonomy {
+	entry := func(key, label string, pos int) TaxonomyEntry {
+		return TaxonomyEntry{Key: key, Labels: map[string]string{"en": label}, Position: pos, Active: true}
+	}
+	return &Taxonomy{
+		ReportTypes: []TaxonomyEntry{
+			entry(string(ReportTypeBug), "Bug Report", 1),
+			entry(string(ReportTypeRequest), "Suggestions", 2),
+		},
+		Categories: []TaxonomyEntry{
+			entry(string(CategoryDesign), "Design", 1),
+			entry(string(CategoryFunctionality), "Functionality", 2),
+			entry(string(CategoryPerformance), "Performance", 3),
+			entry(string(CategoryContent), "Content", 4),
+			entry(string(CategoryMobile), "Mobile", 5),
+			entry(string(CategorySecurity), "Security", 6),
+			entry(string(CategoryOther), "Other", 7),
+		},
+	}
+}
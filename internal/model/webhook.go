@@ -0,0 +1,73 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type WebhookEventType string
+
+const (
+	EventReportCreated       WebhookEventType = "report.created"
+	EventReportStatusChanged WebhookEventType = "report.status_changed"
+)
+
+var ValidWebhookEvents = map[WebhookEventType]bool{
+	EventReportCreated:       true,
+	EventReportStatusChanged: true,
+}
+
+type DeliveryStatus string
+
+const (
+	DeliveryPending   DeliveryStatus = "pending"
+	DeliveryDelivered DeliveryStatus = "delivered"
+	DeliveryFailed    DeliveryStatus = "failed"
+)
+
+var ValidDeliveryStatuses = map[DeliveryStatus]bool{
+	DeliveryPending:   true,
+	DeliveryDelivered: true,
+	DeliveryFailed:    true,
+}
+
+// WebhookPayload is the JSON body POSTed to webhook endpoints.
+type WebhookPayload struct {
+	Type       WebhookEventType `json:"type"`
+	EventID    string           `json:"event_id"`
+	DeliveryID string           `json:"delivery_id"`
+	OccurredAt string           `json:"occurred_at"`
+	Data       WebhookData      `json:"data"`
+}
+
+// WebhookData carries the report snapshot for a webhook event.
+type WebhookData struct {
+	Report         *BugReport `json:"report"`
+	PreviousStatus string     `json:"previous_status,omitempty"`
+}
+
+// WebhookDelivery is a row of the webhook delivery log.
+type WebhookDelivery struct {
+	ID             string           `json:"id"`
+	EndpointID     string           `json:"endpoint_id"`
+	SiteID         string           `json:"site_id"`
+	EventType      WebhookEventType `json:"event_type"`
+	ReportID       string           `json:"report_id"`
+	URL            string           `json:"url"`
+	Payload        json.RawMessage  `json:"payload"`
+	Status         DeliveryStatus   `json:"status"`
+	Attempts       int              `json:"attempts"`
+	NextAttemptAt  time.Time        `json:"next_attempt_at"`
+	LastStatusCode *int             `json:"last_status_code,omitempty"`
+	LastError      *string          `json:"last_error,omitempty"`
+	CreatedAt      time.Time        `json:"created_at"`
+	DeliveredAt    *time.Time       `json:"delivered_at,omitempty"`
+}
+
+// DeliveryListResponse is the admin API response for a page of webhook deliveries.
+type DeliveryListResponse struct {
+	Deliveries []WebhookDelivery `json:"deliveries"`
+	Total      int               `json:"total"`
+	Page       int               `json:"page"`
+	PerPage    int               `json:"per_page"`
+}
@@ -0,0 +1,62 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/devrimsoft/bug-notifications-api/internal/config"
+	"github.com/devrimsoft/bug-notifications-api/internal/model"
+)
+
+// Discord embed colors for bug reports and feature requests.
+const (
+	discordColorBug     = 0xE5484D
+	discordColorRequest = 0x3E63DD
+)
+
+// sendDiscord posts an embed to a Discord webhook.
+func sendDiscord(ctx context.Context, client *http.Client, rt config.NotifyRoute, report *model.BugReport) error {
+	color := discordColorBug
+	if report.ReportType == string(model.ReportTypeRequest) {
+		color = discordColorRequest
+	}
+
+	fields := []map[string]any{
+		{"name": "Site", "value": report.SiteID, "inline": true},
+		{"name": "Category", "value": report.Category, "inline": true},
+		{"name": "Type", "value": report.ReportType, "inline": true},
+	}
+	if report.PageURL != nil && *report.PageURL != "" {
+		fields = append(fields, map[string]any{"name": "Page", "value": truncate(*report.PageURL, 1024)})
+	}
+	if len(report.ImageURLs) > 0 {
+		links := make([]string, len(report.ImageURLs))
+		for i, u := range report.ImageURLs {
+			links[i] = fmt.Sprintf("[image %d](%s)", i+1, u)
+		}
+		fields = append(fields, map[string]any{"name": "Attachments", "value": truncate(strings.Join(links, " · "), 1024)})
+	}
+
+	embed := map[string]any{
+		"title":       truncate(fmt.Sprintf("%s: %s", reportTypeLabel(report.ReportType), report.Title), 256),
+		"description": truncate(report.Description, 4000),
+		"color":       color,
+		"fields":      fields,
+		"footer":      map[string]string{"text": "Event ID: " + report.ID},
+		"timestamp":   report.CreatedAt.UTC().Format(time.RFC3339),
+	}
+	if report.PageURL != nil && *report.PageURL != "" {
+		embed["url"] = *report.PageURL
+	}
+	if len(report.ImageURLs) > 0 {
+		embed["thumbnail"] = map[string]string{"url": report.ImageURLs[0]}
+	}
+
+	return postJSON(ctx, client, rt.URL, map[string]any{
+		"embeds":           []any{embed},
+		"allowed_mentions": map[string]any{"parse": []string{}}, // never ping from user text
+	})
+}
@@ -0,0 +1,176 @@
+// Package notify posts new bug reports to chat channels (Slack, Discord, Telegram).
+//
+// Notifications are best-effort: a failed post is logged and does not hold up
+// the report. Each route claims a report before posting it, so a redelivered
+// queue message does not notify the same channel twice; a worker that dies
+// mid-post loses that notification rather than repeating it.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/devrimsoft/bug-notifications-api/internal/config"
+	"github.com/devrimsoft/bug-notifications-api/internal/model"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// sentKeyPrefix + route ID + ":" + report ID marks a notification as
+	// sent or being sent.
+	sentKeyPrefix = "notify:sent:"
+	sentKeyTTL    = 7 * 24 * time.Hour
+
+	// totalTimeout bounds all posts for one report, well below
+	// queue.ClaimMinIdle so the queue message is acknowledged before another
+	// consumer can reclaim it.
+	totalTimeout = 30 * time.Second
+
+	requestTimeout = 10 * time.Second
+	maxAttempts    = 3
+)
+
+// sender posts a report to one route's channel.
+type sender func(ctx context.Context, client *http.Client, rt config.NotifyRoute, report *model.BugReport) error
+
+// Notifier fans a stored report out to the chat routes that match it.
+type Notifier struct {
+	live    *config.Live
+	rdb     *redis.Client
+	client  *http.Client
+	senders map[string]sender
+}
+
+func New(live *config.Live, rdb *redis.Client) *Notifier {
+	cfg := live.Get()
+	return &Notifier{
+		live:   live,
+		rdb:    rdb,
+		client: &http.Client{Timeout: requestTimeout},
+		senders: map[string]sender{
+			"slack":    sendSlack,
+			"discord":  sendDiscord,
+			"telegram": telegramSender(cfg.TelegramAPIURL),
+		},
+	}
+}
+
+// Enabled reports whether any chat routes are configured.
+func (n *Notifier) Enabled() bool {
+	return len(n.live.Get().NotifyRoutes) > 0
+}
+
+// ReportCreated notifies every matching route about a new report, all routes
+// at once and within totalTimeout. Failures are logged per route and never
+// returned.
+func (n *Notifier) ReportCreated(ctx context.Context, report *model.BugReport) {
+	sendCtx, cancel := context.WithTimeout(ctx, totalTimeout)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for _, rt := range n.live.Get().NotifyRoutesFor(report.SiteID, report.Category) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			n.notifyRoute(ctx, sendCtx, rt, report)
+		}()
+	}
+	wg.Wait()
+}
+
+// notifyRoute claims the report for a route and posts it within sendCtx. The
+// claim is released if the post fails, so a redelivery tries again.
+func (n *Notifier) notifyRoute(ctx, sendCtx context.Context, rt config.NotifyRoute, report *model.BugReport) {
+	key := sentKeyPrefix + rt.ID + ":" + report.ID
+
+	claimed, err := n.rdb.SetNX(ctx, key, 1, sentKeyTTL).Result()
+	if err != nil {
+		slog.Error("claim chat notification failed", "route", rt.ID, "event_id", report.ID, "error", err)
+		return
+	}
+	if !claimed {
+		return
+	}
+
+	if err := n.send(sendCtx, rt, report); err != nil {
+		slog.Error("chat notification failed", "route", rt.ID, "type", rt.Type, "event_id", report.ID, "error", err)
+		if err := n.rdb.Del(ctx, key).Err(); err != nil {
+			slog.Warn("release chat notification failed", "route", rt.ID, "event_id", report.ID, "error", err)
+		}
+		return
+	}
+	slog.Info("chat notification sent", "route", rt.ID, "type", rt.Type, "event_id", report.ID)
+}
+
+// send tries a route a few times with a short linear backoff.
+func (n *Notifier) send(ctx context.Context, rt config.NotifyRoute, report *model.BugReport) error {
+	var err error
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		if err = n.senders[rt.Type](ctx, n.client, rt, report); err == nil {
+			return nil
+		}
+		if attempt < maxAttempts {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(time.Duration(attempt) * time.Second):
+			}
+		}
+	}
+	return err
+}
+
+// postJSON POSTs a JSON body and treats any non-2xx response as an error.
+func postJSON(ctx context.Context, client *http.Client, url string, body any) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("marshal body: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("status %d: %s", resp.StatusCode, string(b))
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+	return nil
+}
+
+// reportTypeLabel returns a short human label for a report type. Types
+// added to the taxonomy are shown by key.
+func reportTypeLabel(t string) string {
+	switch t {
+	case string(model.ReportTypeBug):
+		return "Bug report"
+	case string(model.ReportTypeRequest):
+		return "Feature request"
+	}
+	return t
+}
+
+// truncate shortens s to at most n runes, adding an ellipsis when cut.
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n-1]) + "…"
+}
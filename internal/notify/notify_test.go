@@ -0,0 +1,309 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/devrimsoft/bug-notifications-api/internal/config"
+	"github.com/devrimsoft/bug-notifications-api/internal/model"
+	"github.com/redis/go-redis/v9"
+)
+
+// chatServer stands in for Slack, Discord and the Telegram Bot API and keeps
+// every JSON body it receives by request path.
+type chatServer struct {
+	*httptest.Server
+	mu     sync.Mutex
+	bodies map[string][]map[string]any
+}
+
+func newChatServer(t *testing.T) *chatServer {
+	t.Helper()
+	s := &chatServer{bodies: make(map[string][]map[string]any)}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("%s: %v", r.URL.Path, err)
+		}
+		if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("%s: Content-Type = %q", r.URL.Path, ct)
+		}
+		s.mu.Lock()
+		s.bodies[r.URL.Path] = append(s.bodies[r.URL.Path], body)
+		s.mu.Unlock()
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// take returns the paths posted to since the last call, sorted, and their bodies.
+func (s *chatServer) take() ([]string, map[string][]map[string]any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	bodies := s.bodies
+	s.bodies = make(map[string][]map[string]any)
+	paths := make([]string, 0, len(bodies))
+	for p := range bodies {
+		paths = append(paths, p)
+	}
+	slices.Sort(paths)
+	return paths, bodies
+}
+
+func newTestNotifier(t *testing.T, srv *chatServer, routes []config.NotifyRoute) *Notifier {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+	return New(config.NewLive(&config.Config{NotifyRoutes: routes, TelegramAPIURL: srv.URL}), rdb)
+}
+
+func testReport(id, site, category string) *model.BugReport {
+	page := "https://shop.example.com/checkout?step=2"
+	return &model.BugReport{
+		ID:          id,
+		SiteID:      site,
+		ReportType:  string(model.ReportTypeBug),
+		Title:       "Checkout <fails> & more",
+		Description: "Clicking <b>Pay</b> does nothing @everyone",
+		Category:    category,
+		PageURL:     &page,
+		Status:      "open",
+		CreatedAt:   time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+}
+
+func TestReportCreatedRouting(t *testing.T) {
+	srv := newChatServer(t)
+	n := newTestNotifier(t, srv, []config.NotifyRoute{
+		{ID: "team", Type: "slack", SiteID: "*", ExcludeCategories: []string{"security"}, URL: srv.URL + "/slack/team"},
+		{ID: "shop", Type: "discord", SiteID: "shop.example.com", URL: srv.URL + "/discord/shop"},
+		{ID: "blog", Type: "slack", SiteID: "blog.example.com", URL: srv.URL + "/slack/blog"},
+		{ID: "sec", Type: "telegram", SiteID: "*", Categories: []string{"security"}, BotToken: "123:abc", ChatID: "-100123"},
+	})
+	if !n.Enabled() {
+		t.Fatal("Enabled = false with routes configured")
+	}
+
+	tests := []struct {
+		site     string
+		category string
+		want     []string
+	}{
+		// "*" routes reach every site, site routes only their own
+		{site: "shop.example.com", category: "billing", want: []string{"/discord/shop", "/slack/team"}},
+		{site: "blog.example.com", category: "billing", want: []string{"/slack/blog", "/slack/team"}},
+		{site: "other.example.com", category: "billing", want: []string{"/slack/team"}},
+		// Categories and exclude_categories narrow a route down
+		{site: "other.example.com", category: "security", want: []string{"/bot123:abc/sendMessage"}},
+		{site: "shop.example.com", category: "security", want: []string{"/bot123:abc/sendMessage", "/discord/shop"}},
+	}
+	for i, tt := range tests {
+		report := testReport(strings.Repeat(string(rune('a'+i)), 8), tt.site, tt.category)
+		report.PageURL = nil
+		n.ReportCreated(context.Background(), report)
+		if got, _ := srv.take(); !slices.Equal(got, tt.want) {
+			t.Errorf("%s/%s posted to %v, want %v", tt.site, tt.category, got, tt.want)
+		}
+	}
+}
+
+func TestReportCreatedOnce(t *testing.T) {
+	srv := newChatServer(t)
+	n := newTestNotifier(t, srv, []config.NotifyRoute{
+		{ID: "team", Type: "slack", SiteID: "*", URL: srv.URL + "/slack/team"},
+	})
+	report := testReport("4f3c2b1a-0e9d-4c8b-a7f6-e5d4c3b2a190", "shop.example.com", "billing")
+
+	n.ReportCreated(context.Background(), report)
+	if got, _ := srv.take(); len(got) != 1 {
+		t.Fatalf("posted to %v, want the team route", got)
+	}
+	// A redelivered queue message does not post again
+	n.ReportCreated(context.Background(), report)
+	if got, _ := srv.take(); len(got) != 0 {
+		t.Errorf("redelivery posted to %v", got)
+	}
+}
+
+func TestSlackPayload(t *testing.T) {
+	srv := newChatServer(t)
+	n := newTestNotifier(t, srv, []config.NotifyRoute{
+		{ID: "team", Type: "slack", SiteID: "*", URL: srv.URL + "/slack"},
+	})
+	report := testReport("9e8d7c6b-5a4f-4e3d-8c2b-1a0f9e8d7c6b", "shop.example.com", "billing")
+	report.ImageURLs = []string{"https://cdn.example.com/a.png", "https://cdn.example.com/b.png"}
+
+	n.ReportCreated(context.Background(), report)
+	_, bodies := srv.take()
+	if len(bodies["/slack"]) != 1 {
+		t.Fatalf("slack posts = %v", bodies)
+	}
+	body := bodies["/slack"][0]
+
+	if body["text"] != "Bug report: Checkout <fails> & more" {
+		t.Errorf("text = %q", body["text"])
+	}
+	blocks := body["blocks"].([]any)
+	var types []string
+	for _, b := range blocks {
+		types = append(types, b.(map[string]any)["type"].(string))
+	}
+	if want := []string{"header", "section", "section", "image", "image", "context"}; !slices.Equal(types, want) {
+		t.Fatalf("block types = %v, want %v", types, want)
+	}
+
+	var fields []string
+	for _, f := range blocks[1].(map[string]any)["fields"].([]any) {
+		fields = append(fields, f.(map[string]any)["text"].(string))
+	}
+	wantFields := []string{
+		"*Site*\nshop.example.com",
+		"*Category*\nbilling",
+		"*Type*\nbug",
+		"*Page*\n<https://shop.example.com/checkout?step=2|https://shop.example.com/checkout?step=2>",
+	}
+	if !slices.Equal(fields, wantFields) {
+		t.Errorf("fields = %q, want %q", fields, wantFields)
+	}
+
+	// User text must not open links or mentions in mrkdwn
+	desc := blocks[2].(map[string]any)["text"].(map[string]any)["text"]
+	if desc != "Clicking &lt;b&gt;Pay&lt;/b&gt; does nothing @everyone" {
+		t.Errorf("description = %q", desc)
+	}
+	if img := blocks[4].(map[string]any); img["image_url"] != report.ImageURLs[1] || img["alt_text"] != "attachment 2" {
+		t.Errorf("second image block = %v", img)
+	}
+	ctx := blocks[5].(map[string]any)["elements"].([]any)[0].(map[string]any)["text"]
+	if ctx != "Event ID: `"+report.ID+"`" {
+		t.Errorf("context = %q", ctx)
+	}
+}
+
+func TestDiscordPayload(t *testing.T) {
+	srv := newChatServer(t)
+	n := newTestNotifier(t, srv, []config.NotifyRoute{
+		{ID: "shop", Type: "discord", SiteID: "shop.example.com", URL: srv.URL + "/discord"},
+	})
+	report := testReport("2b3c4d5e-6f7a-4b8c-9d0e-1f2a3b4c5d6e", "shop.example.com", "billing")
+	report.ReportType = string(model.ReportTypeRequest)
+	report.ImageURLs = []string{"https://cdn.example.com/a.png", "https://cdn.example.com/b.png"}
+
+	n.ReportCreated(context.Background(), report)
+	_, bodies := srv.take()
+	if len(bodies["/discord"]) != 1 {
+		t.Fatalf("discord posts = %v", bodies)
+	}
+	body := bodies["/discord"][0]
+
+	// Mentions typed into a report never ping anyone
+	if parse := body["allowed_mentions"].(map[string]any)["parse"].([]any); len(parse) != 0 {
+		t.Errorf("allowed_mentions.parse = %v, want none", parse)
+	}
+	embed := body["embeds"].([]any)[0].(map[string]any)
+	if embed["title"] != "Feature request: Checkout <fails> & more" {
+		t.Errorf("title = %q", embed["title"])
+	}
+	if embed["color"] != float64(discordColorRequest) {
+		t.Errorf("color = %v, want the request color", embed["color"])
+	}
+	if embed["url"] != *report.PageURL || embed["timestamp"] != "2026-01-02T03:04:05Z" {
+		t.Errorf("url = %v, timestamp = %v", embed["url"], embed["timestamp"])
+	}
+	if embed["footer"].(map[string]any)["text"] != "Event ID: "+report.ID {
+		t.Errorf("footer = %v", embed["footer"])
+	}
+	if embed["thumbnail"].(map[string]any)["url"] != report.ImageURLs[0] {
+		t.Errorf("thumbnail = %v", embed["thumbnail"])
+	}
+
+	fields := embed["fields"].([]any)
+	last := fields[len(fields)-1].(map[string]any)
+	if last["name"] != "Attachments" || last["value"] != "[image 1](https://cdn.example.com/a.png) · [image 2](https://cdn.example.com/b.png)" {
+		t.Errorf("attachments field = %v", last)
+	}
+}
+
+func TestTelegramPayload(t *testing.T) {
+	srv := newChatServer(t)
+	n := newTestNotifier(t, srv, []config.NotifyRoute{
+		{ID: "sec", Type: "telegram", SiteID: "*", BotToken: "123:abc", ChatID: "-100123"},
+	})
+
+	// A custom report type is shown by its key
+	report := testReport("7a6b5c4d-3e2f-4a1b-9c8d-7e6f5a4b3c2d", "shop.example.com", "billing")
+	report.ReportType = "praise"
+	n.ReportCreated(context.Background(), report)
+	_, bodies := srv.take()
+	msgs := bodies["/bot123:abc/sendMessage"]
+	if len(msgs) != 1 {
+		t.Fatalf("telegram posts = %v", bodies)
+	}
+	want := "<b>praise: Checkout &lt;fails&gt; &amp; more</b>\n" +
+		"Site: shop.example.com\nCategory: billing\n" +
+		"Page: https://shop.example.com/checkout?step=2\n" +
+		"Event ID: <code>" + report.ID + "</code>\n\n" +
+		"Clicking &lt;b&gt;Pay&lt;/b&gt; does nothing @everyone"
+	if msgs[0]["text"] != want || msgs[0]["chat_id"] != "-100123" || msgs[0]["parse_mode"] != "HTML" {
+		t.Errorf("sendMessage = %v", msgs[0])
+	}
+
+	// With images: the first one as a photo, the caption within its limit
+	report = testReport("8b7c6d5e-4f3a-4b2c-8d1e-0f9a8b7c6d5e", "shop.example.com", "billing")
+	report.ImageURLs = []string{"https://cdn.example.com/a.png"}
+	report.Description = strings.Repeat("ş", 3000)
+	n.ReportCreated(context.Background(), report)
+	_, bodies = srv.take()
+	photos := bodies["/bot123:abc/sendPhoto"]
+	if len(photos) != 1 {
+		t.Fatalf("telegram posts = %v", bodies)
+	}
+	caption := photos[0]["caption"].(string)
+	if photos[0]["photo"] != report.ImageURLs[0] || !strings.Contains(caption, `<a href="https://cdn.example.com/a.png">Image 1</a>`) {
+		t.Errorf("sendPhoto = %v", photos[0])
+	}
+	if n := len([]rune(caption)); n > telegramCaptionLimit || !strings.HasSuffix(caption, "…") {
+		t.Errorf("caption is %d runes, want at most %d and cut", n, telegramCaptionLimit)
+	}
+}
+
+func TestTelegramCaptionManyImages(t *testing.T) {
+	report := testReport("9c8d7e6f-5a4b-4c3d-8e2f-1a0b9c8d7e6f", "shop.example.com", "billing")
+	report.Title = strings.Repeat("<&>", 100)
+	page := "https://shop.example.com/checkout?" + strings.Repeat("a=1&", 100)
+	report.PageURL = &page
+	report.Description = strings.Repeat("<b>", 500)
+	for i := range 8 {
+		// As long as a signed local URL: site/yyyy/mm/uuid plus an HMAC signature
+		report.ImageURLs = append(report.ImageURLs, fmt.Sprintf(
+			"https://bugs.example.com/files/shop.example.com/2026/01/0b9a3c57-41f4-4f0e-8d6e-5a2b6b0e9c1%d.png?expires=1767225600&sig=%s", i, strings.Repeat("f", 64)))
+	}
+
+	caption := telegramText(report, telegramCaptionLimit)
+	if n := len([]rune(caption)); n > telegramCaptionLimit {
+		t.Fatalf("caption is %d runes, want at most %d", n, telegramCaptionLimit)
+	}
+	if !strings.Contains(caption, `<a href="`+html.EscapeString(report.ImageURLs[0])+`">Image 1</a>`) ||
+		!strings.Contains(caption, " more images\n") || !strings.Contains(caption, "<code>"+report.ID+"</code>") {
+		t.Errorf("caption = %q", caption)
+	}
+
+	// Only whole tags and entities are left once the known markup is removed
+	rest := regexp.MustCompile(`<a href="[^"<>]*">Image \d</a>|</?b>|</?code>`).ReplaceAllString(caption, "")
+	rest = regexp.MustCompile(`&(lt|gt|amp|quot|#39);`).ReplaceAllString(rest, "")
+	if strings.ContainsAny(rest, "<>&") {
+		t.Errorf("caption has cut markup: %q", caption)
+	}
+}
@@ -0,0 +1,61 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/devrimsoft/bug-notifications-api/internal/config"
+	"github.com/devrimsoft/bug-notifications-api/internal/model"
+)
+
+// slackEscaper escapes the characters Slack mrkdwn treats as control sequences.
+var slackEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// sendSlack posts a Block Kit message to a Slack incoming webhook.
+func sendSlack(ctx context.Context, client *http.Client, rt config.NotifyRoute, report *model.BugReport) error {
+	heading := fmt.Sprintf("%s: %s", reportTypeLabel(report.ReportType), report.Title)
+
+	fields := []map[string]string{
+		{"type": "mrkdwn", "text": "*Site*\n" + slackEscaper.Replace(report.SiteID)},
+		{"type": "mrkdwn", "text": "*Category*\n" + slackEscaper.Replace(report.Category)},
+		{"type": "mrkdwn", "text": "*Type*\n" + slackEscaper.Replace(report.ReportType)},
+	}
+	if report.PageURL != nil && *report.PageURL != "" {
+		fields = append(fields, map[string]string{
+			"type": "mrkdwn",
+			"text": fmt.Sprintf("*Page*\n<%s|%s>", slackEscaper.Replace(*report.PageURL), slackEscaper.Replace(truncate(*report.PageURL, 80))),
+		})
+	}
+
+	blocks := []any{
+		map[string]any{
+			"type": "header",
+			"text": map[string]string{"type": "plain_text", "text": truncate(heading, 150)},
+		},
+		map[string]any{"type": "section", "fields": fields},
+		map[string]any{
+			"type": "section",
+			"text": map[string]string{"type": "mrkdwn", "text": slackEscaper.Replace(truncate(report.Description, 2900))},
+		},
+	}
+	for i, u := range report.ImageURLs {
+		blocks = append(blocks, map[string]any{
+			"type":      "image",
+			"image_url": u,
+			"alt_text":  fmt.Sprintf("attachment %d", i+1),
+		})
+	}
+	blocks = append(blocks, map[string]any{
+		"type": "context",
+		"elements": []map[string]string{
+			{"type": "mrkdwn", "text": "Event ID: `" + report.ID + "`"},
+		},
+	})
+
+	return postJSON(ctx, client, rt.URL, map[string]any{
+		"text":   truncate(heading, 150), // notification fallback
+		"blocks": blocks,
+	})
+}
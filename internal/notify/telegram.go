@@ -0,0 +1,109 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/devrimsoft/bug-notifications-api/internal/config"
+	"github.com/devrimsoft/bug-notifications-api/internal/model"
+)
+
+// Telegram length limits for captions and plain messages.
+const (
+	telegramCaptionLimit = 1024
+	telegramMessageLimit = 4096
+)
+
+// telegramSender returns a sender for the Bot API rooted at baseURL.
+// Reports with images are sent as a photo (first image) with an HTML caption;
+// others as a plain HTML message.
+func telegramSender(baseURL string) sender {
+	return func(ctx context.Context, client *http.Client, rt config.NotifyRoute, report *model.BugReport) error {
+		endpoint := fmt.Sprintf("%s/bot%s/", baseURL, rt.BotToken)
+
+		if len(report.ImageURLs) > 0 {
+			return postJSON(ctx, client, endpoint+"sendPhoto", map[string]any{
+				"chat_id":    rt.ChatID,
+				"photo":      report.ImageURLs[0],
+				"caption":    telegramText(report, telegramCaptionLimit),
+				"parse_mode": "HTML",
+			})
+		}
+		return postJSON(ctx, client, endpoint+"sendMessage", map[string]any{
+			"chat_id":                  rt.ChatID,
+			"text":                     telegramText(report, telegramMessageLimit),
+			"parse_mode":               "HTML",
+			"disable_web_page_preview": true,
+		})
+	}
+}
+
+// telegramText renders a report as Telegram HTML within the given length.
+// Markup is never cut in half: free text is shortened before it is escaped,
+// and image links that don't fit in half the room left by the header are
+// counted instead of listed, so the description is still shown.
+func telegramText(report *model.BugReport, limit int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "<b>%s: %s</b>\n", reportTypeLabel(report.ReportType), escapeWithin(report.Title, limit/8))
+	fmt.Fprintf(&b, "Site: %s\nCategory: %s\n", escapeWithin(report.SiteID, limit/16), escapeWithin(report.Category, limit/16))
+	if report.PageURL != nil && *report.PageURL != "" {
+		fmt.Fprintf(&b, "Page: %s\n", escapeWithin(*report.PageURL, limit/8))
+	}
+	footer := fmt.Sprintf("Event ID: <code>%s</code>\n\n", html.EscapeString(report.ID))
+
+	room := limit - runeLen(b.String()) - runeLen(footer)
+	linkRoom := room/2 - runeLen(moreImages(len(report.ImageURLs)))
+	shown := 0
+	for i, u := range report.ImageURLs {
+		link := fmt.Sprintf("<a href=\"%s\">Image %d</a>\n", html.EscapeString(u), i+1)
+		if runeLen(link) > linkRoom {
+			break
+		}
+		b.WriteString(link)
+		linkRoom -= runeLen(link)
+		room -= runeLen(link)
+		shown++
+	}
+	if rest := len(report.ImageURLs) - shown; rest > 0 {
+		more := moreImages(rest)
+		b.WriteString(more)
+		room -= runeLen(more)
+	}
+	b.WriteString(footer)
+
+	return b.String() + escapeWithin(report.Description, room)
+}
+
+func moreImages(n int) string {
+	return fmt.Sprintf("+%d more images\n", n)
+}
+
+// escapeWithin HTML-escapes s, shortened with an ellipsis so the escaped
+// text is at most n runes.
+func escapeWithin(s string, n int) string {
+	if e := html.EscapeString(s); runeLen(e) <= n {
+		return e
+	}
+	var b strings.Builder
+	used := 0
+	for _, r := range s {
+		e := html.EscapeString(string(r))
+		if used+runeLen(e) > n-1 {
+			break
+		}
+		b.WriteString(e)
+		used += runeLen(e)
+	}
+	if n > 0 {
+		b.WriteString("…")
+	}
+	return b.String()
+}
+
+func runeLen(s string) int {
+	return utf8.RuneCountInString(s)
+}
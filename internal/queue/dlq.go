@@ -0,0 +1,188 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/devrimsoft/bug-notifications-api/internal/model"
+	"github.com/redis/go-redis/v9"
+)
+
+// replayScript atomically removes one entry from the DLQ, appends its
+// replacement payload to the stream and marks the event queued again. Does
+// nothing if the entry is gone, so concurrent replays of the same message
+// cannot duplicate it. The upload record of the event is dropped, so the
+// files are uploaded again from their staged copies: uploads made before the
+// message died may have been removed by the orphan reconciler since.
+//
+// KEYS[1]: DLQ list
+// KEYS[2]: stream
+// KEYS[3]: event state key
+// KEYS[4]: upload record key
+// ARGV[1]: raw DLQ entry
+// ARGV[2]: payload to enqueue
+// ARGV[3]: payload field name
+// ARGV[4]: queued state
+//
+// Returns 1 if the entry was replayed, 0 if it was not found.
+var replayScript = redis.NewScript(`
+if redis.call('LREM', KEYS[1], 1, ARGV[1]) == 0 then
+    return 0
+end
+redis.call('XADD', KEYS[2], '*', ARGV[3], ARGV[2])
+redis.call('SET', KEYS[3], ARGV[4], 'XX', 'KEEPTTL')
+redis.call('DEL', KEYS[4])
+return 1
+`)
+
+// DLQEntry is a dead-lettered message together with its raw list value.
+// Message is nil when the raw value cannot be decoded.
+type DLQEntry struct {
+	Raw     string
+	Message *model.QueueMessage
+}
+
+// FailedAt returns when the message last failed, falling back to the time it
+// was received for entries written before failures were timestamped.
+func (e DLQEntry) FailedAt() time.Time {
+	if e.Message == nil {
+		return time.Time{}
+	}
+	ts := e.Message.FailedAt
+	if ts == "" {
+		ts = e.Message.ReceivedAt
+	}
+	t, _ := time.Parse(time.RFC3339, ts)
+	return t
+}
+
+// DLQ gives operators access to messages that exhausted their retries.
+type DLQ struct {
+	rdb *redis.Client
+}
+
+func NewDLQ(rdb *redis.Client) *DLQ {
+	return &DLQ{rdb: rdb}
+}
+
+// List returns all DLQ entries, most recently failed first.
+func (d *DLQ) List(ctx context.Context) ([]DLQEntry, error) {
+	raws, err := d.rdb.LRange(ctx, DLQQueue, 0, -1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("list dlq: %w", err)
+	}
+
+	entries := make([]DLQEntry, 0, len(raws))
+	for _, raw := range raws {
+		entry := DLQEntry{Raw: raw}
+		var msg model.QueueMessage
+		if json.Unmarshal([]byte(raw), &msg) == nil {
+			entry.Message = &msg
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// Find returns the DLQ entry with the given event ID, or nil if there is none.
+// It reads the whole DLQ; it is meant for operators, not for request paths.
+func (d *DLQ) Find(ctx context.Context, eventID string) (*DLQEntry, error) {
+	entries, err := d.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		if e.Message != nil && e.Message.EventID == eventID {
+			return &e, nil
+		}
+	}
+	return nil, nil
+}
+
+// Replay moves the message with the given event ID back onto the main stream
+// with its retry state reset. Returns false if no such message is in the DLQ.
+func (d *DLQ) Replay(ctx context.Context, eventID string) (bool, error) {
+	entry, err := d.Find(ctx, eventID)
+	if err != nil || entry == nil {
+		return false, err
+	}
+	return d.replay(ctx, *entry)
+}
+
+// ReplayAll moves every decodable DLQ message back onto the main stream and
+// returns how many were replayed.
+func (d *DLQ) ReplayAll(ctx context.Context) (int, error) {
+	entries, err := d.List(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	replayed := 0
+	for _, e := range entries {
+		if e.Message == nil {
+			continue
+		}
+		ok, err := d.replay(ctx, e)
+		if err != nil {
+			return replayed, err
+		}
+		if ok {
+			replayed++
+		}
+	}
+	return replayed, nil
+}
+
+func (d *DLQ) replay(ctx context.Context, e DLQEntry) (bool, error) {
+	msg := *e.Message
+	msg.RetryCount = 0
+	msg.NextAttemptAt = ""
+	msg.LastError = ""
+	msg.FailedAt = ""
+
+	data, err := json.Marshal(&msg)
+	if err != nil {
+		return false, fmt.Errorf("marshal replay message: %w", err)
+	}
+
+	n, err := replayScript.Run(ctx, d.rdb, []string{DLQQueue, MainStream, eventKeyPrefix + msg.EventID, uploadsKeyPrefix + msg.EventID},
+		e.Raw, data, payloadField, string(model.StateQueued),
+	).Int()
+	if err != nil {
+		return false, fmt.Errorf("replay %s: %w", msg.EventID, err)
+	}
+	return n == 1, nil
+}
+
+// Purge deletes DLQ entries that failed before the cutoff, with their staged
+// files, and returns how many were removed. Undecodable entries are left
+// alone. Files already uploaded for a purged entry are left to the orphan
+// reconciler.
+func (d *DLQ) Purge(ctx context.Context, before time.Time) (int, error) {
+	entries, err := d.List(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	purged := 0
+	for _, e := range entries {
+		failedAt := e.FailedAt()
+		if failedAt.IsZero() || !failedAt.Before(before) {
+			continue
+		}
+		n, err := d.rdb.LRem(ctx, DLQQueue, 1, e.Raw).Result()
+		if err != nil {
+			return purged, fmt.Errorf("purge dlq: %w", err)
+		}
+		if n == 0 {
+			continue // replayed or purged concurrently
+		}
+		if err := d.rdb.Del(ctx, stagedKeys(e.Message.EventID, len(e.Message.StagedFiles))...).Err(); err != nil {
+			return purged, fmt.Errorf("purge staged files: %w", err)
+		}
+		purged += int(n)
+	}
+	return purged, nil
+}
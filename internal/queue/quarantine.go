@@ -0,0 +1,106 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/devrimsoft/bug-notifications-api/internal/model"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// QuarantineQueue holds stream payloads that could not be decoded into a
+// QueueMessage, so a bad schema rollout does not silently drop reports.
+const QuarantineQueue = "bug_reports:quarantine"
+
+// ErrQuarantined is returned by Dequeue when the delivered payload could not
+// be decoded and was moved to QuarantineQueue instead.
+var ErrQuarantined = errors.New("undecodable message quarantined")
+
+// QuarantineEntry is one undecodable payload with the reason it was rejected.
+type QuarantineEntry struct {
+	StreamID      string `json:"stream_id"`
+	Raw           string `json:"raw"`
+	Error         string `json:"error"`
+	QuarantinedAt string `json:"quarantined_at"`
+}
+
+// quarantine moves an undecodable stream entry to QuarantineQueue and
+// acknowledges it in the same transaction. If this fails the entry stays
+// pending and will be reclaimed later, so it is never dropped. If the event
+// ID can still be read, the event is marked quarantined.
+func (c *Consumer) quarantine(ctx context.Context, id, raw string, cause error) error {
+	data, err := json.Marshal(QuarantineEntry{
+		StreamID:      id,
+		Raw:           raw,
+		Error:         cause.Error(),
+		QuarantinedAt: time.Now().UTC().Format(time.RFC3339),
+	})
+	if err != nil {
+		return fmt.Errorf("marshal quarantine entry: %w", err)
+	}
+
+	_, err = c.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.LPush(ctx, QuarantineQueue, data)
+		if eventID := quarantinedEventID(raw); eventID != "" {
+			setEventState(ctx, pipe, eventID, model.StateQuarantined)
+		}
+		pipe.XAck(ctx, MainStream, ConsumerGroup, id)
+		pipe.XDel(ctx, MainStream, id)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("quarantine %s: %w", id, err)
+	}
+	return fmt.Errorf("%w: stream id %s: %v", ErrQuarantined, id, cause)
+}
+
+// quarantinedEventID returns the event ID of an undecodable payload, or ""
+// if it has none. A payload that fails to decode because of one bad field
+// usually still has a readable event_id.
+func quarantinedEventID(raw string) string {
+	var v struct {
+		EventID any `json:"event_id"`
+	}
+	json.Unmarshal([]byte(raw), &v)
+	id, _ := v.EventID.(string)
+	if _, err := uuid.Parse(id); err != nil {
+		return ""
+	}
+	return id
+}
+
+// Quarantine gives operators read access to quarantined payloads.
+type Quarantine struct {
+	rdb *redis.Client
+}
+
+func NewQuarantine(rdb *redis.Client) *Quarantine {
+	return &Quarantine{rdb: rdb}
+}
+
+// Length returns the number of quarantined payloads.
+func (q *Quarantine) Length(ctx context.Context) (int64, error) {
+	return q.rdb.LLen(ctx, QuarantineQueue).Result()
+}
+
+// List returns all quarantined payloads, most recent first.
+func (q *Quarantine) List(ctx context.Context) ([]QuarantineEntry, error) {
+	raws, err := q.rdb.LRange(ctx, QuarantineQueue, 0, -1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("list quarantine: %w", err)
+	}
+
+	entries := make([]QuarantineEntry, 0, len(raws))
+	for _, raw := range raws {
+		var e QuarantineEntry
+		if err := json.Unmarshal([]byte(raw), &e); err != nil {
+			e = QuarantineEntry{Raw: raw, Error: "unreadable quarantine entry"}
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
@@ -3,19 +3,62 @@ package queue
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"os"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/devrimsoft/bug-notifications-api/internal/model"
+	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
 )
 
 const (
-	MainQueue = "bug_reports:queue"
-	DLQQueue  = "bug_reports:dlq"
-	MaxRetry  = 5
+	MainStream    = "bug_reports:stream"
+	ConsumerGroup = "bug_reports:workers"
+	DLQQueue      = "bug_reports:dlq"
+
+	// LegacyQueue is the BRPOP list used before the move to Redis Streams.
+	// Anything still in it is drained into MainStream by the consumer.
+	LegacyQueue = "bug_reports:queue"
+
+	// ClaimMinIdle is how long a delivered message may stay unacknowledged
+	// before another consumer assumes its worker died and reclaims it.
+	ClaimMinIdle = 2 * time.Minute
+
+	// claimInterval throttles how often a consumer scans for abandoned
+	// messages and leftover legacy list entries.
+	claimInterval = 30 * time.Second
+
+	// payloadField is the stream entry field holding the JSON message.
+	payloadField = "data"
+
+	// eventKeyPrefix + event ID holds the model.ReportState of an event
+	// accepted by the API, so its status can be looked up while it is still
+	// in the queue, the DLQ or quarantine.
+	eventKeyPrefix = "bug_reports:event:"
+
+	// EventTTL is how long an accepted event stays known to State.
+	EventTTL = 7 * 24 * time.Hour
 )
 
+// drainLegacyScript atomically moves one entry from the legacy list to the stream.
+//
+// KEYS[1]: legacy list
+// KEYS[2]: stream
+// ARGV[1]: payload field name
+//
+// Returns the moved payload, or nil when the list is empty.
+var drainLegacyScript = redis.NewScript(`
+local v = redis.call('RPOP', KEYS[1])
+if v then
+    redis.call('XADD', KEYS[2], '*', ARGV[1], v)
+end
+return v
+`)
+
 type Producer struct {
 	rdb *redis.Client
 }
@@ -24,56 +67,271 @@ func NewProducer(rdb *redis.Client) *Producer {
 	return &Producer{rdb: rdb}
 }
 
-// Enqueue pushes a message to the main queue.
-func (p *Producer) Enqueue(ctx context.Context, msg *model.QueueMessage) error {
+// Enqueue appends a message to the main stream and records its event ID
+// as accepted. files holds the contents of msg.StagedFiles, in order; they
+// are staged in the same transaction for the worker to upload.
+func (p *Producer) Enqueue(ctx context.Context, msg *model.QueueMessage, files [][]byte) error {
+	if len(files) != len(msg.StagedFiles) {
+		return fmt.Errorf("enqueue: %d staged files but %d file contents", len(msg.StagedFiles), len(files))
+	}
 	data, err := json.Marshal(msg)
 	if err != nil {
 		return fmt.Errorf("marshal queue message: %w", err)
 	}
-	return p.rdb.LPush(ctx, MainQueue, data).Err()
+	_, err = p.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, f := range files {
+			pipe.Set(ctx, stagedFileKey(msg.EventID, i), f, StagedFileTTL)
+		}
+		pipe.XAdd(ctx, streamAddArgs(data))
+		pipe.Set(ctx, eventKeyPrefix+msg.EventID, string(model.StateQueued), EventTTL)
+		return nil
+	})
+	return err
+}
+
+// State returns where an event enqueued within the last EventTTL is while it
+// is not stored: queued (including retries), dead-lettered or quarantined.
+// Returns "" for unknown events.
+func (p *Producer) State(ctx context.Context, eventID string) (model.ReportState, error) {
+	v, err := p.rdb.Get(ctx, eventKeyPrefix+eventID).Result()
+	if err == redis.Nil {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("get event state: %w", err)
+	}
+	switch state := model.ReportState(v); state {
+	case model.StateDeadLettered, model.StateQuarantined:
+		return state, nil
+	}
+	// Events accepted before states were recorded hold their receive time
+	return model.StateQueued, nil
+}
+
+// setEventState records a new state for a known event. Unknown or expired
+// events are left alone, and the key keeps its TTL.
+func setEventState(ctx context.Context, pipe redis.Pipeliner, eventID string, state model.ReportState) {
+	pipe.SetXX(ctx, eventKeyPrefix+eventID, string(state), redis.KeepTTL)
 }
 
+// Consumer reads from the main stream as a member of ConsumerGroup.
+// Messages stay pending until Ack or Requeue, so a worker that dies mid-processing
+// does not lose them: after ClaimMinIdle they are reclaimed by a live consumer.
+//
+// A single Consumer is safe for concurrent use by several worker goroutines;
+// they share one consumer name in the group.
 type Consumer struct {
-	rdb *redis.Client
+	rdb   *redis.Client
+	name  string
+	retry RetryPolicy
+
+	mu          sync.Mutex
+	claimCursor string
+	nextClaim   time.Time
+}
+
+func NewConsumer(rdb *redis.Client, retry RetryPolicy) *Consumer {
+	host, _ := os.Hostname()
+	if host == "" {
+		host = "worker"
+	}
+	return &Consumer{
+		rdb:         rdb,
+		name:        fmt.Sprintf("%s-%d-%s", host, os.Getpid(), uuid.New().String()[:8]),
+		retry:       retry,
+		claimCursor: "0-0",
+	}
+}
+
+// Setup creates the consumer group (and stream) if needed and drains any
+// messages left in the legacy list. Call once before starting workers.
+func (c *Consumer) Setup(ctx context.Context) error {
+	// Start at "0" so entries added before the group existed are still delivered.
+	err := c.rdb.XGroupCreateMkStream(ctx, MainStream, ConsumerGroup, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return fmt.Errorf("create consumer group: %w", err)
+	}
+
+	if _, err := c.DrainLegacy(ctx); err != nil {
+		return err
+	}
+	return nil
 }
 
-func NewConsumer(rdb *redis.Client) *Consumer {
-	return &Consumer{rdb: rdb}
+// DrainLegacy moves every message from the legacy list into the main stream,
+// oldest first, and returns how many were moved.
+func (c *Consumer) DrainLegacy(ctx context.Context) (int, error) {
+	moved := 0
+	for {
+		err := drainLegacyScript.Run(ctx, c.rdb, []string{LegacyQueue, MainStream}, payloadField).Err()
+		if err == redis.Nil {
+			return moved, nil
+		}
+		if err != nil {
+			return moved, fmt.Errorf("drain legacy queue: %w", err)
+		}
+		moved++
+	}
 }
 
 // Dequeue blocks until a message is available, then returns it.
+// Abandoned messages from dead consumers are returned before new ones.
+// The returned message must be passed to Ack or Requeue once handled.
 func (c *Consumer) Dequeue(ctx context.Context) (*model.QueueMessage, error) {
-	result, err := c.rdb.BRPop(ctx, 5*time.Second, MainQueue).Result()
+	if msg, err := c.claimAbandoned(ctx); msg != nil || err != nil {
+		return msg, err
+	}
+
+	streams, err := c.rdb.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    ConsumerGroup,
+		Consumer: c.name,
+		Streams:  []string{MainStream, ">"},
+		Count:    1,
+		Block:    5 * time.Second,
+	}).Result()
 	if err != nil {
 		if err == redis.Nil {
 			return nil, nil // timeout, no message
 		}
-		return nil, fmt.Errorf("brpop: %w", err)
+		return nil, fmt.Errorf("xreadgroup: %w", err)
+	}
+
+	for _, s := range streams {
+		for _, m := range s.Messages {
+			return c.decode(ctx, m)
+		}
+	}
+	return nil, nil
+}
+
+// claimAbandoned takes over one message that has been pending longer than
+// ClaimMinIdle. It also drains the legacy list so entries pushed by old API
+// instances during a rolling deploy are not stranded. Runs at most once per
+// claimInterval unless the previous scan found something.
+func (c *Consumer) claimAbandoned(ctx context.Context) (*model.QueueMessage, error) {
+	c.mu.Lock()
+	if time.Now().Before(c.nextClaim) {
+		c.mu.Unlock()
+		return nil, nil
+	}
+	start := c.claimCursor
+	c.mu.Unlock()
+
+	if start == "0-0" {
+		if _, err := c.DrainLegacy(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	msgs, next, err := c.rdb.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+		Stream:   MainStream,
+		Group:    ConsumerGroup,
+		Consumer: c.name,
+		MinIdle:  ClaimMinIdle,
+		Start:    start,
+		Count:    1,
+	}).Result()
+	if err != nil {
+		return nil, fmt.Errorf("xautoclaim: %w", err)
+	}
+
+	c.mu.Lock()
+	c.claimCursor = next
+	if len(msgs) == 0 && next == "0-0" {
+		// Full scan finished with nothing left to claim.
+		c.nextClaim = time.Now().Add(claimInterval)
+	}
+	c.mu.Unlock()
+
+	if len(msgs) == 0 {
+		return nil, nil
+	}
+	return c.decode(ctx, msgs[0])
+}
+
+// decode turns a stream entry into a QueueMessage carrying its stream ID.
+// Entries without a payload (deleted before they were claimed) are acknowledged and skipped.
+// Payloads that fail to decode are quarantined and reported as ErrQuarantined.
+func (c *Consumer) decode(ctx context.Context, m redis.XMessage) (*model.QueueMessage, error) {
+	raw, ok := m.Values[payloadField].(string)
+	if !ok {
+		return nil, c.ack(ctx, m.ID)
 	}
 
-	// result[0] is key name, result[1] is value
 	var msg model.QueueMessage
-	if err := json.Unmarshal([]byte(result[1]), &msg); err != nil {
-		return nil, fmt.Errorf("unmarshal queue message: %w", err)
+	if err := json.Unmarshal([]byte(raw), &msg); err != nil {
+		return nil, c.quarantine(ctx, m.ID, raw, err)
 	}
+	msg.StreamID = m.ID
 	return &msg, nil
 }
 
-// Requeue puts a failed message back for retry or into DLQ.
-func (c *Consumer) Requeue(ctx context.Context, msg *model.QueueMessage) error {
-	msg.RetryCount++
-	data, err := json.Marshal(msg)
+// Ack marks a message as successfully processed and removes it from the stream.
+func (c *Consumer) Ack(ctx context.Context, msg *model.QueueMessage) error {
+	if msg.StreamID == "" {
+		return errors.New("ack: message has no stream id")
+	}
+	return c.ack(ctx, msg.StreamID)
+}
+
+func (c *Consumer) ack(ctx context.Context, id string) error {
+	_, err := c.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.XAck(ctx, MainStream, ConsumerGroup, id)
+		pipe.XDel(ctx, MainStream, id)
+		return nil
+	})
 	if err != nil {
-		return fmt.Errorf("marshal retry message: %w", err)
+		return fmt.Errorf("ack %s: %w", id, err)
+	}
+	return nil
+}
+
+// Requeue schedules a failed message for a delayed retry, or moves it to the
+// DLQ once it has failed RetryPolicy.MaxRetry times. cause is recorded on the
+// message so DLQ entries show why they died. The original stream entry is
+// acknowledged in the same transaction. dead reports whether the message went
+// to the DLQ; its staged files are then kept for DeadStagedFileTTL so a replay
+// can still store them.
+func (c *Consumer) Requeue(ctx context.Context, msg *model.QueueMessage, cause error) (dead bool, err error) {
+	msg.RetryCount++
+	msg.FailedAt = time.Now().UTC().Format(time.RFC3339)
+	if cause != nil {
+		msg.LastError = cause.Error()
+	}
+	dead = msg.RetryCount >= c.retry.MaxRetry
+
+	var due time.Time
+	if dead {
+		msg.NextAttemptAt = ""
+	} else {
+		due = time.Now().Add(c.retry.Delay(msg.RetryCount))
+		msg.NextAttemptAt = due.UTC().Format(time.RFC3339)
 	}
 
-	if msg.RetryCount >= MaxRetry {
-		// Move to dead letter queue
-		return c.rdb.LPush(ctx, DLQQueue, data).Err()
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return dead, fmt.Errorf("marshal retry message: %w", err)
 	}
 
-	// Requeue to main queue
-	return c.rdb.LPush(ctx, MainQueue, data).Err()
+	_, err = c.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		if dead {
+			// Move to dead letter queue
+			pipe.LPush(ctx, DLQQueue, data)
+			setEventState(ctx, pipe, msg.EventID, model.StateDeadLettered)
+			for _, k := range stagedKeys(msg.EventID, len(msg.StagedFiles)) {
+				pipe.Expire(ctx, k, DeadStagedFileTTL)
+			}
+		} else {
+			// Park in the retry set until due; PromoteDue moves it back
+			pipe.ZAdd(ctx, RetryQueue, redis.Z{Score: float64(due.UnixMilli()), Member: data})
+		}
+		if msg.StreamID != "" {
+			pipe.XAck(ctx, MainStream, ConsumerGroup, msg.StreamID)
+			pipe.XDel(ctx, MainStream, msg.StreamID)
+		}
+		return nil
+	})
+	return dead, err
 }
 
 // DLQLength returns the number of messages in the dead letter queue.
@@ -81,7 +339,22 @@ func (c *Consumer) DLQLength(ctx context.Context) (int64, error) {
 	return c.rdb.LLen(ctx, DLQQueue).Result()
 }
 
-// QueueLength returns the number of messages in the main queue.
+// QueueLength returns the number of messages waiting or in flight in the main
+// stream, waiting for a delayed retry, or not yet drained from the legacy list.
 func (c *Consumer) QueueLength(ctx context.Context) (int64, error) {
-	return c.rdb.LLen(ctx, MainQueue).Result()
+	pipe := c.rdb.Pipeline()
+	streamLen := pipe.XLen(ctx, MainStream)
+	retryLen := pipe.ZCard(ctx, RetryQueue)
+	legacyLen := pipe.LLen(ctx, LegacyQueue)
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return 0, err
+	}
+	return streamLen.Val() + retryLen.Val() + legacyLen.Val(), nil
+}
+
+func streamAddArgs(data []byte) *redis.XAddArgs {
+	return &redis.XAddArgs{
+		Stream: MainStream,
+		Values: map[string]any{payloadField: data},
+	}
 }
@@ -0,0 +1,149 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/devrimsoft/bug-notifications-api/internal/model"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestClaimAbandoned(t *testing.T) {
+	ctx := context.Background()
+	mr, rdb := newTestRedis(t)
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	mr.SetTime(now)
+
+	dead := NewConsumer(rdb, RetryPolicy{})
+	if err := dead.Setup(ctx); err != nil {
+		t.Fatal(err)
+	}
+	const id = "3d5e7f90-1a2b-4c3d-8e4f-5a6b7c8d9e0f"
+	if err := NewProducer(rdb).Enqueue(ctx, &model.QueueMessage{EventID: id}, nil); err != nil {
+		t.Fatal(err)
+	}
+	// Read but never acknowledged: its worker died mid-processing
+	read, err := dead.Dequeue(ctx)
+	if err != nil || read == nil {
+		t.Fatalf("Dequeue = %v, %v", read, err)
+	}
+
+	live := NewConsumer(rdb, RetryPolicy{})
+	if msg, err := live.claimAbandoned(ctx); msg != nil || err != nil {
+		t.Fatalf("claimAbandoned before ClaimMinIdle = %v, %v; want nothing", msg, err)
+	}
+	if live.nextClaim.IsZero() {
+		t.Error("an empty scan did not throttle the next one")
+	}
+
+	mr.SetTime(now.Add(ClaimMinIdle + time.Second))
+	if msg, err := live.claimAbandoned(ctx); msg != nil || err != nil {
+		t.Fatalf("throttled claimAbandoned = %v, %v; want nothing", msg, err)
+	}
+	live.nextClaim = time.Time{}
+	msg, err := live.claimAbandoned(ctx)
+	if err != nil || msg == nil {
+		t.Fatalf("claimAbandoned after ClaimMinIdle = %v, %v", msg, err)
+	}
+	if msg.EventID != id || msg.StreamID != read.StreamID {
+		t.Errorf("claimed %s/%s, want %s/%s", msg.EventID, msg.StreamID, id, read.StreamID)
+	}
+
+	pending, err := rdb.XPendingExt(ctx, &redis.XPendingExtArgs{
+		Stream: MainStream, Group: ConsumerGroup, Start: "-", End: "+", Count: 10,
+	}).Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 1 || pending[0].Consumer != live.name {
+		t.Fatalf("pending = %+v, want one entry owned by %s", pending, live.name)
+	}
+
+	if err := live.Ack(ctx, msg); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := rdb.XLen(ctx, MainStream).Result(); n != 0 {
+		t.Errorf("stream length after Ack = %d", n)
+	}
+	live.nextClaim = time.Time{}
+	if msg, err := live.claimAbandoned(ctx); msg != nil || err != nil {
+		t.Errorf("claimAbandoned after Ack = %v, %v; want nothing", msg, err)
+	}
+}
+
+func TestDrainLegacy(t *testing.T) {
+	ctx := context.Background()
+	_, rdb := newTestRedis(t)
+
+	// The old API pushed with LPUSH and the old worker popped with BRPOP
+	push := func(ids ...string) {
+		t.Helper()
+		for _, id := range ids {
+			data, _ := json.Marshal(model.QueueMessage{EventID: id})
+			if err := rdb.LPush(ctx, LegacyQueue, data).Err(); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	ids := []string{
+		"0a1b2c3d-4e5f-4a6b-8c7d-8e9f0a1b2c3d",
+		"1b2c3d4e-5f6a-4b7c-8d8e-9f0a1b2c3d4e",
+		"2c3d4e5f-6a7b-4c8d-9e9f-0a1b2c3d4e5f",
+	}
+	push(ids...)
+
+	// Several workers starting at once drain the list between them
+	consumers := make([]*Consumer, 3)
+	var wg sync.WaitGroup
+	for i := range consumers {
+		consumers[i] = NewConsumer(rdb, RetryPolicy{})
+		wg.Add(1)
+		go func(c *Consumer) {
+			defer wg.Done()
+			if err := c.Setup(ctx); err != nil {
+				t.Error(err)
+			}
+		}(consumers[i])
+	}
+	wg.Wait()
+
+	if n, _ := rdb.LLen(ctx, LegacyQueue).Result(); n != 0 {
+		t.Errorf("legacy list length = %d after Setup", n)
+	}
+	if n, _ := rdb.XLen(ctx, MainStream).Result(); n != int64(len(ids)) {
+		t.Errorf("stream length = %d, want %d", n, len(ids))
+	}
+	if moved, err := consumers[0].DrainLegacy(ctx); moved != 0 || err != nil {
+		t.Errorf("second DrainLegacy = %d, %v; want 0", moved, err)
+	}
+
+	// An old API instance still pushing during a rolling deploy
+	late := "3d4e5f6a-7b8c-4d9e-8f0a-1b2c3d4e5f6a"
+	push(late)
+	if msg, err := consumers[0].claimAbandoned(ctx); msg != nil || err != nil {
+		t.Fatalf("claimAbandoned = %v, %v; want nothing to claim", msg, err)
+	}
+	if n, _ := rdb.LLen(ctx, LegacyQueue).Result(); n != 0 {
+		t.Errorf("legacy list length = %d after a claim scan", n)
+	}
+
+	// Every entry is delivered once, oldest first
+	for _, want := range append(ids, late) {
+		msg, err := consumers[1].Dequeue(ctx)
+		if err != nil || msg == nil {
+			t.Fatalf("Dequeue = %v, %v; want %s", msg, err, want)
+		}
+		if msg.EventID != want {
+			t.Errorf("Dequeue = %s, want %s", msg.EventID, want)
+		}
+		if err := consumers[1].Ack(ctx, msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n, _ := rdb.XLen(ctx, MainStream).Result(); n != 0 {
+		t.Errorf("stream length = %d after every entry was acknowledged", n)
+	}
+}
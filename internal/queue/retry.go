@@ -0,0 +1,77 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RetryQueue is a sorted set of failed messages waiting for their next
+// attempt, scored by due time in Unix milliseconds.
+const RetryQueue = "bug_reports:retry"
+
+// promoteBatch caps how many due messages one PromoteDue call moves.
+const promoteBatch = 100
+
+// promoteDueScript atomically moves due messages from the retry set to the stream.
+//
+// KEYS[1]: retry sorted set
+// KEYS[2]: stream
+// ARGV[1]: current time in milliseconds
+// ARGV[2]: max messages to move
+// ARGV[3]: payload field name
+//
+// Returns the number of messages moved.
+var promoteDueScript = redis.NewScript(`
+local items = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1], 'LIMIT', 0, tonumber(ARGV[2]))
+for _, v in ipairs(items) do
+    redis.call('XADD', KEYS[2], '*', ARGV[3], v)
+    redis.call('ZREM', KEYS[1], v)
+end
+return #items
+`)
+
+// RetryPolicy controls how failed messages are retried.
+// The n-th retry waits BaseDelay * 2^(n-1), capped at MaxDelay if set, reduced by up
+// to Jitter (a 0..1 fraction) at random so retries from a burst of failures
+// don't all land at the same moment.
+type RetryPolicy struct {
+	MaxRetry  int
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	Jitter    float64
+}
+
+// Delay returns how long to wait before the given retry attempt (1-based).
+func (p RetryPolicy) Delay(attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+	d := p.BaseDelay
+	for i := 1; i < attempt && d < math.MaxInt64/2 && (p.MaxDelay <= 0 || d < p.MaxDelay); i++ {
+		d *= 2
+	}
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	if p.Jitter > 0 && d > 0 {
+		d -= time.Duration(rand.Float64() * p.Jitter * float64(d))
+	}
+	return d
+}
+
+// PromoteDue moves messages whose retry time has come back onto the main
+// stream and returns how many were moved. Safe to call from several workers.
+func (c *Consumer) PromoteDue(ctx context.Context) (int, error) {
+	n, err := promoteDueScript.Run(ctx, c.rdb, []string{RetryQueue, MainStream},
+		time.Now().UnixMilli(), promoteBatch, payloadField,
+	).Int()
+	if err != nil {
+		return 0, fmt.Errorf("promote due retries: %w", err)
+	}
+	return n, nil
+}
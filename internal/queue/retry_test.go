@@ -0,0 +1,49 @@
+package queue
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyDelay(t *testing.T) {
+	p := RetryPolicy{BaseDelay: 5 * time.Second, MaxDelay: time.Minute}
+
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{attempt: -1, want: 5 * time.Second},
+		{attempt: 0, want: 5 * time.Second},
+		{attempt: 1, want: 5 * time.Second},
+		{attempt: 2, want: 10 * time.Second},
+		{attempt: 3, want: 20 * time.Second},
+		{attempt: 4, want: 40 * time.Second},
+		{attempt: 5, want: time.Minute},
+		{attempt: 1000, want: time.Minute},
+	}
+	for _, tt := range tests {
+		if got := p.Delay(tt.attempt); got != tt.want {
+			t.Errorf("Delay(%d) = %v, want %v", tt.attempt, got, tt.want)
+		}
+	}
+}
+
+func TestRetryPolicyDelayUncapped(t *testing.T) {
+	p := RetryPolicy{BaseDelay: time.Second}
+	if got, want := p.Delay(4), 8*time.Second; got != want {
+		t.Errorf("Delay(4) = %v, want %v", got, want)
+	}
+	if got := p.Delay(1000); got <= 0 {
+		t.Errorf("Delay(1000) = %v, want a positive duration", got)
+	}
+}
+
+func TestRetryPolicyDelayJitter(t *testing.T) {
+	p := RetryPolicy{BaseDelay: 10 * time.Second, MaxDelay: time.Minute, Jitter: 0.2}
+	for range 1000 {
+		d := p.Delay(2)
+		if d <= 16*time.Second || d > 20*time.Second {
+			t.Fatalf("Delay(2) = %v, want in (16s, 20s]", d)
+		}
+	}
+}
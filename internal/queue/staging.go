@@ -0,0 +1,121 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/devrimsoft/bug-notifications-api/internal/model"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// stagedFileKeyPrefix + event ID + ":" + index holds a file accepted by
+	// the API until the worker has put it in storage.
+	stagedFileKeyPrefix = "bug_reports:staged:"
+
+	// uploadsKeyPrefix + event ID is a hash of file index -> stored
+	// attachment (JSON), so a retried message only uploads the files that
+	// are left.
+	uploadsKeyPrefix = "bug_reports:uploads:"
+
+	// StagedFileTTL is how long staged files wait for the worker. It covers
+	// the retry schedule and short worker outages. Files that expire are
+	// counted as lost on the report, so file bytes do not sit in Redis for
+	// days.
+	StagedFileTTL = time.Hour
+
+	// DeadStagedFileTTL is how long the staged files and upload record of a
+	// dead-lettered message are kept, so a replay after an outage still
+	// stores them. dlq purge drops them sooner.
+	DeadStagedFileTTL = 24 * time.Hour
+)
+
+func stagedFileKey(eventID string, i int) string {
+	return stagedFileKeyPrefix + eventID + ":" + strconv.Itoa(i)
+}
+
+// StagedFile returns the contents of a staged file, or nil if it has
+// expired or was already cleared.
+func (c *Consumer) StagedFile(ctx context.Context, eventID string, i int) ([]byte, error) {
+	data, err := c.rdb.Get(ctx, stagedFileKey(eventID, i)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get staged file: %w", err)
+	}
+	return data, nil
+}
+
+// Uploads returns the images of an event already stored by an earlier
+// attempt, by index. Records that can't be read are left out, so those
+// images are uploaded again.
+func (c *Consumer) Uploads(ctx context.Context, eventID string) (map[int]model.Attachment, error) {
+	fields, err := c.rdb.HGetAll(ctx, uploadsKeyPrefix+eventID).Result()
+	if err != nil {
+		return nil, fmt.Errorf("get uploads: %w", err)
+	}
+	uploads := make(map[int]model.Attachment, len(fields))
+	for k, v := range fields {
+		i, err := strconv.Atoi(k)
+		if err != nil {
+			continue
+		}
+		var a model.Attachment
+		if err := json.Unmarshal([]byte(v), &a); err != nil || a.URL == "" {
+			continue
+		}
+		uploads[i] = a
+	}
+	return uploads, nil
+}
+
+// RecordUpload remembers where a staged image was stored.
+func (c *Consumer) RecordUpload(ctx context.Context, eventID string, i int, a model.Attachment) error {
+	data, err := json.Marshal(a)
+	if err != nil {
+		return fmt.Errorf("marshal upload: %w", err)
+	}
+	key := uploadsKeyPrefix + eventID
+	_, err = c.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, key, strconv.Itoa(i), data)
+		pipe.Expire(ctx, key, StagedFileTTL)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("record upload: %w", err)
+	}
+	return nil
+}
+
+// ClearUploads forgets an event's stored files, so the next attempt uploads
+// them again from the staged copies.
+func (c *Consumer) ClearUploads(ctx context.Context, eventID string) error {
+	if err := c.rdb.Del(ctx, uploadsKeyPrefix+eventID).Err(); err != nil {
+		return fmt.Errorf("clear uploads: %w", err)
+	}
+	return nil
+}
+
+// stagedKeys returns the staged file keys and upload record key of an event
+// with n staged files.
+func stagedKeys(eventID string, n int) []string {
+	keys := []string{uploadsKeyPrefix + eventID}
+	for i := 0; i < n; i++ {
+		keys = append(keys, stagedFileKey(eventID, i))
+	}
+	return keys
+}
+
+// ClearStaged deletes an event's staged files and upload record once the
+// URLs are saved on the report.
+func (c *Consumer) ClearStaged(ctx context.Context, eventID string, n int) error {
+	if err := c.rdb.Del(ctx, stagedKeys(eventID, n)...).Err(); err != nil {
+		return fmt.Errorf("clear staged files: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/devrimsoft/bug-notifications-api/internal/model"
+)
+
+func TestStagedFiles(t *testing.T) {
+	ctx := context.Background()
+	mr, rdb := newTestRedis(t)
+	p := NewProducer(rdb)
+	c := NewConsumer(rdb, RetryPolicy{})
+
+	const id = "0b9a3c57-41f4-4f0e-8d6e-5a2b6b0e9c10"
+	msg := &model.QueueMessage{EventID: id, StagedFiles: []model.StagedFile{
+		{ObjectKey: "example.com/a.png", ContentType: "image/png"},
+		{ObjectKey: "example.com/b.log", ContentType: "text/plain", Kind: model.AttachmentLog},
+	}}
+	if err := p.Enqueue(ctx, msg, [][]byte{[]byte("png"), []byte("log")}); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Enqueue(ctx, msg, nil); err == nil {
+		t.Error("Enqueue accepted a message without its file contents")
+	}
+
+	for i, want := range []string{"png", "log"} {
+		data, err := c.StagedFile(ctx, id, i)
+		if err != nil || string(data) != want {
+			t.Errorf("StagedFile(%d) = %q, %v; want %q", i, data, err, want)
+		}
+		if ttl := mr.TTL(stagedFileKey(id, i)); ttl <= 0 || ttl > StagedFileTTL {
+			t.Errorf("staged file %d TTL = %v, want at most %v", i, ttl, StagedFileTTL)
+		}
+	}
+
+	a := model.Attachment{Kind: model.AttachmentImage, URL: "https://cdn.example.com/example.com/a.png"}
+	if err := c.RecordUpload(ctx, id, 0, a); err != nil {
+		t.Fatal(err)
+	}
+	if done, err := c.Uploads(ctx, id); err != nil || len(done) != 1 || done[0].URL != a.URL {
+		t.Errorf("Uploads = %v, %v", done, err)
+	}
+
+	if err := c.ClearStaged(ctx, id, len(msg.StagedFiles)); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := c.StagedFile(ctx, id, 0); err != nil || data != nil {
+		t.Errorf("after ClearStaged: StagedFile = %q, %v", data, err)
+	}
+	if done, _ := c.Uploads(ctx, id); len(done) != 0 {
+		t.Errorf("after ClearStaged: Uploads = %v", done)
+	}
+}
+
+func TestStagedFilesExpire(t *testing.T) {
+	ctx := context.Background()
+	mr, rdb := newTestRedis(t)
+	p := NewProducer(rdb)
+	c := NewConsumer(rdb, RetryPolicy{})
+
+	const id = "5e0e6a8f-9d37-4c55-b1a4-0f6f2c8d4e21"
+	msg := &model.QueueMessage{EventID: id, StagedFiles: []model.StagedFile{{ObjectKey: "example.com/a.png"}}}
+	if err := p.Enqueue(ctx, msg, [][]byte{[]byte("png")}); err != nil {
+		t.Fatal(err)
+	}
+
+	mr.FastForward(StagedFileTTL + time.Second)
+	if data, err := c.StagedFile(ctx, id, 0); err != nil || data != nil {
+		t.Errorf("expired StagedFile = %q, %v; want nil", data, err)
+	}
+	if s, _ := p.State(ctx, id); s != model.StateQueued {
+		t.Errorf("State = %q; the event outlives its staged files", s)
+	}
+}
+
+func TestStagedFilesDeadLettered(t *testing.T) {
+	ctx := context.Background()
+	mr, rdb := newTestRedis(t)
+	p := NewProducer(rdb)
+	c := NewConsumer(rdb, RetryPolicy{MaxRetry: 1})
+	if err := c.Setup(ctx); err != nil {
+		t.Fatal(err)
+	}
+	dlq := NewDLQ(rdb)
+
+	const id = "9c4d2b7a-6e1f-4a83-b5d0-3f8e7c1a2b64"
+	msg := &model.QueueMessage{EventID: id, StagedFiles: []model.StagedFile{{ObjectKey: "example.com/a.png"}}}
+	if err := p.Enqueue(ctx, msg, [][]byte{[]byte("png")}); err != nil {
+		t.Fatal(err)
+	}
+	deadLetter := func() {
+		t.Helper()
+		msg, err := c.Dequeue(ctx)
+		if err != nil || msg == nil {
+			t.Fatalf("Dequeue = %v, %v", msg, err)
+		}
+		if err := c.RecordUpload(ctx, id, 0, model.Attachment{URL: "https://cdn.example.com/example.com/a.png"}); err != nil {
+			t.Fatal(err)
+		}
+		if dead, err := c.Requeue(ctx, msg, errors.New("storage down")); err != nil || !dead {
+			t.Fatalf("Requeue = %v, %v", dead, err)
+		}
+	}
+
+	// The files outlive StagedFileTTL while the message waits in the DLQ
+	deadLetter()
+	for _, k := range []string{stagedFileKey(id, 0), uploadsKeyPrefix + id} {
+		if ttl := mr.TTL(k); ttl <= StagedFileTTL || ttl > DeadStagedFileTTL {
+			t.Errorf("%s TTL = %v, want more than %v", k, ttl, StagedFileTTL)
+		}
+	}
+	mr.FastForward(StagedFileTTL + time.Second)
+
+	// A replay uploads every file again from its staged copy
+	if ok, err := dlq.Replay(ctx, id); err != nil || !ok {
+		t.Fatalf("Replay = %v, %v", ok, err)
+	}
+	if data, err := c.StagedFile(ctx, id, 0); err != nil || string(data) != "png" {
+		t.Errorf("after replay: StagedFile = %q, %v", data, err)
+	}
+	if done, _ := c.Uploads(ctx, id); len(done) != 0 {
+		t.Errorf("after replay: Uploads = %v", done)
+	}
+
+	// A purge drops them with the message
+	deadLetter()
+	if n, err := dlq.Purge(ctx, time.Now().Add(time.Minute)); err != nil || n != 1 {
+		t.Fatalf("Purge = %d, %v", n, err)
+	}
+	for _, k := range []string{stagedFileKey(id, 0), uploadsKeyPrefix + id} {
+		if mr.Exists(k) {
+			t.Errorf("after purge: %s still exists", k)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/devrimsoft/bug-notifications-api/internal/model"
+	"github.com/redis/go-redis/v9"
+)
+
+func newTestRedis(t *testing.T) (*miniredis.Miniredis, *redis.Client) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+	return mr, rdb
+}
+
+func TestEventState(t *testing.T) {
+	ctx := context.Background()
+	mr, rdb := newTestRedis(t)
+	p := NewProducer(rdb)
+	c := NewConsumer(rdb, RetryPolicy{MaxRetry: 1})
+	if err := c.Setup(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	state := func(id string) model.ReportState {
+		t.Helper()
+		s, err := p.State(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+
+	const id = "6f1c8d5e-3b0a-4c4e-9a55-2f7f0f3d7a11"
+	if s := state(id); s != "" {
+		t.Fatalf("unknown event: state = %q", s)
+	}
+
+	if err := p.Enqueue(ctx, &model.QueueMessage{EventID: id}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if s := state(id); s != model.StateQueued {
+		t.Fatalf("after enqueue: state = %q", s)
+	}
+
+	msg, err := c.Dequeue(ctx)
+	if err != nil || msg == nil {
+		t.Fatalf("Dequeue = %v, %v", msg, err)
+	}
+	if dead, err := c.Requeue(ctx, msg, errors.New("db down")); err != nil || !dead {
+		t.Fatalf("Requeue = %v, %v", dead, err)
+	}
+	if s := state(id); s != model.StateDeadLettered {
+		t.Fatalf("after dead letter: state = %q", s)
+	}
+	if ttl := mr.TTL(eventKeyPrefix + id); ttl <= 0 || ttl > EventTTL {
+		t.Fatalf("TTL = %v, want kept", ttl)
+	}
+
+	if ok, err := NewDLQ(rdb).Replay(ctx, id); err != nil || !ok {
+		t.Fatalf("Replay = %v, %v", ok, err)
+	}
+	if s := state(id); s != model.StateQueued {
+		t.Fatalf("after replay: state = %q", s)
+	}
+
+	// Quarantined: event_id readable, another field of the wrong type
+	mr.FastForward(time.Second)
+	if _, err := rdb.XAdd(ctx, streamAddArgs([]byte(`{"event_id":"`+id+`","retry_count":"x"}`))).Result(); err != nil {
+		t.Fatal(err)
+	}
+	for {
+		msg, err := c.Dequeue(ctx)
+		if errors.Is(err, ErrQuarantined) {
+			break
+		}
+		if err != nil || msg == nil {
+			t.Fatalf("Dequeue = %v, %v", msg, err)
+		}
+		c.Ack(ctx, msg)
+	}
+	if s := state(id); s != model.StateQuarantined {
+		t.Fatalf("after quarantine: state = %q", s)
+	}
+}
+
+func TestEventStateUnknownEvents(t *testing.T) {
+	ctx := context.Background()
+	mr, rdb := newTestRedis(t)
+	p := NewProducer(rdb)
+
+	// Keys written before states were recorded hold the receive time
+	const legacy = "0c7e9a42-5d1f-4b3e-8f0a-1d2c3b4a5e6f"
+	mr.Set(eventKeyPrefix+legacy, "2026-01-02T03:04:05Z")
+	if s, err := p.State(ctx, legacy); err != nil || s != model.StateQueued {
+		t.Fatalf("legacy event: State = %q, %v", s, err)
+	}
+
+	// A state change never brings back an expired event
+	const expired = "9a8b7c6d-5e4f-4a3b-8c2d-1e0f9a8b7c6d"
+	if _, err := rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		setEventState(ctx, pipe, expired, model.StateDeadLettered)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if mr.Exists(eventKeyPrefix + expired) {
+		t.Fatal("state change created an event key")
+	}
+}
+
+func TestQuarantinedEventID(t *testing.T) {
+	const id = "6f1c8d5e-3b0a-4c4e-9a55-2f7f0f3d7a11"
+	tests := []struct {
+		raw  string
+		want string
+	}{
+		{`{"event_id":"` + id + `","retry_count":"x"}`, id},
+		{`{"event_id":"` + id + `"`, ""},
+		{`{"event_id":42}`, ""},
+		{`{"event_id":"not-a-uuid"}`, ""},
+		{`{"title":"no id"}`, ""},
+		{`garbage`, ""},
+	}
+	for _, tt := range tests {
+		if got := quarantinedEventID(tt.raw); got != tt.want {
+			t.Errorf("quarantinedEventID(%q) = %q, want %q", tt.raw, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,360 @@
+// Package sites manages the sites table and the report taxonomy (the
+// report_types and report_categories tables) through the admin API and keeps
+// the configuration of every process in step with them.
+//
+// The rows are layered over SITES_FILE or ALLOWED_SITES (see
+// config.LoadWithStored). A change is announced on a Redis channel; every API
+// and worker process reloads the tables when it hears one, and every
+// ResyncInterval in case a message was missed.
+package sites
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/devrimsoft/bug-notifications-api/internal/config"
+	"github.com/devrimsoft/bug-notifications-api/internal/db"
+	"github.com/devrimsoft/bug-notifications-api/internal/model"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// ChangedChannel is the Redis channel site and taxonomy changes are
+	// published on.
+	ChangedChannel = "sites:changed"
+
+	// ResyncInterval is how often the table is reloaded without a change message.
+	ResyncInterval = 5 * time.Minute
+
+	MaxNameLength  = 100
+	MaxLabelLength = 100
+)
+
+var (
+	ErrNotFound    = errors.New("site not found")
+	ErrExists      = errors.New("site already exists")
+	ErrUnknownKind = errors.New("unknown taxonomy kind")
+)
+
+// InvalidError is returned when a change is malformed or would leave the
+// configuration invalid.
+type InvalidError struct {
+	Err error
+}
+
+func (e *InvalidError) Error() string { return e.Err.Error() }
+func (e *InvalidError) Unwrap() error { return e.Err }
+
+// domainPattern matches a lowercase host name with at least two labels.
+var domainPattern = regexp.MustCompile(`^([a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?\.)+[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+var (
+	taxonomyKeyPattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,49}$`)
+	languagePattern    = regexp.MustCompile(`^[a-z]{2,3}(-[a-z0-9]{2,8})*$`)
+)
+
+// taxonomyTables maps the kinds of the admin API to their tables.
+var taxonomyTables = map[string]string{
+	"report_types": db.TaxonomyReportTypes,
+	"categories":   db.TaxonomyCategories,
+}
+
+// Service manages the sites table.
+type Service struct {
+	repo *db.Repository
+	rdb  *redis.Client
+	live *config.Live
+}
+
+func NewService(repo *db.Repository, rdb *redis.Client, live *config.Live) *Service {
+	return &Service{repo: repo, rdb: rdb, live: live}
+}
+
+// List returns all rows of the sites table.
+func (s *Service) List(ctx context.Context) ([]model.Site, error) {
+	return s.repo.ListSites(ctx)
+}
+
+// Create adds a site. Without settings it accepts reports with the defaults
+// of the environment. Returns ErrExists or an *InvalidError when rejected.
+func (s *Service) Create(ctx context.Context, req model.SiteCreateRequest) (*model.Site, error) {
+	site := &model.Site{
+		Domain: strings.ToLower(strings.TrimSpace(req.Domain)),
+		Name:   strings.TrimSpace(req.Name),
+		Active: req.Active == nil || *req.Active,
+	}
+	if !domainPattern.MatchString(site.Domain) || len(site.Domain) > 253 {
+		return nil, &InvalidError{fmt.Errorf("invalid domain %q", req.Domain)}
+	}
+	settings, err := normalizeSettings(req.Settings)
+	if err != nil {
+		return nil, err
+	}
+	if settings == nil {
+		settings = json.RawMessage(`{}`)
+	}
+	site.Settings = settings
+	if err := checkName(site.Name); err != nil {
+		return nil, err
+	}
+
+	err = s.repo.WithSiteLock(ctx, func(tx *db.SiteTx) error {
+		stored, err := load(ctx, tx)
+		if err != nil {
+			return err
+		}
+		for _, row := range stored.Sites {
+			if row.Domain == site.Domain {
+				return ErrExists
+			}
+		}
+		stored.Sites = append(stored.Sites, *site)
+		if err := s.live.CheckStored(stored); err != nil {
+			return &InvalidError{err}
+		}
+
+		created, err := tx.CreateSite(ctx, site)
+		if err != nil {
+			return err
+		}
+		if !created {
+			return ErrExists
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.changed(ctx, site.Domain)
+	return site, nil
+}
+
+// Update changes a site. Returns ErrNotFound or an *InvalidError when rejected.
+func (s *Service) Update(ctx context.Context, domain string, req model.SiteUpdateRequest) (*model.Site, error) {
+	var site model.Site
+	err := s.repo.WithSiteLock(ctx, func(tx *db.SiteTx) error {
+		stored, err := load(ctx, tx)
+		if err != nil {
+			return err
+		}
+		rows := stored.Sites
+		i := slices.IndexFunc(rows, func(row model.Site) bool { return row.Domain == domain })
+		if i < 0 {
+			return ErrNotFound
+		}
+
+		site = rows[i]
+		if req.Name != nil {
+			site.Name = strings.TrimSpace(*req.Name)
+			if err := checkName(site.Name); err != nil {
+				return err
+			}
+		}
+		if req.Active != nil {
+			site.Active = *req.Active
+		}
+		if req.Settings != nil {
+			if site.Settings, err = normalizeSettings(req.Settings); err != nil {
+				return err
+			}
+		}
+
+		rows[i] = site
+		if err := s.live.CheckStored(stored); err != nil {
+			return &InvalidError{err}
+		}
+		found, err := tx.UpdateSite(ctx, &site)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return ErrNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.changed(ctx, site.Domain)
+	return &site, nil
+}
+
+// Deactivate stops a site from accepting reports. The row is kept, since
+// stored reports reference it.
+func (s *Service) Deactivate(ctx context.Context, domain string) (*model.Site, error) {
+	inactive := false
+	return s.Update(ctx, domain, model.SiteUpdateRequest{Active: &inactive})
+}
+
+// Taxonomy returns all report types and categories, including inactive ones.
+func (s *Service) Taxonomy(ctx context.Context) (*model.Taxonomy, error) {
+	return s.repo.LoadTaxonomy(ctx)
+}
+
+// SetTaxonomyEntry adds or replaces a report type or category. kind is
+// "report_types" or "categories". Entries are never deleted, since stored
+// reports reference them; set Active to false instead. Returns
+// ErrUnknownKind or an *InvalidError when rejected.
+func (s *Service) SetTaxonomyEntry(ctx context.Context, kind, key string, req model.TaxonomyEntryRequest) (*model.TaxonomyEntry, error) {
+	table, ok := taxonomyTables[kind]
+	if !ok {
+		return nil, ErrUnknownKind
+	}
+	if !taxonomyKeyPattern.MatchString(key) {
+		return nil, &InvalidError{fmt.Errorf("invalid key %q: must be lowercase letters, digits and underscores", key)}
+	}
+	entry := &model.TaxonomyEntry{
+		Key:      key,
+		Labels:   make(map[string]string, len(req.Labels)),
+		Position: req.Position,
+		Active:   req.Active == nil || *req.Active,
+	}
+	for lang, label := range req.Labels {
+		label = strings.TrimSpace(label)
+		if !languagePattern.MatchString(lang) {
+			return nil, &InvalidError{fmt.Errorf("invalid label language %q", lang)}
+		}
+		if label == "" || len([]rune(label)) > MaxLabelLength {
+			return nil, &InvalidError{fmt.Errorf("label %q must be 1 to %d characters", lang, MaxLabelLength)}
+		}
+		entry.Labels[lang] = label
+	}
+
+	err := s.repo.WithSiteLock(ctx, func(tx *db.SiteTx) error {
+		stored, err := load(ctx, tx)
+		if err != nil {
+			return err
+		}
+		entries := &stored.Taxonomy.ReportTypes
+		if table == db.TaxonomyCategories {
+			entries = &stored.Taxonomy.Categories
+		}
+		if i := slices.IndexFunc(*entries, func(e model.TaxonomyEntry) bool { return e.Key == key }); i >= 0 {
+			(*entries)[i] = *entry
+		} else {
+			*entries = append(*entries, *entry)
+		}
+		if err := s.live.CheckStored(stored); err != nil {
+			return &InvalidError{err}
+		}
+		return tx.UpsertTaxonomyEntry(ctx, table, entry)
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.changed(ctx, kind+"/"+key)
+	return entry, nil
+}
+
+// Sync registers the configured sites in the table and swaps in a
+// configuration with the current rows and taxonomy. Reports reference the
+// table, so processes must not accept or store reports before it succeeds.
+func (s *Service) Sync(ctx context.Context) error {
+	if sites := s.live.Get().Sites; len(sites) > 0 {
+		if err := s.repo.RegisterSites(ctx, sites); err != nil {
+			return err
+		}
+	}
+	stored, err := load(ctx, s.repo)
+	if err != nil {
+		return err
+	}
+	if err := s.live.SetStored(stored); err != nil {
+		return fmt.Errorf("apply stored sites: %w", err)
+	}
+	return nil
+}
+
+// storedSource is satisfied by *db.Repository and *db.SiteTx.
+type storedSource interface {
+	ListSites(ctx context.Context) ([]model.Site, error)
+	LoadTaxonomy(ctx context.Context) (*model.Taxonomy, error)
+}
+
+// load reads the sites table and the taxonomy.
+func load(ctx context.Context, src storedSource) (config.Stored, error) {
+	rows, err := src.ListSites(ctx)
+	if err != nil {
+		return config.Stored{}, err
+	}
+	tax, err := src.LoadTaxonomy(ctx)
+	if err != nil {
+		return config.Stored{}, err
+	}
+	return config.Stored{Sites: rows, Taxonomy: tax}, nil
+}
+
+// Run reloads the table whenever a change is published, after the
+// configuration is reloaded (registering sites added to SITES_FILE), and
+// every ResyncInterval, until the context is cancelled.
+func (s *Service) Run(ctx context.Context) {
+	sub := s.rdb.Subscribe(ctx, ChangedChannel)
+	defer sub.Close()
+	changes := sub.Channel()
+
+	ticker := time.NewTicker(ResyncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-changes:
+			if !ok {
+				return
+			}
+			s.sync(ctx, "change: "+msg.Payload)
+		case <-s.live.Reloaded():
+			s.sync(ctx, "config reload")
+		case <-ticker.C:
+			s.sync(ctx, "resync")
+		}
+	}
+}
+
+func (s *Service) sync(ctx context.Context, trigger string) {
+	if err := s.Sync(ctx); err != nil {
+		if ctx.Err() == nil {
+			slog.Error("sites sync failed, keeping current config", "trigger", trigger, "error", err)
+		}
+		return
+	}
+	slog.Debug("sites synced", "trigger", trigger, "sites", len(s.live.Get().Sites))
+}
+
+// changed applies a change locally and announces it to the other processes.
+// what is the changed domain or taxonomy entry.
+func (s *Service) changed(ctx context.Context, what string) {
+	s.sync(ctx, "change: "+what)
+	if err := s.rdb.Publish(ctx, ChangedChannel, what).Err(); err != nil {
+		slog.Error("publish site change failed", "change", what, "error", err)
+	}
+}
+
+// normalizeSettings checks that settings are a JSON object and compacts
+// them. JSON null clears the settings and returns nil.
+func normalizeSettings(data json.RawMessage) (json.RawMessage, error) {
+	if data == nil || string(bytes.TrimSpace(data)) == "null" {
+		return nil, nil
+	}
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, data); err != nil || buf.Len() == 0 || buf.Bytes()[0] != '{' {
+		return nil, &InvalidError{errors.New("settings must be a JSON object")}
+	}
+	return buf.Bytes(), nil
+}
+
+func checkName(name string) error {
+	if len([]rune(name)) > MaxNameLength {
+		return &InvalidError{fmt.Errorf("name must be at most %d characters", MaxNameLength)}
+	}
+	return nil
+}
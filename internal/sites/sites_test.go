@@ -0,0 +1,118 @@
+package sites
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/devrimsoft/bug-notifications-api/internal/model"
+)
+
+// The checks below run before the database is touched, so a Service
+// without a repository is enough.
+
+func TestSetTaxonomyEntryRejects(t *testing.T) {
+	s := NewService(nil, nil, nil)
+	label := func(labels map[string]string) model.TaxonomyEntryRequest {
+		return model.TaxonomyEntryRequest{Labels: labels}
+	}
+
+	if _, err := s.SetTaxonomyEntry(context.Background(), "colours", "red", label(nil)); !errors.Is(err, ErrUnknownKind) {
+		t.Errorf("unknown kind: err = %v, want ErrUnknownKind", err)
+	}
+
+	tests := []struct {
+		name string
+		kind string
+		key  string
+		req  model.TaxonomyEntryRequest
+		want string
+	}{
+		{name: "uppercase key", kind: "categories", key: "Billing", want: `invalid key "Billing"`},
+		{name: "leading digit", kind: "report_types", key: "1st", want: `invalid key "1st"`},
+		{name: "dash", kind: "categories", key: "in-app", want: `invalid key "in-app"`},
+		{name: "long key", kind: "categories", key: "a" + strings.Repeat("b", 50), want: "invalid key"},
+		{name: "language", kind: "categories", key: "billing", req: label(map[string]string{"EN": "Billing"}), want: `invalid label language "EN"`},
+		{name: "empty label", kind: "categories", key: "billing", req: label(map[string]string{"en": "  "}), want: `label "en" must be 1 to 100 characters`},
+		{name: "long label", kind: "categories", key: "billing", req: label(map[string]string{"en": strings.Repeat("ü", 101)}), want: `label "en" must be 1 to 100 characters`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := s.SetTaxonomyEntry(context.Background(), tt.kind, tt.key, tt.req)
+			var invalid *InvalidError
+			if !errors.As(err, &invalid) || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("SetTaxonomyEntry = %v, want an *InvalidError containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestTaxonomyPatterns(t *testing.T) {
+	for _, key := range []string{"bug", "billing", "in_app", "a1", "a" + strings.Repeat("b", 49)} {
+		if !taxonomyKeyPattern.MatchString(key) {
+			t.Errorf("key %q rejected", key)
+		}
+	}
+	for _, lang := range []string{"en", "tr", "pt-br", "zh-hant", "sr-latn-rs"} {
+		if !languagePattern.MatchString(lang) {
+			t.Errorf("language %q rejected", lang)
+		}
+	}
+	for _, lang := range []string{"", "e", "english", "en_US", "en-", "pt-BR"} {
+		if languagePattern.MatchString(lang) {
+			t.Errorf("language %q accepted", lang)
+		}
+	}
+}
+
+func TestCreateRejects(t *testing.T) {
+	s := NewService(nil, nil, nil)
+	tests := []struct {
+		name string
+		req  model.SiteCreateRequest
+		want string
+	}{
+		{name: "missing domain", req: model.SiteCreateRequest{}, want: `invalid domain ""`},
+		{name: "single label", req: model.SiteCreateRequest{Domain: "localhost"}, want: `invalid domain "localhost"`},
+		{name: "scheme", req: model.SiteCreateRequest{Domain: "https://example.com"}, want: "invalid domain"},
+		{name: "settings array", req: model.SiteCreateRequest{Domain: "example.com", Settings: json.RawMessage(`[]`)}, want: "settings must be a JSON object"},
+		{name: "long name", req: model.SiteCreateRequest{Domain: "example.com", Name: strings.Repeat("n", 101)}, want: "name must be at most 100 characters"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := s.Create(context.Background(), tt.req)
+			var invalid *InvalidError
+			if !errors.As(err, &invalid) || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Create = %v, want an *InvalidError containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeSettings(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{in: "", want: ""},
+		{in: " null ", want: ""},
+		{in: `{ "name" : "Shop",  "captcha": "pow" }`, want: `{"name":"Shop","captcha":"pow"}`},
+	}
+	for _, tt := range tests {
+		var in json.RawMessage
+		if tt.in != "" {
+			in = json.RawMessage(tt.in)
+		}
+		got, err := normalizeSettings(in)
+		if err != nil || string(got) != tt.want {
+			t.Errorf("normalizeSettings(%q) = %s, %v; want %s", tt.in, got, err, tt.want)
+		}
+	}
+	for _, in := range []string{`"x"`, `[1]`, `{`, `1`} {
+		if _, err := normalizeSettings(json.RawMessage(in)); err == nil {
+			t.Errorf("normalizeSettings(%q) accepted", in)
+		}
+	}
+}
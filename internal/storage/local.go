@@ -0,0 +1,179 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// LocalPathPrefix is where the API serves images saved by a Local store.
+const LocalPathPrefix = "/images/"
+
+// Local saves images under a directory and serves them from
+// {publicURL}/images/{sig}/{key}, where sig is an HMAC of the key. URLs can't
+// be guessed or rewritten to reach other files, but never expire, so they
+// stay valid in stored reports and sent notifications.
+//
+// The API and worker must see the same directory (same host or a shared volume).
+type Local struct {
+	dir       string
+	publicURL string
+	secret    []byte
+}
+
+func NewLocal(dir, publicURL, secret string) *Local {
+	return &Local{
+		dir:       dir,
+		publicURL: strings.TrimRight(publicURL, "/"),
+		secret:    []byte(secret),
+	}
+}
+
+// Put writes the image to disk. The file is written under a temporary name
+// and renamed, so readers never see a partial file.
+func (s *Local) Put(ctx context.Context, key, contentType string, data []byte) (string, error) {
+	name, ok := s.path(key)
+	if !ok {
+		return "", fmt.Errorf("invalid object key %q", key)
+	}
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return "", fmt.Errorf("create image dir: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(name), ".upload-*")
+	if err != nil {
+		return "", fmt.Errorf("create image file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return "", fmt.Errorf("write image file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return "", fmt.Errorf("write image file: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return "", fmt.Errorf("write image file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), name); err != nil {
+		return "", fmt.Errorf("save image file: %w", err)
+	}
+
+	return s.publicURL + LocalPathPrefix + s.sign(key) + "/" + key, nil
+}
+
+// Delete removes an image file.
+func (s *Local) Delete(ctx context.Context, key string) error {
+	name, ok := s.path(key)
+	if !ok {
+		return fmt.Errorf("invalid object key %q", key)
+	}
+	if err := os.Remove(name); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("delete image file: %w", err)
+	}
+	return nil
+}
+
+// List walks the image files under prefix. Temporary files of uploads in
+// progress are skipped.
+func (s *Local) List(ctx context.Context, prefix string, fn func(Object) error) error {
+	root := filepath.Join(s.dir, filepath.FromSlash(prefix))
+	err := filepath.WalkDir(root, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() || strings.HasPrefix(d.Name(), ".upload-") {
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil // removed while walking
+		}
+		rel, err := filepath.Rel(s.dir, name)
+		if err != nil {
+			return err
+		}
+		return fn(Object{Key: filepath.ToSlash(rel), ModTime: info.ModTime()})
+	})
+	if err != nil {
+		return fmt.Errorf("list images: %w", err)
+	}
+	return nil
+}
+
+// localContentTypes covers attachment extensions that the system MIME table
+// may not know.
+var localContentTypes = map[string]string{
+	".txt":  "text/plain; charset=utf-8",
+	".har":  "application/json",
+	".mp4":  "video/mp4",
+	".webm": "video/webm",
+}
+
+// ServeHTTP serves GET {LocalPathPrefix}{sig}/{key}. Unknown, unsigned or
+// tampered paths all get a plain 404.
+func (s *Local) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	sig, key, ok := strings.Cut(strings.TrimPrefix(r.URL.Path, LocalPathPrefix), "/")
+	if !ok || !hmac.Equal([]byte(sig), []byte(s.sign(key))) {
+		http.NotFound(w, r)
+		return
+	}
+	name, ok := s.path(key)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	f, err := os.Open(name)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		http.NotFound(w, r)
+		return
+	}
+
+	ct := localContentTypes[path.Ext(key)]
+	if ct == "" {
+		ct = mime.TypeByExtension(path.Ext(key))
+	}
+	if ct != "" {
+		w.Header().Set("Content-Type", ct)
+	}
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	http.ServeContent(w, r, path.Base(key), info.ModTime(), f)
+}
+
+// path maps a key to its file, rejecting keys that would escape the directory.
+func (s *Local) path(key string) (string, bool) {
+	if key == "" || path.Clean("/"+key) != "/"+key || strings.Contains(key, "\\") {
+		return "", false
+	}
+	return filepath.Join(s.dir, filepath.FromSlash(key)), true
+}
+
+// sign returns the URL signature for a key.
+func (s *Local) sign(key string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(key))
+	return hex.EncodeToString(mac.Sum(nil)[:16])
+}
@@ -0,0 +1,79 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"path"
+	"time"
+)
+
+// R2 uploads to the DevrimSoft R2 Image Processor API, which converts
+// images to WebP, strips metadata and picks the final object name itself.
+type R2 struct {
+	apiURL string
+	apiKey string
+	client *http.Client
+}
+
+func NewR2(apiURL, apiKey string) *R2 {
+	return &R2{
+		apiURL: apiURL,
+		apiKey: apiKey,
+		client: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// r2UploadResponse is the R2 API response.
+type r2UploadResponse struct {
+	Success bool   `json:"success"`
+	URL     string `json:"url"`
+}
+
+// Put sends the image to POST {IMAGE_API_URL}/upload. Only the base name of
+// key is passed on, as the upload's file name.
+func (s *R2) Put(ctx context.Context, key, contentType string, data []byte) (string, error) {
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+
+	part, err := writer.CreateFormFile("file", path.Base(key))
+	if err != nil {
+		return "", fmt.Errorf("create form file: %w", err)
+	}
+	if _, err := part.Write(data); err != nil {
+		return "", fmt.Errorf("write file data: %w", err)
+	}
+	writer.Close()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.apiURL+"/upload", &buf)
+	if err != nil {
+		return "", fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	req.Header.Set("X-API-Key", s.apiKey)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("r2 upload request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return "", fmt.Errorf("r2 upload failed with status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var result r2UploadResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("decode r2 response: %w", err)
+	}
+	if !result.Success || result.URL == "" {
+		return "", fmt.Errorf("r2 upload returned no url")
+	}
+
+	return result.URL, nil
+}
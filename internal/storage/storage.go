@@ -0,0 +1,71 @@
+// Package storage saves uploaded report images and returns the URL they are
+// served from.
+//
+// The backend is chosen with IMAGE_STORAGE:
+//
+//	r2     DevrimSoft R2 Image Processor API (IMAGE_API_URL, IMAGE_API_KEY)
+//	local  a directory on disk, served back by the API under a signed path
+//	s3     any S3-compatible bucket (AWS S3, MinIO, Cloudflare R2), SigV4 signed
+package storage
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"time"
+
+	"github.com/devrimsoft/bug-notifications-api/internal/config"
+	"github.com/google/uuid"
+)
+
+// Store saves an object under key and returns its public URL.
+type Store interface {
+	Put(ctx context.Context, key, contentType string, data []byte) (string, error)
+}
+
+// Cleaner is a Store that can also list and delete its objects, which
+// orphan cleanup needs. The R2 Image Processor API supports neither.
+type Cleaner interface {
+	Store
+	// Delete removes an object. Deleting a missing object is not an error.
+	Delete(ctx context.Context, key string) error
+	// List calls fn for every object whose key starts with prefix.
+	List(ctx context.Context, prefix string, fn func(Object) error) error
+}
+
+// Object is a stored object, as returned by List.
+type Object struct {
+	Key     string
+	ModTime time.Time
+}
+
+// New returns the store selected by cfg.ImageStorage, or nil when image
+// storage is disabled.
+func New(cfg *config.Config) (Store, error) {
+	switch cfg.ImageStorage {
+	case "":
+		return nil, nil
+	case "r2":
+		return NewR2(cfg.ImageAPIURL, cfg.ImageAPIKey), nil
+	case "local":
+		return NewLocal(cfg.ImageLocalDir, cfg.ImagePublicURL, cfg.ImageSigningKey), nil
+	case "s3":
+		return NewS3(S3Config{
+			Endpoint:        cfg.S3Endpoint,
+			Region:          cfg.S3Region,
+			Bucket:          cfg.S3Bucket,
+			AccessKeyID:     cfg.S3AccessKeyID,
+			SecretAccessKey: cfg.S3SecretAccessKey,
+			PublicURL:       cfg.S3PublicURL,
+		}), nil
+	default:
+		return nil, fmt.Errorf("unknown image storage %q", cfg.ImageStorage)
+	}
+}
+
+// NewKey returns a fresh object key for a site's upload:
+// "{site_id}/{yyyy}/{mm}/{uuid}{ext}".
+func NewKey(siteID, ext string) string {
+	now := time.Now().UTC()
+	return path.Join(siteID, now.Format("2006"), now.Format("01"), uuid.New().String()+ext)
+}
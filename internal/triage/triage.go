@@ -0,0 +1,127 @@
+// Package triage owns the report status lifecycle:
+//
+//	new → triaged → in_progress → resolved | wont_fix | duplicate
+//
+// Reports can also be closed as wont_fix or duplicate before work starts, and
+// any closed report can be reopened (back to triaged). Every change is
+// recorded in the report's status history.
+package triage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/devrimsoft/bug-notifications-api/internal/db"
+	"github.com/devrimsoft/bug-notifications-api/internal/model"
+	"github.com/devrimsoft/bug-notifications-api/internal/webhook"
+)
+
+// DefaultActor is recorded as changed_by when the caller does not name one.
+const DefaultActor = "admin"
+
+// Transitions lists the statuses each status may move to.
+var Transitions = map[model.ReportStatus][]model.ReportStatus{
+	model.StatusNew:        {model.StatusTriaged, model.StatusWontFix, model.StatusDuplicate},
+	model.StatusTriaged:    {model.StatusInProgress, model.StatusWontFix, model.StatusDuplicate},
+	model.StatusInProgress: {model.StatusResolved, model.StatusWontFix, model.StatusDuplicate},
+	model.StatusResolved:   {model.StatusTriaged},
+	model.StatusWontFix:    {model.StatusTriaged},
+	model.StatusDuplicate:  {model.StatusTriaged},
+}
+
+// ErrNotFound is returned when the report does not exist.
+var ErrNotFound = errors.New("report not found")
+
+// TransitionError is returned when a status change is not allowed.
+type TransitionError struct {
+	From, To model.ReportStatus
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("cannot change status from %s to %s", e.From, e.To)
+}
+
+// CanTransition reports whether a report may move from one status to another.
+func CanTransition(from, to model.ReportStatus) bool {
+	for _, s := range Transitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+// Change is a requested status change.
+type Change struct {
+	Status    model.ReportStatus
+	ChangedBy string
+	Note      *string
+}
+
+// Service applies status changes to stored reports.
+type Service struct {
+	repo  *db.Repository
+	hooks *webhook.Publisher
+}
+
+func NewService(repo *db.Repository, hooks *webhook.Publisher) *Service {
+	return &Service{repo: repo, hooks: hooks}
+}
+
+// ChangeStatus moves a report to a new status if the lifecycle allows it and
+// returns the updated report. Setting the current status again is a no-op.
+// Returns ErrNotFound or a *TransitionError when the change is rejected.
+func (s *Service) ChangeStatus(ctx context.Context, id string, c Change) (*model.BugReport, error) {
+	if c.ChangedBy == "" {
+		c.ChangedBy = DefaultActor
+	}
+
+	previous, found, err := s.repo.ChangeReportStatus(ctx, id, string(c.Status), c.ChangedBy, c.Note, func(from string) error {
+		if !CanTransition(model.ReportStatus(from), c.Status) {
+			return &TransitionError{From: model.ReportStatus(from), To: c.Status}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, ErrNotFound
+	}
+
+	report, err := s.repo.GetReport(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if report == nil {
+		return nil, ErrNotFound
+	}
+
+	if previous == report.Status {
+		return report, nil
+	}
+	slog.Info("report status changed", "id", id, "from", previous, "to", report.Status, "by", c.ChangedBy)
+
+	if s.hooks.Enabled() {
+		// The status change is already committed; a failed publish is logged, not surfaced.
+		if err := s.hooks.ReportStatusChanged(ctx, report, previous); err != nil {
+			slog.Error("webhook publish failed", "error", err, "id", id)
+		}
+	}
+	return report, nil
+}
+
+// History returns a report's status changes, oldest first.
+// Returns ErrNotFound if the report does not exist.
+func (s *Service) History(ctx context.Context, id string) ([]model.StatusChange, error) {
+	report, err := s.repo.GetReport(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if report == nil {
+		return nil, ErrNotFound
+	}
+	return s.repo.ListStatusHistory(ctx, id)
+}
@@ -0,0 +1,62 @@
+package triage
+
+import (
+	"testing"
+
+	"github.com/devrimsoft/bug-notifications-api/internal/model"
+)
+
+func TestCanTransition(t *testing.T) {
+	tests := []struct {
+		from, to model.ReportStatus
+		want     bool
+	}{
+		{model.StatusNew, model.StatusTriaged, true},
+		{model.StatusNew, model.StatusWontFix, true},
+		{model.StatusNew, model.StatusDuplicate, true},
+		{model.StatusNew, model.StatusInProgress, false},
+		{model.StatusNew, model.StatusResolved, false},
+		{model.StatusTriaged, model.StatusInProgress, true},
+		{model.StatusTriaged, model.StatusNew, false},
+		{model.StatusTriaged, model.StatusResolved, false},
+		{model.StatusInProgress, model.StatusResolved, true},
+		{model.StatusInProgress, model.StatusWontFix, true},
+		{model.StatusInProgress, model.StatusTriaged, false},
+		{model.StatusResolved, model.StatusTriaged, true},
+		{model.StatusResolved, model.StatusInProgress, false},
+		{model.StatusWontFix, model.StatusTriaged, true},
+		{model.StatusDuplicate, model.StatusTriaged, true},
+		{model.StatusDuplicate, model.StatusResolved, false},
+		{"unknown", model.StatusTriaged, false},
+	}
+	for _, tt := range tests {
+		if got := CanTransition(tt.from, tt.to); got != tt.want {
+			t.Errorf("CanTransition(%s, %s) = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestTransitionsCoverEveryStatus(t *testing.T) {
+	for status := range model.ValidStatuses {
+		targets, ok := Transitions[status]
+		if !ok {
+			t.Errorf("status %s has no transitions", status)
+			continue
+		}
+		for _, to := range targets {
+			if !model.ValidStatuses[to] {
+				t.Errorf("%s → %s: unknown target status", status, to)
+			}
+			if to == status {
+				t.Errorf("%s lists itself as a transition", status)
+			}
+		}
+	}
+}
+
+func TestTransitionError(t *testing.T) {
+	err := &TransitionError{From: model.StatusNew, To: model.StatusResolved}
+	if got, want := err.Error(), "cannot change status from new to resolved"; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+}
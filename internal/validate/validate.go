@@ -1,9 +1,14 @@
 package validate
 
 import (
+	"encoding/json"
 	"fmt"
+	"maps"
+	"math"
 	"net/url"
 	"regexp"
+	"slices"
+	"strconv"
 	"strings"
 
 	"github.com/devrimsoft/bug-notifications-api/internal/model"
@@ -15,6 +20,10 @@ const (
 	MaxURLLen         = 2048
 	MaxContactLen     = 200
 	MaxNameLen        = 100
+
+	// MaxCustomFieldLen is the default and largest max_length of string
+	// custom fields; url fields default to MaxURLLen.
+	MaxCustomFieldLen = 1000
 )
 
 // htmlTagPattern matches HTML tags and common XSS vectors.
@@ -23,6 +32,9 @@ var htmlTagPattern = regexp.MustCompile(`<[^>]*>`)
 // dangerousPatterns matches javascript: URIs and event handlers that could execute scripts.
 var dangerousPatterns = regexp.MustCompile(`(?i)(javascript\s*:|on\w+\s*=)`)
 
+// numberPattern matches a JSON number.
+var numberPattern = regexp.MustCompile(`^-?(0|[1-9][0-9]*)(\.[0-9]+)?([eE][+-]?[0-9]+)?$`)
+
 // sanitizeString strips HTML tags and dangerous patterns from user input to prevent stored XSS.
 func sanitizeString(s string) string {
 	s = htmlTagPattern.ReplaceAllString(s, "")
@@ -38,8 +50,9 @@ func sanitizePtr(s *string) {
 }
 
 // ReportRequest validates the incoming report request and returns a list of errors.
+// Report types and categories are checked against the taxonomy.
 // It also sanitizes text fields in-place to strip HTML/script tags (stored XSS prevention).
-func ReportRequest(r *model.ReportRequest) []string {
+func ReportRequest(r *model.ReportRequest, tax *model.Taxonomy) []string {
 	var errs []string
 
 	// Sanitize all free-text fields before validation
@@ -53,8 +66,8 @@ func ReportRequest(r *model.ReportRequest) []string {
 	if r.ReportType == "" {
 		r.ReportType = model.ReportTypeBug // default
 	}
-	if !model.ValidReportTypes[r.ReportType] {
-		errs = append(errs, fmt.Sprintf("invalid report_type %q, must be 'bug' or 'request'", r.ReportType))
+	if !tax.HasReportType(string(r.ReportType)) {
+		errs = append(errs, fmt.Sprintf("invalid report_type %q, must be one of %s", r.ReportType, strings.Join(tax.ReportTypeKeys(), ", ")))
 	}
 	if strings.TrimSpace(r.SiteID) == "" {
 		errs = append(errs, "site_id is required")
@@ -71,7 +84,7 @@ func ReportRequest(r *model.ReportRequest) []string {
 	}
 	if r.Category == "" {
 		errs = append(errs, "category is required")
-	} else if !model.ValidCategories[r.Category] {
+	} else if !tax.HasCategory(string(r.Category)) {
 		errs = append(errs, fmt.Sprintf("invalid category %q", r.Category))
 	}
 
@@ -105,3 +118,121 @@ func ReportRequest(r *model.ReportRequest) []string {
 
 	return errs
 }
+
+// CustomFields validates the custom field values of a report against the
+// site's field definitions and returns them as a JSON object, or nil if
+// there are none. Multipart forms send every value as a string, so numbers
+// and booleans are accepted in string form too. String values are sanitized
+// like the other free-text fields.
+func CustomFields(r *model.ReportRequest, defs []model.CustomField) (json.RawMessage, []string) {
+	var errs []string
+	// Unknown names are reported in order, not in map order
+	for _, name := range slices.Sorted(maps.Keys(r.CustomFields)) {
+		if !slices.ContainsFunc(defs, func(d model.CustomField) bool { return d.Name == name }) {
+			errs = append(errs, fmt.Sprintf("unknown custom field %q", name))
+		}
+	}
+
+	values := make(map[string]any)
+	for _, d := range defs {
+		field := "custom_fields." + d.Name
+		v, ok := r.CustomFields[d.Name]
+		if s, isString := v.(string); v == nil || (isString && strings.TrimSpace(s) == "") {
+			ok = false
+		}
+		if !ok {
+			if d.Required {
+				errs = append(errs, field+" is required")
+			}
+			continue
+		}
+
+		value, err := customFieldValue(d, v)
+		if err != "" {
+			errs = append(errs, field+" "+err)
+			continue
+		}
+		values[d.Name] = value
+	}
+
+	if len(errs) > 0 || len(values) == 0 {
+		return nil, errs
+	}
+	data, err := json.Marshal(values)
+	if err != nil {
+		return nil, []string{"invalid custom fields"}
+	}
+	return data, nil
+}
+
+// customFieldValue checks a value against its field definition and returns
+// it in its stored form, or an error message.
+func customFieldValue(d model.CustomField, v any) (any, string) {
+	switch d.Type {
+	case model.CustomFieldNumber:
+		var n json.Number
+		switch v := v.(type) {
+		case json.Number:
+			n = v
+		case float64:
+			n = json.Number(strconv.FormatFloat(v, 'f', -1, 64))
+		case string:
+			n = json.Number(strings.TrimSpace(v))
+		default:
+			return nil, "must be a number"
+		}
+		f, err := strconv.ParseFloat(n.String(), 64)
+		if err != nil || math.IsInf(f, 0) || !numberPattern.MatchString(n.String()) {
+			return nil, "must be a number"
+		}
+		return n, ""
+
+	case model.CustomFieldBool:
+		switch v := v.(type) {
+		case bool:
+			return v, ""
+		case string:
+			if b, err := strconv.ParseBool(strings.TrimSpace(v)); err == nil {
+				return b, ""
+			}
+		}
+		return nil, "must be true or false"
+	}
+
+	s, ok := v.(string)
+	if !ok {
+		return nil, "must be a string"
+	}
+	s = strings.TrimSpace(s)
+	switch d.Type {
+	case model.CustomFieldEnum:
+		if !slices.Contains(d.Options, s) {
+			return nil, fmt.Sprintf("must be one of %s", strings.Join(d.Options, ", "))
+		}
+		return s, ""
+
+	case model.CustomFieldURL:
+		maxLen := d.MaxLength
+		if maxLen == 0 {
+			maxLen = MaxURLLen
+		}
+		if len(s) > maxLen {
+			return nil, fmt.Sprintf("must be at most %d characters", maxLen)
+		}
+		if u, err := url.ParseRequestURI(s); err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+			return nil, "must be a valid http or https URL"
+		}
+		return s, ""
+
+	default: // string
+		s = sanitizeString(s)
+		maxLen := d.MaxLength
+		if maxLen == 0 {
+			maxLen = MaxCustomFieldLen
+		}
+		if len(s) > maxLen {
+			return nil, fmt.Sprintf("must be at most %d characters", maxLen)
+		}
+		return s, ""
+	}
+}
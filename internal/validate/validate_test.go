@@ -0,0 +1,245 @@
+package validate
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/devrimsoft/bug-notifications-api/internal/model"
+)
+
+var customFieldDefs = []model.CustomField{
+	{Name: "app_version", Type: model.CustomFieldString, Required: true, MaxLength: 10},
+	{Name: "build", Type: model.CustomFieldNumber},
+	{Name: "beta", Type: model.CustomFieldBool},
+	{Name: "plan", Type: model.CustomFieldEnum, Options: []string{"free", "pro"}},
+	{Name: "profile", Type: model.CustomFieldURL},
+	{Name: "notes", Type: model.CustomFieldString},
+}
+
+func TestCustomFields(t *testing.T) {
+	tests := []struct {
+		name   string
+		values map[string]any
+		want   string // JSON object, "" for nil
+	}{
+		{
+			name:   "multipart strings",
+			values: map[string]any{"app_version": " 1.2.3 ", "build": "42", "beta": "true", "plan": "pro", "profile": "https://example.com/u/1"},
+			want:   `{"app_version":"1.2.3","beta":true,"build":42,"plan":"pro","profile":"https://example.com/u/1"}`,
+		},
+		{
+			name:   "json values",
+			values: map[string]any{"app_version": "1.2", "build": json.Number("-1.5e3"), "beta": false},
+			want:   `{"app_version":"1.2","beta":false,"build":-1.5e3}`,
+		},
+		{
+			name:   "float64 number",
+			values: map[string]any{"app_version": "1.2", "build": float64(7.25)},
+			want:   `{"app_version":"1.2","build":7.25}`,
+		},
+		{
+			name:   "bool forms",
+			values: map[string]any{"app_version": "1.2", "beta": "0"},
+			want:   `{"app_version":"1.2","beta":false}`,
+		},
+		{
+			name:   "blank optional values are left out",
+			values: map[string]any{"app_version": "1.2", "build": " ", "notes": "", "plan": nil},
+			want:   `{"app_version":"1.2"}`,
+		},
+		{
+			name:   "strings are sanitized",
+			values: map[string]any{"app_version": "1.2", "notes": `<b>bold</b> onclick=x`},
+			want:   `{"app_version":"1.2","notes":"bold x"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, errs := CustomFields(&model.ReportRequest{CustomFields: tt.values}, customFieldDefs)
+			if len(errs) > 0 {
+				t.Fatalf("CustomFields errors = %v", errs)
+			}
+			if string(got) != tt.want {
+				t.Errorf("CustomFields = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCustomFieldsNone(t *testing.T) {
+	got, errs := CustomFields(&model.ReportRequest{}, nil)
+	if got != nil || errs != nil {
+		t.Errorf("CustomFields without fields = %s, %v; want nil, nil", got, errs)
+	}
+
+	optional := []model.CustomField{{Name: "notes", Type: model.CustomFieldString}}
+	got, errs = CustomFields(&model.ReportRequest{CustomFields: map[string]any{"notes": "  "}}, optional)
+	if got != nil || errs != nil {
+		t.Errorf("CustomFields with blank values = %s, %v; want nil, nil", got, errs)
+	}
+}
+
+func TestCustomFieldsErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		values map[string]any
+		want   []string
+	}{
+		{
+			name:   "required",
+			values: map[string]any{"build": "1"},
+			want:   []string{"custom_fields.app_version is required"},
+		},
+		{
+			name:   "required blank",
+			values: map[string]any{"app_version": "   "},
+			want:   []string{"custom_fields.app_version is required"},
+		},
+		{
+			name:   "unknown field",
+			values: map[string]any{"app_version": "1", "colour": "red"},
+			want:   []string{`unknown custom field "colour"`},
+		},
+		{
+			name:   "unknown fields",
+			values: map[string]any{"app_version": "1", "size": "l", "colour": "red", "brand": "x", "material": "wool"},
+			want: []string{
+				`unknown custom field "brand"`,
+				`unknown custom field "colour"`,
+				`unknown custom field "material"`,
+				`unknown custom field "size"`,
+			},
+		},
+		{
+			name:   "too long",
+			values: map[string]any{"app_version": "1.2.3.4.5.6"},
+			want:   []string{"custom_fields.app_version must be at most 10 characters"},
+		},
+		{
+			name:   "not a string",
+			values: map[string]any{"app_version": json.Number("1")},
+			want:   []string{"custom_fields.app_version must be a string"},
+		},
+		{
+			name:   "numbers",
+			values: map[string]any{"app_version": "1", "build": "12abc"},
+			want:   []string{"custom_fields.build must be a number"},
+		},
+		{
+			name:   "number forms JSON does not allow",
+			values: map[string]any{"app_version": "1", "build": "0x10"},
+			want:   []string{"custom_fields.build must be a number"},
+		},
+		{
+			name:   "number overflow",
+			values: map[string]any{"app_version": "1", "build": "1e400"},
+			want:   []string{"custom_fields.build must be a number"},
+		},
+		{
+			name:   "number of the wrong type",
+			values: map[string]any{"app_version": "1", "build": true},
+			want:   []string{"custom_fields.build must be a number"},
+		},
+		{
+			name:   "bool",
+			values: map[string]any{"app_version": "1", "beta": "yes"},
+			want:   []string{"custom_fields.beta must be true or false"},
+		},
+		{
+			name:   "enum",
+			values: map[string]any{"app_version": "1", "plan": "Pro"},
+			want:   []string{"custom_fields.plan must be one of free, pro"},
+		},
+		{
+			name:   "url scheme",
+			values: map[string]any{"app_version": "1", "profile": "javascript:alert(1)"},
+			want:   []string{"custom_fields.profile must be a valid http or https URL"},
+		},
+		{
+			name:   "relative url",
+			values: map[string]any{"app_version": "1", "profile": "/u/1"},
+			want:   []string{"custom_fields.profile must be a valid http or https URL"},
+		},
+		{
+			name:   "url length",
+			values: map[string]any{"app_version": "1", "profile": "https://example.com/" + strings.Repeat("a", MaxURLLen)},
+			want:   []string{"custom_fields.profile must be at most 2048 characters"},
+		},
+		{
+			name:   "every error is reported",
+			values: map[string]any{"build": "x", "plan": "team"},
+			want: []string{
+				"custom_fields.app_version is required",
+				"custom_fields.build must be a number",
+				"custom_fields.plan must be one of free, pro",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, errs := CustomFields(&model.ReportRequest{CustomFields: tt.values}, customFieldDefs)
+			if got != nil {
+				t.Errorf("CustomFields = %s, want nil", got)
+			}
+			if !slices.Equal(errs, tt.want) {
+				t.Errorf("CustomFields errors = %q, want %q", errs, tt.want)
+			}
+		})
+	}
+
+	// A site without custom fields rejects any
+	_, errs := CustomFields(&model.ReportRequest{CustomFields: map[string]any{"notes": "x"}}, nil)
+	if !slices.Equal(errs, []string{`unknown custom field "notes"`}) {
+		t.Errorf("CustomFields without definitions = %q", errs)
+	}
+}
+
+func TestReportRequestTaxonomy(t *testing.T) {
+	tax := &model.Taxonomy{
+		ReportTypes: []model.TaxonomyEntry{
+			{Key: "bug", Active: true},
+			{Key: "praise", Active: true},
+			{Key: "request", Active: false},
+		},
+		Categories: []model.TaxonomyEntry{
+			{Key: "billing", Active: true},
+			{Key: "design", Active: false},
+		},
+	}
+	report := func(reportType, category string) *model.ReportRequest {
+		return &model.ReportRequest{
+			SiteID:      "example.com",
+			ReportType:  model.ReportType(reportType),
+			Title:       "Title",
+			Description: "Description",
+			Category:    model.Category(category),
+		}
+	}
+
+	tests := []struct {
+		name       string
+		reportType string
+		category   string
+		want       []string
+	}{
+		{name: "added entries", reportType: "praise", category: "billing"},
+		{name: "default type", reportType: "", category: "billing"},
+		{name: "inactive type", reportType: "request", category: "billing", want: []string{`invalid report_type "request", must be one of bug, praise`}},
+		{name: "unknown type", reportType: "feature", category: "billing", want: []string{`invalid report_type "feature", must be one of bug, praise`}},
+		{name: "inactive category", reportType: "bug", category: "design", want: []string{`invalid category "design"`}},
+		{name: "missing category", reportType: "bug", category: "", want: []string{"category is required"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := report(tt.reportType, tt.category)
+			if errs := ReportRequest(r, tax); !slices.Equal(errs, tt.want) {
+				t.Errorf("ReportRequest errors = %q, want %q", errs, tt.want)
+			}
+			if tt.reportType == "" && r.ReportType != model.ReportTypeBug {
+				t.Errorf("ReportType = %q, want the bug default", r.ReportType)
+			}
+		})
+	}
+}
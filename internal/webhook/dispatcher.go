@@ -0,0 +1,176 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/devrimsoft/bug-notifications-api/internal/config"
+	"github.com/devrimsoft/bug-notifications-api/internal/db"
+	"github.com/devrimsoft/bug-notifications-api/internal/model"
+	"github.com/devrimsoft/bug-notifications-api/internal/queue"
+)
+
+const (
+	// pollInterval is how often the delivery log is checked for due deliveries.
+	pollInterval = 2 * time.Second
+
+	// claimBatch caps how many deliveries are sent per poll. They are sent
+	// concurrently.
+	claimBatch = 20
+
+	// claimLease must exceed batchTimeout so a slow send is not picked up
+	// again by another dispatcher while still in flight.
+	claimLease = time.Minute
+
+	// batchTimeout bounds the sends of one batch; sends still running are
+	// cancelled and recorded as failed attempts.
+	batchTimeout = 30 * time.Second
+
+	requestTimeout = 10 * time.Second
+)
+
+// Dispatcher sends pending webhook deliveries and records the outcome.
+// Several dispatchers (one per worker process) may run concurrently.
+type Dispatcher struct {
+	repo   deliveryStore
+	cfg    *config.Config
+	retry  queue.RetryPolicy
+	client *http.Client
+}
+
+func NewDispatcher(repo *db.Repository, cfg *config.Config) *Dispatcher {
+	return &Dispatcher{
+		repo: repo,
+		cfg:  cfg,
+		retry: queue.RetryPolicy{
+			MaxRetry:  cfg.WebhookMaxAttempts,
+			BaseDelay: 30 * time.Second,
+			MaxDelay:  time.Hour,
+			Jitter:    0.2,
+		},
+		client: &http.Client{Timeout: requestTimeout},
+	}
+}
+
+// Run polls for due deliveries until the context is cancelled.
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		d.poll(ctx)
+	}
+}
+
+// poll claims the due deliveries and sends them.
+func (d *Dispatcher) poll(ctx context.Context) {
+	deliveries, err := d.repo.ClaimDueWebhookDeliveries(ctx, claimBatch, claimLease)
+	if err != nil {
+		if ctx.Err() == nil {
+			slog.Error("claim webhook deliveries failed", "error", err)
+		}
+		return
+	}
+	d.deliverBatch(ctx, deliveries)
+}
+
+// deliverBatch sends claimed deliveries concurrently, within batchTimeout,
+// and waits for all of them to be recorded.
+func (d *Dispatcher) deliverBatch(ctx context.Context, deliveries []model.WebhookDelivery) {
+	sendCtx, cancel := context.WithTimeout(ctx, batchTimeout)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for i := range deliveries {
+		wg.Add(1)
+		go func(del *model.WebhookDelivery) {
+			defer wg.Done()
+			d.deliver(ctx, sendCtx, del)
+		}(&deliveries[i])
+	}
+	wg.Wait()
+}
+
+// deliver sends one delivery within sendCtx and records success, a scheduled
+// retry, or final failure.
+func (d *Dispatcher) deliver(ctx, sendCtx context.Context, del *model.WebhookDelivery) {
+	statusCode, err := d.send(sendCtx, del)
+	if err == nil {
+		if err := d.repo.MarkWebhookDelivered(ctx, del.ID, statusCode); err != nil {
+			slog.Error("record webhook delivery failed", "delivery_id", del.ID, "error", err)
+		}
+		slog.Info("webhook delivered", "delivery_id", del.ID, "endpoint_id", del.EndpointID, "event", del.EventType)
+		return
+	}
+
+	attempt := del.Attempts + 1
+	var next time.Time
+	if attempt < d.retry.MaxRetry {
+		next = time.Now().Add(d.retry.Delay(attempt))
+	}
+	slog.Warn("webhook delivery failed",
+		"delivery_id", del.ID,
+		"endpoint_id", del.EndpointID,
+		"attempt", attempt,
+		"status_code", statusCode,
+		"error", err,
+		"final", next.IsZero(),
+	)
+	if err := d.repo.MarkWebhookAttemptFailed(ctx, del.ID, statusCode, err.Error(), next); err != nil {
+		slog.Error("record webhook failure failed", "delivery_id", del.ID, "error", err)
+	}
+}
+
+// send POSTs the signed payload. Any non-2xx response is an error; the status
+// code is returned whenever a response was received.
+func (d *Dispatcher) send(ctx context.Context, del *model.WebhookDelivery) (int, error) {
+	ep := d.endpoint(del.EndpointID)
+	if ep == nil {
+		return 0, fmt.Errorf("endpoint %q is no longer configured", del.EndpointID)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, ep.URL, bytes.NewReader(del.Payload))
+	if err != nil {
+		return 0, fmt.Errorf("create request: %w", err)
+	}
+	now := time.Now()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "bug-notifications-webhook/1")
+	req.Header.Set(HeaderEvent, string(del.EventType))
+	req.Header.Set(HeaderDeliveryID, del.ID)
+	req.Header.Set(HeaderTimestamp, fmt.Sprintf("%d", now.Unix()))
+	req.Header.Set(HeaderSignature, Sign(ep.Secret, now, del.Payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return resp.StatusCode, fmt.Errorf("endpoint returned status %d: %s", resp.StatusCode, string(body))
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+	return resp.StatusCode, nil
+}
+
+func (d *Dispatcher) endpoint(id string) *config.WebhookEndpoint {
+	for i := range d.cfg.WebhookEndpoints {
+		if d.cfg.WebhookEndpoints[i].ID == id {
+			return &d.cfg.WebhookEndpoints[i]
+		}
+	}
+	return nil
+}
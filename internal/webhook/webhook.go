@@ -0,0 +1,110 @@
+// Package webhook delivers signed report events to per-site HTTP endpoints.
+//
+// Events are written to the webhook_deliveries table by a Publisher (outbox
+// pattern) and sent by a Dispatcher running in the worker, which retries with
+// backoff and records every attempt so failed deliveries can be replayed.
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/devrimsoft/bug-notifications-api/internal/config"
+	"github.com/devrimsoft/bug-notifications-api/internal/db"
+	"github.com/devrimsoft/bug-notifications-api/internal/model"
+	"github.com/google/uuid"
+)
+
+// Request headers set on every delivery.
+const (
+	HeaderEvent      = "X-Webhook-Event"
+	HeaderDeliveryID = "X-Webhook-Delivery"
+	HeaderTimestamp  = "X-Webhook-Timestamp"
+	HeaderSignature  = "X-Webhook-Signature"
+)
+
+// Sign returns the signature header value for a delivery:
+// "sha256=" + hex(HMAC-SHA256(secret, timestamp + "." + body)).
+// Receivers should recompute it and reject stale timestamps.
+func Sign(secret string, timestamp time.Time, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp.Unix(), 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// deliveryStore is the part of *db.Repository that records deliveries.
+type deliveryStore interface {
+	CreateWebhookDelivery(ctx context.Context, d *model.WebhookDelivery, eventKey string) error
+	ClaimDueWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]model.WebhookDelivery, error)
+	MarkWebhookDelivered(ctx context.Context, id string, statusCode int) error
+	MarkWebhookAttemptFailed(ctx context.Context, id string, statusCode int, errMsg string, nextAttempt time.Time) error
+}
+
+// Publisher records webhook deliveries for report events.
+type Publisher struct {
+	repo deliveryStore
+	cfg  *config.Config
+}
+
+func NewPublisher(repo *db.Repository, cfg *config.Config) *Publisher {
+	return &Publisher{repo: repo, cfg: cfg}
+}
+
+// Enabled reports whether any webhook endpoints are configured.
+func (p *Publisher) Enabled() bool {
+	return len(p.cfg.WebhookEndpoints) > 0
+}
+
+// ReportCreated queues report.created deliveries for every subscribed endpoint.
+// Publishing the same report twice is a no-op.
+func (p *Publisher) ReportCreated(ctx context.Context, report *model.BugReport) error {
+	return p.publish(ctx, model.EventReportCreated, report.ID, model.WebhookData{Report: report})
+}
+
+// ReportStatusChanged queues report.status_changed deliveries for every subscribed endpoint.
+func (p *Publisher) ReportStatusChanged(ctx context.Context, report *model.BugReport, previous string) error {
+	return p.publish(ctx, model.EventReportStatusChanged, uuid.New().String(), model.WebhookData{
+		Report:         report,
+		PreviousStatus: previous,
+	})
+}
+
+func (p *Publisher) publish(ctx context.Context, event model.WebhookEventType, eventKey string, data model.WebhookData) error {
+	now := time.Now().UTC().Format(time.RFC3339)
+
+	for _, ep := range p.cfg.WebhookEndpointsFor(data.Report.SiteID, string(event)) {
+		deliveryID := uuid.New().String()
+		payload, err := json.Marshal(model.WebhookPayload{
+			Type:       event,
+			EventID:    data.Report.ID,
+			DeliveryID: deliveryID,
+			OccurredAt: now,
+			Data:       data,
+		})
+		if err != nil {
+			return fmt.Errorf("marshal webhook payload: %w", err)
+		}
+
+		err = p.repo.CreateWebhookDelivery(ctx, &model.WebhookDelivery{
+			ID:         deliveryID,
+			EndpointID: ep.ID,
+			SiteID:     data.Report.SiteID,
+			EventType:  event,
+			ReportID:   data.Report.ID,
+			URL:        ep.URL,
+			Payload:    payload,
+		}, string(event)+":"+eventKey)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
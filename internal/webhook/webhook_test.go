@@ -0,0 +1,410 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/devrimsoft/bug-notifications-api/internal/config"
+	"github.com/devrimsoft/bug-notifications-api/internal/model"
+)
+
+// memStore keeps deliveries in memory, following the state changes the
+// webhook_deliveries queries make.
+type memStore struct {
+	mu         sync.Mutex
+	deliveries map[string]*model.WebhookDelivery
+	eventKeys  map[string]bool
+	order      []string
+	lastNext   map[string]time.Time
+}
+
+func newMemStore() *memStore {
+	return &memStore{
+		deliveries: make(map[string]*model.WebhookDelivery),
+		eventKeys:  make(map[string]bool),
+		lastNext:   make(map[string]time.Time),
+	}
+}
+
+func (s *memStore) CreateWebhookDelivery(_ context.Context, d *model.WebhookDelivery, eventKey string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := d.EndpointID + "|" + eventKey
+	if s.eventKeys[key] {
+		return nil
+	}
+	s.eventKeys[key] = true
+	cp := *d
+	cp.Status = model.DeliveryPending
+	cp.NextAttemptAt = time.Now()
+	s.deliveries[d.ID] = &cp
+	s.order = append(s.order, d.ID)
+	return nil
+}
+
+func (s *memStore) ClaimDueWebhookDeliveries(_ context.Context, limit int, lease time.Duration) ([]model.WebhookDelivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []model.WebhookDelivery
+	for _, id := range s.order {
+		d := s.deliveries[id]
+		if len(out) == limit || d.Status != model.DeliveryPending || d.NextAttemptAt.After(time.Now()) {
+			continue
+		}
+		d.NextAttemptAt = time.Now().Add(lease)
+		out = append(out, *d)
+	}
+	return out, nil
+}
+
+func (s *memStore) MarkWebhookDelivered(_ context.Context, id string, statusCode int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d := s.deliveries[id]
+	d.Status = model.DeliveryDelivered
+	d.Attempts++
+	d.LastStatusCode = &statusCode
+	d.LastError = nil
+	now := time.Now()
+	d.DeliveredAt = &now
+	return nil
+}
+
+func (s *memStore) MarkWebhookAttemptFailed(_ context.Context, id string, statusCode int, errMsg string, nextAttempt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d := s.deliveries[id]
+	d.Attempts++
+	d.LastStatusCode = nil
+	if statusCode != 0 {
+		d.LastStatusCode = &statusCode
+	}
+	d.LastError = &errMsg
+	s.lastNext[id] = nextAttempt
+	if nextAttempt.IsZero() {
+		d.Status = model.DeliveryFailed
+	} else {
+		d.NextAttemptAt = nextAttempt
+	}
+	return nil
+}
+
+// replay resets a delivery the way ReplayWebhookDelivery does.
+func (s *memStore) replay(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d := s.deliveries[id]
+	d.Status = model.DeliveryPending
+	d.Attempts = 0
+	d.NextAttemptAt = time.Now()
+	d.DeliveredAt = nil
+}
+
+// makeDue lets a scheduled retry run now.
+func (s *memStore) makeDue(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deliveries[id].NextAttemptAt = time.Now()
+}
+
+func (s *memStore) get(id string) model.WebhookDelivery {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return *s.deliveries[id]
+}
+
+func (s *memStore) next(id string) time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastNext[id]
+}
+
+const testSecret = "whsec_test"
+
+// setup publishes report.created for one report to an endpoint served by h.
+func setup(t *testing.T, maxAttempts int, h http.HandlerFunc) (*Dispatcher, *memStore, string) {
+	t.Helper()
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+
+	cfg := &config.Config{
+		WebhookEndpoints:   []config.WebhookEndpoint{{ID: "ops", SiteID: "*", URL: srv.URL, Secret: testSecret}},
+		WebhookMaxAttempts: maxAttempts,
+	}
+	store := newMemStore()
+	p := &Publisher{repo: store, cfg: cfg}
+	report := &model.BugReport{ID: "7c9e6679-7425-40de-944b-e07fc1f90ae7", SiteID: "example.com", Status: "open"}
+	if err := p.ReportCreated(context.Background(), report); err != nil {
+		t.Fatal(err)
+	}
+	if len(store.order) != 1 {
+		t.Fatalf("published %d deliveries, want 1", len(store.order))
+	}
+
+	d := NewDispatcher(nil, cfg)
+	d.repo = store
+	return d, store, store.order[0]
+}
+
+func TestSign(t *testing.T) {
+	got := Sign(testSecret, time.Unix(1700000000, 0), []byte(`{"type":"report.created"}`))
+	want := "sha256=08286f704d31c8b1b1649d0701cb8c707d5d60d8944eb1cd75a057622cb4c269"
+	if got != want {
+		t.Errorf("Sign = %s, want %s", got, want)
+	}
+}
+
+func TestDispatcherSignsDeliveries(t *testing.T) {
+	var got *http.Request
+	var body []byte
+	d, store, id := setup(t, 3, func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	before := time.Now().Unix()
+	d.poll(context.Background())
+	if got == nil {
+		t.Fatal("no request was sent")
+	}
+
+	del := store.get(id)
+	if string(body) != string(del.Payload) {
+		t.Errorf("body = %s, want the stored payload %s", body, del.Payload)
+	}
+	if ct := got.Header.Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	if e := got.Header.Get(HeaderEvent); e != string(model.EventReportCreated) {
+		t.Errorf("%s = %q", HeaderEvent, e)
+	}
+	if h := got.Header.Get(HeaderDeliveryID); h != id {
+		t.Errorf("%s = %q, want %s", HeaderDeliveryID, h, id)
+	}
+	ts, err := strconv.ParseInt(got.Header.Get(HeaderTimestamp), 10, 64)
+	if err != nil || ts < before || ts > time.Now().Unix() {
+		t.Fatalf("%s = %q", HeaderTimestamp, got.Header.Get(HeaderTimestamp))
+	}
+	if sig := got.Header.Get(HeaderSignature); sig != Sign(testSecret, time.Unix(ts, 0), body) {
+		t.Errorf("%s = %q does not match the body and timestamp", HeaderSignature, sig)
+	}
+
+	var payload model.WebhookPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		t.Fatal(err)
+	}
+	if payload.DeliveryID != id || payload.EventID != del.ReportID || payload.Data.Report.SiteID != "example.com" {
+		t.Errorf("payload = %+v", payload)
+	}
+
+	if del.Status != model.DeliveryDelivered || del.Attempts != 1 || *del.LastStatusCode != http.StatusNoContent {
+		t.Errorf("delivery = %s after %d attempts, status code %v", del.Status, del.Attempts, del.LastStatusCode)
+	}
+}
+
+func TestDispatcherRetries(t *testing.T) {
+	var calls atomic.Int32
+	d, store, id := setup(t, 4, func(w http.ResponseWriter, r *http.Request) {
+		switch calls.Add(1) {
+		case 1:
+			http.Error(w, "maintenance", http.StatusServiceUnavailable)
+		case 2:
+			time.Sleep(300 * time.Millisecond) // outlives the client timeout
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+	})
+	d.client.Timeout = 100 * time.Millisecond
+	ctx := context.Background()
+
+	d.poll(ctx)
+	del := store.get(id)
+	if del.Status != model.DeliveryPending || del.Attempts != 1 || del.LastStatusCode == nil || *del.LastStatusCode != 503 {
+		t.Fatalf("after a 503: %s, %d attempts, status code %v", del.Status, del.Attempts, del.LastStatusCode)
+	}
+	// The first retry waits BaseDelay, less up to 20% jitter
+	if wait := time.Until(store.next(id)); wait < 23*time.Second || wait > 30*time.Second {
+		t.Errorf("first retry in %v, want 24s to 30s", wait)
+	}
+
+	// Not due yet
+	d.poll(ctx)
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("sent %d times before the retry was due", n)
+	}
+
+	store.makeDue(id)
+	d.poll(ctx)
+	del = store.get(id)
+	if del.Status != model.DeliveryPending || del.Attempts != 2 || del.LastStatusCode != nil {
+		t.Fatalf("after a timeout: %s, %d attempts, status code %v", del.Status, del.Attempts, del.LastStatusCode)
+	}
+	if wait := time.Until(store.next(id)); wait < 47*time.Second || wait > time.Minute {
+		t.Errorf("second retry in %v, want 48s to 60s", wait)
+	}
+
+	store.makeDue(id)
+	d.poll(ctx)
+	if del = store.get(id); del.Status != model.DeliveryDelivered || del.Attempts != 3 || del.LastError != nil {
+		t.Errorf("after a 200: %s, %d attempts, error %v", del.Status, del.Attempts, del.LastError)
+	}
+}
+
+func TestDispatcherGivesUp(t *testing.T) {
+	var calls atomic.Int32
+	d, store, id := setup(t, 3, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		http.Error(w, "boom", http.StatusInternalServerError)
+	})
+	ctx := context.Background()
+
+	for attempt := 1; attempt <= 3; attempt++ {
+		d.poll(ctx)
+		del := store.get(id)
+		if del.Attempts != attempt {
+			t.Fatalf("attempt %d recorded as %d", attempt, del.Attempts)
+		}
+		final := store.next(id).IsZero()
+		if final != (attempt == 3) {
+			t.Errorf("attempt %d: final = %v", attempt, final)
+		}
+		if !final {
+			store.makeDue(id)
+		}
+	}
+
+	d.poll(ctx)
+	del := store.get(id)
+	if del.Status != model.DeliveryFailed || calls.Load() != 3 {
+		t.Errorf("delivery %s after %d requests, want failed after 3", del.Status, calls.Load())
+	}
+	if del.LastError == nil || *del.LastError != "endpoint returned status 500: boom\n" {
+		t.Errorf("last error = %v", del.LastError)
+	}
+}
+
+func TestDispatcherReplay(t *testing.T) {
+	var fail atomic.Bool
+	fail.Store(true)
+	var ids []string
+	var mu sync.Mutex
+	d, store, id := setup(t, 1, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		ids = append(ids, r.Header.Get(HeaderDeliveryID))
+		mu.Unlock()
+		if fail.Load() {
+			http.Error(w, "down", http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+	ctx := context.Background()
+
+	d.poll(ctx)
+	if del := store.get(id); del.Status != model.DeliveryFailed {
+		t.Fatalf("delivery %s, want failed", del.Status)
+	}
+
+	// The endpoint recovers and an operator replays the delivery
+	fail.Store(false)
+	store.replay(id)
+	d.poll(ctx)
+	del := store.get(id)
+	if del.Status != model.DeliveryDelivered || del.Attempts != 1 {
+		t.Errorf("replayed delivery %s after %d attempts", del.Status, del.Attempts)
+	}
+	// The receiver sees the same delivery ID, so it can drop duplicates
+	if len(ids) != 2 || ids[0] != id || ids[1] != id {
+		t.Errorf("delivery IDs sent = %v, want %s twice", ids, id)
+	}
+}
+
+func TestDispatcherRemovedEndpoint(t *testing.T) {
+	var calls atomic.Int32
+	d, store, id := setup(t, 3, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+	})
+	d.cfg = &config.Config{WebhookMaxAttempts: 3}
+
+	d.poll(context.Background())
+	del := store.get(id)
+	if calls.Load() != 0 || del.Attempts != 1 || del.Status != model.DeliveryPending {
+		t.Errorf("%d requests; delivery %s after %d attempts", calls.Load(), del.Status, del.Attempts)
+	}
+	if del.LastError == nil || *del.LastError != `endpoint "ops" is no longer configured` {
+		t.Errorf("last error = %v", del.LastError)
+	}
+}
+
+func TestPublisher(t *testing.T) {
+	cfg := &config.Config{WebhookEndpoints: []config.WebhookEndpoint{
+		{ID: "all", SiteID: "*", URL: "https://hooks.example.net/all"},
+		{ID: "shop-created", SiteID: "shop.example.com", URL: "https://hooks.example.net/shop", Events: []string{"report.created"}},
+		{ID: "blog", SiteID: "blog.example.com", URL: "https://hooks.example.net/blog"},
+	}}
+	store := newMemStore()
+	p := &Publisher{repo: store, cfg: cfg}
+	ctx := context.Background()
+	report := &model.BugReport{ID: "1b4e28ba-2fa1-41d2-883f-0016d3cca427", SiteID: "shop.example.com", Status: "open"}
+
+	endpoints := func() []string {
+		var out []string
+		for _, id := range store.order {
+			out = append(out, store.deliveries[id].EndpointID)
+		}
+		return out
+	}
+
+	if err := p.ReportCreated(ctx, report); err != nil {
+		t.Fatal(err)
+	}
+	if got := endpoints(); len(got) != 2 || got[0] != "all" || got[1] != "shop-created" {
+		t.Fatalf("report.created went to %v, want [all shop-created]", got)
+	}
+	for _, id := range store.order {
+		del := store.deliveries[id]
+		var payload model.WebhookPayload
+		if err := json.Unmarshal(del.Payload, &payload); err != nil {
+			t.Fatal(err)
+		}
+		if payload.Type != model.EventReportCreated || payload.DeliveryID != del.ID || payload.EventID != report.ID {
+			t.Errorf("payload for %s = %+v", del.EndpointID, payload)
+		}
+		if del.SiteID != report.SiteID || del.ReportID != report.ID || del.EventType != model.EventReportCreated {
+			t.Errorf("delivery = %+v", del)
+		}
+	}
+
+	// Publishing the same report again is a no-op
+	if err := p.ReportCreated(ctx, report); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(store.order); n != 2 {
+		t.Errorf("%d deliveries after publishing twice, want 2", n)
+	}
+
+	report.Status = "resolved"
+	if err := p.ReportStatusChanged(ctx, report, "open"); err != nil {
+		t.Fatal(err)
+	}
+	got := endpoints()
+	if len(got) != 3 || got[2] != "all" {
+		t.Fatalf("report.status_changed went to %v, want only all", got[2:])
+	}
+	var payload model.WebhookPayload
+	if err := json.Unmarshal(store.deliveries[store.order[2]].Payload, &payload); err != nil {
+		t.Fatal(err)
+	}
+	if payload.Data.PreviousStatus != "open" || payload.Data.Report.Status != "resolved" {
+		t.Errorf("status change payload = %+v", payload.Data)
+	}
+}
@@ -0,0 +1,44 @@
+package worker
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/devrimsoft/bug-notifications-api/internal/queue"
+)
+
+// PromoteInterval is how often the retry set is checked for due messages.
+const PromoteInterval = time.Second
+
+// PromoteRetries periodically moves delayed retries whose time has come back
+// onto the main queue. Blocks until context is cancelled.
+func PromoteRetries(ctx context.Context, consumer *queue.Consumer) {
+	ticker := time.NewTicker(PromoteInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		// Drain in batches until nothing due is left
+		for {
+			n, err := consumer.PromoteDue(ctx)
+			if err != nil {
+				if ctx.Err() == nil {
+					slog.Error("promote retries failed", "error", err)
+				}
+				break
+			}
+			if n > 0 {
+				slog.Info("retries promoted", "count", n)
+			}
+			if n == 0 || ctx.Err() != nil {
+				break
+			}
+		}
+	}
+}
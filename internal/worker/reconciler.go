@@ -0,0 +1,162 @@
+package worker
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/devrimsoft/bug-notifications-api/internal/config"
+	"github.com/devrimsoft/bug-notifications-api/internal/db"
+	"github.com/devrimsoft/bug-notifications-api/internal/model"
+	"github.com/devrimsoft/bug-notifications-api/internal/storage"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// ReconcileInterval is how often stored images are checked for orphans.
+	ReconcileInterval = time.Hour
+
+	// reconcileLockKey makes sure only one worker process reconciles per
+	// interval. The lock is not released; it expires shortly before the next run.
+	reconcileLockKey = "images:reconcile:lock"
+
+	// reconcileBatch is how many keys are checked against the database at once.
+	reconcileBatch = 500
+)
+
+// imageRefs is the part of *db.Repository the reconciler reads.
+type imageRefs interface {
+	ReferencedImageKeys(ctx context.Context, keys []string) (map[string]bool, error)
+	ListSites(ctx context.Context) ([]model.Site, error)
+}
+
+// Reconciler deletes stored images that no report references, such as
+// uploads of a report that was never saved. Only images older than the grace
+// period are touched, so uploads still being attached are left alone.
+type Reconciler struct {
+	rdb   *redis.Client
+	repo  imageRefs
+	store storage.Cleaner
+	live  *config.Live
+	grace time.Duration
+}
+
+func NewReconciler(rdb *redis.Client, repo *db.Repository, store storage.Cleaner, live *config.Live) *Reconciler {
+	return &Reconciler{
+		rdb:   rdb,
+		repo:  repo,
+		store: store,
+		live:  live,
+		grace: live.Get().ImageOrphanGrace,
+	}
+}
+
+// Run reconciles every ReconcileInterval until the context is cancelled.
+func (r *Reconciler) Run(ctx context.Context) {
+	ticker := time.NewTicker(ReconcileInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		ok, err := r.rdb.SetNX(ctx, reconcileLockKey, time.Now().UTC().Format(time.RFC3339), ReconcileInterval-time.Minute).Result()
+		if err != nil {
+			if ctx.Err() == nil {
+				slog.Error("image reconcile lock failed", "error", err)
+			}
+			continue
+		}
+		if !ok {
+			continue // another worker has this run
+		}
+		r.reconcileAll(ctx)
+	}
+}
+
+// reconcileAll deletes the orphaned images of every site.
+func (r *Reconciler) reconcileAll(ctx context.Context) {
+	sites, err := r.sites(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			slog.Error("image reconcile failed", "error", err)
+		}
+		return
+	}
+	for _, site := range sites {
+		checked, deleted, err := r.reconcile(ctx, site+"/")
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			slog.Error("image reconcile failed", "site_id", site, "error", err)
+		}
+		if deleted > 0 {
+			slog.Info("orphaned images deleted", "site_id", site, "checked", checked, "deleted", deleted)
+		}
+	}
+}
+
+// sites returns the sites whose prefixes hold this service's images: the
+// configured ones and every row of the sites table, so the uploads of a
+// deactivated site are still cleaned up.
+func (r *Reconciler) sites(ctx context.Context) ([]string, error) {
+	sites := slices.Clone(r.live.Get().Sites)
+	rows, err := r.repo.ListSites(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range rows {
+		sites = append(sites, s.Domain)
+	}
+	slices.Sort(sites)
+	return slices.Compact(sites), nil
+}
+
+// reconcile deletes the orphaned images under prefix.
+func (r *Reconciler) reconcile(ctx context.Context, prefix string) (checked, deleted int, err error) {
+	cutoff := time.Now().Add(-r.grace)
+	var batch []string
+
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		refs, err := r.repo.ReferencedImageKeys(ctx, batch)
+		if err != nil {
+			return err
+		}
+		for _, key := range batch {
+			if refs[key] {
+				continue
+			}
+			if err := r.store.Delete(ctx, key); err != nil {
+				slog.Error("delete orphaned image failed", "key", key, "error", err)
+				continue
+			}
+			deleted++
+		}
+		checked += len(batch)
+		batch = batch[:0]
+		return nil
+	}
+
+	err = r.store.List(ctx, prefix, func(obj storage.Object) error {
+		if obj.ModTime.After(cutoff) {
+			return nil
+		}
+		batch = append(batch, obj.Key)
+		if len(batch) < reconcileBatch {
+			return nil
+		}
+		return flush()
+	})
+	if err == nil {
+		err = flush()
+	}
+	return checked, deleted, err
+}
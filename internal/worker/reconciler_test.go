@@ -0,0 +1,179 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/devrimsoft/bug-notifications-api/internal/config"
+	"github.com/devrimsoft/bug-notifications-api/internal/model"
+	"github.com/devrimsoft/bug-notifications-api/internal/storage"
+)
+
+// memStorage is an in-memory storage.Cleaner.
+type memStorage struct {
+	mu      sync.Mutex
+	objects map[string]memObject
+}
+
+type memObject struct {
+	contentType string
+	data        []byte
+	modTime     time.Time
+}
+
+func newMemStorage() *memStorage {
+	return &memStorage{objects: make(map[string]memObject)}
+}
+
+func (s *memStorage) Put(_ context.Context, key, contentType string, data []byte) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.objects[key] = memObject{contentType: contentType, data: data, modTime: time.Now()}
+	return "https://cdn.example.com/" + key, nil
+}
+
+func (s *memStorage) Delete(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.objects, key)
+	return nil
+}
+
+func (s *memStorage) List(_ context.Context, prefix string, fn func(storage.Object) error) error {
+	s.mu.Lock()
+	var objs []storage.Object
+	for k, o := range s.objects {
+		if strings.HasPrefix(k, prefix) {
+			objs = append(objs, storage.Object{Key: k, ModTime: o.modTime})
+		}
+	}
+	s.mu.Unlock()
+	for _, o := range objs {
+		if err := fn(o); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// putAt stores an object last modified at t.
+func (s *memStorage) putAt(key string, t time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.objects[key] = memObject{modTime: t}
+}
+
+func (s *memStorage) keys() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var keys []string
+	for k := range s.objects {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// fakeRefs holds the referenced keys and the rows of the sites table.
+type fakeRefs struct {
+	referenced map[string]bool
+	sites      []model.Site
+	batches    int
+}
+
+func (f *fakeRefs) ReferencedImageKeys(_ context.Context, keys []string) (map[string]bool, error) {
+	f.batches++
+	refs := make(map[string]bool)
+	for _, k := range keys {
+		if f.referenced[k] {
+			refs[k] = true
+		}
+	}
+	return refs, nil
+}
+
+func (f *fakeRefs) ListSites(context.Context) ([]model.Site, error) {
+	return f.sites, nil
+}
+
+func TestReconcile(t *testing.T) {
+	const grace = 24 * time.Hour
+	old := time.Now().Add(-grace - time.Hour)
+	store := newMemStorage()
+	refs := &fakeRefs{
+		referenced: map[string]bool{
+			"a.com/2026/01/kept.png":       true,
+			"a.com/2026/01/kept_thumb.jpg": true,
+			"old.com/2026/01/kept.png":     true,
+		},
+		sites: []model.Site{
+			{Domain: "a.com", Active: true},
+			{Domain: "old.com", Active: false}, // deactivated through the admin API
+		},
+	}
+	r := &Reconciler{
+		repo:  refs,
+		store: store,
+		live:  config.NewLive(&config.Config{Sites: []string{"a.com", "env.com"}}),
+		grace: grace,
+	}
+
+	for _, k := range []string{
+		"a.com/2026/01/kept.png",
+		"a.com/2026/01/kept_thumb.jpg",
+		"a.com/2026/01/orphan.png",
+		"a.com/2026/01/orphan_thumb.jpg",
+		"old.com/2026/01/kept.png",
+		"old.com/2026/01/orphan.png",
+		"env.com/2026/01/orphan.log",
+		"unknown.com/2026/01/orphan.png", // not a site of this service
+	} {
+		store.putAt(k, old)
+	}
+	store.putAt("a.com/2026/10/fresh.png", time.Now()) // may still be attached
+
+	r.reconcileAll(context.Background())
+
+	want := []string{
+		"a.com/2026/01/kept.png",
+		"a.com/2026/01/kept_thumb.jpg",
+		"a.com/2026/10/fresh.png",
+		"old.com/2026/01/kept.png",
+		"unknown.com/2026/01/orphan.png",
+	}
+	if got := store.keys(); !slices.Equal(got, want) {
+		t.Errorf("objects left = %v, want %v", got, want)
+	}
+}
+
+func TestReconcileBatches(t *testing.T) {
+	old := time.Now().Add(-48 * time.Hour)
+	store := newMemStorage()
+	refs := &fakeRefs{referenced: make(map[string]bool)}
+	for i := range reconcileBatch + 10 {
+		key := fmt.Sprintf("a.com/2026/01/%04d.png", i)
+		store.putAt(key, old)
+		if i%2 == 0 {
+			refs.referenced[key] = true
+		}
+	}
+	r := &Reconciler{repo: refs, store: store, grace: 24 * time.Hour}
+
+	checked, deleted, err := r.reconcile(context.Background(), "a.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if checked != reconcileBatch+10 || deleted != (reconcileBatch+10)/2 || refs.batches != 2 {
+		t.Errorf("checked %d, deleted %d in %d batches", checked, deleted, refs.batches)
+	}
+	for _, k := range store.keys() {
+		if !refs.referenced[k] {
+			t.Errorf("orphan %s was kept", k)
+		}
+	}
+}
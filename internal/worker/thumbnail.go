@@ -0,0 +1,67 @@
+package worker
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/gif" // register decoders for image.Decode
+	"image/jpeg"
+	_ "image/png"
+	"path"
+	"strings"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+const (
+	// ThumbnailSize is the longest side of a thumbnail, in pixels.
+	ThumbnailSize = 320
+
+	thumbnailQuality = 80
+)
+
+// thumbnailKey is where the thumbnail of the image stored under key goes:
+// next to it, with a "_thumb.jpg" suffix.
+func thumbnailKey(key string) string {
+	return strings.TrimSuffix(key, path.Ext(key)) + "_thumb.jpg"
+}
+
+// imageSize returns the pixel size of an image without decoding it.
+func imageSize(data []byte) (width, height int, err error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return 0, 0, fmt.Errorf("read image size: %w", err)
+	}
+	return cfg.Width, cfg.Height, nil
+}
+
+// thumbnail returns a JPEG that fits in ThumbnailSize x ThumbnailSize, or nil
+// if the image already fits. Transparent areas are filled with white; for
+// animated images the first frame is used.
+func thumbnail(data []byte) ([]byte, error) {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("decode image: %w", err)
+	}
+	b := img.Bounds()
+	if b.Dx() <= ThumbnailSize && b.Dy() <= ThumbnailSize {
+		return nil, nil
+	}
+
+	w, h := ThumbnailSize, ThumbnailSize
+	if b.Dx() > b.Dy() {
+		h = max(1, b.Dy()*ThumbnailSize/b.Dx())
+	} else {
+		w = max(1, b.Dx()*ThumbnailSize/b.Dy())
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(dst, dst.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, b, draw.Over, nil)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: thumbnailQuality}); err != nil {
+		return nil, fmt.Errorf("encode thumbnail: %w", err)
+	}
+	return buf.Bytes(), nil
+}
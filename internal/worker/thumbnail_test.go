@@ -0,0 +1,148 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"slices"
+	"testing"
+
+	"github.com/devrimsoft/bug-notifications-api/internal/model"
+)
+
+// pngImage encodes a w x h PNG whose left half is transparent.
+func pngImage(t *testing.T, w, h int) []byte {
+	t.Helper()
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := range h {
+		for x := w / 2; x < w; x++ {
+			img.Set(x, y, color.NRGBA{R: 200, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestThumbnail(t *testing.T) {
+	tests := []struct {
+		w, h         int
+		wantW, wantH int
+		fitsAlready  bool
+	}{
+		{w: 1000, h: 500, wantW: 320, wantH: 160},
+		{w: 100, h: 2000, wantW: 16, wantH: 320},
+		{w: 5000, h: 4, wantW: 320, wantH: 1},
+		{w: 320, h: 200, fitsAlready: true},
+	}
+	for _, tt := range tests {
+		thumb, err := thumbnail(pngImage(t, tt.w, tt.h))
+		if err != nil {
+			t.Fatalf("%dx%d: %v", tt.w, tt.h, err)
+		}
+		if tt.fitsAlready {
+			if thumb != nil {
+				t.Errorf("%dx%d: got a thumbnail for an image that fits", tt.w, tt.h)
+			}
+			continue
+		}
+		img, err := jpeg.Decode(bytes.NewReader(thumb))
+		if err != nil {
+			t.Fatalf("%dx%d: thumbnail is not a JPEG: %v", tt.w, tt.h, err)
+		}
+		if b := img.Bounds(); b.Dx() != tt.wantW || b.Dy() != tt.wantH {
+			t.Errorf("%dx%d: thumbnail is %dx%d, want %dx%d", tt.w, tt.h, b.Dx(), b.Dy(), tt.wantW, tt.wantH)
+		}
+	}
+
+	// Transparent areas turn white
+	thumb, _ := thumbnail(pngImage(t, 1000, 500))
+	img, _ := jpeg.Decode(bytes.NewReader(thumb))
+	if r, g, b, _ := img.At(10, 80).RGBA(); r>>8 < 240 || g>>8 < 240 || b>>8 < 240 {
+		t.Errorf("transparent pixel = %d,%d,%d; want white", r>>8, g>>8, b>>8)
+	}
+
+	if _, err := thumbnail([]byte("not an image")); err == nil {
+		t.Error("thumbnail accepted garbage")
+	}
+}
+
+func TestThumbnailKey(t *testing.T) {
+	for key, want := range map[string]string{
+		"a.com/2026/01/0b9a3c57.png":  "a.com/2026/01/0b9a3c57_thumb.jpg",
+		"a.com/2026/01/0b9a3c57.webp": "a.com/2026/01/0b9a3c57_thumb.jpg",
+		"a.com/2026/01/0b9a3c57":      "a.com/2026/01/0b9a3c57_thumb.jpg",
+	} {
+		if got := thumbnailKey(key); got != want {
+			t.Errorf("thumbnailKey(%q) = %q, want %q", key, got, want)
+		}
+	}
+}
+
+func TestStoreFile(t *testing.T) {
+	ctx := context.Background()
+	store := newMemStorage()
+	w := &Worker{images: store}
+
+	// A large image gets a JPEG thumbnail next to it
+	data := pngImage(t, 1000, 500)
+	a, err := w.storeFile(ctx, model.StagedFile{ObjectKey: "a.com/2026/01/big.png", ContentType: "image/png", Kind: model.AttachmentImage}, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.URL != "https://cdn.example.com/a.com/2026/01/big.png" || a.Width != 1000 || a.Height != 500 || a.Size != int64(len(data)) || len(a.SHA256) != 64 {
+		t.Errorf("attachment = %+v", a)
+	}
+	if a.ThumbnailURL == nil || *a.ThumbnailURL != "https://cdn.example.com/a.com/2026/01/big_thumb.jpg" {
+		t.Fatalf("thumbnail URL = %v", a.ThumbnailURL)
+	}
+	if thumb := store.objects["a.com/2026/01/big_thumb.jpg"]; thumb.contentType != "image/jpeg" {
+		t.Errorf("thumbnail stored as %q", thumb.contentType)
+	}
+
+	// A small image is its own thumbnail
+	a, err = w.storeFile(ctx, model.StagedFile{ObjectKey: "a.com/2026/01/small.png", ContentType: "image/png", Kind: model.AttachmentImage}, pngImage(t, 64, 64))
+	if err != nil || a.ThumbnailURL == nil || *a.ThumbnailURL != a.URL {
+		t.Errorf("small image: thumbnail URL = %v, %v; want its own URL", a.ThumbnailURL, err)
+	}
+	if _, ok := store.objects["a.com/2026/01/small_thumb.jpg"]; ok {
+		t.Error("small image: a thumbnail was stored")
+	}
+
+	// A thumbnail failure stores the image without one
+	header := data[:33] // signature and IHDR: the size is readable, the pixels are not
+	a, err = w.storeFile(ctx, model.StagedFile{ObjectKey: "a.com/2026/01/cut.png", ContentType: "image/png", Kind: model.AttachmentImage}, header)
+	if err != nil {
+		t.Fatalf("cut image: %v", err)
+	}
+	if a.URL == "" || a.Width != 1000 || a.ThumbnailURL != nil {
+		t.Errorf("cut image: attachment = %+v, want stored without a thumbnail", a)
+	}
+	a, err = w.storeFile(ctx, model.StagedFile{ObjectKey: "a.com/2026/01/bad.png", ContentType: "image/png", Kind: model.AttachmentImage}, []byte("not an image"))
+	if err != nil || a.URL == "" || a.ThumbnailURL != nil || a.Width != 0 {
+		t.Errorf("unreadable image: attachment = %+v, %v; want stored without a thumbnail", a, err)
+	}
+
+	// Other kinds are stored as they are
+	a, err = w.storeFile(ctx, model.StagedFile{ObjectKey: "a.com/2026/01/console.log", ContentType: "text/plain", Kind: model.AttachmentLog}, []byte("boom"))
+	if err != nil || a.Kind != model.AttachmentLog || a.ThumbnailURL != nil {
+		t.Errorf("log: attachment = %+v, %v", a, err)
+	}
+
+	want := []string{
+		"a.com/2026/01/bad.png",
+		"a.com/2026/01/big.png",
+		"a.com/2026/01/big_thumb.jpg",
+		"a.com/2026/01/console.log",
+		"a.com/2026/01/cut.png",
+		"a.com/2026/01/small.png",
+	}
+	if got := store.keys(); !slices.Equal(got, want) {
+		t.Errorf("stored %v, want %v", got, want)
+	}
+}
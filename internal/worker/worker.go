@@ -2,21 +2,38 @@ package worker
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"log/slog"
 
 	"github.com/devrimsoft/bug-notifications-api/internal/db"
+	"github.com/devrimsoft/bug-notifications-api/internal/email"
+	"github.com/devrimsoft/bug-notifications-api/internal/model"
+	"github.com/devrimsoft/bug-notifications-api/internal/notify"
 	"github.com/devrimsoft/bug-notifications-api/internal/queue"
+	"github.com/devrimsoft/bug-notifications-api/internal/storage"
+	"github.com/devrimsoft/bug-notifications-api/internal/webhook"
 )
 
 type Worker struct {
 	consumer *queue.Consumer
 	repo     *db.Repository
+	images   storage.Store // nil when image storage is not configured
+	hooks    *webhook.Publisher
+	mail     *email.Publisher
+	notifier *notify.Notifier
 }
 
-func New(consumer *queue.Consumer, repo *db.Repository) *Worker {
+func New(consumer *queue.Consumer, repo *db.Repository, images storage.Store, hooks *webhook.Publisher, mail *email.Publisher, notifier *notify.Notifier) *Worker {
 	return &Worker{
 		consumer: consumer,
 		repo:     repo,
+		images:   images,
+		hooks:    hooks,
+		mail:     mail,
+		notifier: notifier,
 	}
 }
 
@@ -37,6 +54,10 @@ func (w *Worker) Run(ctx context.Context) {
 			if ctx.Err() != nil {
 				return // context cancelled
 			}
+			if errors.Is(err, queue.ErrQuarantined) {
+				slog.Warn("poison message quarantined", "error", err)
+				continue
+			}
 			slog.Error("dequeue failed", "error", err)
 			continue
 		}
@@ -47,13 +68,177 @@ func (w *Worker) Run(ctx context.Context) {
 		slog.Info("processing report", "event_id", msg.EventID, "site_id", msg.SiteID, "retry", msg.RetryCount)
 
 		if err := w.repo.InsertReport(ctx, msg); err != nil {
-			slog.Error("insert failed, requeuing", "event_id", msg.EventID, "error", err, "retry", msg.RetryCount)
-			if reqErr := w.consumer.Requeue(ctx, msg); reqErr != nil {
-				slog.Error("requeue failed", "event_id", msg.EventID, "error", reqErr)
+			w.retry(ctx, msg, "insert failed, scheduling retry", err)
+			continue
+		}
+
+		report, err := w.repo.GetReport(ctx, msg.EventID)
+		if err == nil && report == nil {
+			err = fmt.Errorf("report %s not found after insert", msg.EventID)
+		}
+		if err != nil {
+			w.retry(ctx, msg, "load failed, scheduling retry", err)
+			continue
+		}
+
+		// Upload staged files before anyone is notified, so notifications
+		// carry the attachment links. A message that goes to the DLQ keeps
+		// its staged files, so a replay uploads them.
+		if report.AttachmentsPending {
+			if err := w.attachFiles(ctx, msg, report); err != nil {
+				w.retry(ctx, msg, "attachment upload failed, scheduling retry", err)
+				continue
 			}
+		}
+
+		// Outbound webhooks and emails are recorded before the ack so a crash
+		// here redelivers the message; publishing is idempotent per report.
+		if err := w.afterInsert(ctx, report); err != nil {
+			w.retry(ctx, msg, "publish failed, scheduling retry", err)
 			continue
 		}
 
+		if err := w.consumer.Ack(ctx, msg); err != nil {
+			// The message will be redelivered; InsertReport is idempotent.
+			slog.Error("ack failed", "event_id", msg.EventID, "error", err)
+		}
+
 		slog.Info("report saved", "event_id", msg.EventID)
 	}
 }
+
+// retry logs a processing failure and hands the message back to the queue.
+func (w *Worker) retry(ctx context.Context, msg *model.QueueMessage, logMsg string, err error) {
+	slog.Error(logMsg, "event_id", msg.EventID, "error", err, "retry", msg.RetryCount)
+	if _, reqErr := w.consumer.Requeue(ctx, msg, err); reqErr != nil {
+		slog.Error("requeue failed", "event_id", msg.EventID, "error", reqErr)
+	}
+}
+
+// afterInsert fans a stored report out to webhooks, email and chat channels.
+// Webhook and email outbox errors are returned; chat notifications are
+// best-effort, bounded in time and deduplicated per route, so a redelivered
+// message does not notify twice.
+func (w *Worker) afterInsert(ctx context.Context, report *model.BugReport) error {
+	if w.hooks.Enabled() {
+		if err := w.hooks.ReportCreated(ctx, report); err != nil {
+			return err
+		}
+	}
+	if w.mail.Enabled() {
+		if err := w.mail.ReportCreated(ctx, report); err != nil {
+			return err
+		}
+	}
+	if w.notifier.Enabled() {
+		w.notifier.ReportCreated(ctx, report)
+	}
+	return nil
+}
+
+// attachFiles uploads a report's staged files, with thumbnails for images,
+// and saves them as attachments of the report. Uploads that succeeded on an
+// earlier attempt are not repeated, so a retry only uploads what is left. A
+// staged file that has expired is counted as lost on the report rather than
+// failing the report forever.
+func (w *Worker) attachFiles(ctx context.Context, msg *model.QueueMessage, report *model.BugReport) error {
+	if w.images == nil {
+		return errors.New("image storage is not configured")
+	}
+
+	done, err := w.consumer.Uploads(ctx, msg.EventID)
+	if err != nil {
+		return err
+	}
+
+	var attachments []model.Attachment
+	lost := 0
+	for i, f := range msg.StagedFiles {
+		a, ok := done[i]
+		if !ok {
+			data, err := w.consumer.StagedFile(ctx, msg.EventID, i)
+			if err != nil {
+				return err
+			}
+			if data == nil {
+				slog.Error("staged file expired, recording it as lost", "event_id", msg.EventID, "index", i)
+				lost++
+				continue
+			}
+			a, err = w.storeFile(ctx, f, data)
+			if err != nil {
+				return fmt.Errorf("upload %s %d: %w", a.Kind, i, err)
+			}
+			if err := w.consumer.RecordUpload(ctx, msg.EventID, i, a); err != nil {
+				return err
+			}
+		}
+		a.ObjectKey = f.ObjectKey
+		if a.ThumbnailURL != nil && *a.ThumbnailURL != a.URL {
+			a.ThumbnailKey = thumbnailKey(f.ObjectKey)
+		}
+		attachments = append(attachments, a)
+	}
+
+	if err := w.repo.AttachFiles(ctx, msg.EventID, attachments, lost); err != nil {
+		return err
+	}
+	for _, a := range attachments {
+		report.Attachments = append(report.Attachments, a)
+		if a.Kind == model.AttachmentImage {
+			report.ImageURLs = append(report.ImageURLs, a.URL)
+		}
+	}
+	report.AttachmentsPending = false
+	report.AttachmentsLost = lost
+
+	if err := w.consumer.ClearStaged(ctx, msg.EventID, len(msg.StagedFiles)); err != nil {
+		// Staged files expire on their own
+		slog.Warn("clear staged files failed", "event_id", msg.EventID, "error", err)
+	}
+	slog.Info("attachments saved", "event_id", msg.EventID, "attachments", len(attachments))
+	return nil
+}
+
+// storeFile uploads a staged file, and the thumbnail of an image. An image
+// that can't be decoded for a thumbnail is still stored, without one; an
+// image that already fits the thumbnail size is its own thumbnail.
+func (w *Worker) storeFile(ctx context.Context, f model.StagedFile, data []byte) (model.Attachment, error) {
+	sum := sha256.Sum256(data)
+	a := model.Attachment{
+		Kind:        f.Kind,
+		ContentType: f.ContentType,
+		Size:        int64(len(data)),
+		SHA256:      hex.EncodeToString(sum[:]),
+	}
+
+	url, err := w.images.Put(ctx, f.ObjectKey, f.ContentType, data)
+	if err != nil {
+		return a, err
+	}
+	a.URL = url
+	if a.Kind != model.AttachmentImage {
+		return a, nil
+	}
+
+	a.Width, a.Height, err = imageSize(data)
+	if err != nil {
+		slog.Warn("image not readable, skipping thumbnail", "key", f.ObjectKey, "error", err)
+		return a, nil
+	}
+	thumb, err := thumbnail(data)
+	if err != nil {
+		slog.Warn("thumbnail failed, skipping", "key", f.ObjectKey, "error", err)
+		return a, nil
+	}
+	if thumb == nil {
+		a.ThumbnailURL = &a.URL
+		return a, nil
+	}
+	thumbURL, err := w.images.Put(ctx, thumbnailKey(f.ObjectKey), "image/jpeg", thumb)
+	if err != nil {
+		return a, fmt.Errorf("upload thumbnail: %w", err)
+	}
+	a.ThumbnailURL = &thumbURL
+	return a, nil
+}
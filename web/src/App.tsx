@@ -5,10 +5,21 @@ import type { Language, AppView } from './types';
 import { Header } from './components/Header';
 import { FeedbackForm } from './components/FeedbackForm';
 import { SuccessScreen } from './components/SuccessScreen';
+import { StatusScreen } from './components/StatusScreen';
+
+// /status/<event_id> opens the status page for a submitted report.
+const STATUS_PATH = /^\/status\/([0-9a-f-]{36})\/?$/i;
+
+function statusEventId(): string {
+  const m = window.location.pathname.match(STATUS_PATH);
+  return m ? m[1] : '';
+}
 
 export default function App() {
   const [lang, setLang] = useState<Language>(detectLanguage);
-  const [view, setView] = useState<AppView>('form');
+  const [statusId] = useState(statusEventId);
+  const [view, setView] = useState<AppView>(statusId ? 'status' : 'form');
+  const [eventId, setEventId] = useState('');
   const theme = useTheme();
 
   const i18nValue = useMemo(
@@ -19,15 +30,23 @@ export default function App() {
   return (
     <I18nContext.Provider value={i18nValue}>
       {view === 'success' && (
-        <SuccessScreen onNewReport={() => setView('form')} />
+        <SuccessScreen eventId={eventId} onNewReport={() => setView('form')} />
       )}
       <div className="app-shell">
         <div className="main-card">
           <Header theme={theme} />
           <div className="form-body">
             {view === 'form' && (
-              <FeedbackForm key={view} onSuccess={() => setView('success')} resolvedTheme={theme.resolved} />
+              <FeedbackForm
+                key={view}
+                onSuccess={(id) => {
+                  setEventId(id);
+                  setView('success');
+                }}
+                resolvedTheme={theme.resolved}
+              />
             )}
+            {view === 'status' && <StatusScreen eventId={statusId} />}
           </div>
           <div className="footer">
             <span>
@@ -1,9 +1,16 @@
-import type { ReportFormData, ReportResponse, ErrorResponse } from './types';
+import type {
+  ReportFormData,
+  ReportResponse,
+  ReportStatusResponse,
+  CaptchaChallenge,
+  SitesResponse,
+  ErrorResponse,
+} from './types';
 
 export async function submitReport(
   data: ReportFormData,
   images: File[],
-  turnstileToken: string
+  captchaToken: string
 ): Promise<ReportResponse> {
   const fd = new FormData();
   fd.append('site_id', data.siteId);
@@ -22,8 +29,12 @@ export async function submitReport(
     fd.append('contact_value', data.phone.trim());
   }
 
+  for (const [name, value] of Object.entries(data.customFields)) {
+    if (value.trim()) fd.append(`custom_fields[${name}]`, value.trim());
+  }
+
   images.forEach((f) => fd.append('images', f));
-  fd.append('cf-turnstile-response', turnstileToken);
+  if (captchaToken) fd.append('captcha_token', captchaToken);
 
   const res = await fetch('/v1/reports', {
     method: 'POST',
@@ -39,3 +50,49 @@ export async function submitReport(
 
   return body as ReportResponse;
 }
+
+// Returns null when the event is unknown (404).
+export async function fetchReportStatus(
+  eventId: string
+): Promise<ReportStatusResponse | null> {
+  const res = await fetch(`/v1/reports/${encodeURIComponent(eventId)}/status`);
+  if (res.status === 404) return null;
+
+  const body = await res.json();
+
+  if (!res.ok) {
+    const err = body as ErrorResponse;
+    throw new Error(err.error || 'Status lookup failed');
+  }
+
+  return body as ReportStatusResponse;
+}
+
+// Issues a proof-of-work challenge for sites using the pow captcha.
+export async function fetchCaptchaChallenge(): Promise<CaptchaChallenge> {
+  const res = await fetch('/v1/captcha/challenge', { method: 'POST' });
+
+  const body = await res.json();
+
+  if (!res.ok) {
+    const err = body as ErrorResponse;
+    throw new Error(err.error || 'Challenge request failed');
+  }
+
+  return body as CaptchaChallenge;
+}
+
+// Lists the reportable sites with their report types, categories and
+// custom fields.
+export async function fetchSites(): Promise<SitesResponse> {
+  const res = await fetch('/v1/sites');
+
+  const body = await res.json();
+
+  if (!res.ok) {
+    const err = body as ErrorResponse;
+    throw new Error(err.error || 'Site list request failed');
+  }
+
+  return body as SitesResponse;
+}
@@ -0,0 +1,54 @@
+import { useEffect } from 'react';
+import { getConfig } from '../config';
+import { TurnstileWidget } from './TurnstileWidget';
+import { HCaptchaWidget } from './HCaptchaWidget';
+import { ReCaptchaWidget } from './ReCaptchaWidget';
+import { PowWidget } from './PowWidget';
+
+interface Props {
+  siteId: string;
+  onVerify: (token: string) => void;
+  onExpire: () => void;
+  onError: () => void;
+  theme: 'light' | 'dark';
+}
+
+// Renders the captcha the selected site uses, as injected by the server.
+// Sites without one, and the form before a site is picked, report an empty
+// token straight away: no token is needed.
+export function CaptchaWidget({ siteId, onVerify, onExpire, onError, theme }: Props) {
+  const captcha = siteId ? getConfig().captcha[siteId] : undefined;
+
+  useEffect(() => {
+    if (!captcha) onVerify('');
+  }, [captcha, onVerify]);
+
+  switch (captcha?.provider) {
+    case 'turnstile':
+      return (
+        <TurnstileWidget
+          siteKey={captcha.siteKey}
+          onVerify={onVerify}
+          onExpire={onExpire}
+          onError={onError}
+          theme={theme}
+        />
+      );
+    case 'hcaptcha':
+      return (
+        <HCaptchaWidget
+          siteKey={captcha.siteKey}
+          onVerify={onVerify}
+          onExpire={onExpire}
+          onError={onError}
+          theme={theme}
+        />
+      );
+    case 'recaptcha':
+      return <ReCaptchaWidget siteKey={captcha.siteKey} onVerify={onVerify} onExpire={onExpire} onError={onError} />;
+    case 'pow':
+      return <PowWidget onVerify={onVerify} onExpire={onExpire} onError={onError} />;
+    default:
+      return null;
+  }
+}
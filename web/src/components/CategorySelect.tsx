@@ -1,23 +1,14 @@
-import { useI18n } from '../i18n';
-import type { Category } from '../types';
+import { useI18n, entryLabel } from '../i18n';
+import type { Category, TaxonomyEntry } from '../types';
 
 interface Props {
+  entries: TaxonomyEntry[];
   value: Category | '';
   onChange: (cat: Category | '') => void;
 }
 
-export function CategorySelect({ value, onChange }: Props) {
-  const { t } = useI18n();
-
-  const categories: { value: Category; label: string }[] = [
-    { value: 'design', label: t.catDesign },
-    { value: 'functionality', label: t.catFunctionality },
-    { value: 'performance', label: t.catPerformance },
-    { value: 'content', label: t.catContent },
-    { value: 'mobile', label: t.catMobile },
-    { value: 'security', label: t.catSecurity },
-    { value: 'other', label: t.catOther },
-  ];
+export function CategorySelect({ entries, value, onChange }: Props) {
+  const { t, lang } = useI18n();
 
   return (
     <>
@@ -26,13 +17,13 @@ export function CategorySelect({ value, onChange }: Props) {
       </label>
       <select
         value={value}
-        onChange={(e) => onChange(e.target.value as Category | '')}
+        onChange={(e) => onChange(e.target.value)}
         required
       >
         <option value="">{t.selectPlaceholder}</option>
-        {categories.map((c) => (
-          <option key={c.value} value={c.value}>
-            {c.label}
+        {entries.map((c) => (
+          <option key={c.key} value={c.key}>
+            {entryLabel(c, lang)}
           </option>
         ))}
       </select>
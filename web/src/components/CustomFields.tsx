@@ -0,0 +1,84 @@
+import { useI18n } from '../i18n';
+import type { CustomField } from '../types';
+
+interface Props {
+  fields: CustomField[];
+  values: Record<string, string>;
+  errors: Record<string, string>;
+  onChange: (name: string, value: string) => void;
+}
+
+// Renders the custom report fields of the selected site. Values are kept as
+// strings, the way the multipart form sends them.
+export function CustomFields({ fields, values, errors, onChange }: Props) {
+  const { t } = useI18n();
+
+  if (fields.length === 0) return null;
+
+  function input(f: CustomField) {
+    const value = values[f.name] ?? '';
+    switch (f.type) {
+      case 'enum':
+        return (
+          <select value={value} onChange={(e) => onChange(f.name, e.target.value)}>
+            <option value="">{t.selectPlaceholder}</option>
+            {(f.options ?? []).map((o) => (
+              <option key={o} value={o}>
+                {o}
+              </option>
+            ))}
+          </select>
+        );
+      case 'bool':
+        return (
+          <select value={value} onChange={(e) => onChange(f.name, e.target.value)}>
+            <option value="">{t.selectPlaceholder}</option>
+            <option value="true">{t.optionYes}</option>
+            <option value="false">{t.optionNo}</option>
+          </select>
+        );
+      case 'number':
+        return (
+          <input
+            type="number"
+            step="any"
+            value={value}
+            onChange={(e) => onChange(f.name, e.target.value)}
+          />
+        );
+      case 'url':
+        return (
+          <input
+            type="url"
+            placeholder="https://..."
+            maxLength={f.max_length || 2048}
+            value={value}
+            onChange={(e) => onChange(f.name, e.target.value)}
+          />
+        );
+      default:
+        return (
+          <input
+            type="text"
+            maxLength={f.max_length || 1000}
+            value={value}
+            onChange={(e) => onChange(f.name, e.target.value)}
+          />
+        );
+    }
+  }
+
+  return (
+    <div className="custom-fields">
+      {fields.map((f) => (
+        <div key={f.name} className={`field${errors[f.name] ? ' has-error' : ''}`}>
+          <label>
+            {f.label || f.name} {f.required && <span className="req">*</span>}
+          </label>
+          {input(f)}
+          {errors[f.name] && <div className="field-error">{errors[f.name]}</div>}
+        </div>
+      ))}
+    </div>
+  );
+}
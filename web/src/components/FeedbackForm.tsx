@@ -1,21 +1,26 @@
-import { useState, useCallback, useEffect } from 'react';
+import { useState, useCallback, useEffect, useMemo } from 'react';
 import { useI18n } from '../i18n';
+import type { Translations } from '../i18n';
 import { submitReport } from '../api';
 import { getConfig } from '../config';
+import { useSites } from '../hooks/useSites';
 import type {
   ReportType,
-  Category,
+  CustomField,
   ReportFormData,
+  SiteDetails,
+  TaxonomyEntry,
 } from '../types';
 import { ReportTypeToggle } from './ReportTypeToggle';
 import { SiteSelect } from './SiteSelect';
 import { CategorySelect } from './CategorySelect';
 import { ContactSection } from './ContactSection';
+import { CustomFields } from './CustomFields';
 import { ImageUpload } from './ImageUpload';
-import { TurnstileWidget } from './TurnstileWidget';
+import { CaptchaWidget } from './CaptchaWidget';
 
 interface Props {
-  onSuccess: () => void;
+  onSuccess: (eventId: string) => void;
   resolvedTheme: 'light' | 'dark';
 }
 
@@ -29,6 +34,7 @@ const EMPTY_FORM: ReportFormData = {
   fullName: '',
   phone: '',
   email: '',
+  customFields: {},
 };
 
 interface FieldErrors {
@@ -37,6 +43,43 @@ interface FieldErrors {
   category?: string;
   description?: string;
   pageUrl?: string;
+  customFields?: Record<string, string>; // by field name
+}
+
+// customFieldError checks a custom field value the way the server does and
+// returns the error message, or '' if it is fine.
+function customFieldError(
+  f: CustomField,
+  value: string,
+  t: Translations
+): string {
+  const v = value.trim();
+  if (!v) return f.required ? t.errFieldRequired : '';
+  if (f.type === 'number' && !Number.isFinite(Number(v))) return t.errNumber;
+  if (f.type === 'url') {
+    try {
+      const u = new URL(v);
+      if (u.protocol !== 'http:' && u.protocol !== 'https:') return t.errUrl;
+    } catch {
+      return t.errUrl;
+    }
+  }
+  return '';
+}
+
+// allEntries merges the report types or categories of every site, for the
+// form before a site is picked.
+function allEntries(
+  sites: Record<string, SiteDetails>,
+  pick: (s: SiteDetails) => TaxonomyEntry[]
+): TaxonomyEntry[] {
+  const byKey = new Map<string, TaxonomyEntry>();
+  for (const s of Object.values(sites)) {
+    for (const e of pick(s)) byKey.set(e.key, e);
+  }
+  return [...byKey.values()].sort(
+    (a, b) => a.position - b.position || a.key.localeCompare(b.key)
+  );
 }
 
 function matchesSiteDomain(url: string, siteDomain: string): boolean {
@@ -52,10 +95,14 @@ function matchesSiteDomain(url: string, siteDomain: string): boolean {
 export function FeedbackForm({ onSuccess, resolvedTheme }: Props) {
   const { t } = useI18n();
   const config = getConfig();
+  const sites = useSites();
 
   const [form, setForm] = useState<ReportFormData>({ ...EMPTY_FORM });
   const [images, setImages] = useState<File[]>([]);
-  const [turnstileToken, setTurnstileToken] = useState('');
+  // The captcha token is kept with the widget it came from, so a token never
+  // outlives a site change or a new widget after a failed submission.
+  const [captcha, setCaptcha] = useState<{ key: string; token: string } | null>(null);
+  const [captchaRound, setCaptchaRound] = useState(0);
   const [submitting, setSubmitting] = useState(false);
   const [error, setError] = useState('');
   const [autoDetectedSite, setAutoDetectedSite] = useState(false);
@@ -94,13 +141,37 @@ export function FeedbackForm({ onSuccess, resolvedTheme }: Props) {
     }
   }
 
+  function handleSiteChange(siteId: string) {
+    // Custom fields belong to a site
+    setForm((prev) => ({ ...prev, siteId, customFields: {} }));
+    setFieldErrors((prev) => {
+      const next = { ...prev };
+      delete next.siteId;
+      delete next.customFields;
+      return next;
+    });
+  }
+
+  function updateCustomField(name: string, value: string) {
+    setForm((prev) => ({
+      ...prev,
+      customFields: { ...prev.customFields, [name]: value },
+    }));
+    if (fieldErrors.customFields?.[name]) {
+      setFieldErrors((prev) => {
+        const customFields = { ...prev.customFields };
+        delete customFields[name];
+        return { ...prev, customFields };
+      });
+    }
+  }
+
   function handleReportTypeChange(type: ReportType) {
     if (type === form.reportType) return;
     const siteId = autoDetectedSite ? form.siteId : '';
     const pageUrl = autoDetectedSite ? form.pageUrl : '';
     setForm({ ...EMPTY_FORM, reportType: type, siteId, pageUrl });
     setImages([]);
-    setTurnstileToken('');
     setError('');
     setFieldErrors({});
   }
@@ -109,6 +180,43 @@ export function FeedbackForm({ onSuccess, resolvedTheme }: Props) {
     setForm((prev) => ({ ...prev, pageUrl: url }));
   }, []);
 
+  const site = sites?.[form.siteId];
+  const customFields = site?.custom_fields ?? [];
+  const reportTypes = useMemo(
+    () => site?.report_types ?? (sites ? allEntries(sites, (s) => s.report_types) : []),
+    [site, sites]
+  );
+  const categories = useMemo(
+    () => site?.categories ?? (sites ? allEntries(sites, (s) => s.categories) : []),
+    [site, sites]
+  );
+
+  // Keep the type and category within what the selected site accepts
+  useEffect(() => {
+    setForm((prev) => {
+      let next = prev;
+      if (reportTypes.length > 0 && !reportTypes.some((e) => e.key === prev.reportType)) {
+        next = { ...next, reportType: reportTypes[0].key };
+      }
+      if (
+        prev.category &&
+        categories.length > 0 &&
+        !categories.some((e) => e.key === prev.category)
+      ) {
+        next = { ...next, category: '' };
+      }
+      return next;
+    });
+  }, [reportTypes, categories]);
+
+  const captchaKey = `${form.siteId}:${captchaRound}`;
+  const captchaToken = captcha?.key === captchaKey ? captcha.token : null;
+  const handleCaptchaVerify = useCallback(
+    (token: string) => setCaptcha({ key: captchaKey, token }),
+    [captchaKey]
+  );
+  const handleCaptchaReset = useCallback(() => setCaptcha(null), []);
+
   function validate(): boolean {
     const errors: FieldErrors = {};
 
@@ -119,6 +227,10 @@ export function FeedbackForm({ onSuccess, resolvedTheme }: Props) {
     if (form.pageUrl.trim() && form.siteId && !matchesSiteDomain(form.pageUrl.trim(), form.siteId)) {
       errors.pageUrl = t.errPageUrlDomain;
     }
+    for (const f of customFields) {
+      const msg = customFieldError(f, form.customFields[f.name] ?? '', t);
+      if (msg) errors.customFields = { ...errors.customFields, [f.name]: msg };
+    }
 
     setFieldErrors(errors);
     return Object.keys(errors).length === 0;
@@ -133,17 +245,19 @@ export function FeedbackForm({ onSuccess, resolvedTheme }: Props) {
     setSubmitting(true);
 
     try {
-      await submitReport(form, images, turnstileToken);
-      onSuccess();
+      const res = await submitReport(form, images, captchaToken ?? '');
+      onSuccess(res.event_id);
     } catch (err) {
       setError(err instanceof Error ? err.message : t.errorGeneric);
-      setTurnstileToken('');
+      // Captcha tokens can be used once; get a new one
+      setCaptcha(null);
+      setCaptchaRound((n) => n + 1);
     } finally {
       setSubmitting(false);
     }
   }
 
-  const canSubmit = !submitting && !!turnstileToken;
+  const canSubmit = !submitting && captchaToken !== null;
   const isBug = form.reportType === 'bug';
   const titlePlaceholder = isBug ? t.titlePlaceholderBug : t.titlePlaceholderRequest;
   const descPlaceholder = isBug ? t.descPlaceholderBug : t.descPlaceholderRequest;
@@ -151,6 +265,7 @@ export function FeedbackForm({ onSuccess, resolvedTheme }: Props) {
   return (
     <>
       <ReportTypeToggle
+        entries={reportTypes}
         value={form.reportType}
         onChange={handleReportTypeChange}
       />
@@ -162,7 +277,7 @@ export function FeedbackForm({ onSuccess, resolvedTheme }: Props) {
             <div className={`field${fieldErrors.siteId ? ' has-error' : ''}`}>
               <SiteSelect
                 value={form.siteId}
-                onChange={(v) => updateField('siteId', v)}
+                onChange={handleSiteChange}
                 onAutoFillUrl={handleAutoFillUrl}
                 autoDetected={autoDetectedSite}
               />
@@ -207,8 +322,9 @@ export function FeedbackForm({ onSuccess, resolvedTheme }: Props) {
           <div className="form-col">
             <div className={`field${fieldErrors.category ? ' has-error' : ''}`}>
               <CategorySelect
+                entries={categories}
                 value={form.category}
-                onChange={(v) => updateField('category', v as Category | '')}
+                onChange={(v) => updateField('category', v)}
               />
               {fieldErrors.category && (
                 <div className="field-error">{fieldErrors.category}</div>
@@ -230,6 +346,14 @@ export function FeedbackForm({ onSuccess, resolvedTheme }: Props) {
           </div>
         </div>
 
+        {/* Custom fields of the selected site (full width) */}
+        <CustomFields
+          fields={customFields}
+          values={form.customFields}
+          errors={fieldErrors.customFields ?? {}}
+          onChange={updateCustomField}
+        />
+
         {/* Contact (full width) */}
         <ContactSection
           fullName={form.fullName}
@@ -240,11 +364,13 @@ export function FeedbackForm({ onSuccess, resolvedTheme }: Props) {
           onEmailChange={(v) => updateField('email', v)}
         />
 
-        {/* Turnstile */}
-        <TurnstileWidget
-          onVerify={useCallback((token: string) => setTurnstileToken(token), [])}
-          onExpire={useCallback(() => setTurnstileToken(''), [])}
-          onError={useCallback(() => setTurnstileToken(''), [])}
+        {/* Captcha of the selected site */}
+        <CaptchaWidget
+          key={captchaKey}
+          siteId={form.siteId}
+          onVerify={handleCaptchaVerify}
+          onExpire={handleCaptchaReset}
+          onError={handleCaptchaReset}
           theme={resolvedTheme}
         />
 
@@ -0,0 +1,64 @@
+import { useEffect, useRef } from 'react';
+import { loadScript } from '../loadScript';
+
+const HCAPTCHA_SRC = 'https://js.hcaptcha.com/1/api.js?render=explicit';
+
+interface HCaptchaOptions {
+  sitekey: string;
+  theme: 'light' | 'dark';
+  callback: (token: string) => void;
+  'expired-callback': () => void;
+  'error-callback': () => void;
+}
+
+declare global {
+  interface Window {
+    hcaptcha?: {
+      render: (container: HTMLElement, options: HCaptchaOptions) => string;
+      remove: (widgetId: string) => void;
+    };
+  }
+}
+
+interface Props {
+  siteKey: string;
+  onVerify: (token: string) => void;
+  onExpire: () => void;
+  onError: () => void;
+  theme: 'light' | 'dark';
+}
+
+export function HCaptchaWidget({ siteKey, onVerify, onExpire, onError, theme }: Props) {
+  const container = useRef<HTMLDivElement>(null);
+
+  useEffect(() => {
+    let cancelled = false;
+    let widgetId: string | undefined;
+
+    loadScript(HCAPTCHA_SRC)
+      .then(() => {
+        if (cancelled || !container.current || !window.hcaptcha) return;
+        widgetId = window.hcaptcha.render(container.current, {
+          sitekey: siteKey,
+          theme,
+          callback: onVerify,
+          'expired-callback': onExpire,
+          'error-callback': onError,
+        });
+      })
+      .catch(() => {
+        if (!cancelled) onError();
+      });
+
+    return () => {
+      cancelled = true;
+      if (widgetId !== undefined) window.hcaptcha?.remove(widgetId);
+    };
+  }, [siteKey, theme, onVerify, onExpire, onError]);
+
+  return (
+    <div className="captcha-wrap">
+      <div ref={container} />
+    </div>
+  );
+}
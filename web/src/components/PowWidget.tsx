@@ -0,0 +1,85 @@
+import { useEffect, useState } from 'react';
+import { useI18n } from '../i18n';
+import { fetchCaptchaChallenge } from '../api';
+
+// Solve a fresh challenge this long before the current one expires.
+const EXPIRY_MARGIN_MS = 30_000;
+
+interface Props {
+  onVerify: (token: string) => void;
+  onExpire: () => void;
+  onError: () => void;
+}
+
+type PowState = 'solving' | 'solved' | 'failed';
+
+// Proof-of-work captcha: fetches a challenge from the API and solves it in a
+// web worker, with no third party involved.
+export function PowWidget({ onVerify, onExpire, onError }: Props) {
+  const { t } = useI18n();
+  const [state, setState] = useState<PowState>('solving');
+  const [attempt, setAttempt] = useState(0);
+
+  useEffect(() => {
+    let cancelled = false;
+    let timer: number | undefined;
+    const worker = new Worker(new URL('../powWorker.ts', import.meta.url), { type: 'module' });
+
+    setState('solving');
+    fetchCaptchaChallenge()
+      .then(
+        (c) =>
+          new Promise<void>((resolve, reject) => {
+            worker.onmessage = (e: MessageEvent<string>) => {
+              if (cancelled) return;
+              onVerify(`${c.challenge}:${e.data}`);
+              setState('solved');
+              const refreshIn = new Date(c.expires_at).getTime() - Date.now() - EXPIRY_MARGIN_MS;
+              timer = window.setTimeout(() => {
+                onExpire();
+                setAttempt((n) => n + 1);
+              }, Math.max(refreshIn, 0));
+              resolve();
+            };
+            worker.onerror = () => reject(new Error('Proof-of-work worker failed'));
+            worker.postMessage({ challenge: c.challenge, difficulty: c.difficulty });
+          })
+      )
+      .catch(() => {
+        if (cancelled) return;
+        setState('failed');
+        onError();
+      });
+
+    return () => {
+      cancelled = true;
+      window.clearTimeout(timer);
+      worker.terminate();
+    };
+  }, [attempt, onVerify, onExpire, onError]);
+
+  return (
+    <div className="captcha-wrap">
+      <div className={`pow-status ${state}`}>
+        {state === 'solving' && (
+          <>
+            <span className="spinner" /> {t.captchaVerifying}
+          </>
+        )}
+        {state === 'solved' && (
+          <>
+            <i className="fa-solid fa-circle-check" /> {t.captchaVerified}
+          </>
+        )}
+        {state === 'failed' && (
+          <>
+            <i className="fa-solid fa-triangle-exclamation" /> {t.captchaFailed}{' '}
+            <button type="button" className="link-btn" onClick={() => setAttempt((n) => n + 1)}>
+              {t.captchaRetry}
+            </button>
+          </>
+        )}
+      </div>
+    </div>
+  );
+}
@@ -0,0 +1,63 @@
+import { useEffect } from 'react';
+import { loadScript } from '../loadScript';
+
+// reCAPTCHA v3 tokens are valid for two minutes; fetch a new one before that.
+const REFRESH_MS = 100_000;
+
+declare global {
+  interface Window {
+    grecaptcha?: {
+      ready: (cb: () => void) => void;
+      execute: (siteKey: string, options: { action: string }) => Promise<string>;
+    };
+  }
+}
+
+interface Props {
+  siteKey: string;
+  onVerify: (token: string) => void;
+  onExpire: () => void;
+  onError: () => void;
+}
+
+// reCAPTCHA v3 has no challenge to solve: it scores the visitor in the
+// background and shows only its badge, so this renders nothing.
+export function ReCaptchaWidget({ siteKey, onVerify, onExpire, onError }: Props) {
+  useEffect(() => {
+    let cancelled = false;
+    let timer: number | undefined;
+
+    function execute() {
+      const grecaptcha = window.grecaptcha;
+      if (cancelled || !grecaptcha) return;
+      grecaptcha.ready(() => {
+        grecaptcha
+          .execute(siteKey, { action: 'submit' })
+          .then((token) => {
+            if (cancelled) return;
+            onVerify(token);
+            timer = window.setTimeout(() => {
+              onExpire();
+              execute();
+            }, REFRESH_MS);
+          })
+          .catch(() => {
+            if (!cancelled) onError();
+          });
+      });
+    }
+
+    loadScript(`https://www.google.com/recaptcha/api.js?render=${encodeURIComponent(siteKey)}`)
+      .then(execute)
+      .catch(() => {
+        if (!cancelled) onError();
+      });
+
+    return () => {
+      cancelled = true;
+      window.clearTimeout(timer);
+    };
+  }, [siteKey, onVerify, onExpire, onError]);
+
+  return null;
+}
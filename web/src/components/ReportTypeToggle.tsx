@@ -1,30 +1,35 @@
-import { useI18n } from '../i18n';
-import type { ReportType } from '../types';
+import { useI18n, entryLabel } from '../i18n';
+import type { ReportType, TaxonomyEntry } from '../types';
 
 interface Props {
+  entries: TaxonomyEntry[];
   value: ReportType;
   onChange: (type: ReportType) => void;
 }
 
-export function ReportTypeToggle({ value, onChange }: Props) {
-  const { t } = useI18n();
+// Icons of the built-in report types; others get a tag.
+const ICONS: Record<string, string> = {
+  bug: 'fa-bug',
+  request: 'fa-lightbulb',
+};
+
+export function ReportTypeToggle({ entries, value, onChange }: Props) {
+  const { lang } = useI18n();
+
+  if (entries.length < 2) return null;
 
   return (
     <div className="type-toggle">
-      <button
-        type="button"
-        className={value === 'bug' ? 'active' : ''}
-        onClick={() => onChange('bug')}
-      >
-        <i className="fa-solid fa-bug" /> {t.typeBug}
-      </button>
-      <button
-        type="button"
-        className={value === 'request' ? 'active' : ''}
-        onClick={() => onChange('request')}
-      >
-        <i className="fa-solid fa-lightbulb" /> {t.typeRequest}
-      </button>
+      {entries.map((e) => (
+        <button
+          key={e.key}
+          type="button"
+          className={value === e.key ? 'active' : ''}
+          onClick={() => onChange(e.key)}
+        >
+          <i className={`fa-solid ${ICONS[e.key] ?? 'fa-tag'}`} /> {entryLabel(e, lang)}
+        </button>
+      ))}
     </div>
   );
 }
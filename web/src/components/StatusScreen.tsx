@@ -0,0 +1,91 @@
+import { useEffect, useState } from 'react';
+import { useI18n, type Translations } from '../i18n';
+import { fetchReportStatus } from '../api';
+import type { ReportStatusResponse } from '../types';
+
+interface Props {
+  eventId: string;
+}
+
+function stateLabel(t: Translations, s: ReportStatusResponse): string {
+  switch (s.state) {
+    case 'queued':
+      return t.stateQueued;
+    case 'dead_lettered':
+    case 'quarantined':
+      return t.stateDeadLettered;
+    case 'attachments_pending':
+      return t.stateAttachmentsPending;
+    default:
+      return t.stateStored;
+  }
+}
+
+function triageLabel(t: Translations, status: ReportStatusResponse['status']): string {
+  switch (status) {
+    case 'triaged':
+      return t.statusTriaged;
+    case 'in_progress':
+      return t.statusInProgress;
+    case 'resolved':
+      return t.statusResolved;
+    case 'wont_fix':
+      return t.statusWontFix;
+    case 'duplicate':
+      return t.statusDuplicate;
+    default:
+      return t.statusNew;
+  }
+}
+
+export function StatusScreen({ eventId }: Props) {
+  const { t } = useI18n();
+  const [status, setStatus] = useState<ReportStatusResponse | null>(null);
+  const [loading, setLoading] = useState(true);
+  const [error, setError] = useState('');
+
+  useEffect(() => {
+    let cancelled = false;
+    fetchReportStatus(eventId)
+      .then((s) => {
+        if (cancelled) return;
+        setStatus(s);
+        if (!s) setError(t.statusNotFound);
+      })
+      .catch(() => {
+        if (!cancelled) setError(t.errorGeneric);
+      })
+      .finally(() => {
+        if (!cancelled) setLoading(false);
+      });
+    return () => {
+      cancelled = true;
+    };
+  }, [eventId, t]);
+
+  return (
+    <div className="status-view">
+      <h2>{t.statusTitle}</h2>
+      <p className="status-event-id">{eventId}</p>
+
+      {loading && <p className="status-muted">{t.statusLoading}</p>}
+      {!loading && error && <div className="msg error">{error}</div>}
+      {!loading && status && (
+        <dl className="status-list">
+          <dt>{t.labelState}</dt>
+          <dd>{stateLabel(t, status)}</dd>
+          {status.status && (
+            <>
+              <dt>{t.labelStatus}</dt>
+              <dd>{triageLabel(t, status.status)}</dd>
+            </>
+          )}
+        </dl>
+      )}
+
+      <a className="btn-new" href="/">
+        <i className="fa-solid fa-plus" /> {t.newReport}
+      </a>
+    </div>
+  );
+}
@@ -1,10 +1,11 @@
 import { useI18n } from '../i18n';
 
 interface Props {
+  eventId: string;
   onNewReport: () => void;
 }
 
-export function SuccessScreen({ onNewReport }: Props) {
+export function SuccessScreen({ eventId, onNewReport }: Props) {
   const { t } = useI18n();
 
   return (
@@ -15,6 +16,13 @@ export function SuccessScreen({ onNewReport }: Props) {
         </div>
         <h2>{t.successTitle}</h2>
         <p>{t.successText}</p>
+        {eventId && (
+          <p className="status-link">
+            <a href={`/status/${eventId}`} target="_blank" rel="noopener noreferrer">
+              <i className="fa-solid fa-magnifying-glass" /> {t.trackStatus}
+            </a>
+          </p>
+        )}
         <button type="button" className="btn-new" onClick={onNewReport}>
           <i className="fa-solid fa-plus" /> {t.newReport}
         </button>
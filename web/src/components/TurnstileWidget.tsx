@@ -1,32 +1,18 @@
-import { useEffect } from 'react';
 import Turnstile from 'react-turnstile';
-import { getConfig } from '../config';
 
 interface Props {
+  siteKey: string;
   onVerify: (token: string) => void;
   onExpire: () => void;
   onError: () => void;
   theme?: 'light' | 'dark' | 'auto';
 }
 
-export function TurnstileWidget({ onVerify, onExpire, onError, theme = 'auto' }: Props) {
-  const config = getConfig();
-
-  // When Turnstile is not configured, skip verification
-  useEffect(() => {
-    if (!config.turnstileSiteKey) {
-      onVerify('__skip__');
-    }
-  }, [config.turnstileSiteKey, onVerify]);
-
-  if (!config.turnstileSiteKey) {
-    return null;
-  }
-
+export function TurnstileWidget({ siteKey, onVerify, onExpire, onError, theme = 'auto' }: Props) {
   return (
-    <div className="turnstile-wrap">
+    <div className="captcha-wrap">
       <Turnstile
-        sitekey={config.turnstileSiteKey}
+        sitekey={siteKey}
         theme={theme}
         onVerify={onVerify}
         onExpire={onExpire}
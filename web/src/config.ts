@@ -9,7 +9,7 @@ declare global {
 let cachedConfig: AppConfig | null = null;
 
 const EMPTY_CONFIG: AppConfig = {
-  turnstileSiteKey: '',
+  captcha: {},
   sites: [],
   portalDomain: '',
 };
@@ -17,7 +17,7 @@ const EMPTY_CONFIG: AppConfig = {
 // Ensure all fields have safe defaults (guards against null from JSON)
 function normalize(cfg: AppConfig): AppConfig {
   return {
-    turnstileSiteKey: cfg.turnstileSiteKey ?? '',
+    captcha: cfg.captcha ?? {},
     sites: cfg.sites ?? [],
     portalDomain: cfg.portalDomain ?? '',
   };
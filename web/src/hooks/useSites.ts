@@ -0,0 +1,42 @@
+import { useState, useEffect } from 'react';
+import { fetchSites } from '../api';
+import type { SiteDetails } from '../types';
+
+let request: Promise<Record<string, SiteDetails>> | null = null;
+
+function loadSites(): Promise<Record<string, SiteDetails>> {
+  if (!request) {
+    request = fetchSites().then((res) => {
+      const byDomain: Record<string, SiteDetails> = {};
+      for (const d of res.details ?? []) byDomain[d.domain] = d;
+      return byDomain;
+    });
+    // Let a later render try again
+    request.catch(() => {
+      request = null;
+    });
+  }
+  return request;
+}
+
+// Returns the details of the reportable sites by domain, or null while they
+// load or when they can't be loaded.
+export function useSites(): Record<string, SiteDetails> | null {
+  const [sites, setSites] = useState<Record<string, SiteDetails> | null>(null);
+
+  useEffect(() => {
+    let cancelled = false;
+    loadSites()
+      .then((s) => {
+        if (!cancelled) setSites(s);
+      })
+      .catch(() => {
+        // The form still works; the server validates the report
+      });
+    return () => {
+      cancelled = true;
+    };
+  }, []);
+
+  return sites;
+}
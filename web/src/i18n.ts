@@ -1,5 +1,5 @@
 import { createContext, useContext } from 'react';
-import type { Language } from './types';
+import type { Language, TaxonomyEntry } from './types';
 
 export interface Translations {
   pageTitle: string;
@@ -20,13 +20,6 @@ export interface Translations {
   errorGeneric: string;
   autoDetected: string;
   selectPlaceholder: string;
-  catDesign: string;
-  catFunctionality: string;
-  catPerformance: string;
-  catContent: string;
-  catMobile: string;
-  catSecurity: string;
-  catOther: string;
   titlePlaceholderBug: string;
   titlePlaceholderRequest: string;
   descPlaceholderBug: string;
@@ -36,13 +29,36 @@ export interface Translations {
   errCategoryRequired: string;
   errDescRequired: string;
   errPageUrlDomain: string;
+  errFieldRequired: string;
+  errNumber: string;
+  errUrl: string;
+  optionYes: string;
+  optionNo: string;
   maxImages: string;
   siteSelectPlaceholder: string;
-  typeBug: string;
-  typeRequest: string;
   successTitle: string;
   successText: string;
   newReport: string;
+  trackStatus: string;
+  statusTitle: string;
+  statusLoading: string;
+  statusNotFound: string;
+  labelState: string;
+  labelStatus: string;
+  stateQueued: string;
+  stateStored: string;
+  stateAttachmentsPending: string;
+  stateDeadLettered: string;
+  captchaVerifying: string;
+  captchaVerified: string;
+  captchaFailed: string;
+  captchaRetry: string;
+  statusNew: string;
+  statusTriaged: string;
+  statusInProgress: string;
+  statusResolved: string;
+  statusWontFix: string;
+  statusDuplicate: string;
   footerText: string;
 }
 
@@ -66,13 +82,6 @@ const translations: Record<Language, Translations> = {
     errorGeneric: 'Bir hata oluştu. Lütfen tekrar deneyin.',
     autoDetected: 'Otomatik algılandı',
     selectPlaceholder: 'Seçin...',
-    catDesign: 'Tasarım',
-    catFunctionality: 'İşlevsellik',
-    catPerformance: 'Performans',
-    catContent: 'İçerik',
-    catMobile: 'Mobil',
-    catSecurity: 'Güvenlik',
-    catOther: 'Diğer',
     titlePlaceholderBug: 'Hatanın kısa başlığını yazın',
     titlePlaceholderRequest: 'Önerinizin kısa başlığını yazın',
     descPlaceholderBug:
@@ -84,14 +93,37 @@ const translations: Record<Language, Translations> = {
     errCategoryRequired: 'Lütfen kategori seçiniz',
     errDescRequired: 'Lütfen açıklama giriniz',
     errPageUrlDomain: 'URL seçili sitenin domainine ait olmalıdır',
+    errFieldRequired: 'Bu alan zorunludur',
+    errNumber: 'Lütfen bir sayı giriniz',
+    errUrl: 'Lütfen geçerli bir URL giriniz',
+    optionYes: 'Evet',
+    optionNo: 'Hayır',
     maxImages: 'En fazla 5 görsel yüklenebilir',
     siteSelectPlaceholder: 'Site seçin...',
-    typeBug: 'Hata Bildirimi',
-    typeRequest: 'Öneriler',
     successTitle: 'Teşekkürler!',
     successText:
       'Geri bildiriminiz başarıyla gönderildi. En kısa sürede değerlendirilecektir.',
     newReport: 'Yeni Bildirim',
+    trackStatus: 'Bildirimin durumunu takip et',
+    statusTitle: 'Bildirim Durumu',
+    statusLoading: 'Yükleniyor...',
+    statusNotFound: 'Bu bildirim bulunamadı.',
+    labelState: 'Aşama',
+    labelStatus: 'Durum',
+    stateQueued: 'Sırada, henüz kaydedilmedi',
+    stateStored: 'Kaydedildi',
+    stateAttachmentsPending: 'Kaydedildi, ekler yükleniyor',
+    stateDeadLettered: 'Kaydedilemedi, ekibimiz inceliyor',
+    captchaVerifying: 'Güvenlik doğrulaması yapılıyor…',
+    captchaVerified: 'Doğrulandı',
+    captchaFailed: 'Doğrulama başarısız.',
+    captchaRetry: 'Tekrar dene',
+    statusNew: 'Yeni',
+    statusTriaged: 'İncelendi',
+    statusInProgress: 'Üzerinde çalışılıyor',
+    statusResolved: 'Çözüldü',
+    statusWontFix: 'Düzeltilmeyecek',
+    statusDuplicate: 'Mükerrer',
     footerText: 'Powered by',
   },
   en: {
@@ -113,13 +145,6 @@ const translations: Record<Language, Translations> = {
     errorGeneric: 'An error occurred. Please try again.',
     autoDetected: 'Auto-detected',
     selectPlaceholder: 'Select...',
-    catDesign: 'Design',
-    catFunctionality: 'Functionality',
-    catPerformance: 'Performance',
-    catContent: 'Content',
-    catMobile: 'Mobile',
-    catSecurity: 'Security',
-    catOther: 'Other',
     titlePlaceholderBug: 'Short title for the bug',
     titlePlaceholderRequest: 'Short title for your suggestion',
     descPlaceholderBug:
@@ -131,14 +156,37 @@ const translations: Record<Language, Translations> = {
     errCategoryRequired: 'Please select a category',
     errDescRequired: 'Please enter a description',
     errPageUrlDomain: 'URL must belong to the selected site domain',
+    errFieldRequired: 'This field is required',
+    errNumber: 'Please enter a number',
+    errUrl: 'Please enter a valid URL',
+    optionYes: 'Yes',
+    optionNo: 'No',
     maxImages: 'Maximum 5 images allowed',
     siteSelectPlaceholder: 'Select a site...',
-    typeBug: 'Bug Report',
-    typeRequest: 'Suggestions',
     successTitle: 'Thank you!',
     successText:
       'Your feedback has been submitted successfully. It will be reviewed shortly.',
     newReport: 'New Report',
+    trackStatus: 'Track the status of your report',
+    statusTitle: 'Report Status',
+    statusLoading: 'Loading...',
+    statusNotFound: 'This report could not be found.',
+    labelState: 'Stage',
+    labelStatus: 'Status',
+    stateQueued: 'Queued, not saved yet',
+    stateStored: 'Saved',
+    stateAttachmentsPending: 'Saved, attachments are uploading',
+    stateDeadLettered: 'Could not be saved, our team is looking into it',
+    captchaVerifying: 'Running security check…',
+    captchaVerified: 'Verified',
+    captchaFailed: 'Verification failed.',
+    captchaRetry: 'Try again',
+    statusNew: 'New',
+    statusTriaged: 'Triaged',
+    statusInProgress: 'In progress',
+    statusResolved: 'Resolved',
+    statusWontFix: "Won't fix",
+    statusDuplicate: 'Duplicate',
     footerText: 'Powered by',
   },
   de: {
@@ -160,13 +208,6 @@ const translations: Record<Language, Translations> = {
     errorGeneric: 'Ein Fehler ist aufgetreten. Bitte versuchen Sie es erneut.',
     autoDetected: 'Automatisch erkannt',
     selectPlaceholder: 'Auswählen...',
-    catDesign: 'Design',
-    catFunctionality: 'Funktionalität',
-    catPerformance: 'Leistung',
-    catContent: 'Inhalt',
-    catMobile: 'Mobil',
-    catSecurity: 'Sicherheit',
-    catOther: 'Sonstiges',
     titlePlaceholderBug: 'Kurzer Titel für den Fehler',
     titlePlaceholderRequest: 'Kurzer Titel für Ihren Vorschlag',
     descPlaceholderBug:
@@ -178,14 +219,37 @@ const translations: Record<Language, Translations> = {
     errCategoryRequired: 'Bitte wählen Sie eine Kategorie',
     errDescRequired: 'Bitte geben Sie eine Beschreibung ein',
     errPageUrlDomain: 'URL muss zur ausgewählten Webseite gehören',
+    errFieldRequired: 'Dieses Feld ist erforderlich',
+    errNumber: 'Bitte eine Zahl eingeben',
+    errUrl: 'Bitte eine gültige URL eingeben',
+    optionYes: 'Ja',
+    optionNo: 'Nein',
     maxImages: 'Maximal 5 Bilder erlaubt',
     siteSelectPlaceholder: 'Webseite auswählen...',
-    typeBug: 'Fehlerbericht',
-    typeRequest: 'Vorschläge',
     successTitle: 'Vielen Dank!',
     successText:
       'Ihr Feedback wurde erfolgreich gesendet. Es wird in Kürze bearbeitet.',
     newReport: 'Neuer Bericht',
+    trackStatus: 'Status Ihres Berichts verfolgen',
+    statusTitle: 'Berichtsstatus',
+    statusLoading: 'Wird geladen...',
+    statusNotFound: 'Dieser Bericht wurde nicht gefunden.',
+    labelState: 'Phase',
+    labelStatus: 'Status',
+    stateQueued: 'In der Warteschlange, noch nicht gespeichert',
+    stateStored: 'Gespeichert',
+    stateAttachmentsPending: 'Gespeichert, Anhänge werden hochgeladen',
+    stateDeadLettered: 'Konnte nicht gespeichert werden, unser Team prüft das',
+    captchaVerifying: 'Sicherheitsprüfung läuft…',
+    captchaVerified: 'Verifiziert',
+    captchaFailed: 'Überprüfung fehlgeschlagen.',
+    captchaRetry: 'Erneut versuchen',
+    statusNew: 'Neu',
+    statusTriaged: 'Gesichtet',
+    statusInProgress: 'In Bearbeitung',
+    statusResolved: 'Gelöst',
+    statusWontFix: 'Wird nicht behoben',
+    statusDuplicate: 'Duplikat',
     footerText: 'Powered by',
   },
   ru: {
@@ -207,13 +271,6 @@ const translations: Record<Language, Translations> = {
     errorGeneric: 'Произошла ошибка. Попробуйте снова.',
     autoDetected: 'Определено автоматически',
     selectPlaceholder: 'Выбрать...',
-    catDesign: 'Дизайн',
-    catFunctionality: 'Функциональность',
-    catPerformance: 'Производительность',
-    catContent: 'Контент',
-    catMobile: 'Мобильный',
-    catSecurity: 'Безопасность',
-    catOther: 'Другое',
     titlePlaceholderBug: 'Короткий заголовок ошибки',
     titlePlaceholderRequest: 'Короткий заголовок предложения',
     descPlaceholderBug:
@@ -225,14 +282,37 @@ const translations: Record<Language, Translations> = {
     errCategoryRequired: 'Пожалуйста, выберите категорию',
     errDescRequired: 'Пожалуйста, введите описание',
     errPageUrlDomain: 'URL должен принадлежать выбранному сайту',
+    errFieldRequired: 'Это поле обязательно',
+    errNumber: 'Введите число',
+    errUrl: 'Введите корректный URL',
+    optionYes: 'Да',
+    optionNo: 'Нет',
     maxImages: 'Максимум 5 изображений',
     siteSelectPlaceholder: 'Выбрать сайт...',
-    typeBug: 'Ошибка',
-    typeRequest: 'Предложения',
     successTitle: 'Спасибо!',
     successText:
       'Ваш отзыв успешно отправлен. Он будет рассмотрен в ближайшее время.',
     newReport: 'Новый отчёт',
+    trackStatus: 'Отслеживать статус отчёта',
+    statusTitle: 'Статус отчёта',
+    statusLoading: 'Загрузка...',
+    statusNotFound: 'Отчёт не найден.',
+    labelState: 'Этап',
+    labelStatus: 'Статус',
+    stateQueued: 'В очереди, ещё не сохранён',
+    stateStored: 'Сохранён',
+    stateAttachmentsPending: 'Сохранён, вложения загружаются',
+    stateDeadLettered: 'Не удалось сохранить, наша команда разбирается',
+    captchaVerifying: 'Выполняется проверка безопасности…',
+    captchaVerified: 'Проверено',
+    captchaFailed: 'Проверка не удалась.',
+    captchaRetry: 'Повторить',
+    statusNew: 'Новый',
+    statusTriaged: 'Рассмотрен',
+    statusInProgress: 'В работе',
+    statusResolved: 'Решён',
+    statusWontFix: 'Не будет исправлен',
+    statusDuplicate: 'Дубликат',
     footerText: 'Powered by',
   },
   uk: {
@@ -254,13 +334,6 @@ const translations: Record<Language, Translations> = {
     errorGeneric: 'Сталася помилка. Будь ласка, спробуйте ще раз.',
     autoDetected: 'Визначено автоматично',
     selectPlaceholder: 'Обрати...',
-    catDesign: 'Дизайн',
-    catFunctionality: 'Функціональність',
-    catPerformance: 'Продуктивність',
-    catContent: 'Контент',
-    catMobile: 'Мобільний',
-    catSecurity: 'Безпека',
-    catOther: 'Інше',
     titlePlaceholderBug: 'Короткий заголовок помилки',
     titlePlaceholderRequest: 'Короткий заголовок пропозиції',
     descPlaceholderBug:
@@ -272,14 +345,37 @@ const translations: Record<Language, Translations> = {
     errCategoryRequired: 'Будь ласка, оберіть категорію',
     errDescRequired: 'Будь ласка, введіть опис',
     errPageUrlDomain: 'URL повинен належати обраному сайту',
+    errFieldRequired: "Це поле обов'язкове",
+    errNumber: 'Введіть число',
+    errUrl: 'Введіть коректний URL',
+    optionYes: 'Так',
+    optionNo: 'Ні',
     maxImages: 'Максимум 5 зображень',
     siteSelectPlaceholder: 'Обрати сайт...',
-    typeBug: 'Помилка',
-    typeRequest: 'Пропозиції',
     successTitle: 'Дякуємо!',
     successText:
       'Ваш відгук успішно відправлено. Він буде розглянутий найближчим часом.',
     newReport: 'Новий звіт',
+    trackStatus: 'Відстежувати статус звіту',
+    statusTitle: 'Статус звіту',
+    statusLoading: 'Завантаження...',
+    statusNotFound: 'Звіт не знайдено.',
+    labelState: 'Етап',
+    labelStatus: 'Статус',
+    stateQueued: 'У черзі, ще не збережено',
+    stateStored: 'Збережено',
+    stateAttachmentsPending: 'Збережено, вкладення завантажуються',
+    stateDeadLettered: 'Не вдалося зберегти, наша команда розбирається',
+    captchaVerifying: 'Виконується перевірка безпеки…',
+    captchaVerified: 'Перевірено',
+    captchaFailed: 'Перевірка не вдалася.',
+    captchaRetry: 'Спробувати ще',
+    statusNew: 'Новий',
+    statusTriaged: 'Розглянуто',
+    statusInProgress: 'В роботі',
+    statusResolved: 'Вирішено',
+    statusWontFix: 'Не буде виправлено',
+    statusDuplicate: 'Дублікат',
     footerText: 'Powered by',
   },
   es: {
@@ -301,13 +397,6 @@ const translations: Record<Language, Translations> = {
     errorGeneric: 'Ocurrió un error. Por favor, inténtelo de nuevo.',
     autoDetected: 'Detectado automáticamente',
     selectPlaceholder: 'Seleccionar...',
-    catDesign: 'Diseño',
-    catFunctionality: 'Funcionalidad',
-    catPerformance: 'Rendimiento',
-    catContent: 'Contenido',
-    catMobile: 'Móvil',
-    catSecurity: 'Seguridad',
-    catOther: 'Otro',
     titlePlaceholderBug: 'Título breve del error',
     titlePlaceholderRequest: 'Título breve de su sugerencia',
     descPlaceholderBug:
@@ -319,14 +408,37 @@ const translations: Record<Language, Translations> = {
     errCategoryRequired: 'Por favor, seleccione una categoría',
     errDescRequired: 'Por favor, ingrese una descripción',
     errPageUrlDomain: 'La URL debe pertenecer al sitio seleccionado',
+    errFieldRequired: 'Este campo es obligatorio',
+    errNumber: 'Introduce un número',
+    errUrl: 'Introduce una URL válida',
+    optionYes: 'Sí',
+    optionNo: 'No',
     maxImages: 'Máximo 5 imágenes permitidas',
     siteSelectPlaceholder: 'Seleccionar sitio...',
-    typeBug: 'Informe de error',
-    typeRequest: 'Sugerencias',
     successTitle: '¡Gracias!',
     successText:
       'Sus comentarios se han enviado correctamente. Se revisarán en breve.',
     newReport: 'Nuevo informe',
+    trackStatus: 'Seguir el estado de tu reporte',
+    statusTitle: 'Estado del reporte',
+    statusLoading: 'Cargando...',
+    statusNotFound: 'No se encontró este reporte.',
+    labelState: 'Etapa',
+    labelStatus: 'Estado',
+    stateQueued: 'En cola, aún no guardado',
+    stateStored: 'Guardado',
+    stateAttachmentsPending: 'Guardado, los adjuntos se están subiendo',
+    stateDeadLettered: 'No se pudo guardar, nuestro equipo lo está revisando',
+    captchaVerifying: 'Realizando verificación de seguridad…',
+    captchaVerified: 'Verificado',
+    captchaFailed: 'La verificación falló.',
+    captchaRetry: 'Reintentar',
+    statusNew: 'Nuevo',
+    statusTriaged: 'Revisado',
+    statusInProgress: 'En progreso',
+    statusResolved: 'Resuelto',
+    statusWontFix: 'No se corregirá',
+    statusDuplicate: 'Duplicado',
     footerText: 'Powered by',
   },
 };
@@ -352,6 +464,12 @@ export function detectLanguage(): Language {
   return 'tr';
 }
 
+// entryLabel returns the label of a report type or category in a language,
+// falling back to English and then the key.
+export function entryLabel(entry: TaxonomyEntry, lang: Language): string {
+  return entry.labels?.[lang] || entry.labels?.en || entry.key;
+}
+
 export function t(lang: Language): Translations {
   return translations[lang];
 }
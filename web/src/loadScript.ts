@@ -0,0 +1,23 @@
+const loading = new Map<string, Promise<void>>();
+
+// Adds a third-party script to the page once and resolves when it has
+// loaded. A script that fails to load is tried again on the next call.
+export function loadScript(src: string): Promise<void> {
+  let p = loading.get(src);
+  if (!p) {
+    p = new Promise<void>((resolve, reject) => {
+      const script = document.createElement('script');
+      script.src = src;
+      script.async = true;
+      script.onload = () => resolve();
+      script.onerror = () => {
+        loading.delete(src);
+        script.remove();
+        reject(new Error(`Failed to load ${src}`));
+      };
+      document.head.appendChild(script);
+    });
+    loading.set(src, p);
+  }
+  return p;
+}
@@ -0,0 +1,100 @@
+// Proof-of-work solver for the pow captcha: finds a solution such that
+// SHA-256("{challenge}:{solution}") starts with `difficulty` zero bits.
+// SubtleCrypto hashes asynchronously, one promise per hash, which is far too
+// slow for a search of about 2^difficulty hashes, so SHA-256 is done here.
+
+const K = new Uint32Array([
+  0x428a2f98, 0x71374491, 0xb5c0fbcf, 0xe9b5dba5, 0x3956c25b, 0x59f111f1, 0x923f82a4, 0xab1c5ed5,
+  0xd807aa98, 0x12835b01, 0x243185be, 0x550c7dc3, 0x72be5d74, 0x80deb1fe, 0x9bdc06a7, 0xc19bf174,
+  0xe49b69c1, 0xefbe4786, 0x0fc19dc6, 0x240ca1cc, 0x2de92c6f, 0x4a7484aa, 0x5cb0a9dc, 0x76f988da,
+  0x983e5152, 0xa831c66d, 0xb00327c8, 0xbf597fc7, 0xc6e00bf3, 0xd5a79147, 0x06ca6351, 0x14292967,
+  0x27b70a85, 0x2e1b2138, 0x4d2c6dfc, 0x53380d13, 0x650a7354, 0x766a0abb, 0x81c2c92e, 0x92722c85,
+  0xa2bfe8a1, 0xa81a664b, 0xc24b8b70, 0xc76c51a3, 0xd192e819, 0xd6990624, 0xf40e3585, 0x106aa070,
+  0x19a4c116, 0x1e376c08, 0x2748774c, 0x34b0bcb5, 0x391c0cb3, 0x4ed8aa4a, 0x5b9cca4f, 0x682e6ff3,
+  0x748f82ee, 0x78a5636f, 0x84c87814, 0x8cc70208, 0x90befffa, 0xa4506ceb, 0xbef9a3f7, 0xc67178f2,
+]);
+
+const IV = [0x6a09e667, 0xbb67ae85, 0x3c6ef372, 0xa54ff53a, 0x510e527f, 0x9b05688c, 0x1f83d9ab, 0x5be0cd19];
+
+const W = new Uint32Array(64);
+
+function rotr(x: number, n: number): number {
+  return (x >>> n) | (x << (32 - n));
+}
+
+// Pads a message of `length` bytes in place: 0x80, zeros, then the length
+// in bits. buf must be sized with paddedLength.
+function pad(buf: Uint8Array, length: number) {
+  buf.fill(0, length);
+  buf[length] = 0x80;
+  const view = new DataView(buf.buffer, buf.byteOffset, buf.byteLength);
+  view.setUint32(buf.length - 8, Math.floor(length / 0x20000000));
+  view.setUint32(buf.length - 4, length << 3);
+}
+
+function paddedLength(length: number): number {
+  return (((length + 8) >> 6) + 1) << 6;
+}
+
+// Hashes a padded message into state (eight 32-bit words).
+function compress(state: Uint32Array, buf: Uint8Array) {
+  state.set(IV);
+  const view = new DataView(buf.buffer, buf.byteOffset, buf.byteLength);
+  for (let off = 0; off < buf.length; off += 64) {
+    for (let i = 0; i < 16; i++) W[i] = view.getUint32(off + i * 4);
+    for (let i = 16; i < 64; i++) {
+      const s0 = rotr(W[i - 15], 7) ^ rotr(W[i - 15], 18) ^ (W[i - 15] >>> 3);
+      const s1 = rotr(W[i - 2], 17) ^ rotr(W[i - 2], 19) ^ (W[i - 2] >>> 10);
+      W[i] = W[i - 16] + s0 + W[i - 7] + s1;
+    }
+
+    let a = state[0], b = state[1], c = state[2], d = state[3];
+    let e = state[4], f = state[5], g = state[6], h = state[7];
+    for (let i = 0; i < 64; i++) {
+      const t1 = (h + (rotr(e, 6) ^ rotr(e, 11) ^ rotr(e, 25)) + ((e & f) ^ (~e & g)) + K[i] + W[i]) | 0;
+      const t2 = ((rotr(a, 2) ^ rotr(a, 13) ^ rotr(a, 22)) + ((a & b) ^ (a & c) ^ (b & c))) | 0;
+      h = g;
+      g = f;
+      f = e;
+      e = (d + t1) | 0;
+      d = c;
+      c = b;
+      b = a;
+      a = (t1 + t2) | 0;
+    }
+    state[0] += a; state[1] += b; state[2] += c; state[3] += d;
+    state[4] += e; state[5] += f; state[6] += g; state[7] += h;
+  }
+}
+
+// Returns the first solution, counting up in base 36, that meets the
+// difficulty. Takes about 2^difficulty hashes; run it in a worker. The
+// message buffer is reused, so the search allocates nothing per hash.
+export function solve(challenge: string, difficulty: number): string {
+  const prefix = new TextEncoder().encode(`${challenge}:`);
+  const state = new Uint32Array(8);
+  let buf = new Uint8Array(0);
+  let digits = 0;
+  for (let n = 0; ; n++) {
+    const solution = n.toString(36);
+    if (solution.length !== digits) {
+      digits = solution.length;
+      buf = new Uint8Array(paddedLength(prefix.length + digits));
+      buf.set(prefix);
+      pad(buf, prefix.length + digits);
+    }
+    for (let i = 0; i < digits; i++) buf[prefix.length + i] = solution.charCodeAt(i);
+    compress(state, buf);
+    if (stateZeroBits(state) >= difficulty) return solution;
+  }
+}
+
+// Counts the zero bits at the start of a hash state.
+function stateZeroBits(state: Uint32Array): number {
+  let n = 0;
+  for (const word of state) {
+    if (word !== 0) return n + Math.clz32(word);
+    n += 32;
+  }
+  return n;
+}
@@ -0,0 +1,8 @@
+import { solve } from './pow';
+
+// Solves proof-of-work challenges off the main thread.
+const ctx = self as unknown as Worker;
+
+ctx.onmessage = (e: MessageEvent<{ challenge: string; difficulty: number }>) => {
+  ctx.postMessage(solve(e.data.challenge, e.data.difficulty));
+};
@@ -1,23 +1,26 @@
+export type CaptchaProvider = 'turnstile' | 'hcaptcha' | 'recaptcha' | 'pow';
+
+// Captcha of one site; siteKey is empty for pow.
+export interface CaptchaConfig {
+  provider: CaptchaProvider;
+  siteKey: string;
+}
+
 export interface AppConfig {
-  turnstileSiteKey: string;
+  captcha: Record<string, CaptchaConfig>; // sites without an entry need no captcha
   sites: string[];
   portalDomain: string;
 }
 
-export type ReportType = 'bug' | 'request';
+// Report types and categories are keys of the taxonomy, which the admin
+// API can extend; the ones a site accepts come with GET /v1/sites.
+export type ReportType = string;
 
-export type Category =
-  | 'design'
-  | 'functionality'
-  | 'performance'
-  | 'content'
-  | 'mobile'
-  | 'security'
-  | 'other';
+export type Category = string;
 
 export type Language = 'tr' | 'en' | 'de' | 'ru' | 'uk' | 'es';
 
-export type AppView = 'form' | 'success';
+export type AppView = 'form' | 'success' | 'status';
 
 export interface ReportFormData {
   siteId: string;
@@ -29,6 +32,40 @@ export interface ReportFormData {
   fullName: string;
   phone: string;
   email: string;
+  customFields: Record<string, string>; // by field name, as typed
+}
+
+export type CustomFieldType = 'string' | 'number' | 'enum' | 'bool' | 'url';
+
+// An extra report field a site declares, see GET /v1/sites.
+export interface CustomField {
+  name: string;
+  label?: string; // defaults to the name
+  type: CustomFieldType;
+  required?: boolean;
+  max_length?: number; // string and url only
+  options?: string[]; // enum only
+}
+
+export interface TaxonomyEntry {
+  key: string;
+  labels?: Record<string, string>; // by language
+  position: number;
+  active: boolean;
+}
+
+// One entry of the "details" of GET /v1/sites.
+export interface SiteDetails {
+  domain: string;
+  name: string;
+  report_types: TaxonomyEntry[];
+  categories: TaxonomyEntry[];
+  custom_fields?: CustomField[];
+}
+
+export interface SitesResponse {
+  sites: string[];
+  details: SiteDetails[];
 }
 
 export interface ReportResponse {
@@ -36,6 +73,29 @@ export interface ReportResponse {
   queued: boolean;
 }
 
+export type ReportState = 'queued' | 'stored' | 'attachments_pending' | 'dead_lettered' | 'quarantined';
+
+export type TriageStatus =
+  | 'new'
+  | 'triaged'
+  | 'in_progress'
+  | 'resolved'
+  | 'wont_fix'
+  | 'duplicate';
+
+export interface ReportStatusResponse {
+  event_id: string;
+  state: ReportState;
+  status?: TriageStatus;
+}
+
+export interface CaptchaChallenge {
+  challenge: string;
+  algorithm: 'sha256';
+  difficulty: number;
+  expires_at: string;
+}
+
 export interface ErrorResponse {
   error: string;
   code: string;